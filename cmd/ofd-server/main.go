@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "监听地址")
+	maxSize := flag.Int64("max-size", 32<<20, "OFD 文件的最大字节数")
+	timeout := flag.Duration("timeout", time.Minute, "单个请求的转换超时时间 (包括排队等待)")
+	concurrency := flag.Int("concurrency", runtime.NumCPU(), "同时进行的转换数")
+	maxDPI := flag.Float64("max-dpi", 600, "图像转换允许的最大分辨率")
+	maxThumbnail := flag.Int("max-thumbnail", 2048, "缩略图允许的最大边长")
+	maxPixels := flag.Float64("max-pixels", 5e7, "图像转换允许的最大像素数")
+	queue := flag.Int("queue", 16, "等待转换的最大请求数，超出时返回 503")
+	flag.Parse()
+	if *concurrency < 1 || *maxSize < 1 || *timeout <= 0 || *maxDPI <= 0 || *maxThumbnail < 1 || *maxPixels <= 0 || *queue < 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	srv := &http.Server{
+		Addr: *addr,
+		Handler: newServer(config{
+			maxSize:      *maxSize,
+			timeout:      *timeout,
+			concurrency:  *concurrency,
+			maxDPI:       *maxDPI,
+			maxThumbnail: *maxThumbnail,
+			maxPixels:    *maxPixels,
+			queue:        *queue,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("OFD 转换服务已启动", "addr", *addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		slog.Error("服务异常退出", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+	// 等待进行中的请求完成
+	shutdown, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdown); err != nil {
+		slog.Error("关闭服务失败", "error", err)
+	}
+}
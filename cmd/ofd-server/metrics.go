@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metrics Prometheus 文本格式的服务指标
+type metrics struct {
+	mu        sync.Mutex
+	requests  map[[2]string]uint64 // 键为 接口, 状态码
+	durations map[string]*duration
+	rejected  map[string]uint64
+	inFlight  atomic.Int64
+}
+
+type duration struct {
+	sum   float64
+	count uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:  make(map[[2]string]uint64),
+		durations: make(map[string]*duration),
+		rejected:  make(map[string]uint64),
+	}
+}
+
+// observe 记录一个请求的状态码及耗时
+func (m *metrics) observe(handler string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[2]string{handler, fmt.Sprint(code)}]++
+	if m.durations[handler] == nil {
+		m.durations[handler] = &duration{}
+	}
+	m.durations[handler].sum += d.Seconds()
+	m.durations[handler].count++
+}
+
+// reject 记录被拒绝的请求，reason 为 busy、queue_full、timeout 或 too_large
+func (m *metrics) reject(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rejected[reason]++
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	var sb strings.Builder
+	sb.WriteString("# HELP ofd_requests_total Number of conversion requests by handler and status code.\n")
+	sb.WriteString("# TYPE ofd_requests_total counter\n")
+	for _, key := range sortedKeys(m.requests, func(k [2]string) string { return k[0] + " " + k[1] }) {
+		fmt.Fprintf(&sb, "ofd_requests_total{handler=%q,code=%q} %d\n", key[0], key[1], m.requests[key])
+	}
+	sb.WriteString("# HELP ofd_request_duration_seconds Time spent serving conversion requests.\n")
+	sb.WriteString("# TYPE ofd_request_duration_seconds summary\n")
+	for _, key := range sortedKeys(m.durations, func(k string) string { return k }) {
+		d := m.durations[key]
+		fmt.Fprintf(&sb, "ofd_request_duration_seconds_sum{handler=%q} %g\n", key, d.sum)
+		fmt.Fprintf(&sb, "ofd_request_duration_seconds_count{handler=%q} %d\n", key, d.count)
+	}
+	sb.WriteString("# HELP ofd_requests_rejected_total Number of requests rejected because the server was busy, the conversion timed out or the file was too large.\n")
+	sb.WriteString("# TYPE ofd_requests_rejected_total counter\n")
+	for _, key := range sortedKeys(m.rejected, func(k string) string { return k }) {
+		fmt.Fprintf(&sb, "ofd_requests_rejected_total{reason=%q} %d\n", key, m.rejected[key])
+	}
+	m.mu.Unlock()
+	sb.WriteString("# HELP ofd_conversions_in_flight Number of conversions currently running.\n")
+	sb.WriteString("# TYPE ofd_conversions_in_flight gauge\n")
+	fmt.Fprintf(&sb, "ofd_conversions_in_flight %d\n", m.inFlight.Load())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(sb.String()))
+}
+
+// sortedKeys 按 name 排序的键，使输出稳定
+func sortedKeys[K comparable, V any](m map[K]V, name func(K) string) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return name(keys[i]) < name(keys[j]) })
+	return keys
+}
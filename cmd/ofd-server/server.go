@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/zc310/ofd/pkg/converter"
+)
+
+// config 服务配置
+type config struct {
+	// maxSize 请求中 OFD 文件的最大字节数
+	maxSize int64
+	// timeout 单个请求的转换超时时间
+	timeout time.Duration
+	// concurrency 同时进行的转换数，超出时请求排队等待，直到超时
+	concurrency int
+	// maxDPI 图像转换允许的最大分辨率
+	maxDPI float64
+	// maxThumbnail 缩略图允许的最大边长
+	maxThumbnail int
+	// maxPixels 图像转换允许的最大像素数 (按页面大小及分辨率计算，缩略图也先按该分辨率绘制)
+	maxPixels float64
+	// queue 等待转换名额的最大请求数，超出时直接返回 503，限制缓存请求数据占用的内存
+	queue int
+}
+
+// server OFD 转换服务
+type server struct {
+	cfg   config
+	slots chan struct{}
+	// pending 读取中、排队及进行中的请求，容量为 concurrency + queue
+	pending chan struct{}
+	metrics *metrics
+	mux     *http.ServeMux
+}
+
+// httpError 带状态码的错误
+type httpError struct {
+	code int
+	err  error
+}
+
+func (e *httpError) Error() string { return e.err.Error() }
+
+func (e *httpError) Unwrap() error { return e.err }
+
+func badRequest(format string, args ...interface{}) error {
+	return &httpError{code: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+// handlerFunc 转换处理函数，结果写入 w，出错时不会发送给客户端
+type handlerFunc func(w *response, r *http.Request, data []byte) error
+
+// response 缓存转换结果，成功后才发送，失败时可以返回错误状态码
+type response struct {
+	bytes.Buffer
+	contentType string
+}
+
+func newServer(cfg config) *server {
+	s := &server{
+		cfg:     cfg,
+		slots:   make(chan struct{}, cfg.concurrency),
+		pending: make(chan struct{}, cfg.concurrency+cfg.queue),
+		metrics: newMetrics(),
+		mux:     http.NewServeMux(),
+	}
+	s.handle("POST /v1/pdf", "pdf", s.pdf)
+	s.handle("POST /v1/image", "image", s.image)
+	s.handle("POST /v1/text", "text", s.text)
+	s.handle("POST /v1/info", "info", s.info)
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	s.mux.Handle("GET /metrics", s.metrics)
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handle 注册转换接口：限制请求大小、排队等待空闲的转换并在超时后返回 504
+func (s *server) handle(pattern, name string, h handlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		code := s.serve(w, r, h)
+		s.metrics.observe(name, code, time.Since(start))
+	})
+}
+
+func (s *server) serve(w http.ResponseWriter, r *http.Request, h handlerFunc) int {
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.timeout)
+	defer cancel()
+
+	// 先占用排队名额再读取请求，同时缓存的请求数据不超过 concurrency + queue 个
+	select {
+	case s.pending <- struct{}{}:
+	default:
+		s.metrics.reject("queue_full")
+		return s.fail(w, r, &httpError{code: http.StatusServiceUnavailable, err: errors.New("服务繁忙，排队请求过多")})
+	}
+	data, err := s.readInput(w, r)
+	if err != nil {
+		<-s.pending
+		return s.fail(w, r, err)
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		<-s.pending
+		s.metrics.reject("busy")
+		return s.fail(w, r, &httpError{code: http.StatusServiceUnavailable, err: errors.New("服务繁忙")})
+	}
+	s.metrics.inFlight.Add(1)
+
+	res := new(response)
+	done := make(chan error, 1)
+	go func() {
+		// 转换无法中途取消，超时后仍占用转换及排队名额直到完成，使同时进行的转换数不超过限制
+		defer func() {
+			s.metrics.inFlight.Add(-1)
+			<-s.slots
+			<-s.pending
+		}()
+		// 转换协程中的 panic 不会被 net/http 恢复，返回 500 以免整个服务退出
+		defer func() {
+			if p := recover(); p != nil {
+				slog.Error("转换异常", "path", r.URL.Path, "panic", p, "stack", string(debug.Stack()))
+				done <- &httpError{code: http.StatusInternalServerError, err: errors.New("转换异常")}
+			}
+		}()
+		done <- h(res, r, data)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		s.metrics.reject("timeout")
+		return s.fail(w, r, &httpError{code: http.StatusGatewayTimeout, err: errors.New("转换超时")})
+	}
+	if err != nil {
+		return s.fail(w, r, err)
+	}
+	w.Header().Set("Content-Type", res.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(res.Len()))
+	_, _ = res.WriteTo(w)
+	return http.StatusOK
+}
+
+// readInput 读取请求中的 OFD 文件，可以是请求体或 multipart 表单中的 file 字段
+func (s *server) readInput(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.maxSize)
+	var data []byte
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		var file io.ReadCloser
+		if file, _, err = r.FormFile("file"); err == nil {
+			data, err = io.ReadAll(file)
+			_ = file.Close()
+		}
+	} else {
+		data, err = io.ReadAll(r.Body)
+	}
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		s.metrics.reject("too_large")
+		return nil, &httpError{code: http.StatusRequestEntityTooLarge, err: fmt.Errorf("文件超过 %d 字节", s.cfg.maxSize)}
+	}
+	if err != nil {
+		return nil, badRequest("读取请求失败: %v", err)
+	}
+	if len(data) == 0 {
+		return nil, badRequest("没有 OFD 文件")
+	}
+	return data, nil
+}
+
+// fail 返回错误，无法解析或转换的 OFD 文件返回 422
+func (s *server) fail(w http.ResponseWriter, r *http.Request, err error) int {
+	code := http.StatusUnprocessableEntity
+	var he *httpError
+	switch {
+	case errors.As(err, &he):
+		code = he.code
+	case errors.Is(err, converter.ErrPageOutOfRange):
+		code = http.StatusBadRequest
+	}
+	if code >= http.StatusInternalServerError {
+		slog.Warn("转换失败", "path", r.URL.Path, "code", code, "error", err)
+	}
+	http.Error(w, err.Error(), code)
+	return code
+}
+
+// options 返回查询参数 pages 对应的转换选项
+func options(r *http.Request) ([]converter.Option, error) {
+	var opts []converter.Option
+	if s := r.URL.Query().Get("pages"); s != "" {
+		pages, err := converter.ParsePages(s)
+		if err != nil {
+			return nil, badRequest("%v", err)
+		}
+		opts = append(opts, converter.Pages(pages...))
+	}
+	return opts, nil
+}
+
+// pdf POST /v1/pdf?pages=1,3-5
+func (s *server) pdf(w *response, r *http.Request, data []byte) error {
+	opts, err := options(r)
+	if err != nil {
+		return err
+	}
+	w.contentType = "application/pdf"
+	return converter.PDF(data, w, opts...)
+}
+
+// image POST /v1/image?page=1&dpi=150&format=png&thumbnail=256，返回一个页面的图像
+func (s *server) image(w *response, r *http.Request, data []byte) error {
+	q := r.URL.Query()
+	page, dpi, thumbnail := 1, 150.0, 0
+	var err error
+	if v := q.Get("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return badRequest("page 参数错误: %s", v)
+		}
+	}
+	if v := q.Get("dpi"); v != "" {
+		if dpi, err = strconv.ParseFloat(v, 64); err != nil || dpi <= 0 || dpi > s.cfg.maxDPI {
+			return badRequest("dpi 参数错误: %s，应为 0 到 %g 之间", v, s.cfg.maxDPI)
+		}
+	}
+	if v := q.Get("thumbnail"); v != "" {
+		if thumbnail, err = strconv.Atoi(v); err != nil || thumbnail < 0 || thumbnail > s.cfg.maxThumbnail {
+			return badRequest("thumbnail 参数错误: %s，应为 0 到 %d 之间", v, s.cfg.maxThumbnail)
+		}
+	}
+
+	var encode func(io.Writer, image.Image) error
+	opts := []converter.Option{converter.Page(page), converter.DPI(dpi)}
+	switch format := q.Get("format"); format {
+	case "", "png":
+		w.contentType = "image/png"
+		encode = png.Encode
+	case "jpeg", "jpg":
+		w.contentType = "image/jpeg"
+		encode = func(w io.Writer, img image.Image) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+		}
+		// JPEG 没有透明通道
+		opts = append(opts, converter.BgColor(color.White))
+	default:
+		return badRequest("不支持的图像格式: %s", format)
+	}
+	// 绘制前检查像素数，避免大页面或高分辨率占用过多内存
+	info, err := converter.Info(data)
+	if err != nil {
+		return err
+	}
+	if page <= len(info.Pages) {
+		p := info.Pages[page-1]
+		if pixels := p.Width * p.Height * (dpi / 25.4) * (dpi / 25.4); pixels > s.cfg.maxPixels {
+			return badRequest("页面图像过大: %.0f 像素，超过 %.0f，请降低 dpi", pixels, s.cfg.maxPixels)
+		}
+	}
+	if thumbnail > 0 {
+		opts = append(opts, converter.Thumbnail(thumbnail))
+	}
+	opts = append(opts, converter.ImageWriter(func(_ int, img image.Image) error {
+		return encode(w, img)
+	}))
+	return converter.Image(data, opts...)
+}
+
+// text POST /v1/text?pages=1,3-5，返回各页面文字的 JSON
+func (s *server) text(w *response, r *http.Request, data []byte) error {
+	opts, err := options(r)
+	if err != nil {
+		return err
+	}
+	pages, err := converter.Text(data, opts...)
+	if err != nil {
+		return err
+	}
+	w.contentType = "application/json; charset=utf-8"
+	return json.NewEncoder(w).Encode(pages)
+}
+
+// info POST /v1/info，返回文档元数据的 JSON
+func (s *server) info(w *response, r *http.Request, data []byte) error {
+	info, err := converter.Info(data)
+	if err != nil {
+		return err
+	}
+	w.contentType = "application/json; charset=utf-8"
+	return json.NewEncoder(w).Encode(info)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zc310/ofd/pkg/converter"
+)
+
+func testServer(t *testing.T, cfg config) (*server, *httptest.Server) {
+	if cfg.maxSize == 0 {
+		cfg.maxSize = 1 << 20
+	}
+	if cfg.timeout == 0 {
+		cfg.timeout = time.Minute
+	}
+	if cfg.concurrency == 0 {
+		cfg.concurrency = 2
+	}
+	if cfg.maxDPI == 0 {
+		cfg.maxDPI = 300
+	}
+	if cfg.maxThumbnail == 0 {
+		cfg.maxThumbnail = 1024
+	}
+	if cfg.maxPixels == 0 {
+		cfg.maxPixels = 1e8
+	}
+	s := newServer(cfg)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	data, err := os.ReadFile("../../test/testdata/" + name)
+	assert.Nil(t, err)
+	return data
+}
+
+func post(t *testing.T, url, contentType string, body []byte) (*http.Response, []byte) {
+	resp, err := http.Post(url, contentType, bytes.NewReader(body))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp, data
+}
+
+func get(t *testing.T, url string) (*http.Response, []byte) {
+	resp, err := http.Get(url)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp, data
+}
+
+func TestServer(t *testing.T) {
+	_, ts := testServer(t, config{})
+	ofd := readTestdata(t, "999.ofd")
+
+	resp, data := post(t, ts.URL+"/v1/pdf?pages=1-2", "application/ofd", ofd)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF")))
+
+	resp, data = post(t, ts.URL+"/v1/image?page=2&dpi=50&thumbnail=64", "application/ofd", ofd)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(data))
+	assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+	img, err := png.Decode(bytes.NewReader(data))
+	if assert.Nil(t, err) {
+		b := img.Bounds()
+		assert.Equal(t, 64, b.Dy())
+		assert.Less(t, b.Dx(), 64)
+	}
+
+	resp, data = post(t, ts.URL+"/v1/text?pages=1", "application/ofd", ofd)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(data))
+	var pages []converter.PageText
+	assert.Nil(t, json.Unmarshal(data, &pages))
+	if assert.Len(t, pages, 1) {
+		assert.Contains(t, pages[0].Text, "发票代码：050001700111")
+	}
+
+	// multipart 表单上传
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "999.ofd")
+	_, _ = fw.Write(ofd)
+	_ = mw.Close()
+	resp, data = post(t, ts.URL+"/v1/info", mw.FormDataContentType(), body.Bytes())
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(data))
+	var info converter.DocumentInfo
+	assert.Nil(t, json.Unmarshal(data, &info))
+	assert.Equal(t, "050001700111_12235358", info.DocID)
+	assert.Len(t, info.Pages, 5)
+
+	resp, _ = get(t, ts.URL+"/healthz")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_Errors(t *testing.T) {
+	// A4 页面在 100 dpi 时约 97 万像素
+	_, ts := testServer(t, config{maxSize: 16 << 10, maxPixels: 1e6})
+	ofd := readTestdata(t, "helloworld.ofd")
+	tests := []struct {
+		name, path string
+		body       []byte
+		code       int
+	}{
+		{"page out of range", "/v1/image?page=9", ofd, http.StatusBadRequest},
+		{"bad dpi", "/v1/image?dpi=1000", ofd, http.StatusBadRequest},
+		{"bad format", "/v1/image?format=gif", ofd, http.StatusBadRequest},
+		{"large thumbnail", "/v1/image?thumbnail=1000000", ofd, http.StatusBadRequest},
+		{"too many pixels", "/v1/image?dpi=150&thumbnail=64", ofd, http.StatusBadRequest},
+		{"pixels within limit", "/v1/image?dpi=100", ofd, http.StatusOK},
+		{"bad pages", "/v1/pdf?pages=x", ofd, http.StatusBadRequest},
+		{"empty", "/v1/pdf", nil, http.StatusBadRequest},
+		{"not ofd", "/v1/pdf", []byte("not a zip"), http.StatusUnprocessableEntity},
+		{"too large", "/v1/pdf", readTestdata(t, "999.ofd"), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		resp, data := post(t, ts.URL+tt.path, "application/ofd", tt.body)
+		assert.Equal(t, tt.code, resp.StatusCode, "%s: %s", tt.name, data)
+	}
+
+	resp, _ := get(t, ts.URL+"/v1/pdf")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestServer_Limits(t *testing.T) {
+	s, ts := testServer(t, config{concurrency: 1, queue: 1, timeout: 100 * time.Millisecond})
+	release := make(chan struct{})
+	s.handle("POST /slow", "slow", func(w *response, r *http.Request, data []byte) error {
+		<-release
+		return nil
+	})
+
+	// 转换超时
+	resp, _ := post(t, ts.URL+"/slow", "application/ofd", []byte("ofd"))
+	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	// 超时的转换仍占用唯一的转换名额
+	resp, _ = post(t, ts.URL+"/v1/info", "application/ofd", readTestdata(t, "helloworld.ofd"))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for s.metrics.inFlight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, _ = post(t, ts.URL+"/v1/info", "application/ofd", readTestdata(t, "helloworld.ofd"))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, data := get(t, ts.URL+"/metrics")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	for _, want := range []string{
+		`ofd_requests_total{handler="slow",code="504"} 1`,
+		`ofd_requests_total{handler="info",code="503"} 1`,
+		`ofd_requests_total{handler="info",code="200"} 1`,
+		`ofd_requests_rejected_total{reason="busy"} 1`,
+		`ofd_requests_rejected_total{reason="timeout"} 1`,
+		`ofd_request_duration_seconds_count{handler="info"} 2`,
+		"ofd_conversions_in_flight 0",
+	} {
+		assert.Contains(t, string(data), want)
+	}
+}
+
+func TestServer_Queue(t *testing.T) {
+	s, ts := testServer(t, config{concurrency: 1})
+	started, release := make(chan struct{}), make(chan struct{})
+	s.handle("POST /slow", "slow", func(w *response, r *http.Request, data []byte) error {
+		close(started)
+		<-release
+		return nil
+	})
+	done := make(chan int)
+	go func() {
+		resp, err := http.Post(ts.URL+"/slow", "application/ofd", strings.NewReader("ofd"))
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	<-started
+
+	// 没有排队名额时不读取请求，直接返回 503
+	resp, _ := post(t, ts.URL+"/v1/info", "application/ofd", readTestdata(t, "helloworld.ofd"))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	s.metrics.mu.Lock()
+	n := s.metrics.rejected["queue_full"]
+	s.metrics.mu.Unlock()
+	assert.Equal(t, uint64(1), n)
+}
+
+func TestServer_Panic(t *testing.T) {
+	s, ts := testServer(t, config{concurrency: 1})
+	s.handle("POST /panic", "panic", func(w *response, r *http.Request, data []byte) error {
+		var m map[string]int
+		m["x"]++
+		return nil
+	})
+	resp, _ := post(t, ts.URL+"/panic", "application/ofd", []byte("ofd"))
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	// panic 后释放转换名额，服务继续可用
+	deadline := time.Now().Add(time.Second)
+	for s.metrics.inFlight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, _ = post(t, ts.URL+"/v1/info", "application/ofd", readTestdata(t, "helloworld.ofd"))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
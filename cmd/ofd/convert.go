@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nao1215/imaging"
+	"github.com/zc310/ofd/pkg/converter"
+)
+
+// runPDF ofd to-pdf [-o output.pdf] [-pages 1,3-5] input.ofd
+func runPDF(args []string) error {
+	f := newFlags("to-pdf").withStrict().withPages()
+	output := f.String("o", "", "输出文件，- 为标准输出，默认为与输入同名的 .pdf 文件")
+	input, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	opts, err := f.options()
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = outputName(input, ".pdf")
+	}
+	if *output == "-" {
+		return converter.PDF(input, os.Stdout, opts...)
+	}
+
+	w, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err = converter.PDF(input, w, opts...); err != nil {
+		_ = w.Close()
+		_ = os.Remove(*output)
+		return err
+	}
+	return w.Close()
+}
+
+// runImage ofd to-png [-o dir] [-dpi 150] [-format png|jpeg] [-thumbnail 256] [-pages 1,3-5] input.ofd
+func runImage(args []string) error {
+	f := newFlags("to-png").withStrict().withPages()
+	dir := f.String("o", "", "输出目录，默认为输入文件所在目录，文件名为 <输入文件名>-<页码>.<格式>")
+	dpi := f.Float64("dpi", 150, "分辨率")
+	format := f.String("format", "png", "图像格式: png, jpeg")
+	thumbnail := f.Int("thumbnail", 0, "缩略图的最大边长 (像素)，0 为不缩放")
+	input, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	opts, err := f.options()
+	if err != nil {
+		return err
+	}
+
+	ext := ".png"
+	switch strings.ToLower(*format) {
+	case "png":
+		opts = append(opts, converter.PNG())
+	case "jpeg", "jpg":
+		ext = ".jpg"
+		// JPEG 没有透明通道
+		opts = append(opts, converter.JPG(), converter.BgColor(color.White))
+	default:
+		return fmt.Errorf("%w: 不支持的图像格式 %s", errUsage, *format)
+	}
+	if *dpi <= 0 {
+		return fmt.Errorf("%w: dpi 必须大于 0", errUsage)
+	}
+	if *dir == "" {
+		*dir = filepath.Dir(input)
+	} else if err = os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	opts = append(opts, converter.DPI(*dpi))
+	name := func(page int) string {
+		return filepath.Join(*dir, fmt.Sprintf("%s-%d%s", base, page, ext))
+	}
+	if *thumbnail > 0 {
+		// 缩略图需要先缩放再编码
+		opts = append(opts, converter.Thumbnail(*thumbnail), converter.ImageWriter(func(page int, img image.Image) error {
+			file := name(page)
+			fmt.Println(file)
+			return imaging.Save(img, file)
+		}))
+	} else {
+		opts = append(opts, converter.Writer(func(page int) (io.WriteCloser, error) {
+			file := name(page)
+			fmt.Println(file)
+			return os.Create(file)
+		}))
+	}
+	return converter.Image(input, opts...)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zc310/ofd/pkg/converter"
+	"github.com/zc310/ofd/pkg/editor"
+)
+
+// runMerge ofd merge -o output.ofd input1.ofd input2.ofd ...
+func runMerge(args []string) error {
+	f := newFlags("merge")
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "用法: ofd merge -o output.ofd <input.ofd>...\n\n按顺序合并各文档的页面，签名不保留\n\n选项:")
+		f.PrintDefaults()
+	}
+	output := f.String("o", "", "输出文件")
+	if err := f.parseFlags(args); err != nil {
+		return err
+	}
+	if *output == "" || f.NArg() == 0 {
+		f.Usage()
+		return fmt.Errorf("%w: 需要输出文件及至少一个输入文件", errUsage)
+	}
+
+	inputs := f.Args()
+	docs := make([]*editor.Document, 0, len(inputs))
+	for _, input := range inputs {
+		doc, err := editor.Open(input)
+		if err != nil {
+			return fmt.Errorf("%s: %w", input, err)
+		}
+		docs = append(docs, doc)
+	}
+	doc, err := editor.Merge(docs...)
+	if err != nil {
+		return err
+	}
+	report(doc, inputs)
+	return save(doc, *output)
+}
+
+// runSplit ofd split [-pages 1,3-5] [-o output.ofd] input.ofd
+func runSplit(args []string) error {
+	f := newFlags("split").withPages()
+	output := f.String("o", "", "输出文件，所选页面保存为一个文件；默认每页保存为一个文件，文件名为 <输入文件名>-<页码>.ofd")
+	input, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	var pages []int
+	if f.pages != "" {
+		if pages, err = converter.ParsePages(f.pages); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+	}
+	doc, err := editor.Open(input)
+	if err != nil {
+		return err
+	}
+	if pages == nil {
+		for i := 1; i <= doc.NumPages(); i++ {
+			pages = append(pages, i)
+		}
+	}
+
+	// 指定输出文件时所选页面保存为一个文件，否则每页一个文件
+	groups := [][]int{pages}
+	if *output == "" {
+		groups = groups[:0]
+		for _, page := range pages {
+			groups = append(groups, []int{page})
+		}
+	}
+	parts := make([]*editor.Document, len(groups))
+	for i, group := range groups {
+		if parts[i], err = doc.Extract(group...); err != nil {
+			return err
+		}
+	}
+	report(parts[0], []string{input})
+	if *output != "" {
+		return save(parts[0], *output)
+	}
+	for i, part := range parts {
+		name := outputName(input, fmt.Sprintf("-%d.ofd", pages[i]))
+		if err = save(part, name); err != nil {
+			return err
+		}
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// report 输出读取时跳过的部件及不保留的签名
+func report(doc *editor.Document, inputs []string) {
+	for _, w := range doc.Warnings {
+		fmt.Fprintln(os.Stderr, "警告:", w)
+	}
+	for _, sig := range doc.Signatures {
+		fmt.Fprintf(os.Stderr, "不保留签名: %s 的签名 %s", inputs[sig.Source], sig.ID)
+		if sig.Provider != "" {
+			fmt.Fprintf(os.Stderr, " (%s)", sig.Provider)
+		}
+		if len(sig.Pages) > 0 {
+			pages := make([]string, len(sig.Pages))
+			for i, p := range sig.Pages {
+				pages[i] = strconv.Itoa(p)
+			}
+			fmt.Fprintf(os.Stderr, "，位于第 %s 页", strings.Join(pages, ", "))
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// save 保存文档，失败时删除不完整的输出文件
+func save(doc *editor.Document, output string) error {
+	if err := doc.SaveFile(output); err != nil {
+		_ = os.Remove(output)
+		return err
+	}
+	return nil
+}
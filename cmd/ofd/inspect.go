@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zc310/ofd/pkg/converter"
+	"github.com/zc310/ofd/pkg/validator"
+)
+
+// runInfo ofd info [-json] input.ofd
+func runInfo(args []string) error {
+	f := newFlags("info").withStrict().withJSON()
+	input, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	opts, err := f.options()
+	if err != nil {
+		return err
+	}
+	info, err := converter.Info(input, opts...)
+	if err != nil {
+		return err
+	}
+	if f.json {
+		return writeJSON(os.Stdout, info)
+	}
+
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Printf("%s: %s\n", name, value)
+		}
+	}
+	field("版本", info.Version)
+	field("类型", info.DocType)
+	field("文档标识", info.DocID)
+	field("标题", info.Title)
+	field("作者", info.Author)
+	field("主题", info.Subject)
+	field("摘要", info.Abstract)
+	field("创建者", info.Creator)
+	field("创建者版本", info.CreatorVersion)
+	field("分类", info.DocUsage)
+	field("创建日期", formatDate(info.CreationDate))
+	field("修改日期", formatDate(info.ModDate))
+	for _, keyword := range info.Keywords {
+		field("关键词", keyword)
+	}
+	for _, d := range info.CustomData {
+		field(d.Name, d.Value)
+	}
+	field("页数", fmt.Sprint(len(info.Pages)))
+	for _, page := range info.Pages {
+		field(fmt.Sprintf("第%d页", page.Page), fmt.Sprintf("%g × %g mm (ID %d)", page.Width, page.Height, page.ID))
+	}
+	field("签名", fmt.Sprint(info.Signatures))
+	field("附件", fmt.Sprint(info.Attachments))
+	for _, warning := range info.Warnings {
+		field("警告", warning)
+	}
+	return nil
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format(time.DateTime)
+}
+
+// runText ofd text [-json] [-pages 1,3-5] input.ofd
+func runText(args []string) error {
+	f := newFlags("text").withStrict().withJSON().withPages()
+	input, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	opts, err := f.options()
+	if err != nil {
+		return err
+	}
+	pages, err := converter.Text(input, opts...)
+	if err != nil {
+		return err
+	}
+	if f.json {
+		return writeJSON(os.Stdout, pages)
+	}
+	for i, page := range pages {
+		if i > 0 {
+			// 换页符分隔页面
+			fmt.Print("\f\n")
+		}
+		fmt.Println(page.Text)
+	}
+	return nil
+}
+
+// runValidate ofd validate [-json] input.ofd，有错误时退出码为 1
+func runValidate(args []string) error {
+	f := newFlags("validate").withJSON()
+	input, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	result, err := validator.Validate(input)
+	if err != nil {
+		return err
+	}
+	if f.json {
+		err = writeJSON(os.Stdout, result)
+	} else {
+		for _, finding := range result.Findings {
+			fmt.Println(finding)
+		}
+	}
+	if err == nil && !result.Valid() {
+		err = errFailed
+	}
+	return err
+}
+
+// runVerify ofd verify [-json] input.ofd，只校验签名引用文件的杂凑值，不验证签名值及证书。
+// 有引用文件被修改或签名没有引用文件时退出码为 1
+func runVerify(args []string) error {
+	f := newFlags("verify").withStrict().withJSON()
+	input, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	opts, err := f.options()
+	if err != nil {
+		return err
+	}
+	results, err := converter.Verify(input, opts...)
+	if err != nil {
+		return err
+	}
+	match := true
+	for _, r := range results {
+		match = match && r.DigestsMatch
+	}
+	if f.json {
+		err = writeJSON(os.Stdout, results)
+	} else {
+		if len(results) == 0 {
+			fmt.Println("没有签名")
+		}
+		for _, r := range results {
+			status := "杂凑值一致"
+			switch {
+			case len(r.References) == 0:
+				status = "没有引用文件"
+			case !r.DigestsMatch:
+				status = "杂凑值不一致"
+			}
+			fmt.Printf("签名 %d: %s (%s %s %s)\n", r.ID, status, r.Provider, r.Method, r.DateTime)
+			for _, ref := range r.References {
+				if !ref.Valid {
+					fmt.Printf("  %s: %s\n", ref.File, ref.Error)
+				}
+			}
+		}
+		if len(results) > 0 {
+			fmt.Println("注: 只校验引用文件的杂凑值，未验证签名值及证书")
+		}
+	}
+	if err == nil && !match {
+		err = errFailed
+	}
+	return err
+}
+
+// runAttachments ofd attachments [-json] [-o dir] input.ofd
+func runAttachments(args []string) error {
+	f := newFlags("attachments").withStrict().withJSON()
+	dir := f.String("o", "", "导出附件到目录，文件名为附件在包内的文件名")
+	input, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+	opts, err := f.options()
+	if err != nil {
+		return err
+	}
+	attachments, err := converter.Attachments(input, opts...)
+	if err != nil {
+		return err
+	}
+	if *dir != "" {
+		if err = os.MkdirAll(*dir, 0o755); err != nil {
+			return err
+		}
+		for _, a := range attachments {
+			file := filepath.Join(*dir, filepath.Base(a.Path))
+			if err = os.WriteFile(file, a.Data, 0o644); err != nil {
+				return err
+			}
+			fmt.Println(file)
+		}
+		return nil
+	}
+	if f.json {
+		return writeJSON(os.Stdout, attachments)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t名称\t格式\t大小\t路径")
+	for _, a := range attachments {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", a.ID, a.Name, a.Format, a.Size, a.Path)
+	}
+	return w.Flush()
+}
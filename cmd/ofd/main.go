@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zc310/ofd/pkg/converter"
+)
+
+// command 子命令
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"info":        {"显示文档信息", runInfo},
+	"to-pdf":      {"转换为 PDF", runPDF},
+	"to-png":      {"转换为 PNG/JPEG 图像", runImage},
+	"text":        {"提取文字", runText},
+	"validate":    {"按 GB/T 33190 校验文档结构", runValidate},
+	"verify":      {"校验签名引用文件的杂凑值", runVerify},
+	"attachments": {"列出或导出附件", runAttachments},
+	"merge":       {"合并多个文档", runMerge},
+	"split":       {"拆分文档或提取页面", runSplit},
+}
+
+var (
+	// errUsage 参数错误，退出码为 2
+	errUsage = errors.New("参数错误")
+	// errFailed 校验未通过，结果已输出，退出码为 1
+	errFailed = errors.New("校验未通过")
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run 执行子命令，返回退出码
+func run(args []string) int {
+	if len(args) < 1 {
+		usage()
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		if args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+			fmt.Fprintf(os.Stderr, "未知命令: %s\n", args[0])
+		}
+		usage()
+		return 2
+	}
+	if err := cmd.run(args[1:]); err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errUsage):
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		case errors.Is(err, errFailed):
+			return 1
+		}
+		fmt.Fprintln(os.Stderr, "ofd:", err)
+		return 1
+	}
+	return 0
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: ofd <命令> [选项] <input.ofd>")
+	fmt.Fprintln(os.Stderr, "\n命令:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr, "\n使用 ofd <命令> -h 查看命令的选项")
+}
+
+// flags 子命令的公共选项
+type flags struct {
+	*flag.FlagSet
+	json   bool
+	strict bool
+	pages  string
+}
+
+func newFlags(name string) *flags {
+	f := &flags{FlagSet: flag.NewFlagSet(name, flag.ContinueOnError)}
+	f.Usage = func() {
+		fmt.Fprintf(f.Output(), "用法: ofd %s [选项] <input.ofd>\n\n选项:\n", name)
+		f.PrintDefaults()
+	}
+	return f
+}
+
+// withStrict 添加 -strict 选项
+func (f *flags) withStrict() *flags {
+	f.BoolVar(&f.strict, "strict", false, "严格模式，任何格式错误或缺失的部件都视为错误")
+	return f
+}
+
+// withJSON 添加 -json 选项
+func (f *flags) withJSON() *flags {
+	f.BoolVar(&f.json, "json", false, "以 JSON 格式输出")
+	return f
+}
+
+// withPages 添加 -pages 选项
+func (f *flags) withPages() *flags {
+	f.StringVar(&f.pages, "pages", "", "页码，如 1,3-5，默认为全部页面")
+	return f
+}
+
+// parse 解析选项并返回输入文件
+func (f *flags) parse(args []string) (string, error) {
+	if err := f.parseFlags(args); err != nil {
+		return "", err
+	}
+	if f.NArg() != 1 {
+		f.Usage()
+		return "", fmt.Errorf("%w: 需要一个输入文件", errUsage)
+	}
+	return f.Arg(0), nil
+}
+
+// parseFlags 解析选项，参数错误时返回 errUsage
+func (f *flags) parseFlags(args []string) error {
+	if err := f.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return nil
+}
+
+// options 返回公共选项对应的转换选项
+func (f *flags) options() ([]converter.Option, error) {
+	var opts []converter.Option
+	if f.strict {
+		opts = append(opts, converter.Strict())
+	}
+	if f.pages != "" {
+		pages, err := converter.ParsePages(f.pages)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		opts = append(opts, converter.Pages(pages...))
+	}
+	return opts, nil
+}
+
+// writeJSON 以缩进格式输出 JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// outputName 返回与输入文件同名、扩展名为 ext 的文件名
+func outputName(input, ext string) string {
+	return strings.TrimSuffix(input, filepath.Ext(input)) + ext
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/zc310/ofd/pkg/converter"
+)
+
+// execute 执行命令，返回退出码及标准输出
+func execute(t *testing.T, args ...string) (int, string) {
+	f, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdout := os.Stdout
+	os.Stdout = f
+	code := run(args)
+	os.Stdout = stdout
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, string(data)
+}
+
+// patchSignature 按正则表达式修改 999.ofd 的签名文件，返回新文件的路径
+func patchSignature(t *testing.T, expr, repl string) string {
+	r, err := zip.OpenReader("../../test/testdata/999.ofd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Name == "Doc_0/Signs/Sign_0/Signature.xml" {
+			data = regexp.MustCompile(expr).ReplaceAll(data, []byte(repl))
+		}
+		fw, err := w.Create(f.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "patched.ofd")
+	if err = os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestVerify(t *testing.T) {
+	signed := "../../test/testdata/999.ofd"
+	// 修改第一个引用文件的杂凑值
+	modified := patchSignature(t, `<ofd:CheckValue>9`, `<ofd:CheckValue>8`)
+	empty := patchSignature(t, `<ofd:Reference .*?</ofd:Reference>`, "")
+
+	tests := []struct {
+		args []string
+		code int
+		out  string
+	}{
+		{[]string{"verify", signed}, 0, "签名 1: 杂凑值一致"},
+		{[]string{"verify", "../../test/testdata/helloworld.ofd"}, 0, "没有签名"},
+		{[]string{"verify", modified}, 1, "杂凑值不一致"},
+		{[]string{"verify", empty}, 1, "没有引用文件"},
+		{[]string{"verify"}, 2, ""},
+		{[]string{"verify", "-unknown", signed}, 2, ""},
+		{[]string{"verify", filepath.Join(t.TempDir(), "missing.ofd")}, 1, ""},
+	}
+	for _, tt := range tests {
+		code, out := execute(t, tt.args...)
+		if code != tt.code {
+			t.Errorf("%v: 退出码 %d, 期望 %d", tt.args, code, tt.code)
+		}
+		if !strings.Contains(out, tt.out) {
+			t.Errorf("%v: 输出 %q 不包含 %q", tt.args, out, tt.out)
+		}
+	}
+}
+
+func TestVerify_JSON(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		code  int
+		match bool
+	}{
+		{"../../test/testdata/999.ofd", 0, true},
+		{patchSignature(t, `<ofd:Reference .*?</ofd:Reference>`, ""), 1, false},
+	} {
+		code, out := execute(t, "verify", "-json", tt.input)
+		if code != tt.code {
+			t.Errorf("%s: 退出码 %d, 期望 %d", tt.input, code, tt.code)
+		}
+		var results []converter.SignatureResult
+		if err := json.Unmarshal([]byte(out), &results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].DigestsMatch != tt.match {
+			t.Errorf("%s: 结果 %+v", tt.input, results)
+		}
+		if !strings.Contains(out, `"digestsMatch"`) {
+			t.Errorf("%s: 输出缺少 digestsMatch: %s", tt.input, out)
+		}
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	for _, args := range [][]string{nil, {"unknown"}} {
+		if code, _ := execute(t, args...); code != 2 {
+			t.Errorf("%v: 退出码 %d, 期望 2", args, code)
+		}
+	}
+	if code, _ := execute(t, "verify", "-h"); code != 0 {
+		t.Errorf("-h: 退出码 %d, 期望 0", code)
+	}
+}
+
+func TestImage_JPEG(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{nil, {"-thumbnail", "64"}} {
+		args = append([]string{"to-png", "-format", "jpeg", "-dpi", "20", "-pages", "1", "-o", dir}, args...)
+		if code, _ := execute(t, append(args, "../../test/testdata/999.ofd")...); code != 0 {
+			t.Fatalf("%v: 退出码 %d", args, code)
+		}
+		f, err := os.Open(filepath.Join(dir, "999-1.jpg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := jpeg.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 页面空白处为白色而不是透明通道丢失后的黑色
+		c := img.At(img.Bounds().Min.X, img.Bounds().Min.Y)
+		if r, g, b, _ := c.RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+			t.Errorf("%v: 左上角颜色 %v, 期望白色", args, c)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Package icc 解析 ICC 颜色配置文件，支持矩阵/曲线形式的 RGB 与灰度配置文件，
+// 以及使用 AToB 查找表 (lut8、lut16、lutAtoB) 的配置文件 (如 CMYK) 转换到 sRGB
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// 配置文件数据颜色空间
+const (
+	SpaceGray = "GRAY"
+	SpaceRGB  = "RGB"
+	SpaceCMYK = "CMYK"
+)
+
+// Profile ICC 颜色配置文件
+type Profile struct {
+	// ColorSpace 数据颜色空间，GRAY、RGB、CMYK 或其他四字符签名
+	ColorSpace string
+	// matrix 将线性分量转换为 PCS XYZ (D50)，按列为 rXYZ、gXYZ、bXYZ
+	matrix *[3][3]float64
+	trc    []curve
+	// lut AToB 查找表，优先于矩阵/曲线
+	lut *lut
+	// lab PCS 为 CIELAB，否则为 XYZ
+	lab bool
+}
+
+// Parse 解析配置文件头及转换所需的标签
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < 132 {
+		return nil, errors.New("icc: 配置文件过短")
+	}
+	if string(data[36:40]) != "acsp" {
+		return nil, errors.New("icc: 无效的配置文件签名")
+	}
+
+	p := &Profile{}
+	switch sig := string(data[16:20]); sig {
+	case "GRAY":
+		p.ColorSpace = SpaceGray
+	case "RGB ":
+		p.ColorSpace = SpaceRGB
+	case "CMYK":
+		p.ColorSpace = SpaceCMYK
+	default:
+		p.ColorSpace = sig
+	}
+	switch string(data[20:24]) {
+	case "XYZ ":
+	case "Lab ":
+		p.lab = true
+	default:
+		return p, nil
+	}
+
+	tags := make(map[string][]byte)
+	count := int(binary.BigEndian.Uint32(data[128:132]))
+	for i := 0; i < count; i++ {
+		off := 132 + i*12
+		if off+12 > len(data) {
+			return nil, errors.New("icc: 标签表越界")
+		}
+		sig := string(data[off : off+4])
+		start := int(binary.BigEndian.Uint32(data[off+4 : off+8]))
+		size := int(binary.BigEndian.Uint32(data[off+8 : off+12]))
+		if start < 0 || size < 0 || start+size > len(data) {
+			return nil, fmt.Errorf("icc: 标签 %q 越界", sig)
+		}
+		tags[sig] = data[start : start+size]
+	}
+
+	// 感知意图的 AToB0，其次为相对色度意图的 AToB1
+	for _, sig := range []string{"A2B0", "A2B1"} {
+		if l, err := parseLUT(tags[sig]); err == nil {
+			p.lut = l
+			break
+		}
+	}
+	if p.lab {
+		// PCS 为 Lab 时只能使用查找表
+		return p, nil
+	}
+
+	switch p.ColorSpace {
+	case SpaceGray:
+		c, err := parseCurve(tags["kTRC"])
+		if err != nil {
+			return p, nil
+		}
+		p.trc = []curve{c}
+	case SpaceRGB:
+		var m [3][3]float64
+		for i, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+			xyz, err := parseXYZ(tags[sig])
+			if err != nil {
+				return p, nil
+			}
+			for j := 0; j < 3; j++ {
+				m[j][i] = xyz[j]
+			}
+		}
+		for _, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+			c, err := parseCurve(tags[sig])
+			if err != nil {
+				return p, nil
+			}
+			p.trc = append(p.trc, c)
+		}
+		p.matrix = &m
+	}
+	return p, nil
+}
+
+// xyzToSRGB 将 D50 的 XYZ 经 Bradford 色适应转换为线性 sRGB
+var xyzToSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// ToSRGB 将 0~1 的分量转换为 0~1 的 sRGB，配置文件不支持转换时返回 false
+func (p *Profile) ToSRGB(v []float64) (r, g, b float64, ok bool) {
+	switch {
+	case p.lut != nil && len(v) >= p.lut.in:
+		xyz := p.lut.eval(v[:p.lut.in], p.lab)
+		if p.lab {
+			xyz = labToXYZ(xyz)
+		}
+		var rgb [3]float64
+		for i := 0; i < 3; i++ {
+			rgb[i] = encode(xyzToSRGB[i][0]*xyz[0] + xyzToSRGB[i][1]*xyz[1] + xyzToSRGB[i][2]*xyz[2])
+		}
+		return rgb[0], rgb[1], rgb[2], true
+	case p.ColorSpace == SpaceGray && len(p.trc) == 1 && len(v) >= 1:
+		y := encode(p.trc[0].eval(v[0]))
+		return y, y, y, true
+	case p.matrix != nil && len(v) >= 3:
+		var lin, xyz, rgb [3]float64
+		for i := 0; i < 3; i++ {
+			lin[i] = p.trc[i].eval(v[i])
+		}
+		for i := 0; i < 3; i++ {
+			xyz[i] = p.matrix[i][0]*lin[0] + p.matrix[i][1]*lin[1] + p.matrix[i][2]*lin[2]
+		}
+		for i := 0; i < 3; i++ {
+			rgb[i] = encode(xyzToSRGB[i][0]*xyz[0] + xyzToSRGB[i][1]*xyz[1] + xyzToSRGB[i][2]*xyz[2])
+		}
+		return rgb[0], rgb[1], rgb[2], true
+	}
+	return 0, 0, 0, false
+}
+
+// encode 对线性分量进行 sRGB 伽马编码
+func encode(v float64) float64 {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+func parseXYZ(b []byte) ([3]float64, error) {
+	var xyz [3]float64
+	if len(b) < 20 || string(b[:4]) != "XYZ " {
+		return xyz, errors.New("icc: 无效的 XYZ 标签")
+	}
+	for i := 0; i < 3; i++ {
+		xyz[i] = s15Fixed16(b[8+i*4:])
+	}
+	return xyz, nil
+}
+
+// curve 色调再现曲线，table 为采样表，否则为参数曲线
+type curve struct {
+	table  []float64
+	kind   int
+	params [7]float64
+}
+
+func parseCurve(b []byte) (curve, error) {
+	var c curve
+	if len(b) < 12 {
+		return c, errors.New("icc: 无效的曲线标签")
+	}
+	switch string(b[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(b[8:12]))
+		switch {
+		case n == 0:
+			c.params[0] = 1
+		case n == 1 && len(b) >= 14:
+			c.params[0] = float64(binary.BigEndian.Uint16(b[12:14])) / 256
+		case len(b) >= 12+n*2:
+			c.table = make([]float64, n)
+			for i := range c.table {
+				c.table[i] = float64(binary.BigEndian.Uint16(b[12+i*2:])) / 65535
+			}
+		default:
+			return c, errors.New("icc: 曲线标签越界")
+		}
+	case "para":
+		c.kind = int(binary.BigEndian.Uint16(b[8:10]))
+		n := []int{1, 3, 4, 5, 7}
+		if c.kind >= len(n) || len(b) < 12+n[c.kind]*4 {
+			return c, errors.New("icc: 不支持的参数曲线")
+		}
+		for i := 0; i < n[c.kind]; i++ {
+			c.params[i] = s15Fixed16(b[12+i*4:])
+		}
+	default:
+		return c, errors.New("icc: 不支持的曲线类型")
+	}
+	return c, nil
+}
+
+func (c curve) eval(x float64) float64 {
+	x = math.Max(0, math.Min(1, x))
+	if c.table != nil {
+		if len(c.table) == 1 {
+			return c.table[0]
+		}
+		f := x * float64(len(c.table)-1)
+		i := int(f)
+		if i >= len(c.table)-1 {
+			return c.table[len(c.table)-1]
+		}
+		return c.table[i] + (c.table[i+1]-c.table[i])*(f-float64(i))
+	}
+	g, a, b, cc, d, e, f := c.params[0], c.params[1], c.params[2], c.params[3], c.params[4], c.params[5], c.params[6]
+	switch c.kind {
+	case 1:
+		if x >= -b/a {
+			return math.Pow(a*x+b, g)
+		}
+		return 0
+	case 2:
+		if x >= -b/a {
+			return math.Pow(a*x+b, g) + cc
+		}
+		return cc
+	case 3:
+		if x >= d {
+			return math.Pow(a*x+b, g)
+		}
+		return cc * x
+	case 4:
+		if x >= d {
+			return math.Pow(a*x+b, g) + e
+		}
+		return cc*x + f
+	}
+	return math.Pow(x, g)
+}
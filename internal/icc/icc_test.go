@@ -0,0 +1,231 @@
+package icc
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// srgbProfile 构造使用 sRGB 原色与参数曲线的矩阵/曲线配置文件
+func srgbProfile() []byte {
+	fixed := func(v float64) []byte {
+		return binary.BigEndian.AppendUint32(nil, uint32(int32(math.Round(v*65536))))
+	}
+	xyz := func(x, y, z float64) []byte {
+		b := append([]byte("XYZ "), 0, 0, 0, 0)
+		b = append(b, fixed(x)...)
+		b = append(b, fixed(y)...)
+		return append(b, fixed(z)...)
+	}
+	para := append([]byte("para"), 0, 0, 0, 0, 0, 3, 0, 0)
+	for _, v := range []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045} {
+		para = append(para, fixed(v)...)
+	}
+	return profile("RGB XYZ ", []tag{
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", para},
+		{"gTRC", para},
+		{"bTRC", para},
+	})
+}
+
+type tag struct {
+	sig  string
+	data []byte
+}
+
+// profile 构造配置文件，spaces 为数据颜色空间与 PCS 签名
+func profile(spaces string, tags []tag) []byte {
+	data := make([]byte, 128)
+	copy(data[16:], spaces)
+	copy(data[36:], "acsp")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(tags)))
+	offset := len(data) + len(tags)*12
+	var body []byte
+	for _, tag := range tags {
+		data = append(data, tag.sig...)
+		data = binary.BigEndian.AppendUint32(data, uint32(offset+len(body)))
+		data = binary.BigEndian.AppendUint32(data, uint32(len(tag.data)))
+		body = append(body, tag.data...)
+	}
+	return append(data, body...)
+}
+
+func TestProfile_ToSRGB(t *testing.T) {
+	p, err := Parse(srgbProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ColorSpace != SpaceRGB {
+		t.Fatalf("ColorSpace = %q", p.ColorSpace)
+	}
+	for _, v := range [][3]float64{{0, 0, 0}, {1, 1, 1}, {0.5, 0.5, 0.5}, {1, 0, 0}, {0.2, 0.6, 0.9}} {
+		r, g, b, ok := p.ToSRGB(v[:])
+		if !ok {
+			t.Fatal("ToSRGB not supported")
+		}
+		if math.Abs(r-v[0]) > 0.01 || math.Abs(g-v[1]) > 0.01 || math.Abs(b-v[2]) > 0.01 {
+			t.Errorf("ToSRGB(%v) = %.3f %.3f %.3f", v, r, g, b)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	if _, err := Parse([]byte("short")); err == nil {
+		t.Error("expected error for short profile")
+	}
+	cmyk := make([]byte, 132)
+	copy(cmyk[16:], "CMYKLab ")
+	copy(cmyk[36:], "acsp")
+	p, err := Parse(cmyk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, ok := p.ToSRGB([]float64{0, 0, 0, 0}); ok || p.ColorSpace != SpaceCMYK {
+		t.Errorf("CMYK profile: ColorSpace = %q, ok = %v", p.ColorSpace, ok)
+	}
+}
+
+// naiveCMYK 简单的 CMYK 到 sRGB 转换，作为查找表网格点的期望值
+func naiveCMYK(c, m, y, k float64) [3]float64 {
+	return [3]float64{(1 - c) * (1 - k), (1 - m) * (1 - k), (1 - y) * (1 - k)}
+}
+
+// srgbToXYZ 将 sRGB 转换为 D50 的 XYZ
+func srgbToXYZ(rgb [3]float64) [3]float64 {
+	var lin [3]float64
+	for i, v := range rgb {
+		if v <= 0.04045 {
+			lin[i] = v / 12.92
+		} else {
+			lin[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	m := [3][3]float64{{0.4361, 0.3851, 0.1431}, {0.2225, 0.7169, 0.0606}, {0.0139, 0.0971, 0.7141}}
+	var xyz [3]float64
+	for i := range xyz {
+		xyz[i] = m[i][0]*lin[0] + m[i][1]*lin[1] + m[i][2]*lin[2]
+	}
+	return xyz
+}
+
+func srgbToLab(rgb [3]float64) [3]float64 {
+	xyz := srgbToXYZ(rgb)
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return t*24389/27/116 + 16.0/116
+	}
+	fx, fy, fz := f(xyz[0]/0.9642), f(xyz[1]), f(xyz[2]/0.8249)
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// cmykGrid 按查找表的顺序 (C 变化最慢) 返回 2x2x2x2 网格点的 PCS 值
+func cmykGrid(pcs func([3]float64) [3]float64) [][3]float64 {
+	var grid [][3]float64
+	for i := 0; i < 16; i++ {
+		c, m, y, k := float64(i>>3&1), float64(i>>2&1), float64(i>>1&1), float64(i&1)
+		grid = append(grid, pcs(naiveCMYK(c, m, y, k)))
+	}
+	return grid
+}
+
+// lut16 构造 PCS 为 Lab 的 lut16Type CMYK 到 Lab 查找表
+func lut16() []byte {
+	b := append([]byte("mft2"), 0, 0, 0, 0, 4, 3, 2, 0)
+	for i := 0; i < 9; i++ {
+		v := uint32(0)
+		if i%4 == 0 {
+			v = 1 << 16
+		}
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	b = binary.BigEndian.AppendUint16(b, 2)
+	b = binary.BigEndian.AppendUint16(b, 2)
+	identity := func(n int) {
+		for i := 0; i < n; i++ {
+			b = binary.BigEndian.AppendUint16(b, 0)
+			b = binary.BigEndian.AppendUint16(b, 0xFFFF)
+		}
+	}
+	identity(4)
+	for _, lab := range cmykGrid(srgbToLab) {
+		// 旧版 16 位 Lab 编码
+		for _, v := range []float64{lab[0] / 100, (lab[1] + 128) / 255, (lab[2] + 128) / 255} {
+			b = binary.BigEndian.AppendUint16(b, uint16(math.Round(v*65280)))
+		}
+	}
+	identity(3)
+	return b
+}
+
+// lutAtoB 构造 PCS 为 XYZ 的 lutAtoBType CMYK 到 XYZ 查找表，只包含 B 曲线、颜色查找表及 A 曲线
+func lutAtoB() []byte {
+	curv := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x00")
+	b := append([]byte("mAB "), 0, 0, 0, 0, 4, 3, 0, 0)
+	bOff := 32
+	clutOff := bOff + 3*len(curv)
+	aOff := clutOff + 20 + 16*3*2
+	for _, off := range []int{bOff, 0, 0, clutOff, aOff} {
+		b = binary.BigEndian.AppendUint32(b, uint32(off))
+	}
+	for i := 0; i < 3; i++ {
+		b = append(b, curv...)
+	}
+	grid := make([]byte, 20)
+	copy(grid, []byte{2, 2, 2, 2})
+	grid[16] = 2
+	b = append(b, grid...)
+	for _, xyz := range cmykGrid(srgbToXYZ) {
+		for _, v := range xyz {
+			b = binary.BigEndian.AppendUint16(b, uint16(math.Round(v*32768)))
+		}
+	}
+	for i := 0; i < 4; i++ {
+		b = append(b, curv...)
+	}
+	return b
+}
+
+func TestProfile_CMYK(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"lut16":    profile("CMYKLab ", []tag{{"A2B0", lut16()}}),
+		"lutAtoB":  profile("CMYKXYZ ", []tag{{"A2B0", lutAtoB()}}),
+		"A2B1":     profile("CMYKLab ", []tag{{"A2B1", lut16()}}),
+		"truncate": profile("CMYKLab ", []tag{{"A2B0", lut16()[:150]}}),
+	} {
+		p, err := Parse(data)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if p.ColorSpace != SpaceCMYK {
+			t.Errorf("%s: ColorSpace = %q", name, p.ColorSpace)
+		}
+		if name == "truncate" {
+			if _, _, _, ok := p.ToSRGB([]float64{0, 0, 0, 0}); ok {
+				t.Error("truncated table: ToSRGB supported")
+			}
+			continue
+		}
+		// 网格点上与期望值一致，网格之间插值
+		for _, v := range [][4]float64{{0, 0, 0, 0}, {0, 0, 0, 1}, {1, 0, 0, 0}, {0, 1, 1, 0}, {0, 0, 1, 0}, {0.5, 0.5, 0.5, 0.5}} {
+			r, g, b, ok := p.ToSRGB(v[:])
+			if !ok {
+				t.Fatalf("%s: ToSRGB not supported", name)
+			}
+			if v[0] == 0.5 {
+				if r <= 0.1 || r >= 0.6 || g <= 0.1 || g >= 0.6 || b <= 0.1 || b >= 0.6 {
+					t.Errorf("%s: ToSRGB(%v) = %.3f %.3f %.3f", name, v, r, g, b)
+				}
+				continue
+			}
+			want := naiveCMYK(v[0], v[1], v[2], v[3])
+			if math.Abs(r-want[0]) > 0.02 || math.Abs(g-want[1]) > 0.02 || math.Abs(b-want[2]) > 0.02 {
+				t.Errorf("%s: ToSRGB(%v) = %.3f %.3f %.3f, want %v", name, v, r, g, b, want)
+			}
+		}
+	}
+}
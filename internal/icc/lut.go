@@ -0,0 +1,343 @@
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// lut AToB 查找表，依次经过输入曲线、多维颜色查找表、M 曲线、矩阵及输出曲线转换到 PCS
+type lut struct {
+	in, out int
+	a       []curve
+	clut    *clut
+	m       []curve
+	matrix  *[3][4]float64
+	b       []curve
+	// pcs 将 0~1 的输出分量解码为 PCS 值
+	pcs func(v [3]float64, lab bool) [3]float64
+}
+
+// clut 多维颜色查找表，grid 为各输入维度的网格点数，数据按第一维变化最慢排列
+type clut struct {
+	grid []int
+	data []float64
+	out  int
+}
+
+// parseLUT 解析 lut8Type (mft1)、lut16Type (mft2) 或 lutAtoBType (mAB) 标签
+func parseLUT(b []byte) (*lut, error) {
+	if len(b) < 12 {
+		return nil, errors.New("icc: 无效的查找表标签")
+	}
+	switch string(b[:4]) {
+	case "mft1":
+		return parseLegacyLUT(b, 1)
+	case "mft2":
+		return parseLegacyLUT(b, 2)
+	case "mAB ":
+		return parseLutAtoB(b)
+	}
+	return nil, errors.New("icc: 不支持的查找表类型")
+}
+
+// parseLegacyLUT 解析 lut8Type 及 lut16Type，size 为每个表项的字节数
+func parseLegacyLUT(b []byte, size int) (*lut, error) {
+	if len(b) < 48 {
+		return nil, errors.New("icc: 查找表标签过短")
+	}
+	in, out, grid := int(b[8]), int(b[9]), int(b[10])
+	if in < 1 || in > 15 || out != 3 || grid < 2 {
+		return nil, errors.New("icc: 不支持的查找表通道数")
+	}
+	off := 48
+	inEntries, outEntries := 256, 256
+	if size == 2 {
+		if len(b) < 52 {
+			return nil, errors.New("icc: 查找表标签过短")
+		}
+		inEntries = int(binary.BigEndian.Uint16(b[48:50]))
+		outEntries = int(binary.BigEndian.Uint16(b[50:52]))
+		off = 52
+		if inEntries < 2 || outEntries < 2 {
+			return nil, errors.New("icc: 无效的查找表项数")
+		}
+	}
+	read := func(n int) ([]float64, error) {
+		if n > (len(b)-off)/size {
+			return nil, errors.New("icc: 查找表标签越界")
+		}
+		v := make([]float64, n)
+		for i := range v {
+			if size == 1 {
+				v[i] = float64(b[off+i]) / 255
+			} else {
+				v[i] = float64(binary.BigEndian.Uint16(b[off+i*2:])) / 65535
+			}
+		}
+		off += n * size
+		return v, nil
+	}
+	tables := func(n, entries int) ([]curve, error) {
+		c := make([]curve, n)
+		for i := range c {
+			t, err := read(entries)
+			if err != nil {
+				return nil, err
+			}
+			c[i].table = t
+		}
+		return c, nil
+	}
+
+	l := &lut{in: in, out: out, pcs: legacyPCS(size)}
+	var err error
+	if l.a, err = tables(in, inEntries); err != nil {
+		return nil, err
+	}
+	points := 1
+	for i := 0; i < in; i++ {
+		if points > math.MaxInt32/grid {
+			return nil, errors.New("icc: 颜色查找表过大")
+		}
+		points *= grid
+	}
+	c := &clut{grid: make([]int, in), out: out}
+	for i := range c.grid {
+		c.grid[i] = grid
+	}
+	if points > math.MaxInt32/out {
+		return nil, errors.New("icc: 颜色查找表过大")
+	}
+	if c.data, err = read(points * out); err != nil {
+		return nil, err
+	}
+	l.clut = c
+	if l.b, err = tables(out, outEntries); err != nil {
+		return nil, err
+	}
+	// 矩阵只用于输入为 XYZ 的配置文件，设备颜色空间不使用
+	return l, nil
+}
+
+// legacyPCS 返回 lut8Type/lut16Type 的 PCS 解码函数
+func legacyPCS(size int) func(v [3]float64, lab bool) [3]float64 {
+	return func(v [3]float64, lab bool) [3]float64 {
+		if !lab {
+			// u1Fixed15 编码
+			for i := range v {
+				v[i] *= 65535.0 / 32768
+			}
+			return v
+		}
+		if size == 2 {
+			// 旧版 16 位 Lab 编码，0xFF00 对应 L* 为 100
+			for i := range v {
+				v[i] *= 65535.0 / 65280
+			}
+		}
+		return [3]float64{v[0] * 100, v[1]*255 - 128, v[2]*255 - 128}
+	}
+}
+
+// parseLutAtoB 解析 lutAtoBType
+func parseLutAtoB(b []byte) (*lut, error) {
+	if len(b) < 32 {
+		return nil, errors.New("icc: 查找表标签过短")
+	}
+	in, out := int(b[8]), int(b[9])
+	if in < 1 || in > 15 || out != 3 {
+		return nil, errors.New("icc: 不支持的查找表通道数")
+	}
+	offset := func(i int) int { return int(binary.BigEndian.Uint32(b[12+i*4:])) }
+	l := &lut{in: in, out: out, pcs: func(v [3]float64, lab bool) [3]float64 {
+		if lab {
+			return [3]float64{v[0] * 100, v[1]*255 - 128, v[2]*255 - 128}
+		}
+		for i := range v {
+			v[i] *= 65535.0 / 32768
+		}
+		return v
+	}}
+
+	var err error
+	if off := offset(0); off != 0 {
+		if l.b, err = parseCurves(b, off, out); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, errors.New("icc: 查找表缺少 B 曲线")
+	}
+	if off := offset(1); off != 0 {
+		if off+48 > len(b) || off < 0 {
+			return nil, errors.New("icc: 查找表矩阵越界")
+		}
+		var m [3][4]float64
+		for i := 0; i < 9; i++ {
+			m[i/3][i%3] = s15Fixed16(b[off+i*4:])
+		}
+		for i := 0; i < 3; i++ {
+			m[i][3] = s15Fixed16(b[off+36+i*4:])
+		}
+		l.matrix = &m
+	}
+	if off := offset(2); off != 0 {
+		if l.m, err = parseCurves(b, off, out); err != nil {
+			return nil, err
+		}
+	}
+	if off := offset(3); off != 0 {
+		if l.clut, err = parseCLUT(b, off, in, out); err != nil {
+			return nil, err
+		}
+	}
+	if off := offset(4); off != 0 {
+		if l.a, err = parseCurves(b, off, in); err != nil {
+			return nil, err
+		}
+	}
+	if l.clut == nil && in != out {
+		return nil, errors.New("icc: 查找表缺少颜色查找表")
+	}
+	return l, nil
+}
+
+// parseCurves 解析从 off 开始的 n 条曲线，每条曲线按 4 字节对齐
+func parseCurves(b []byte, off, n int) ([]curve, error) {
+	c := make([]curve, n)
+	for i := range c {
+		if off < 0 || off+12 > len(b) {
+			return nil, errors.New("icc: 曲线越界")
+		}
+		var err error
+		if c[i], err = parseCurve(b[off:]); err != nil {
+			return nil, err
+		}
+		size := 12
+		switch string(b[off : off+4]) {
+		case "curv":
+			size += int(binary.BigEndian.Uint32(b[off+8:])) * 2
+		case "para":
+			size += []int{1, 3, 4, 5, 7}[c[i].kind] * 4
+		}
+		off += (size + 3) &^ 3
+	}
+	return c, nil
+}
+
+// parseCLUT 解析 lutAtoBType 中的颜色查找表
+func parseCLUT(b []byte, off, in, out int) (*clut, error) {
+	if off < 0 || off+20 > len(b) {
+		return nil, errors.New("icc: 颜色查找表越界")
+	}
+	c := &clut{grid: make([]int, in), out: out}
+	points := out
+	for i := range c.grid {
+		c.grid[i] = int(b[off+i])
+		if c.grid[i] < 2 {
+			return nil, errors.New("icc: 无效的颜色查找表网格")
+		}
+		points *= c.grid[i]
+	}
+	size := int(b[off+16])
+	if size != 1 && size != 2 {
+		return nil, errors.New("icc: 无效的颜色查找表精度")
+	}
+	off += 20
+	if points > (len(b)-off)/size {
+		return nil, errors.New("icc: 颜色查找表越界")
+	}
+	c.data = make([]float64, points)
+	for i := range c.data {
+		if size == 1 {
+			c.data[i] = float64(b[off+i]) / 255
+		} else {
+			c.data[i] = float64(binary.BigEndian.Uint16(b[off+i*2:])) / 65535
+		}
+	}
+	return c, nil
+}
+
+// eval 将 0~1 的输入分量转换为 PCS 值 (Lab 或 D50 XYZ)，lab 表示 PCS 为 Lab
+func (l *lut) eval(v []float64, lab bool) [3]float64 {
+	x := make([]float64, l.in)
+	for i := range x {
+		x[i] = math.Max(0, math.Min(1, v[i]))
+		if l.a != nil {
+			x[i] = l.a[i].eval(x[i])
+		}
+	}
+	var y [3]float64
+	if l.clut != nil {
+		copy(y[:], l.clut.eval(x))
+	} else {
+		copy(y[:], x)
+	}
+	if l.m != nil {
+		for i := range y {
+			y[i] = l.m[i].eval(y[i])
+		}
+	}
+	if m := l.matrix; m != nil {
+		y = [3]float64{
+			m[0][0]*y[0] + m[0][1]*y[1] + m[0][2]*y[2] + m[0][3],
+			m[1][0]*y[0] + m[1][1]*y[1] + m[1][2]*y[2] + m[1][3],
+			m[2][0]*y[0] + m[2][1]*y[1] + m[2][2]*y[2] + m[2][3],
+		}
+	}
+	for i := range y {
+		y[i] = l.b[i].eval(y[i])
+	}
+	return l.pcs(y, lab)
+}
+
+// eval 对网格进行多线性插值
+func (c *clut) eval(x []float64) []float64 {
+	n := len(c.grid)
+	base := make([]int, n)
+	frac := make([]float64, n)
+	stride := make([]int, n)
+	s := c.out
+	for i := n - 1; i >= 0; i-- {
+		stride[i] = s
+		s *= c.grid[i]
+		f := x[i] * float64(c.grid[i]-1)
+		base[i] = min(int(f), c.grid[i]-2)
+		frac[i] = f - float64(base[i])
+	}
+	y := make([]float64, c.out)
+	for corner := 0; corner < 1<<n; corner++ {
+		w, off := 1.0, 0
+		for i := 0; i < n; i++ {
+			if corner&(1<<i) != 0 {
+				w *= frac[i]
+				off += (base[i] + 1) * stride[i]
+			} else {
+				w *= 1 - frac[i]
+				off += base[i] * stride[i]
+			}
+		}
+		if w == 0 {
+			continue
+		}
+		for j := range y {
+			y[j] += w * c.data[off+j]
+		}
+	}
+	return y
+}
+
+// labToXYZ 将 CIELAB 转换为 D50 的 XYZ
+func labToXYZ(lab [3]float64) [3]float64 {
+	fy := (lab[0] + 16) / 116
+	fx := fy + lab[1]/500
+	fz := fy - lab[2]/200
+	f := func(t float64) float64 {
+		if t > 6.0/29 {
+			return t * t * t
+		}
+		return 3 * (6.0 / 29) * (6.0 / 29) * (t - 4.0/29)
+	}
+	// D50 白点
+	return [3]float64{0.9642 * f(fx), f(fy), 0.8249 * f(fz)}
+}
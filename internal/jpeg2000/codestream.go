@@ -0,0 +1,472 @@
+package jpeg2000
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// 码流标记
+const (
+	markerSOC = 0xFF4F
+	markerSIZ = 0xFF51
+	markerCOD = 0xFF52
+	markerCOC = 0xFF53
+	markerQCD = 0xFF5C
+	markerQCC = 0xFF5D
+	markerRGN = 0xFF5E
+	markerPOC = 0xFF5F
+	markerPPM = 0xFF60
+	markerPPT = 0xFF61
+	markerSOT = 0xFF90
+	markerSOP = 0xFF91
+	markerEPH = 0xFF92
+	markerSOD = 0xFF93
+	markerEOC = 0xFFD9
+)
+
+// 码块编码风格
+const (
+	cbBypass    = 0x01
+	cbReset     = 0x02
+	cbTermAll   = 0x04
+	cbCausal    = 0x08
+	cbSegSymbol = 0x20
+	cbHT        = 0x40
+)
+
+// 量化方式
+const (
+	quantNone      = 0
+	quantDerived   = 1
+	quantExpounded = 2
+)
+
+// component SIZ 中的分量信息
+type component struct {
+	precision int
+	signed    bool
+	dx, dy    int
+}
+
+// siz 图像及分块尺寸
+type siz struct {
+	width, height  int // Xsiz, Ysiz
+	x0, y0         int // XOsiz, YOsiz
+	tileW, tileH   int // XTsiz, YTsiz
+	tileX0, tileY0 int // XTOsiz, YTOsiz
+	components     []component
+	tilesX, tilesY int
+}
+
+// codingStyle 分量的编码参数 (COD/COC)
+type codingStyle struct {
+	levels     int
+	xcb, ycb   int
+	cbStyle    int
+	reversible bool
+	// precincts 各分辨率级别的分区尺寸指数，低 4 位为 PPx，高 4 位为 PPy
+	precincts []byte
+}
+
+// quantization 分量的量化参数 (QCD/QCC)
+type quantization struct {
+	style    int
+	guard    int
+	steps    []step
+	roiShift int
+}
+
+type step struct {
+	exponent, mantissa int
+}
+
+// progression 进展顺序变化 (POC)
+type progression struct {
+	resStart, compStart int
+	layerEnd            int
+	resEnd, compEnd     int
+	order               int
+}
+
+// params 主头或分块头中的编码参数
+type params struct {
+	order  int
+	layers int
+	mct    bool
+	sop    bool
+	eph    bool
+	cod    []codingStyle
+	qcd    []quantization
+	poc    []progression
+}
+
+func (p *params) clone() *params {
+	c := *p
+	c.cod = append([]codingStyle(nil), p.cod...)
+	c.qcd = append([]quantization(nil), p.qcd...)
+	c.poc = append([]progression(nil), p.poc...)
+	return &c
+}
+
+// codestream 解析后的码流
+type codestream struct {
+	siz
+	main *params
+	// mainOver 主头中 COC/QCC 指定的分量
+	mainOver *tileOverrides
+	tiles    map[int]*tileData
+}
+
+// tileData 分块的参数及合并后的数据
+type tileData struct {
+	params *params
+	data   []byte
+}
+
+// reader 大端字节读取
+type reader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (r *reader) u8() int {
+	if r.off+1 > len(r.b) {
+		r.err = errTruncated
+		return 0
+	}
+	v := r.b[r.off]
+	r.off++
+	return int(v)
+}
+
+func (r *reader) u16() int {
+	if r.off+2 > len(r.b) {
+		r.err = errTruncated
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.b[r.off:])
+	r.off += 2
+	return int(v)
+}
+
+func (r *reader) u32() int {
+	if r.off+4 > len(r.b) {
+		r.err = errTruncated
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b[r.off:])
+	r.off += 4
+	return int(v)
+}
+
+var errTruncated = errors.New("jpeg2000: 码流不完整")
+
+// parseCodestream 解析主头及各分块
+func parseCodestream(b []byte, headerOnly bool) (*codestream, error) {
+	r := &reader{b: b}
+	if r.u16() != markerSOC {
+		return nil, errors.New("jpeg2000: 缺少 SOC 标记")
+	}
+	cs := &codestream{main: &params{}, tiles: make(map[int]*tileData)}
+
+	// 主头
+	for {
+		marker := r.u16()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if marker == markerSOT {
+			r.off -= 2
+			break
+		}
+		length := r.u16()
+		if length < 2 || r.off+length-2 > len(b) {
+			return nil, errTruncated
+		}
+		seg := &reader{b: b[r.off : r.off+length-2]}
+		r.off += length - 2
+		var err error
+		switch marker {
+		case markerSIZ:
+			err = cs.parseSIZ(seg)
+			if err == nil && headerOnly {
+				return cs, nil
+			}
+		case markerCOD, markerCOC, markerQCD, markerQCC, markerRGN, markerPOC:
+			if cs.components == nil {
+				return nil, errors.New("jpeg2000: SIZ 标记缺失")
+			}
+			err = cs.parseParam(marker, seg, cs.main, nil)
+		case markerPPM:
+			return nil, errors.New("jpeg2000: 不支持 PPM 打包包头")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cs.components == nil {
+		return nil, errors.New("jpeg2000: SIZ 标记缺失")
+	}
+	if err := cs.main.check(); err != nil {
+		return nil, err
+	}
+
+	// 分块
+	tileCOC := make(map[int][]bool)
+	tileQCC := make(map[int][]bool)
+	for r.off+2 <= len(b) {
+		start := r.off
+		marker := r.u16()
+		if marker == markerEOC {
+			break
+		}
+		if marker != markerSOT {
+			return nil, fmt.Errorf("jpeg2000: 期望 SOT 标记，实际为 %04X", marker)
+		}
+		r.u16() // Lsot
+		index := r.u16()
+		psot := r.u32()
+		r.u8() // TPsot
+		r.u8() // TNsot
+		if r.err != nil {
+			return nil, r.err
+		}
+		end := len(b)
+		if psot != 0 && start+psot <= len(b) {
+			end = start + psot
+		}
+		if index >= cs.tilesX*cs.tilesY {
+			return nil, fmt.Errorf("jpeg2000: 分块序号 %d 越界", index)
+		}
+		tile := cs.tiles[index]
+		if tile == nil {
+			tile = &tileData{params: cs.main.clone()}
+			cs.tiles[index] = tile
+			tileCOC[index] = make([]bool, len(cs.components))
+			tileQCC[index] = make([]bool, len(cs.components))
+		}
+
+		// 分块头
+		for {
+			marker := r.u16()
+			if r.err != nil {
+				return nil, r.err
+			}
+			if marker == markerSOD {
+				break
+			}
+			length := r.u16()
+			if length < 2 || r.off+length-2 > end {
+				return nil, errTruncated
+			}
+			seg := &reader{b: b[r.off : r.off+length-2]}
+			r.off += length - 2
+			var err error
+			switch marker {
+			case markerCOD, markerCOC, markerQCD, markerQCC, markerRGN, markerPOC:
+				err = cs.parseParam(marker, seg, tile.params, &tileOverrides{coc: tileCOC[index], qcc: tileQCC[index]})
+			case markerPPT:
+				return nil, errors.New("jpeg2000: 不支持 PPT 打包包头")
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := tile.params.check(); err != nil {
+			return nil, err
+		}
+		tile.data = append(tile.data, b[r.off:end]...)
+		r.off = end
+	}
+	return cs, nil
+}
+
+func (cs *codestream) parseSIZ(r *reader) error {
+	r.u16() // Rsiz
+	cs.width, cs.height = r.u32(), r.u32()
+	cs.x0, cs.y0 = r.u32(), r.u32()
+	cs.tileW, cs.tileH = r.u32(), r.u32()
+	cs.tileX0, cs.tileY0 = r.u32(), r.u32()
+	n := r.u16()
+	if r.err != nil {
+		return r.err
+	}
+	if n == 0 || n > 16384 || cs.width <= cs.x0 || cs.height <= cs.y0 || cs.tileW == 0 || cs.tileH == 0 {
+		return errors.New("jpeg2000: 无效的 SIZ 参数")
+	}
+	if cs.tileX0 > cs.x0 || cs.tileY0 > cs.y0 || cs.tileX0+cs.tileW <= cs.x0 || cs.tileY0+cs.tileH <= cs.y0 {
+		return errors.New("jpeg2000: 无效的分块原点")
+	}
+	cs.components = make([]component, n)
+	for i := range cs.components {
+		s := r.u8()
+		c := component{precision: s&0x7F + 1, signed: s&0x80 != 0, dx: r.u8(), dy: r.u8()}
+		if c.dx == 0 || c.dy == 0 || c.precision > 38 {
+			return errors.New("jpeg2000: 无效的分量参数")
+		}
+		cs.components[i] = c
+	}
+	cs.tilesX = ceilDiv(cs.width-cs.tileX0, cs.tileW)
+	cs.tilesY = ceilDiv(cs.height-cs.tileY0, cs.tileH)
+	if int64(cs.tilesX)*int64(cs.tilesY) > 65535 {
+		return errors.New("jpeg2000: 分块数量过多")
+	}
+	cs.main.cod = make([]codingStyle, n)
+	cs.main.qcd = make([]quantization, n)
+	cs.mainOver = &tileOverrides{coc: make([]bool, n), qcc: make([]bool, n)}
+	return r.err
+}
+
+// tileOverrides 记录分块头中按分量指定的参数，COD/QCD 不覆盖这些分量
+type tileOverrides struct {
+	coc, qcc []bool
+}
+
+func (cs *codestream) compIndex(r *reader) int {
+	if len(cs.components) < 257 {
+		return r.u8()
+	}
+	return r.u16()
+}
+
+// parseParam 解析编码参数标记，优先级为：分块 COC > 分块 COD > 主头 COC > 主头 COD
+func (cs *codestream) parseParam(marker int, r *reader, p *params, over *tileOverrides) error {
+	n := len(cs.components)
+	if over == nil {
+		over = cs.mainOver
+	}
+	switch marker {
+	case markerCOD:
+		scod := r.u8()
+		p.order = r.u8()
+		p.layers = r.u16()
+		p.mct = r.u8() != 0
+		p.sop = scod&0x02 != 0
+		p.eph = scod&0x04 != 0
+		style, err := parseCodingStyle(r, scod&0x01 != 0)
+		if err != nil {
+			return err
+		}
+		for c := 0; c < n; c++ {
+			if !over.coc[c] {
+				p.cod[c] = style
+			}
+		}
+	case markerCOC:
+		c := cs.compIndex(r)
+		scoc := r.u8()
+		if c >= n {
+			return errors.New("jpeg2000: COC 分量越界")
+		}
+		style, err := parseCodingStyle(r, scoc&0x01 != 0)
+		if err != nil {
+			return err
+		}
+		p.cod[c] = style
+		over.coc[c] = true
+	case markerQCD:
+		q := parseQuantization(r)
+		for c := 0; c < n; c++ {
+			if !over.qcc[c] {
+				shift := p.qcd[c].roiShift
+				p.qcd[c] = q
+				p.qcd[c].roiShift = shift
+			}
+		}
+	case markerQCC:
+		c := cs.compIndex(r)
+		if c >= n {
+			return errors.New("jpeg2000: QCC 分量越界")
+		}
+		shift := p.qcd[c].roiShift
+		p.qcd[c] = parseQuantization(r)
+		p.qcd[c].roiShift = shift
+		over.qcc[c] = true
+	case markerRGN:
+		c := cs.compIndex(r)
+		r.u8() // Srgn，只有 Maxshift
+		if c < n {
+			p.qcd[c].roiShift = r.u8()
+		}
+	case markerPOC:
+		p.poc = nil
+		for r.off < len(r.b) {
+			var pr progression
+			pr.resStart = r.u8()
+			pr.compStart = cs.compIndex(r)
+			pr.layerEnd = r.u16()
+			pr.resEnd = r.u8()
+			pr.compEnd = cs.compIndex(r)
+			if pr.compEnd == 0 {
+				pr.compEnd = 256
+			}
+			pr.order = r.u8()
+			if r.err != nil {
+				return r.err
+			}
+			p.poc = append(p.poc, pr)
+		}
+	}
+	return r.err
+}
+
+// check 检查各分量是否都有编码参数，且分区尺寸数与分辨率级别数一致
+func (p *params) check() error {
+	for c, s := range p.cod {
+		if len(s.precincts) != s.levels+1 {
+			return fmt.Errorf("jpeg2000: 分量 %d 缺少编码参数 (COD/COC)", c)
+		}
+	}
+	return nil
+}
+
+func parseCodingStyle(r *reader, precincts bool) (codingStyle, error) {
+	s := codingStyle{
+		levels:     r.u8(),
+		xcb:        r.u8() + 2,
+		ycb:        r.u8() + 2,
+		cbStyle:    r.u8(),
+		reversible: r.u8() == 1,
+	}
+	if s.levels > 32 || s.xcb > 10 || s.ycb > 10 || s.xcb+s.ycb > 12 {
+		return s, errors.New("jpeg2000: 无效的编码参数")
+	}
+	if s.cbStyle&cbHT != 0 {
+		return s, errors.New("jpeg2000: 不支持 HTJ2K 码块编码")
+	}
+	s.precincts = make([]byte, s.levels+1)
+	for i := range s.precincts {
+		if precincts {
+			s.precincts[i] = byte(r.u8())
+			// 除最低分辨率外分区尺寸指数不能为 0
+			if i > 0 && (s.precincts[i]&0x0F == 0 || s.precincts[i]>>4 == 0) {
+				return s, errors.New("jpeg2000: 无效的分区尺寸")
+			}
+		} else {
+			s.precincts[i] = 0xFF
+		}
+	}
+	return s, r.err
+}
+
+func parseQuantization(r *reader) quantization {
+	sq := r.u8()
+	q := quantization{style: sq & 0x1F, guard: sq >> 5}
+	for r.off < len(r.b) && r.err == nil {
+		var s step
+		if q.style == quantNone {
+			s.exponent = r.u8() >> 3
+		} else {
+			v := r.u16()
+			s.exponent, s.mantissa = v>>11, v&0x7FF
+		}
+		q.steps = append(q.steps, s)
+	}
+	return q
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
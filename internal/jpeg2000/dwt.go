@@ -0,0 +1,105 @@
+package jpeg2000
+
+import "math"
+
+// 9/7 小波提升系数 (T.800 表 F.4)
+const (
+	dwtAlpha = -1.586134342059924
+	dwtBeta  = -0.052980118572961
+	dwtGamma = 0.882911075530934
+	dwtDelta = 0.443506852043971
+	dwtK     = 1.230174104914001
+)
+
+// inverseDWT 在分量系数平面上逐级进行二维小波反变换
+func inverseDWT(tc *tileComp) {
+	stride := tc.x1 - tc.x0
+	reversible := tc.cod.reversible
+	var buf, line []float64
+	for r := 1; r < len(tc.res); r++ {
+		res, low := tc.res[r], tc.res[r-1]
+		w, h := res.x1-res.x0, res.y1-res.y0
+		wl, hl := low.x1-low.x0, low.y1-low.y0
+		if w == 0 || h == 0 {
+			continue
+		}
+		if n := max(w, h); len(buf) < n {
+			buf = make([]float64, n)
+			line = make([]float64, n)
+		}
+		for y := 0; y < h; y++ {
+			row := tc.data[y*stride : y*stride+w]
+			interleave(buf[:w], row[:wl], row[wl:w], res.x0)
+			lift(buf[:w], res.x0, reversible)
+			copy(row, buf[:w])
+		}
+		for x := 0; x < w; x++ {
+			for y := 0; y < h; y++ {
+				line[y] = tc.data[y*stride+x]
+			}
+			interleave(buf[:h], line[:hl], line[hl:h], res.y0)
+			lift(buf[:h], res.y0, reversible)
+			for y := 0; y < h; y++ {
+				tc.data[y*stride+x] = buf[y]
+			}
+		}
+	}
+}
+
+// interleave 按绝对坐标的奇偶将低频和高频系数交错排列
+func interleave(dst, low, high []float64, u0 int) {
+	li, hi := 0, 0
+	for i := range dst {
+		if (u0+i)&1 == 0 {
+			dst[i] = low[li]
+			li++
+		} else {
+			dst[i] = high[hi]
+			hi++
+		}
+	}
+}
+
+// lift 一维提升反变换，边界按对称方式延拓
+func lift(x []float64, u0 int, reversible bool) {
+	n := len(x)
+	if n == 1 {
+		if u0&1 == 1 {
+			x[0] /= 2
+			if reversible {
+				x[0] = math.Trunc(x[0])
+			}
+		}
+		return
+	}
+	at := func(i int) float64 {
+		if i < 0 {
+			i = -i
+		} else if i >= n {
+			i = 2*(n-1) - i
+		}
+		return x[i]
+	}
+	// step 对奇偶性为 parity 的样本加上 f(左邻 + 右邻)
+	step := func(parity int, f func(float64) float64) {
+		for i := (parity - u0) & 1; i < n; i += 2 {
+			x[i] += f(at(i-1) + at(i+1))
+		}
+	}
+	if reversible {
+		step(0, func(s float64) float64 { return -math.Floor((s + 2) / 4) })
+		step(1, func(s float64) float64 { return math.Floor(s / 2) })
+		return
+	}
+	for i := range x {
+		if (u0+i)&1 == 0 {
+			x[i] *= dwtK
+		} else {
+			x[i] /= dwtK
+		}
+	}
+	step(0, func(s float64) float64 { return -dwtDelta * s })
+	step(1, func(s float64) float64 { return -dwtGamma * s })
+	step(0, func(s float64) float64 { return -dwtBeta * s })
+	step(1, func(s float64) float64 { return -dwtAlpha * s })
+}
@@ -0,0 +1,398 @@
+// Package jpeg2000 解码 JPEG 2000 (ISO/IEC 15444-1) 图像，支持 JP2 文件格式及原始 J2K 码流
+//
+// 支持全部进展顺序、POC、可逆 5/3 及不可逆 9/7 小波、分量变换、子采样及 JP2 调色板，
+// 不支持 PPM/PPT 打包包头及 HTJ2K 码块编码。
+package jpeg2000
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+const (
+	jp2Magic = "\x00\x00\x00\x0cjP  \r\n\x87\n"
+	j2kMagic = "\xff\x4f\xff\x51"
+)
+
+// maxPixels 解码图像的像素数上限
+const maxPixels = 1 << 28
+
+// JP2 颜色空间枚举值
+const (
+	csCMYK = 12
+	csSRGB = 16
+	csGray = 17
+	csSYCC = 18
+)
+
+func init() {
+	image.RegisterFormat("jp2", jp2Magic, Decode, DecodeConfig)
+	image.RegisterFormat("j2k", j2kMagic, Decode, DecodeConfig)
+}
+
+// jp2 JP2 文件头中与解码相关的信息
+type jp2 struct {
+	colorSpace int
+	palette    *palette
+	codestream []byte
+}
+
+type palette struct {
+	entries [][]int32 // 每列的条目
+	bits    []int
+	// cmap 每个输出通道对应的分量及调色板列，pcol < 0 表示直接使用分量
+	cmap []struct{ comp, pcol int }
+}
+
+// Decode 解码 JP2 文件或 J2K 码流
+func Decode(r io.Reader) (image.Image, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	info, err := parseFile(b)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := parseCodestream(info.codestream, false)
+	if err != nil {
+		return nil, err
+	}
+	w, h := cs.width-cs.x0, cs.height-cs.y0
+	if int64(w)*int64(h) > maxPixels {
+		return nil, fmt.Errorf("jpeg2000: 图像尺寸 %dx%d 过大", w, h)
+	}
+	planes := cs.decode()
+	return info.image(cs, planes, w, h), nil
+}
+
+// DecodeConfig 返回图像尺寸及颜色模型
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	info, err := parseFile(b)
+	if err != nil {
+		return image.Config{}, err
+	}
+	cs, err := parseCodestream(info.codestream, true)
+	if err != nil {
+		return image.Config{}, err
+	}
+	var model color.Model = color.NRGBAModel
+	switch n := info.channels(cs); {
+	case n == 1:
+		model = color.GrayModel
+	case n == 4 && info.colorSpace == csCMYK:
+		model = color.CMYKModel
+	}
+	return image.Config{ColorModel: model, Width: cs.width - cs.x0, Height: cs.height - cs.y0}, nil
+}
+
+// parseFile 解析 JP2 盒结构，原始码流直接返回
+func parseFile(b []byte) (*jp2, error) {
+	if bytes.HasPrefix(b, []byte(j2kMagic)) {
+		return &jp2{codestream: b}, nil
+	}
+	if !bytes.HasPrefix(b, []byte(jp2Magic)) {
+		return nil, errors.New("jpeg2000: 无法识别的文件格式")
+	}
+	info := &jp2{}
+	err := walkBoxes(b, func(typ string, body []byte) error {
+		switch typ {
+		case "jp2h":
+			return walkBoxes(body, info.header)
+		case "jp2c":
+			if info.codestream == nil {
+				info.codestream = body
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if info.codestream == nil {
+		return nil, errors.New("jpeg2000: 缺少码流盒")
+	}
+	return info, nil
+}
+
+// walkBoxes 依次访问 b 中的盒
+func walkBoxes(b []byte, fn func(typ string, body []byte) error) error {
+	for len(b) >= 8 {
+		length := uint64(binary.BigEndian.Uint32(b))
+		typ := string(b[4:8])
+		head := uint64(8)
+		switch length {
+		case 0:
+			length = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return errTruncated
+			}
+			length, head = binary.BigEndian.Uint64(b[8:]), 16
+		}
+		if length < head || length > uint64(len(b)) {
+			// 码流盒截断时尽量解码已有数据
+			if typ == "jp2c" {
+				length = uint64(len(b))
+			} else {
+				return errTruncated
+			}
+		}
+		if err := fn(typ, b[head:length]); err != nil {
+			return err
+		}
+		b = b[length:]
+	}
+	return nil
+}
+
+// header 解析 jp2h 中的子盒
+func (info *jp2) header(typ string, body []byte) error {
+	switch typ {
+	case "colr":
+		if len(body) >= 7 && body[0] == 1 && info.colorSpace == 0 {
+			info.colorSpace = int(binary.BigEndian.Uint32(body[3:]))
+		}
+	case "pclr":
+		if len(body) < 3 {
+			return errTruncated
+		}
+		n, cols := int(binary.BigEndian.Uint16(body)), int(body[2])
+		if len(body) < 3+cols {
+			return errTruncated
+		}
+		pal := info.ensurePalette()
+		pal.bits = make([]int, cols)
+		size := 0
+		for i := range pal.bits {
+			pal.bits[i] = int(body[3+i]&0x7F) + 1
+			size += (pal.bits[i] + 7) / 8
+		}
+		data := body[3+cols:]
+		if len(data) < n*size {
+			return errTruncated
+		}
+		pal.entries = make([][]int32, cols)
+		for i := range pal.entries {
+			pal.entries[i] = make([]int32, n)
+		}
+		for e := 0; e < n; e++ {
+			for i, bits := range pal.bits {
+				var v int32
+				for k := 0; k < (bits+7)/8; k++ {
+					v = v<<8 | int32(data[0])
+					data = data[1:]
+				}
+				pal.entries[i][e] = v
+			}
+		}
+	case "cmap":
+		pal := info.ensurePalette()
+		for ; len(body) >= 4; body = body[4:] {
+			m := struct{ comp, pcol int }{int(binary.BigEndian.Uint16(body)), int(body[3])}
+			if body[2] == 0 {
+				m.pcol = -1
+			}
+			pal.cmap = append(pal.cmap, m)
+		}
+	}
+	return nil
+}
+
+func (info *jp2) ensurePalette() *palette {
+	if info.palette == nil {
+		info.palette = &palette{}
+	}
+	return info.palette
+}
+
+// channels 返回输出通道数
+func (info *jp2) channels(cs *codestream) int {
+	if info.palette != nil && len(info.palette.cmap) > 0 {
+		return len(info.palette.cmap)
+	}
+	return len(cs.components)
+}
+
+// decode 解码全部分块，返回按分量排列、已做电平平移的样本
+func (cs *codestream) decode() [][]int32 {
+	planes := make([][]int32, len(cs.components))
+	for c, comp := range cs.components {
+		w := ceilDiv(cs.width, comp.dx) - ceilDiv(cs.x0, comp.dx)
+		h := ceilDiv(cs.height, comp.dy) - ceilDiv(cs.y0, comp.dy)
+		planes[c] = make([]int32, w*h)
+	}
+	for index := 0; index < cs.tilesX*cs.tilesY; index++ {
+		td, ok := cs.tiles[index]
+		if !ok {
+			continue
+		}
+		t := cs.newTile(index, td.params)
+		cs.decodePackets(t, td.data)
+		for _, tc := range t.comps {
+			decodeCoefficients(tc)
+			inverseDWT(tc)
+		}
+		if td.params.mct && len(t.comps) >= 3 {
+			inverseMCT(t)
+		}
+		for c, tc := range t.comps {
+			comp := cs.components[c]
+			stride := ceilDiv(cs.width, comp.dx) - ceilDiv(cs.x0, comp.dx)
+			ox, oy := ceilDiv(cs.x0, comp.dx), ceilDiv(cs.y0, comp.dy)
+			shift := 0.0
+			if !comp.signed {
+				shift = float64(int64(1) << (comp.precision - 1))
+			}
+			lo, hi := 0.0, float64(int64(1)<<comp.precision-1)
+			if comp.signed {
+				lo, hi = -float64(int64(1)<<(comp.precision-1)), float64(int64(1)<<(comp.precision-1)-1)
+			}
+			w := tc.x1 - tc.x0
+			for y := tc.y0; y < tc.y1; y++ {
+				row := (y-oy)*stride - ox
+				for x := tc.x0; x < tc.x1; x++ {
+					v := math.Round(tc.data[(y-tc.y0)*w+x-tc.x0] + shift)
+					planes[c][row+x] = int32(min(max(v, lo), hi))
+				}
+			}
+		}
+	}
+	return planes
+}
+
+// inverseMCT 分量反变换，可逆时使用 RCT，否则使用 ICT
+func inverseMCT(t *tile) {
+	a, b, c := t.comps[0], t.comps[1], t.comps[2]
+	if len(a.data) != len(b.data) || len(a.data) != len(c.data) {
+		return
+	}
+	if a.cod.reversible {
+		for i := range a.data {
+			y0, y1, y2 := a.data[i], b.data[i], c.data[i]
+			g := y0 - math.Floor((y1+y2)/4)
+			a.data[i], b.data[i], c.data[i] = y2+g, g, y1+g
+		}
+		return
+	}
+	for i := range a.data {
+		y, cb, cr := a.data[i], b.data[i], c.data[i]
+		a.data[i] = y + 1.402*cr
+		b.data[i] = y - 0.34413*cb - 0.71414*cr
+		c.data[i] = y + 1.772*cb
+	}
+}
+
+// image 按 JP2 头信息将分量样本组合为图像
+func (info *jp2) image(cs *codestream, planes [][]int32, w, h int) image.Image {
+	type channel struct {
+		sample func(x, y int) int32
+		bits   int
+	}
+	at := func(c int) func(x, y int) int32 {
+		comp := cs.components[c]
+		stride := ceilDiv(cs.width, comp.dx) - ceilDiv(cs.x0, comp.dx)
+		ox, oy := ceilDiv(cs.x0, comp.dx), ceilDiv(cs.y0, comp.dy)
+		plane := planes[c]
+		return func(x, y int) int32 {
+			return plane[((cs.y0+y)/comp.dy-oy)*stride+(cs.x0+x)/comp.dx-ox]
+		}
+	}
+	var channels []channel
+	if pal := info.palette; pal != nil && len(pal.cmap) > 0 {
+		for _, m := range pal.cmap {
+			if m.comp >= len(planes) {
+				continue
+			}
+			sample := at(m.comp)
+			if m.pcol < 0 || m.pcol >= len(pal.entries) {
+				channels = append(channels, channel{sample, cs.components[m.comp].precision})
+				continue
+			}
+			entries := pal.entries[m.pcol]
+			channels = append(channels, channel{func(x, y int) int32 {
+				i := int(sample(x, y))
+				return entries[min(max(i, 0), len(entries)-1)]
+			}, pal.bits[m.pcol]})
+		}
+	} else {
+		for c, comp := range cs.components {
+			bits := comp.precision
+			if comp.signed {
+				// 有符号分量平移到无符号范围显示
+				s := at(c)
+				half := int32(1) << (bits - 1)
+				channels = append(channels, channel{func(x, y int) int32 { return s(x, y) + half }, bits})
+				continue
+			}
+			channels = append(channels, channel{at(c), bits})
+		}
+	}
+	if len(channels) == 0 {
+		return image.NewGray(image.Rect(0, 0, w, h))
+	}
+
+	to8 := func(ch channel, x, y int) uint8 {
+		v := int64(ch.sample(x, y))
+		if ch.bits == 8 {
+			return uint8(v)
+		}
+		return uint8(v * 255 / (int64(1)<<ch.bits - 1))
+	}
+	switch {
+	case len(channels) < 3:
+		if len(channels) == 1 {
+			img := image.NewGray(image.Rect(0, 0, w, h))
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					img.Pix[y*img.Stride+x] = to8(channels[0], x, y)
+				}
+			}
+			return img
+		}
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				g := to8(channels[0], x, y)
+				img.SetNRGBA(x, y, color.NRGBA{g, g, g, to8(channels[1], x, y)})
+			}
+		}
+		return img
+	case len(channels) == 4 && info.colorSpace == csCMYK:
+		img := image.NewCMYK(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i := img.PixOffset(x, y)
+				for k := 0; k < 4; k++ {
+					img.Pix[i+k] = to8(channels[k], x, y)
+				}
+			}
+		}
+		return img
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b := to8(channels[0], x, y), to8(channels[1], x, y), to8(channels[2], x, y)
+			if info.colorSpace == csSYCC {
+				r, g, b = color.YCbCrToRGB(r, g, b)
+			}
+			a := uint8(255)
+			if len(channels) > 3 {
+				a = to8(channels[3], x, y)
+			}
+			img.SetNRGBA(x, y, color.NRGBA{r, g, b, a})
+		}
+	}
+	return img
+}
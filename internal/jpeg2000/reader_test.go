@@ -0,0 +1,132 @@
+package jpeg2000
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+	data, err := os.ReadFile("testdata/relax.jp2")
+	assert.Nil(t, err)
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, "jp2", format)
+	assert.Equal(t, 400, cfg.Width)
+	assert.Equal(t, 300, cfg.Height)
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	assert.Nil(t, err)
+	assert.Equal(t, "jp2", format)
+	assert.Equal(t, image.Rect(0, 0, 400, 300), img.Bounds())
+
+	near := func(x, y int, want color.NRGBA) {
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		for i, d := range []int{int(c.R) - int(want.R), int(c.G) - int(want.G), int(c.B) - int(want.B)} {
+			assert.LessOrEqual(t, d*d, 16, "(%d, %d) 通道 %d: %v", x, y, i, c)
+		}
+	}
+	// 白色文字、苔藓及水流
+	near(60, 260, color.NRGBA{245, 249, 253, 255})
+	near(20, 30, color.NRGBA{41, 71, 19, 255})
+	near(170, 180, color.NRGBA{137, 165, 187, 255})
+
+	// 原始码流与 JP2 文件解码结果一致
+	info, err := parseFile(data)
+	assert.Nil(t, err)
+	raw, format, err := image.Decode(bytes.NewReader(info.codestream))
+	assert.Nil(t, err)
+	assert.Equal(t, "j2k", format)
+	assert.Equal(t, img.At(300, 200), raw.At(300, 200))
+}
+
+func TestDecode_Truncated(t *testing.T) {
+	data, err := os.ReadFile("testdata/relax.jp2")
+	assert.Nil(t, err)
+	// 码流截断时解码已有的包，头部截断时返回错误
+	img, err := Decode(bytes.NewReader(data[:len(data)/2]))
+	assert.Nil(t, err)
+	assert.Equal(t, 400, img.Bounds().Dx())
+	_, err = Decode(bytes.NewReader(data[:60]))
+	assert.NotNil(t, err)
+}
+
+func TestIDWT53(t *testing.T) {
+	// 5/3 可逆变换：正变换后反变换还原原始样本
+	for _, u0 := range []int{0, 1} {
+		x := []float64{3, 7, 1, 8, 2, 9, 4}
+		n := len(x)
+		y := append([]float64(nil), x...)
+		at := func(i int) float64 {
+			if i < 0 {
+				i = -i
+			} else if i >= n {
+				i = 2*(n-1) - i
+			}
+			return y[i]
+		}
+		for i := (1 - u0) & 1; i < n; i += 2 {
+			y[i] -= math.Floor((at(i-1) + at(i+1)) / 2)
+		}
+		for i := u0 & 1; i < n; i += 2 {
+			y[i] += math.Floor((at(i-1) + at(i+1) + 2) / 4)
+		}
+		var low, high []float64
+		for i, v := range y {
+			if (u0+i)&1 == 0 {
+				low = append(low, v)
+			} else {
+				high = append(high, v)
+			}
+		}
+		got := make([]float64, n)
+		interleave(got, low, high, u0)
+		lift(got, u0, true)
+		assert.Equal(t, x, got, "u0=%d", u0)
+	}
+}
+
+func TestDecode_Corrupt(t *testing.T) {
+	data, err := os.ReadFile("testdata/relax.jp2")
+	assert.Nil(t, err)
+	i := bytes.Index(data, []byte{0xFF, 0x52, 0x00, 0x0C})
+	assert.Greater(t, i, 0)
+
+	// 缺少 COD 时各分量没有分区尺寸，返回错误而不是 panic
+	b := bytes.Clone(data)
+	b[i+1] = 0x64 // COD 改为 COM
+	_, err = Decode(bytes.NewReader(b))
+	assert.ErrorContains(t, err, "COD")
+
+	// 指定了分区尺寸但 COD 中没有分区尺寸
+	b = bytes.Clone(data)
+	b[i+4] |= 0x01
+	_, err = Decode(bytes.NewReader(b))
+	assert.NotNil(t, err)
+}
+
+func FuzzDecode(f *testing.F) {
+	data, err := os.ReadFile("testdata/relax.jp2")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+	info, err := parseFile(data)
+	if err != nil {
+		f.Fatal(err)
+	}
+	// 只保留头部及第一个分块的开头，缩短每次解码的时间
+	f.Add(info.codestream[:min(len(info.codestream), 2048)])
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if cfg, err := DecodeConfig(bytes.NewReader(b)); err != nil || int64(cfg.Width)*int64(cfg.Height) > 1<<20 {
+			return
+		}
+		_, _ = Decode(bytes.NewReader(b))
+	})
+}
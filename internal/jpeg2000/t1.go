@@ -0,0 +1,512 @@
+package jpeg2000
+
+// qeEntry MQ 编码器概率状态
+type qeEntry struct {
+	qe         uint32
+	nmps, nlps uint8
+	switchMPS  bool
+}
+
+var qeTable = [47]qeEntry{
+	{0x5601, 1, 1, true}, {0x3401, 2, 6, false}, {0x1801, 3, 9, false},
+	{0x0AC1, 4, 12, false}, {0x0521, 5, 29, false}, {0x0221, 38, 33, false},
+	{0x5601, 7, 6, true}, {0x5401, 8, 14, false}, {0x4801, 9, 14, false},
+	{0x3801, 10, 14, false}, {0x3001, 11, 17, false}, {0x2401, 12, 18, false},
+	{0x1C01, 13, 20, false}, {0x1601, 29, 21, false}, {0x5601, 15, 14, true},
+	{0x5401, 16, 14, false}, {0x5101, 17, 15, false}, {0x4801, 18, 16, false},
+	{0x3801, 19, 17, false}, {0x3401, 20, 18, false}, {0x3001, 21, 19, false},
+	{0x2801, 22, 19, false}, {0x2401, 23, 20, false}, {0x2201, 24, 21, false},
+	{0x1C01, 25, 22, false}, {0x1801, 26, 23, false}, {0x1601, 27, 24, false},
+	{0x1401, 28, 25, false}, {0x1201, 29, 26, false}, {0x1101, 30, 27, false},
+	{0x0AC1, 31, 28, false}, {0x09C1, 32, 29, false}, {0x08A1, 33, 30, false},
+	{0x0521, 34, 31, false}, {0x0441, 35, 32, false}, {0x02A1, 36, 33, false},
+	{0x0221, 37, 34, false}, {0x0141, 38, 35, false}, {0x0111, 39, 36, false},
+	{0x0085, 40, 37, false}, {0x0049, 41, 38, false}, {0x0025, 42, 39, false},
+	{0x0015, 43, 40, false}, {0x0009, 44, 41, false}, {0x0005, 45, 42, false},
+	{0x0001, 45, 43, false}, {0x5601, 46, 46, false},
+}
+
+// 上下文编号
+const (
+	ctxRL  = 17
+	ctxUNI = 18
+)
+
+// mqContext 上下文状态，低 7 位为状态序号，最高位为 MPS
+type mqContexts [19]uint8
+
+func (c *mqContexts) reset() {
+	*c = mqContexts{}
+	c[0] = 4
+	c[ctxRL] = 3
+	c[ctxUNI] = 46
+}
+
+// mqDecoder MQ 算术解码器 (T.800 附录 C)
+type mqDecoder struct {
+	data []byte
+	bp   int
+	a, c uint32
+	ct   int
+}
+
+func (d *mqDecoder) byteAt(i int) uint32 {
+	if i < len(d.data) {
+		return uint32(d.data[i])
+	}
+	return 0xFF
+}
+
+func (d *mqDecoder) init(data []byte) {
+	d.data = data
+	d.bp = 0
+	d.c = d.byteAt(0) << 16
+	d.byteIn()
+	d.c <<= 7
+	d.ct -= 7
+	d.a = 0x8000
+}
+
+func (d *mqDecoder) byteIn() {
+	if d.byteAt(d.bp) == 0xFF {
+		if d.byteAt(d.bp+1) > 0x8F {
+			d.c += 0xFF00
+			d.ct = 8
+		} else {
+			d.bp++
+			d.c += d.byteAt(d.bp) << 9
+			d.ct = 7
+		}
+	} else {
+		d.bp++
+		d.c += d.byteAt(d.bp) << 8
+		d.ct = 8
+	}
+}
+
+func (d *mqDecoder) decode(cx *uint8) int {
+	state := &qeTable[*cx&0x7F]
+	mps := int(*cx >> 7)
+	qe := state.qe
+	d.a -= qe
+	var bit int
+	if d.c>>16 < qe {
+		// LPS 交换
+		if d.a < qe {
+			bit = mps
+			*cx = state.nmps | uint8(mps<<7)
+		} else {
+			bit = 1 - mps
+			if state.switchMPS {
+				mps = 1 - mps
+			}
+			*cx = state.nlps | uint8(mps<<7)
+		}
+		d.a = qe
+	} else {
+		d.c -= qe << 16
+		if d.a&0x8000 != 0 {
+			return mps
+		}
+		// MPS 交换
+		if d.a < qe {
+			bit = 1 - mps
+			if state.switchMPS {
+				mps = 1 - mps
+			}
+			*cx = state.nlps | uint8(mps<<7)
+		} else {
+			bit = mps
+			*cx = state.nmps | uint8(mps<<7)
+		}
+	}
+	for d.a&0x8000 == 0 {
+		if d.ct == 0 {
+			d.byteIn()
+		}
+		d.a <<= 1
+		d.c <<= 1
+		d.ct--
+	}
+	return bit
+}
+
+// rawDecoder 旁路模式下的原始比特读取，0xFF 之后的字节只有 7 位有效
+type rawDecoder struct {
+	data []byte
+	bp   int
+	c    uint32
+	ct   int
+}
+
+func (d *rawDecoder) init(data []byte) {
+	*d = rawDecoder{data: data}
+}
+
+func (d *rawDecoder) decode() int {
+	if d.ct == 0 {
+		if d.c == 0xFF {
+			if d.bp < len(d.data) {
+				d.c = uint32(d.data[d.bp])
+				d.bp++
+			} else {
+				d.c = 0xFF
+			}
+			if d.c > 0x8F {
+				// 标记码，填充 1
+				d.c = 0xFF
+				d.ct = 8
+			} else {
+				d.ct = 7
+			}
+		} else {
+			if d.bp < len(d.data) {
+				d.c = uint32(d.data[d.bp])
+				d.bp++
+			} else {
+				d.c = 0xFF
+			}
+			d.ct = 8
+		}
+	}
+	d.ct--
+	return int(d.c>>uint(d.ct)) & 1
+}
+
+// 系数状态
+const (
+	flagSig     = 1 << iota // 已重要
+	flagNeg                 // 符号为负
+	flagVisit               // 当前位平面的重要性传播通道已编码
+	flagRefined             // 已细化过
+)
+
+// 子带方向
+const (
+	bandLL = iota
+	bandHL
+	bandLH
+	bandHH
+)
+
+// t1 码块解码状态
+type t1 struct {
+	w, h   int
+	flags  []uint8 // (w+2)*(h+2)，四周留空
+	mag    []int32
+	orient int
+	causal bool
+	ctx    mqContexts
+	mq     mqDecoder
+	raw    rawDecoder
+}
+
+func (t *t1) flag(x, y int) uint8 {
+	return t.flags[(y+1)*(t.w+2)+x+1]
+}
+
+// neighbors 返回水平、垂直、对角重要邻居数，垂直因果模式下忽略下一条带
+func (t *t1) neighbors(x, y int) (h, v, d int) {
+	stride := t.w + 2
+	i := (y+1)*stride + x + 1
+	f := t.flags
+	sig := func(j int) int { return int(f[j] & flagSig) }
+	h = sig(i-1) + sig(i+1)
+	v = sig(i - stride)
+	d = sig(i-stride-1) + sig(i-stride+1)
+	if !(t.causal && y%4 == 3) {
+		v += sig(i + stride)
+		d += sig(i+stride-1) + sig(i+stride+1)
+	}
+	return
+}
+
+// zcContext 零编码上下文 (表 D.1)
+func (t *t1) zcContext(x, y int) int {
+	h, v, d := t.neighbors(x, y)
+	switch t.orient {
+	case bandHL:
+		h, v = v, h
+		fallthrough
+	case bandLL, bandLH:
+		switch {
+		case h == 2:
+			return 8
+		case h == 1:
+			if v >= 1 {
+				return 7
+			}
+			if d >= 1 {
+				return 6
+			}
+			return 5
+		case v == 2:
+			return 4
+		case v == 1:
+			return 3
+		case d >= 2:
+			return 2
+		case d == 1:
+			return 1
+		}
+		return 0
+	}
+	hv := h + v
+	switch {
+	case d >= 3:
+		return 8
+	case d == 2:
+		if hv >= 1 {
+			return 7
+		}
+		return 6
+	case d == 1:
+		if hv >= 2 {
+			return 5
+		}
+		if hv == 1 {
+			return 4
+		}
+		return 3
+	case hv >= 2:
+		return 2
+	case hv == 1:
+		return 1
+	}
+	return 0
+}
+
+// signContext 符号编码上下文及异或位 (表 D.3)
+func (t *t1) signContext(x, y int) (int, int) {
+	stride := t.w + 2
+	i := (y+1)*stride + x + 1
+	contrib := func(j int) int {
+		f := t.flags[j]
+		if f&flagSig == 0 {
+			return 0
+		}
+		if f&flagNeg != 0 {
+			return -1
+		}
+		return 1
+	}
+	h := contrib(i-1) + contrib(i+1)
+	v := contrib(i - stride)
+	if !(t.causal && y%4 == 3) {
+		v += contrib(i + stride)
+	}
+	h, v = max(-1, min(h, 1)), max(-1, min(v, 1))
+	xor := 0
+	if h < 0 || (h == 0 && v < 0) {
+		h, v, xor = -h, -v, 1
+	}
+	switch {
+	case h == 1 && v == 1:
+		return 13, xor
+	case h == 1 && v == 0:
+		return 12, xor
+	case h == 1:
+		return 11, xor
+	case v == 1:
+		return 10, xor
+	}
+	return 9, xor
+}
+
+func (t *t1) setSig(x, y, neg int, bit int32) {
+	i := (y+1)*(t.w+2) + x + 1
+	t.flags[i] |= flagSig
+	if neg != 0 {
+		t.flags[i] |= flagNeg
+	}
+	t.mag[y*t.w+x] |= bit
+}
+
+// decodeSign 解码符号位
+func (t *t1) decodeSign(x, y int, raw bool) int {
+	if raw {
+		return t.raw.decode()
+	}
+	cx, xor := t.signContext(x, y)
+	return t.mq.decode(&t.ctx[cx]) ^ xor
+}
+
+// sigPass 重要性传播通道
+func (t *t1) sigPass(p int, raw bool) {
+	bit := int32(1) << p
+	for y0 := 0; y0 < t.h; y0 += 4 {
+		for x := 0; x < t.w; x++ {
+			for y := y0; y < y0+4 && y < t.h; y++ {
+				i := (y+1)*(t.w+2) + x + 1
+				if t.flags[i]&flagSig != 0 {
+					continue
+				}
+				cx := t.zcContext(x, y)
+				if cx == 0 {
+					continue
+				}
+				var b int
+				if raw {
+					b = t.raw.decode()
+				} else {
+					b = t.mq.decode(&t.ctx[cx])
+				}
+				t.flags[i] |= flagVisit
+				if b != 0 {
+					t.setSig(x, y, t.decodeSign(x, y, raw), bit)
+				}
+			}
+		}
+	}
+}
+
+// refPass 幅值细化通道
+func (t *t1) refPass(p int, raw bool) {
+	bit := int32(1) << p
+	for y0 := 0; y0 < t.h; y0 += 4 {
+		for x := 0; x < t.w; x++ {
+			for y := y0; y < y0+4 && y < t.h; y++ {
+				i := (y+1)*(t.w+2) + x + 1
+				f := t.flags[i]
+				if f&flagSig == 0 || f&flagVisit != 0 {
+					continue
+				}
+				var b int
+				if raw {
+					b = t.raw.decode()
+				} else {
+					cx := 16
+					if f&flagRefined == 0 {
+						cx = 14
+						if h, v, d := t.neighbors(x, y); h+v+d > 0 {
+							cx = 15
+						}
+					}
+					b = t.mq.decode(&t.ctx[cx])
+				}
+				if b != 0 {
+					t.mag[y*t.w+x] |= bit
+				}
+				t.flags[i] |= flagRefined
+			}
+		}
+	}
+}
+
+// cleanupPass 清理通道，结束后清除 flagVisit
+func (t *t1) cleanupPass(p int, segSymbol bool) {
+	bit := int32(1) << p
+	stride := t.w + 2
+	for y0 := 0; y0 < t.h; y0 += 4 {
+		for x := 0; x < t.w; x++ {
+			y := y0
+			if y0+4 <= t.h {
+				run := true
+				for k := 0; k < 4 && run; k++ {
+					f := t.flags[(y0+k+1)*stride+x+1]
+					if f&(flagSig|flagVisit) != 0 || t.zcContext(x, y0+k) != 0 {
+						run = false
+					}
+				}
+				if run {
+					if t.mq.decode(&t.ctx[ctxRL]) == 0 {
+						continue
+					}
+					k := t.mq.decode(&t.ctx[ctxUNI]) << 1
+					k |= t.mq.decode(&t.ctx[ctxUNI])
+					y = y0 + k
+					t.setSig(x, y, t.decodeSign(x, y, false), bit)
+					y++
+				}
+			}
+			for ; y < y0+4 && y < t.h; y++ {
+				i := (y+1)*stride + x + 1
+				if t.flags[i]&(flagSig|flagVisit) != 0 {
+					continue
+				}
+				if t.mq.decode(&t.ctx[t.zcContext(x, y)]) != 0 {
+					t.setSig(x, y, t.decodeSign(x, y, false), bit)
+				}
+			}
+		}
+	}
+	for i := range t.flags {
+		t.flags[i] &^= flagVisit
+	}
+	if segSymbol {
+		for k := 0; k < 4; k++ {
+			t.mq.decode(&t.ctx[ctxUNI])
+		}
+	}
+}
+
+// decodeBlock 解码码块，返回带符号的量化值 (已加入未解码位平面的中点)
+func decodeBlock(cb *codeblock, orient, mb, cbStyle int) []int32 {
+	w, h := cb.x1-cb.x0, cb.y1-cb.y0
+	t := &t1{
+		w: w, h: h,
+		flags:  make([]uint8, (w+2)*(h+2)),
+		mag:    make([]int32, w*h),
+		orient: orient,
+		causal: cbStyle&cbCausal != 0,
+	}
+	t.ctx.reset()
+
+	planes := mb - cb.zeroPlanes
+	pass, lowest := 0, planes
+	for _, seg := range cb.segs {
+		if len(seg.data) == 0 && seg.passes == 0 {
+			continue
+		}
+		first := true
+		for k := 0; k < seg.passes; k++ {
+			p := planes - 1 - (pass+2)/3
+			if p < 0 || p > 30 {
+				break
+			}
+			kind := (pass + 2) % 3 // 0 重要性传播，1 幅值细化，2 清理
+			raw := cbStyle&cbBypass != 0 && pass >= 10 && kind != 2
+			if first {
+				if raw {
+					t.raw.init(seg.data)
+				} else {
+					t.mq.init(seg.data)
+				}
+				first = false
+			}
+			switch kind {
+			case 0:
+				t.sigPass(p, raw)
+			case 1:
+				t.refPass(p, raw)
+			default:
+				t.cleanupPass(p, cbStyle&cbSegSymbol != 0)
+			}
+			if cbStyle&cbReset != 0 {
+				t.ctx.reset()
+			}
+			lowest = p
+			pass++
+		}
+	}
+
+	out := make([]int32, w*h)
+	half := int32(0)
+	if lowest > 0 {
+		half = 1 << (lowest - 1)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			m := t.mag[y*w+x]
+			if m == 0 {
+				continue
+			}
+			m |= half
+			if t.flag(x, y)&flagNeg != 0 {
+				m = -m
+			}
+			out[y*w+x] = m
+		}
+	}
+	return out
+}
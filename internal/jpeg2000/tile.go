@@ -0,0 +1,642 @@
+package jpeg2000
+
+import (
+	"math"
+)
+
+// segment 码块的码字段，跨层累积
+type segment struct {
+	data      []byte
+	passes    int
+	maxPasses int
+}
+
+type codeblock struct {
+	x0, y0, x1, y1 int
+	included       bool
+	lblock         int
+	zeroPlanes     int
+	segs           []*segment
+}
+
+// precinctBand 分区在某个子带中的码块及标签树
+type precinctBand struct {
+	cw, ch int
+	blocks []*codeblock
+	incl   *tagTree
+	zbp    *tagTree
+}
+
+type precinct struct {
+	bands []precinctBand
+	// layers 已解码的层数
+	layers int
+}
+
+type subband struct {
+	orient         int
+	x0, y0, x1, y1 int
+	mb             int
+	step           float64
+	// ox, oy 在分量系数平面中的偏移
+	ox, oy int
+}
+
+type resolution struct {
+	x0, y0, x1, y1 int
+	ppx, ppy       int
+	pw, ph         int
+	bands          []*subband
+	precincts      []*precinct
+}
+
+type tileComp struct {
+	x0, y0, x1, y1 int
+	cod            *codingStyle
+	qcd            *quantization
+	res            []*resolution
+	data           []float64
+}
+
+type tile struct {
+	x0, y0, x1, y1 int
+	params         *params
+	comps          []*tileComp
+}
+
+// ceilShift 返回 ceil(a / 2^n)，a 可以为负
+func ceilShift(a, n int) int {
+	return -((-a) >> uint(n))
+}
+
+// newTile 计算分块、分量、分辨率、子带、分区及码块的几何信息
+func (cs *codestream) newTile(index int, params *params) *tile {
+	p, q := index%cs.tilesX, index/cs.tilesX
+	t := &tile{
+		x0:     max(cs.tileX0+p*cs.tileW, cs.x0),
+		y0:     max(cs.tileY0+q*cs.tileH, cs.y0),
+		x1:     min(cs.tileX0+(p+1)*cs.tileW, cs.width),
+		y1:     min(cs.tileY0+(q+1)*cs.tileH, cs.height),
+		params: params,
+	}
+	for c, comp := range cs.components {
+		tc := &tileComp{
+			x0:  ceilDiv(t.x0, comp.dx),
+			y0:  ceilDiv(t.y0, comp.dy),
+			x1:  ceilDiv(t.x1, comp.dx),
+			y1:  ceilDiv(t.y1, comp.dy),
+			cod: &params.cod[c],
+			qcd: &params.qcd[c],
+		}
+		tc.data = make([]float64, (tc.x1-tc.x0)*(tc.y1-tc.y0))
+		nl := tc.cod.levels
+		for r := 0; r <= nl; r++ {
+			res := &resolution{
+				x0: ceilShift(tc.x0, nl-r),
+				y0: ceilShift(tc.y0, nl-r),
+				x1: ceilShift(tc.x1, nl-r),
+				y1: ceilShift(tc.y1, nl-r),
+			}
+			pp := tc.cod.precincts[r]
+			res.ppx, res.ppy = int(pp&0x0F), int(pp>>4)
+			if res.x1 > res.x0 {
+				res.pw = ceilShift(res.x1, res.ppx) - res.x0>>res.ppx
+			}
+			if res.y1 > res.y0 {
+				res.ph = ceilShift(res.y1, res.ppy) - res.y0>>res.ppy
+			}
+
+			if r == 0 {
+				res.bands = []*subband{{orient: bandLL, x0: res.x0, y0: res.y0, x1: res.x1, y1: res.y1}}
+			} else {
+				nb := nl - r + 1
+				low := tc.res[r-1]
+				wl, hl := low.x1-low.x0, low.y1-low.y0
+				for _, orient := range []int{bandHL, bandLH, bandHH} {
+					xo, yo := orient&1, orient>>1
+					b := &subband{
+						orient: orient,
+						x0:     ceilShift(tc.x0-(xo<<(nb-1)), nb),
+						y0:     ceilShift(tc.y0-(yo<<(nb-1)), nb),
+						x1:     ceilShift(tc.x1-(xo<<(nb-1)), nb),
+						y1:     ceilShift(tc.y1-(yo<<(nb-1)), nb),
+						ox:     xo * wl,
+						oy:     yo * hl,
+					}
+					res.bands = append(res.bands, b)
+				}
+			}
+			for i, b := range res.bands {
+				cs.quantize(tc, b, c, r, i)
+			}
+			tc.res = append(tc.res, res)
+			cs.precincts(tc, res, r)
+		}
+		t.comps = append(t.comps, tc)
+	}
+	return t
+}
+
+// quantize 计算子带的幅值位平面数及反量化步长
+func (cs *codestream) quantize(tc *tileComp, b *subband, c, r, i int) {
+	q := tc.qcd
+	nl := tc.cod.levels
+	idx := 0
+	if r > 0 {
+		idx = 1 + 3*(r-1) + i
+	}
+	var s step
+	switch {
+	case q.style == quantDerived && len(q.steps) > 0:
+		nb := nl
+		if r > 0 {
+			nb = nl - r + 1
+		}
+		s = step{exponent: q.steps[0].exponent - nl + nb, mantissa: q.steps[0].mantissa}
+	case idx < len(q.steps):
+		s = q.steps[idx]
+	case len(q.steps) > 0:
+		s = q.steps[len(q.steps)-1]
+	}
+	b.mb = q.guard + s.exponent - 1 + q.roiShift
+	b.step = 1
+	if !tc.cod.reversible {
+		gain := [4]int{0, 1, 1, 2}[b.orient]
+		rb := cs.components[c].precision + gain
+		b.step = math.Ldexp(1+float64(s.mantissa)/2048, rb-s.exponent)
+	}
+}
+
+// precincts 划分分区及码块
+func (cs *codestream) precincts(tc *tileComp, res *resolution, r int) {
+	cod := tc.cod
+	ppx, ppy := res.ppx, res.ppy
+	if r > 0 {
+		ppx, ppy = ppx-1, ppy-1
+	}
+	xcb, ycb := min(cod.xcb, ppx), min(cod.ycb, ppy)
+	px0, py0 := res.x0>>res.ppx, res.y0>>res.ppy
+	for j := 0; j < res.ph; j++ {
+		for i := 0; i < res.pw; i++ {
+			prc := &precinct{}
+			for _, b := range res.bands {
+				bx0 := max((px0+i)<<ppx, b.x0)
+				by0 := max((py0+j)<<ppy, b.y0)
+				bx1 := min((px0+i+1)<<ppx, b.x1)
+				by1 := min((py0+j+1)<<ppy, b.y1)
+				var pb precinctBand
+				if bx1 > bx0 && by1 > by0 {
+					cx0, cy0 := bx0>>xcb, by0>>ycb
+					pb.cw = ceilShift(bx1, xcb) - cx0
+					pb.ch = ceilShift(by1, ycb) - cy0
+					for y := 0; y < pb.ch; y++ {
+						for x := 0; x < pb.cw; x++ {
+							pb.blocks = append(pb.blocks, &codeblock{
+								x0:     max((cx0+x)<<xcb, b.x0),
+								y0:     max((cy0+y)<<ycb, b.y0),
+								x1:     min((cx0+x+1)<<xcb, b.x1),
+								y1:     min((cy0+y+1)<<ycb, b.y1),
+								lblock: 3,
+							})
+						}
+					}
+					pb.incl = newTagTree(pb.cw, pb.ch)
+					pb.zbp = newTagTree(pb.cw, pb.ch)
+				}
+				prc.bands = append(prc.bands, pb)
+			}
+			res.precincts = append(res.precincts, prc)
+		}
+	}
+}
+
+// tagTree 标签树 (T.800 B.10.2)
+type tagTree struct {
+	nodes []tagNode
+	leafs int
+}
+
+type tagNode struct {
+	parent int
+	value  int
+	low    int
+}
+
+func newTagTree(w, h int) *tagTree {
+	t := &tagTree{leafs: w * h}
+	type level struct{ w, h, off int }
+	var levels []level
+	for {
+		levels = append(levels, level{w, h, len(t.nodes)})
+		for i := 0; i < w*h; i++ {
+			t.nodes = append(t.nodes, tagNode{parent: -1, value: math.MaxInt32})
+		}
+		if w <= 1 && h <= 1 {
+			break
+		}
+		w, h = (w+1)/2, (h+1)/2
+	}
+	for l := 0; l+1 < len(levels); l++ {
+		cur, next := levels[l], levels[l+1]
+		for y := 0; y < cur.h; y++ {
+			for x := 0; x < cur.w; x++ {
+				t.nodes[cur.off+y*cur.w+x].parent = next.off + (y/2)*next.w + x/2
+			}
+		}
+	}
+	return t
+}
+
+// decode 解码叶节点直到确定其值是否小于 threshold
+func (t *tagTree) decode(br *bitReader, leaf, threshold int) bool {
+	var stack [32]int
+	n := 0
+	for i := leaf; i >= 0 && n < len(stack); i = t.nodes[i].parent {
+		stack[n] = i
+		n++
+	}
+	low := 0
+	for k := n - 1; k >= 0; k-- {
+		node := &t.nodes[stack[k]]
+		if low > node.low {
+			node.low = low
+		} else {
+			low = node.low
+		}
+		for low < threshold && low < node.value {
+			if br.err {
+				return false
+			}
+			if br.bit() == 1 {
+				node.value = low
+			} else {
+				low++
+			}
+		}
+		node.low = low
+	}
+	return t.nodes[leaf].value < threshold
+}
+
+// bitReader 包头比特读取，0xFF 之后的字节只有 7 位有效
+type bitReader struct {
+	data   []byte
+	pos    int
+	cur    byte
+	bits   int
+	lastFF bool
+	err    bool
+}
+
+func (b *bitReader) bit() int {
+	if b.bits == 0 {
+		if b.pos >= len(b.data) {
+			b.err = true
+			return 0
+		}
+		b.cur = b.data[b.pos]
+		b.pos++
+		b.bits = 8
+		if b.lastFF {
+			b.bits = 7
+		}
+		b.lastFF = b.cur == 0xFF
+	}
+	b.bits--
+	return int(b.cur>>uint(b.bits)) & 1
+}
+
+func (b *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		v = v<<1 | b.bit()
+	}
+	return v
+}
+
+// align 包头结束，最后一个字节为 0xFF 时跳过填充字节
+func (b *bitReader) align() {
+	b.bits = 0
+	if b.lastFF {
+		b.pos++
+		b.lastFF = false
+	}
+}
+
+// passes 解码新增编码通道数 (表 B.4)
+func (b *bitReader) passes() int {
+	if b.bit() == 0 {
+		return 1
+	}
+	if b.bit() == 0 {
+		return 2
+	}
+	if v := b.read(2); v != 3 {
+		return 3 + v
+	}
+	if v := b.read(5); v != 31 {
+		return 6 + v
+	}
+	return 37 + b.read(7)
+}
+
+// maxPasses 返回新码字段可包含的编码通道数
+func maxPasses(cbStyle int, prev *segment) int {
+	switch {
+	case cbStyle&cbTermAll != 0:
+		return 1
+	case cbStyle&cbBypass != 0:
+		if prev == nil {
+			return 10
+		}
+		if prev.maxPasses == 1 || prev.maxPasses == 10 {
+			return 2
+		}
+		return 1
+	}
+	return math.MaxInt32
+}
+
+type packetPiece struct {
+	seg    *segment
+	length int
+}
+
+// readPacket 从 off 处读取一个包，返回下一个包的位置
+func readPacket(data []byte, off int, params *params, tc *tileComp, res *resolution, prc *precinct, layer int) (int, bool) {
+	if params.sop && off+6 <= len(data) && data[off] == 0xFF && data[off+1] == 0x91 {
+		off += 6
+	}
+	if off >= len(data) {
+		return off, false
+	}
+	br := &bitReader{data: data[off:]}
+	var pieces []packetPiece
+	if br.bit() == 1 {
+		cbStyle := tc.cod.cbStyle
+		for bi := range prc.bands {
+			pb := &prc.bands[bi]
+			for i, cb := range pb.blocks {
+				var included bool
+				if cb.included {
+					included = br.bit() == 1
+				} else {
+					included = pb.incl.decode(br, i, layer+1)
+				}
+				if !included {
+					continue
+				}
+				if !cb.included {
+					zbp := 0
+					for !pb.zbp.decode(br, i, zbp+1) && !br.err && zbp < 64 {
+						zbp++
+					}
+					cb.zeroPlanes = zbp
+					cb.included = true
+				}
+				n := br.passes()
+				for br.bit() == 1 && !br.err {
+					cb.lblock++
+				}
+				var seg *segment
+				if len(cb.segs) > 0 {
+					seg = cb.segs[len(cb.segs)-1]
+				}
+				if seg == nil || seg.passes >= seg.maxPasses {
+					seg = &segment{maxPasses: maxPasses(cbStyle, seg)}
+					cb.segs = append(cb.segs, seg)
+				}
+				for n > 0 && !br.err {
+					take := min(seg.maxPasses-seg.passes, n)
+					length := br.read(cb.lblock + int(math.Log2(float64(take))))
+					pieces = append(pieces, packetPiece{seg: seg, length: length})
+					seg.passes += take
+					n -= take
+					if n > 0 {
+						seg = &segment{maxPasses: maxPasses(cbStyle, seg)}
+						cb.segs = append(cb.segs, seg)
+					}
+				}
+			}
+		}
+	}
+	if br.err {
+		return len(data), false
+	}
+	br.align()
+	off += br.pos
+	if params.eph && off+2 <= len(data) && data[off] == 0xFF && data[off+1] == 0x92 {
+		off += 2
+	}
+	for _, piece := range pieces {
+		end := min(off+piece.length, len(data))
+		piece.seg.data = append(piece.seg.data, data[off:end]...)
+		off = end
+	}
+	return off, true
+}
+
+// decodePackets 按进展顺序读取分块中的全部包
+func (cs *codestream) decodePackets(t *tile, data []byte) {
+	p := t.params
+	maxRes := 0
+	for _, tc := range t.comps {
+		maxRes = max(maxRes, len(tc.res))
+	}
+	progressions := p.poc
+	if len(progressions) == 0 {
+		progressions = []progression{{layerEnd: p.layers, resEnd: maxRes, compEnd: len(t.comps), order: p.order}}
+	}
+
+	off := 0
+	ok := true
+	visit := func(l, r, c, pi int) {
+		if !ok {
+			return
+		}
+		tc := t.comps[c]
+		res := tc.res[r]
+		prc := res.precincts[pi]
+		if prc.layers != l {
+			return
+		}
+		prc.layers++
+		off, ok = readPacket(data, off, p, tc, res, prc, l)
+	}
+	for _, pr := range progressions {
+		layers := min(pr.layerEnd, p.layers)
+		r0, r1 := pr.resStart, min(pr.resEnd, maxRes)
+		c0, c1 := pr.compStart, min(pr.compEnd, len(t.comps))
+		cs.iterate(t, pr.order, layers, r0, r1, c0, c1, visit)
+		if !ok {
+			return
+		}
+	}
+}
+
+// iterate 按进展顺序枚举包 (T.800 B.12)
+func (cs *codestream) iterate(t *tile, order, layers, r0, r1, c0, c1 int, visit func(l, r, c, p int)) {
+	precincts := func(l, r, c int) {
+		if r >= len(t.comps[c].res) {
+			return
+		}
+		for p := range t.comps[c].res[r].precincts {
+			visit(l, r, c, p)
+		}
+	}
+	switch order {
+	case 0: // LRCP
+		for l := 0; l < layers; l++ {
+			for r := r0; r < r1; r++ {
+				for c := c0; c < c1; c++ {
+					precincts(l, r, c)
+				}
+			}
+		}
+	case 1: // RLCP
+		for r := r0; r < r1; r++ {
+			for l := 0; l < layers; l++ {
+				for c := c0; c < c1; c++ {
+					precincts(l, r, c)
+				}
+			}
+		}
+	case 2: // RPCL
+		dx, dy := cs.steps(t, c0, c1, r0, r1)
+		for r := r0; r < r1; r++ {
+			for y := t.y0; y < t.y1; y += dy - y%dy {
+				for x := t.x0; x < t.x1; x += dx - x%dx {
+					for c := c0; c < c1; c++ {
+						if p, ok := cs.precinctAt(t, c, r, x, y); ok {
+							for l := 0; l < layers; l++ {
+								visit(l, r, c, p)
+							}
+						}
+					}
+				}
+			}
+		}
+	case 3: // PCRL
+		dx, dy := cs.steps(t, c0, c1, r0, r1)
+		for y := t.y0; y < t.y1; y += dy - y%dy {
+			for x := t.x0; x < t.x1; x += dx - x%dx {
+				for c := c0; c < c1; c++ {
+					for r := r0; r < r1; r++ {
+						if p, ok := cs.precinctAt(t, c, r, x, y); ok {
+							for l := 0; l < layers; l++ {
+								visit(l, r, c, p)
+							}
+						}
+					}
+				}
+			}
+		}
+	case 4: // CPRL
+		for c := c0; c < c1; c++ {
+			dx, dy := cs.steps(t, c, c+1, r0, r1)
+			for y := t.y0; y < t.y1; y += dy - y%dy {
+				for x := t.x0; x < t.x1; x += dx - x%dx {
+					for r := r0; r < r1; r++ {
+						if p, ok := cs.precinctAt(t, c, r, x, y); ok {
+							for l := 0; l < layers; l++ {
+								visit(l, r, c, p)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// steps 返回位置进展顺序在参考网格上的最小步长
+func (cs *codestream) steps(t *tile, c0, c1, r0, r1 int) (int, int) {
+	dx, dy := math.MaxInt32, math.MaxInt32
+	for c := c0; c < c1; c++ {
+		tc := t.comps[c]
+		comp := cs.components[c]
+		nl := tc.cod.levels
+		for r := r0; r < r1 && r < len(tc.res); r++ {
+			res := tc.res[r]
+			if sx := comp.dx << (res.ppx + nl - r); sx > 0 && sx < dx {
+				dx = sx
+			}
+			if sy := comp.dy << (res.ppy + nl - r); sy > 0 && sy < dy {
+				dy = sy
+			}
+		}
+	}
+	if dx == math.MaxInt32 {
+		dx = t.x1 - t.x0
+	}
+	if dy == math.MaxInt32 {
+		dy = t.y1 - t.y0
+	}
+	return max(dx, 1), max(dy, 1)
+}
+
+// precinctAt 返回参考网格位置 (x, y) 处开始的分区序号
+func (cs *codestream) precinctAt(t *tile, c, r, x, y int) (int, bool) {
+	tc := t.comps[c]
+	if r >= len(tc.res) {
+		return 0, false
+	}
+	res := tc.res[r]
+	if res.pw == 0 || res.ph == 0 {
+		return 0, false
+	}
+	comp := cs.components[c]
+	level := tc.cod.levels - r
+	rpx, rpy := res.ppx+level, res.ppy+level
+	if !(y%(comp.dy<<rpy) == 0 || (y == t.y0 && (res.y0<<level)%(1<<rpy) != 0)) {
+		return 0, false
+	}
+	if !(x%(comp.dx<<rpx) == 0 || (x == t.x0 && (res.x0<<level)%(1<<rpx) != 0)) {
+		return 0, false
+	}
+	i := ceilDiv(x, comp.dx<<level)>>res.ppx - res.x0>>res.ppx
+	j := ceilDiv(y, comp.dy<<level)>>res.ppy - res.y0>>res.ppy
+	if i < 0 || j < 0 || i >= res.pw || j >= res.ph {
+		return 0, false
+	}
+	return i + j*res.pw, true
+}
+
+// decodeCoefficients 解码全部码块并反量化到分量系数平面
+func decodeCoefficients(tc *tileComp) {
+	stride := tc.x1 - tc.x0
+	for _, res := range tc.res {
+		for _, prc := range res.precincts {
+			for bi, pb := range prc.bands {
+				b := res.bands[bi]
+				for _, cb := range pb.blocks {
+					if len(cb.segs) == 0 {
+						continue
+					}
+					vals := decodeBlock(cb, b.orient, b.mb, tc.cod.cbStyle)
+					w := cb.x1 - cb.x0
+					shift := tc.qcd.roiShift
+					for y := cb.y0; y < cb.y1; y++ {
+						row := (b.oy+y-b.y0)*stride + b.ox - b.x0
+						for x := cb.x0; x < cb.x1; x++ {
+							v := vals[(y-cb.y0)*w+x-cb.x0]
+							if shift > 0 {
+								if m := abs32(v); m >= 1<<shift {
+									v >>= shift
+								}
+							}
+							tc.data[row+x] = float64(v) * b.step
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -20,9 +20,9 @@ type Annot struct {
 	Type        AnnotType   `xml:"Type,attr"`
 	Creator     string      `xml:"Creator,attr"`
 	LastModDate DateTime    `xml:"LastModDate,attr"`
-	Visible     bool        `xml:"Visible,attr,omitempty"`
+	Visible     *bool       `xml:"Visible,attr,omitempty"`
 	Subtype     string      `xml:"Subtype,attr,omitempty"`
-	Print       bool        `xml:"Print,attr,omitempty"`
+	Print       *bool       `xml:"Print,attr,omitempty"`
 	NoZoom      bool        `xml:"NoZoom,attr,omitempty"`
 	NoRotate    bool        `xml:"NoRotate,attr,omitempty"`
 	ReadOnly    bool        `xml:"ReadOnly,attr,omitempty"`
@@ -31,6 +31,16 @@ type Annot struct {
 	Appearance  *Appearance `xml:"Appearance"`
 }
 
+// IsVisible 是否显示，默认值为 true
+func (a *Annot) IsVisible() bool {
+	return a.Visible == nil || *a.Visible
+}
+
+// IsPrint 是否随文档打印，默认值为 true
+func (a *Annot) IsPrint() bool {
+	return a.Print == nil || *a.Print
+}
+
 // AnnotType 注解类型枚举
 type AnnotType string
 
@@ -7,6 +7,7 @@ import (
 	"image/color"
 	"log/slog"
 	"math"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -277,6 +278,7 @@ func (t *DateTime) UnmarshalXMLAttr(attr xml.Attr) error {
 
 // parseTime 解析时间的通用方法
 func (t *DateTime) parseTime(v string) error {
+	v = strings.TrimSpace(v)
 	// 尝试解析多种可能的时间格式
 	formats := []string{
 		"2006-01-02",
@@ -335,8 +337,10 @@ func (t *DateTime) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
 	}, nil
 }
 
+// Color 颜色值，Values 保存原始各通道分量，RGBA 为按分量个数推断颜色空间后的简单转换结果
 type Color struct {
 	color.RGBA
+	Values []int
 }
 
 // UnmarshalXML 解析 XML 元素
@@ -379,7 +383,7 @@ func (c *Color) parseInt(s string) (int, error) {
 	return int(val), err
 }
 
-// 解析字符串 "156 82 35" 或 "156 82 35 255"
+// 解析字符串 "156 82 35" 或 "156 82 35 255" (R G B A)，灰度为单个分量
 func (c *Color) parse(s string) error {
 	s = strings.TrimSpace(s)
 	if s == "" {
@@ -390,39 +394,92 @@ func (c *Color) parse(s string) error {
 	}
 
 	parts := strings.Fields(s)
-	if len(parts) != 3 && len(parts) != 4 {
-		return fmt.Errorf("invalid color format: %s, expected 'R G B' or 'R G B A'", s)
-	}
-
-	// 解析 RGB
-	values := [4]uint8{0, 0, 0, 255}
+	values := make([]int, len(parts))
 	for i := 0; i < len(parts); i++ {
 		val, err := c.parseInt(parts[i])
 		if err != nil {
 			return fmt.Errorf("invalid number '%s' in color: %v", parts[i], err)
 		}
-		if val < 0 || val > 255 {
-			return fmt.Errorf("color value out of range 0-255: %d", val)
+		// 分量位数由颜色空间的 BitsPerComponent 决定，最大 16 位
+		if val < 0 || val > 0xffff {
+			return fmt.Errorf("color value out of range 0-65535: %d", val)
 		}
-		values[i] = uint8(val)
+		values[i] = val
 	}
 
-	// 如果没有 Alpha，使用 255
-	if len(parts) == 3 {
-		values[3] = 255
+	c.Values = values
+	c.RGBA = DeviceRGBA(c.Components("", 0))
+	return nil
+}
+
+// Components 返回颜色空间类型及 0~1 的分量。
+//
+// space 为 GRAY、RGB 或 CMYK，为空时按分量个数推断 (1 个为灰度，其他为 RGB，第四个分量为透明度)；
+// bpc 为分量位数，为 0 时按 8 位，分量超过 255 时按 16 位。RGB 颜色空间保留第四个分量作为透明度
+func (c Color) Components(space string, bpc int) (string, []float64) {
+	if space == "" {
+		space = "RGB"
+		if len(c.Values) == 1 {
+			space = "GRAY"
+		}
+	}
+	if bpc <= 0 || bpc > 16 {
+		bpc = 8
+		if slices.Max(append([]int{0}, c.Values...)) > 255 {
+			bpc = 16
+		}
+	}
+	n := 3
+	switch space {
+	case "GRAY":
+		n = 1
+	case "CMYK":
+		n = 4
+	case "RGB":
+		if len(c.Values) > 3 {
+			n = 4
+		}
+	}
+	maxValue := float64(int(1)<<bpc - 1)
+	v := make([]float64, n)
+	for i := range v {
+		if i < len(c.Values) {
+			v[i] = min(float64(c.Values[i])/maxValue, 1)
+		}
 	}
+	return space, v
+}
 
-	c.RGBA = color.RGBA{
-		R: values[0],
-		G: values[1],
-		B: values[2],
-		A: values[3],
+// DeviceRGBA 不使用颜色配置文件，将颜色空间中 0~1 的分量简单转换为 sRGB
+func DeviceRGBA(space string, v []float64) color.RGBA {
+	channel := func(f float64) uint8 {
+		return uint8(math.Round(max(0, min(f, 1)) * 255))
 	}
-	return nil
+	out := color.RGBA{A: 255}
+	switch {
+	case space == "GRAY" && len(v) >= 1:
+		out.R, out.G, out.B = channel(v[0]), channel(v[0]), channel(v[0])
+	case space == "CMYK" && len(v) >= 4:
+		k := 1 - v[3]
+		out.R, out.G, out.B = channel((1-v[0])*k), channel((1-v[1])*k), channel((1-v[2])*k)
+	case len(v) >= 3:
+		out.R, out.G, out.B = channel(v[0]), channel(v[1]), channel(v[2])
+		if len(v) > 3 {
+			out.A = channel(v[3])
+		}
+	}
+	return out
 }
 
-// String 返回 "R G B" 或 "R G B A" 格式
+// String 返回原始分量，或 "R G B" / "R G B A" 格式
 func (c Color) String() string {
+	if len(c.Values) > 0 {
+		parts := make([]string, len(c.Values))
+		for i, v := range c.Values {
+			parts[i] = strconv.Itoa(v)
+		}
+		return strings.Join(parts, " ")
+	}
 	if c.A == 255 {
 		return fmt.Sprintf("%d %d %d", c.R, c.G, c.B)
 	}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/xml"
+	"image/color"
+	"slices"
+	"testing"
+)
+
+func TestColor(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		rgba   color.RGBA
+		values []int
+	}{
+		{name: "RGB", value: "156 82 35", rgba: color.RGBA{156, 82, 35, 255}, values: []int{156, 82, 35}},
+		{name: "RGBA", value: "156 82 35 128", rgba: color.RGBA{156, 82, 35, 128}, values: []int{156, 82, 35, 128}},
+		{name: "十六进制", value: "#FF #00 #80", rgba: color.RGBA{255, 0, 128, 255}, values: []int{255, 0, 128}},
+		{name: "灰度", value: "128", rgba: color.RGBA{128, 128, 128, 255}, values: []int{128}},
+		{name: "16 位分量", value: "65535 0 0", rgba: color.RGBA{255, 0, 0, 255}, values: []int{65535, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Color
+			if err := c.UnmarshalXMLAttr(xml.Attr{Value: tt.value}); err != nil {
+				t.Fatal(err)
+			}
+			if c.RGBA != tt.rgba || !slices.Equal(c.Values, tt.values) {
+				t.Errorf("got %v %v, want %v %v", c.RGBA, c.Values, tt.rgba, tt.values)
+			}
+		})
+	}
+
+	var c Color
+	if err := c.UnmarshalXMLAttr(xml.Attr{Value: "0 -1 0"}); err == nil {
+		t.Error("expected error for negative component")
+	}
+}
+
+func TestColor_Components(t *testing.T) {
+	tests := []struct {
+		value string
+		space string
+		bpc   int
+		want  string
+		rgba  color.RGBA
+	}{
+		{value: "156 82 35 128", space: "RGB", want: "RGB", rgba: color.RGBA{156, 82, 35, 128}},
+		{value: "15", space: "GRAY", bpc: 4, want: "GRAY", rgba: color.RGBA{255, 255, 255, 255}},
+		{value: "0 65535 65535 0", space: "CMYK", bpc: 16, want: "CMYK", rgba: color.RGBA{255, 0, 0, 255}},
+		{value: "32768 0 0", want: "RGB", rgba: color.RGBA{128, 0, 0, 255}},
+		{value: "0 255 255 0", want: "RGB", rgba: color.RGBA{0, 255, 255, 0}},
+		{value: "300 0 0", space: "RGB", bpc: 8, want: "RGB", rgba: color.RGBA{255, 0, 0, 255}},
+	}
+	for _, tt := range tests {
+		var c Color
+		if err := c.UnmarshalXMLAttr(xml.Attr{Value: tt.value}); err != nil {
+			t.Fatal(err)
+		}
+		space, v := c.Components(tt.space, tt.bpc)
+		if got := DeviceRGBA(space, v); space != tt.want || got != tt.rgba {
+			t.Errorf("%q %s/%d: got %s %v, want %s %v", tt.value, tt.space, tt.bpc, space, got, tt.want, tt.rgba)
+		}
+	}
+}
@@ -17,6 +17,12 @@ type Template struct {
 	ZOrder     string  `xml:"ZOrder,attr,omitempty"` // Background or Foreground
 }
 
+// 模板层次
+const (
+	ZOrderBackground = "Background"
+	ZOrderForeground = "Foreground"
+)
+
 type Content struct {
 	Layer []*Layer `xml:"Layer"`
 }
@@ -25,12 +31,23 @@ type Layer struct {
 	ID        StID    `xml:"ID,attr"`
 	Type      string  `xml:"Type,attr,omitempty"` // Body, Background, Foreground, Custom
 	DrawParam StRefID `xml:"DrawParam,attr,omitempty"`
-	// CTPageBlock 内容
-	TextObject      []TextObject      `xml:"TextObject"`
-	PathObject      []PathObject      `xml:"PathObject"`
-	ImageObject     []ImageObject     `xml:"ImageObject"`
-	CompositeObject []CompositeObject `xml:"CompositeObject"`
-	PageBlock       []PageBlock       `xml:"PageBlock"`
+	CTPageBlock
+}
+
+// 图层类型
+const (
+	LayerTypeBody       = "Body"
+	LayerTypeBackground = "Background"
+	LayerTypeForeground = "Foreground"
+	LayerTypeCustom     = "Custom"
+)
+
+// LayerType 返回图层类型，未指定时为 Body
+func (p *Layer) LayerType() string {
+	if p.Type == "" {
+		return LayerTypeBody
+	}
+	return p.Type
 }
 
 type Actions struct {
@@ -160,12 +177,12 @@ type CtImage struct {
 }
 
 type Border struct {
-	BorderColor           *CTColor `xml:"BorderColor"`
-	LineWidth             float64  `xml:"LineWidth,attr,omitempty"`
-	HorizonalCornerRadius float64  `xml:"HorizonalCornerRadius,attr,omitempty"`
-	VerticalCornerRadius  float64  `xml:"VerticalCornerRadius,attr,omitempty"`
-	DashOffset            float64  `xml:"DashOffset,attr,omitempty"`
-	DashPattern           StArray  `xml:"DashPattern,attr,omitempty"`
+	BorderColor           *CTColor  `xml:"BorderColor"`
+	LineWidth             float64   `xml:"LineWidth,attr,omitempty"`
+	HorizonalCornerRadius float64   `xml:"HorizonalCornerRadius,attr,omitempty"`
+	VerticalCornerRadius  float64   `xml:"VerticalCornerRadius,attr,omitempty"`
+	DashOffset            float64   `xml:"DashOffset,attr,omitempty"`
+	DashPattern           *StArrayF `xml:"DashPattern,attr,omitempty"`
 }
 
 type CtComposite struct {
@@ -192,7 +209,7 @@ type CtPattern struct {
 	YStep         float64     `xml:"YStep,attr,omitempty"`
 	ReflectMethod string      `xml:"ReflectMethod,attr,omitempty"` // Normal, Row, Column, RowAndColumn
 	RelativeTo    string      `xml:"RelativeTo,attr,omitempty"`    // Page, Object
-	CTM           StArray     `xml:"CTM,attr,omitempty"`
+	CTM           *CTM        `xml:"CTM,attr,omitempty"`
 }
 
 type CellContent struct {
@@ -271,6 +288,7 @@ type CTColor struct {
 }
 
 type Segment struct {
-	Color    CTColor `xml:"Color"`
-	Position float64 `xml:"Position,attr,omitempty"`
+	Color CTColor `xml:"Color"`
+	// Position 颜色段位置，取值 [0 1.0]，未指定时在相邻颜色段间均匀分布
+	Position *float64 `xml:"Position,attr,omitempty"`
 }
@@ -22,7 +22,8 @@ type ColorSpace struct {
 }
 
 type Palette struct {
-	CV []StArray `xml:"CV"`
+	// CV 调色板中的颜色，分量个数与颜色空间一致
+	CV []Color `xml:"CV"`
 }
 
 type DrawParams struct {
@@ -0,0 +1,22 @@
+package parser
+
+import (
+	"github.com/zc310/ofd/internal/models"
+)
+
+// ParseAttachments 读取文档的附件列表，FileLoc 已解析为包内路径
+func (p *Document) ParseAttachments() ([]models.Attachment, error) {
+	if p.Document.Attachments == nil || p.Document.Attachments.IsEmpty() {
+		return nil, nil
+	}
+	var list models.Attachments
+	fileName := p.Document.Attachments.Resolve(p.BaseLoc)
+	if err := p.FileCache.ParseXMLContent(fileName.String(), &list); err != nil {
+		return nil, err
+	}
+	dir := fileName.Dir()
+	for i := range list.Attachments {
+		list.Attachments[i].FileLoc = list.Attachments[i].FileLoc.Resolve(dir)
+	}
+	return list.Attachments, nil
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"log/slog"
 	"path"
@@ -24,34 +25,56 @@ func (p *Common) Init(fileCache *utils.ZipFileCache, dir models.StLoc) {
 type Document struct {
 	Common
 	models.Document
-	Pages       []*Page
-	Templates   map[models.StID]*models.PageContent
-	DrawParams  map[models.StID]*models.DrawParam
-	Res         map[models.StID]*models.MultiMedia
-	FontRes     map[models.StID]*models.Font
-	PublicRes   []*models.Res
-	DocumentRes []*models.Res
-	Signs       map[models.StID]*models.Signature
-	Seals       map[models.StID][]*SealInfo
+	Pages      []*Page
+	Templates  map[models.StID]*models.PageContent
+	DrawParams map[models.StID]*models.DrawParam
+	Res        map[models.StID]*models.MultiMedia
+	FontRes    map[models.StID]*models.Font
+	// CompositeUnits 矢量图像资源
+	CompositeUnits map[models.StID]*models.CompositeGraphicUnit
+	// ColorSpaces 颜色空间，Profile 已解析为包内绝对路径
+	ColorSpaces map[models.StID]*models.ColorSpace
+	// JBIG2Globals JBIG2 图像对应的全局段文件
+	JBIG2Globals map[models.StID]models.StLoc
+	PublicRes    []*models.Res
+	DocumentRes  []*models.Res
+	Signs        map[models.StID]*models.Signature
+	Seals        map[models.StID][]*SealInfo
+	// SealErrors 各页面无法提取的签章
+	SealErrors  map[models.StID][]error
 	Annotations map[models.StID]*models.PageAnnot
+	// Warnings 宽松模式下无法读取而跳过的部件，均为 *PartError
+	Warnings []error
+
+	mode ParseMode
 }
 
-func (p *Document) parsePublicRes() error {
-	if len(p.CommonData.PublicRes) == 0 {
-		return nil
+// parseRes 解析公共资源或文档资源文件，并登记其中的各类资源
+func (p *Document) parseRes(locs []models.StLoc) ([]*models.Res, error) {
+	if len(locs) == 0 {
+		return nil, nil
 	}
-	p.PublicRes = make([]*models.Res, len(p.CommonData.PublicRes))
+	list := make([]*models.Res, 0, len(locs))
 	var err error
-	for i, res := range p.CommonData.PublicRes {
+	for _, res := range locs {
 		var pr models.Res
-		if err = p.FileCache.ParseXMLContent(res.Resolve(p.BaseLoc).String(), &pr); err != nil {
-			return err
+		name := res.Resolve(p.BaseLoc).String()
+		if err = p.FileCache.ParseXMLContent(name, &pr); err != nil {
+			if err = p.fail(name, err); err != nil {
+				return nil, err
+			}
+			continue
 		}
-		p.PublicRes[i] = &pr
+		list = append(list, &pr)
 		if pr.MultiMedias != nil {
 			for _, media := range pr.MultiMedias.MultiMedia {
+				media.MediaFile = p.resolve(&pr, media.MediaFile)
+				if err = p.checkPart(media.MediaFile); err != nil {
+					return nil, err
+				}
 				p.Res[media.ID] = media
 			}
+			p.linkJBIG2Globals(pr.MultiMedias)
 		}
 
 		if pr.DrawParams != nil {
@@ -59,56 +82,74 @@ func (p *Document) parsePublicRes() error {
 				p.DrawParams[param.ID] = param
 			}
 		}
+		if pr.CompositeGraphicUnits != nil {
+			for i := range pr.CompositeGraphicUnits.CompositeGraphicUnit {
+				unit := &pr.CompositeGraphicUnits.CompositeGraphicUnit[i]
+				p.CompositeUnits[unit.ID] = unit
+			}
+		}
+		if err = p.parseColorSpaces(&pr); err != nil {
+			return nil, err
+		}
 		if pr.Fonts != nil {
 			for _, font := range pr.Fonts.Font {
 				if font.FontFile != "" {
-					font.FontFile = models.StLoc(p.BaseLoc) + "/" + pr.BaseLoc + "/" + font.FontFile
+					font.FontFile = p.resolve(&pr, font.FontFile)
+					if err = p.checkPart(font.FontFile); err != nil {
+						return nil, err
+					}
 				}
 				p.FontRes[font.ID] = &font
 			}
 		}
 	}
-	return nil
+	return list, nil
 }
-func (p *Document) parseDocumentRes() error {
-	if len(p.CommonData.DocumentRes) == 0 {
-		return nil
+
+// resolve 返回资源文件中引用的文件在包内的路径，相对路径基于文档目录及资源文件的 BaseLoc
+func (p *Document) resolve(pr *models.Res, loc models.StLoc) models.StLoc {
+	if loc.IsAbsolute() {
+		return loc
 	}
-	p.DocumentRes = make([]*models.Res, len(p.CommonData.DocumentRes))
-	var err error
-	for i, res := range p.CommonData.DocumentRes {
-		var pr models.Res
-		if err = p.FileCache.ParseXMLContent(res.Resolve(p.BaseLoc).String(), &pr); err != nil {
-			return err
-		}
-		p.DocumentRes[i] = &pr
-		if pr.MultiMedias != nil {
-			for _, media := range pr.MultiMedias.MultiMedia {
-				if !strings.HasPrefix(media.MediaFile.String(), "/") {
-					if pr.BaseLoc == "" {
-						media.MediaFile = models.StLoc(p.BaseLoc) + "/" + media.MediaFile
-					} else {
-						media.MediaFile = models.StLoc(p.BaseLoc) + "/" + pr.BaseLoc + "/" + media.MediaFile
-					}
-				}
+	if pr.BaseLoc == "" {
+		return p.BaseLoc + "/" + loc
+	}
+	return p.BaseLoc + "/" + pr.BaseLoc + "/" + loc
+}
 
-				p.Res[media.ID] = media
-			}
+// linkJBIG2Globals 将资源文件中 Format 为 JBIG2Globals 的多媒体作为同一资源文件中 JBIG2 图像的全局段
+func (p *Document) linkJBIG2Globals(medias *models.MultiMedias) {
+	var globals models.StLoc
+	for _, media := range medias.MultiMedia {
+		if strings.EqualFold(media.Format, "JBIG2Globals") {
+			globals = media.MediaFile
 		}
-
-		if pr.DrawParams != nil {
-			for _, param := range pr.DrawParams.DrawParam {
-				p.DrawParams[param.ID] = param
-			}
+	}
+	if globals == "" {
+		return
+	}
+	for _, media := range medias.MultiMedia {
+		switch strings.ToUpper(media.Format) {
+		case "JBIG2", "JB2":
+			p.JBIG2Globals[media.ID] = globals
 		}
-		if pr.Fonts != nil {
-			for _, font := range pr.Fonts.Font {
-				if font.FontFile != "" {
-					font.FontFile = models.StLoc(p.BaseLoc) + "/" + pr.BaseLoc + "/" + font.FontFile
-				}
-				p.FontRes[font.ID] = &font
+	}
+}
+
+// parseColorSpaces 登记资源文件中的颜色空间
+func (p *Document) parseColorSpaces(pr *models.Res) error {
+	if pr.ColorSpaces == nil {
+		return nil
+	}
+	for i := range pr.ColorSpaces.ColorSpace {
+		cs := &pr.ColorSpaces.ColorSpace[i]
+		if cs.Profile != "" {
+			cs.Profile = p.resolve(pr, cs.Profile)
+			if err := p.checkPart(cs.Profile); err != nil {
+				return err
 			}
 		}
+		p.ColorSpaces[cs.ID] = cs
 	}
 	return nil
 }
@@ -120,8 +161,13 @@ func (p *Document) parse(body models.DocBody) error {
 	}
 	for _, page := range p.Document.Pages.Pages {
 		var pc models.PageContent
-		if err = p.FileCache.ParseXMLContent(page.BaseLoc.Resolve(p.BaseLoc).String(), &pc); err != nil {
-			return err
+		name := page.BaseLoc.Resolve(p.BaseLoc).String()
+		if err = p.FileCache.ParseXMLContent(name, &pc); err != nil {
+			// 宽松模式下无法读取的页面按空白页处理，保持页码不变
+			if err = p.fail(name, err); err != nil {
+				return err
+			}
+			pc = models.PageContent{}
 		}
 		if pc.Area == nil {
 			pc.Area = &p.CommonData.PageArea
@@ -134,10 +180,13 @@ func (p *Document) parse(body models.DocBody) error {
 	p.DrawParams = make(map[models.StID]*models.DrawParam)
 	p.Res = make(map[models.StID]*models.MultiMedia)
 	p.FontRes = make(map[models.StID]*models.Font)
-	if err = p.parsePublicRes(); err != nil {
-		slog.Error(err.Error())
+	p.CompositeUnits = make(map[models.StID]*models.CompositeGraphicUnit)
+	p.ColorSpaces = make(map[models.StID]*models.ColorSpace)
+	p.JBIG2Globals = make(map[models.StID]models.StLoc)
+	if p.PublicRes, err = p.parseRes(p.CommonData.PublicRes); err != nil {
+		return err
 	}
-	if err = p.parseDocumentRes(); err != nil {
+	if p.DocumentRes, err = p.parseRes(p.CommonData.DocumentRes); err != nil {
 		return err
 	}
 	if err = p.parseAnnotations(); err != nil {
@@ -152,8 +201,12 @@ func (p *Document) parseTemplates() error {
 	var err error
 	for _, page := range p.Document.CommonData.TemplatePages {
 		var pc models.PageContent
-		if err = p.FileCache.ParseXMLContent(page.BaseLoc.Resolve(p.BaseLoc).String(), &pc); err != nil {
-			return err
+		name := page.BaseLoc.Resolve(p.BaseLoc).String()
+		if err = p.FileCache.ParseXMLContent(name, &pc); err != nil {
+			if err = p.fail(name, err); err != nil {
+				return err
+			}
+			continue
 		}
 		p.Templates[page.ID] = &pc
 	}
@@ -203,6 +256,7 @@ func (p *Document) GetDrawParam(id models.StID) *models.DrawParam {
 func (p *Document) ParseSigns(file *models.StLoc) error {
 	p.Signs = make(map[models.StID]*models.Signature)
 	p.Seals = make(map[models.StID][]*SealInfo)
+	p.SealErrors = make(map[models.StID][]error)
 	if file == nil {
 		return nil
 	}
@@ -210,43 +264,54 @@ func (p *Document) ParseSigns(file *models.StLoc) error {
 	var signatures Signatures
 	dir := file.Dir()
 	if err = p.FileCache.ParseXMLContent(file.String(), &signatures); err != nil {
-		return err
+		return p.fail(file.String(), err)
 	}
 
 	for _, body := range signatures.Signatures {
 		var sig models.Signature
-		if err = p.FileCache.ParseXMLContent(body.BaseLoc.Resolve(dir).String(), &sig); err != nil {
-			return err
+		name := body.BaseLoc.Resolve(dir).String()
+		if err = p.FileCache.ParseXMLContent(name, &sig); err != nil {
+			if err = p.fail(name, err); err != nil {
+				return err
+			}
+			continue
 		}
 		seDir := body.BaseLoc.Resolve(dir).Dir()
+		refs := sig.SignedInfo.References.Reference
+		for i := range refs {
+			refs[i].FileRef = refs[i].FileRef.Resolve(seDir)
+		}
 		p.Signs[body.ID] = &sig
-		var sealData *SealData
-		var buf []byte
+		if len(sig.SignedInfo.StampAnnot) == 0 {
+			continue
+		}
+		// 签章文件，没有 Seal 时印章在签名值中
+		seFile := sig.SignedValue.Resolve(seDir).String()
 		if sig.SignedInfo.Seal != nil {
-			seFile := sig.SignedInfo.Seal.BaseLoc.Resolve(seDir).String()
-			if buf, err = p.FileCache.ParseContent(seFile); err != nil {
-				return err
+			seFile = sig.SignedInfo.Seal.BaseLoc.Resolve(seDir).String()
+		}
+		var sealData *SealData
+		buf, err := p.FileCache.ParseContent(seFile)
+		if err == nil {
+			sealData, err = ExtractSealData(buf)
+		}
+		if err != nil {
+			var pe *PartError
+			if !errors.As(err, &pe) {
+				err = &PartError{Path: seFile, Err: fmt.Errorf("提取签章失败: %w", err)}
 			}
-
-			if sealData, err = ExtractSealData(buf); err != nil {
-				slog.Error(fmt.Sprintf("提取签章失败(%s): %v", seFile, err))
-				continue
+			if p.mode == Strict {
+				return err
 			}
+			slog.Error(err.Error())
+			p.Warnings = append(p.Warnings, err)
 			for _, annot := range sig.SignedInfo.StampAnnot {
-				p.Seals[models.StID(annot.PageRef)] = append(p.Seals[models.StID(annot.PageRef)], &SealInfo{StampAnnot: annot, SealData: sealData})
-			}
-		} else {
-			if len(sig.SignedInfo.StampAnnot) > 0 {
-				if buf, err = p.FileCache.ParseContent(sig.SignedValue.Resolve(seDir).String()); err != nil {
-					return err
-				}
-				if sealData, err = ExtractSealData(buf); err != nil {
-					return err
-				}
-				for _, annot := range sig.SignedInfo.StampAnnot {
-					p.Seals[models.StID(annot.PageRef)] = append(p.Seals[models.StID(annot.PageRef)], &SealInfo{StampAnnot: annot, SealData: sealData})
-				}
+				p.SealErrors[models.StID(annot.PageRef)] = append(p.SealErrors[models.StID(annot.PageRef)], err)
 			}
+			continue
+		}
+		for _, annot := range sig.SignedInfo.StampAnnot {
+			p.Seals[models.StID(annot.PageRef)] = append(p.Seals[models.StID(annot.PageRef)], &SealInfo{StampAnnot: annot, SealData: sealData})
 		}
 	}
 	return nil
@@ -261,7 +326,7 @@ func (p *Document) parseAnnotations() error {
 	var annot models.Annotations
 	fileName := p.Document.Annotations.Resolve(p.BaseLoc)
 	if err = p.FileCache.ParseXMLContent(fileName.String(), &annot); err != nil {
-		return err
+		return p.fail(fileName.String(), err)
 	}
 	dir := fileName.Dir()
 	for _, page := range annot.Pages {
@@ -272,7 +337,9 @@ func (p *Document) parseAnnotations() error {
 			fileName = models.StLoc.Join(dir, page.FileLoc.String())
 		}
 		if err = p.FileCache.ParseXMLContent(fileName.String(), &pa); err != nil {
-			slog.Error(err.Error())
+			if err = p.fail(fileName.String(), err); err != nil {
+				return err
+			}
 			continue
 		}
 		p.Annotations[models.StID(page.PageID)] = &pa
@@ -280,6 +347,28 @@ func (p *Document) parseAnnotations() error {
 	return nil
 }
 
+// fail 处理无法读取的部件：严格模式下返回 PartError，宽松模式下记入 Warnings 后继续解析
+func (p *Document) fail(name string, err error) error {
+	var pe *PartError
+	if !errors.As(err, &pe) {
+		err = &PartError{Path: name, Err: err}
+	}
+	if p.mode == Strict {
+		return err
+	}
+	slog.Warn(err.Error())
+	p.Warnings = append(p.Warnings, err)
+	return nil
+}
+
+// checkPart 检查资源引用的文件是否存在
+func (p *Document) checkPart(loc models.StLoc) error {
+	if _, err := p.FileCache.FindFile(loc.Clean().String()); err != nil {
+		return p.fail(loc.String(), err)
+	}
+	return nil
+}
+
 type Signatures struct {
 	XMLName    xml.Name    `xml:"Signatures"`
 	Xmlns      string      `xml:"xmlns,attr"`
@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"errors"
+
+	"github.com/zc310/ofd/internal/utils"
+)
+
+var (
+	// ErrNoDocument OFD 包中没有文档
+	ErrNoDocument = errors.New("没有文档")
+	// ErrNoPages 文档没有页面
+	ErrNoPages = errors.New("文档没有页面")
+	// ErrPageOutOfRange 页码超出文档的页数
+	ErrPageOutOfRange = errors.New("页码超出范围")
+)
+
+type (
+	// PartError 包内部件缺失、无法读取或格式错误，Path 为部件在包内的路径
+	PartError = utils.PartError
+	// XMLError 部件的 XML 格式错误，包装在 PartError 中
+	XMLError = utils.XMLError
+)
@@ -18,15 +18,32 @@ type OFD struct {
 	fileCache *utils.ZipFileCache
 	file      string
 
+	// Mode 解析模式，需在 Open 之前设置
+	Mode      ParseMode
 	Documents []*Document
 }
 
+// ParseMode 解析模式，决定如何处理格式错误或缺失的部件
+type ParseMode int
+
+const (
+	// Lenient 宽松模式 (默认)，尽可能解析，无法读取的页面按空白页处理，
+	// 其他无法读取的资源、模板、注释及签章被跳过并记入 Document.Warnings
+	Lenient ParseMode = iota
+	// Strict 严格模式，任何格式错误或缺失的引用部件都返回带部件路径的错误
+	Strict
+)
+
 const (
 	rootDocument = "OFD.xml"
 )
 
-func NewOFD(file interface{}) (*OFD, error) {
+// NewOFD 打开 OFD 文件，mode 为解析模式，默认为宽松模式
+func NewOFD(file interface{}, mode ...ParseMode) (*OFD, error) {
 	var ofd OFD
+	if len(mode) > 0 {
+		ofd.Mode = mode[0]
+	}
 	return &ofd, ofd.Open(file)
 }
 
@@ -123,6 +140,7 @@ func (p *OFD) parseDocument() error {
 	for _, body := range p.OFD.DocBodies {
 		var document Document
 		document.Init(p.fileCache, body.DocRoot)
+		document.mode = p.Mode
 		if err = document.parse(body); err != nil {
 			return err
 		}
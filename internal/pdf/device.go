@@ -0,0 +1,129 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+)
+
+// 设备颜色空间
+const (
+	DeviceGray = "DeviceGray"
+	DeviceRGB  = "DeviceRGB"
+	DeviceCMYK = "DeviceCMYK"
+	ICCBased   = "ICCBased"
+)
+
+// DeviceColor 设备颜色空间中的颜色
+type DeviceColor struct {
+	// Space 颜色空间，DeviceGray、DeviceRGB、DeviceCMYK 或 ICCBased
+	Space string
+	// Values 0~1 的颜色分量
+	Values []float64
+	// Profile ICCBased 颜色空间的 ICC 配置文件，分量个数即配置文件的通道数
+	Profile []byte
+}
+
+func (d *DeviceColor) equal(o *DeviceColor) bool {
+	if d == nil || o == nil {
+		return d == o
+	}
+	return d.Space == o.Space && slices.Equal(d.Values, o.Values) && bytes.Equal(d.Profile, o.Profile)
+}
+
+// SetDeviceColors 设置随后绘制中纯色 (预乘透明度) 对应的设备颜色，其他颜色及 colors 为 nil 时按 DeviceRGB 输出
+func (r *PDF) SetDeviceColors(colors map[color.RGBA]DeviceColor) {
+	r.w.pdf.devices = colors
+}
+
+// setColor 设置填充或勾边的纯色，dc 不为 nil 时按设备颜色空间输出
+func (w *pdfPageWriter) setColor(c color.RGBA, dc *DeviceColor, stroke bool) {
+	a := float64(c.A) / 255.0
+	op := func(s string) string {
+		if stroke {
+			return strings.ToUpper(s)
+		}
+		return s
+	}
+	if dc != nil {
+		values := make([]string, len(dc.Values))
+		for i, v := range dc.Values {
+			values[i] = dec(math.Max(0.0, math.Min(1.0, v))).String()
+		}
+		switch dc.Space {
+		case DeviceGray:
+			fmt.Fprintf(w, " %v %v", values[0], op("g"))
+		case DeviceRGB:
+			fmt.Fprintf(w, " %v %v", strings.Join(values, " "), op("rg"))
+		case DeviceCMYK:
+			fmt.Fprintf(w, " %v %v", strings.Join(values, " "), op("k"))
+		default:
+			fmt.Fprintf(w, " /%v %v %v %v", w.getICCColorSpace(dc), op("cs"), strings.Join(values, " "), op("scn"))
+		}
+	} else if c.R == c.G && c.R == c.B {
+		fmt.Fprintf(w, " %v %v", dec(float64(c.R)/255.0/a), op("g"))
+	} else {
+		fmt.Fprintf(w, " %v %v %v %v", dec(float64(c.R)/255.0/a), dec(float64(c.G)/255.0/a), dec(float64(c.B)/255.0/a), op("rg"))
+	}
+	w.SetAlpha(a)
+}
+
+// deviceColorOf 返回纯色对应的设备颜色，没有设备颜色或分量个数与颜色空间不符时返回 nil
+func (w *pdfPageWriter) deviceColorOf(c color.RGBA) *DeviceColor {
+	dc, ok := w.pdf.devices[c]
+	if !ok || c.A == 0 {
+		return nil
+	}
+	switch dc.Space {
+	case DeviceGray:
+		ok = len(dc.Values) == 1
+	case DeviceRGB:
+		ok = len(dc.Values) == 3
+	case DeviceCMYK:
+		ok = len(dc.Values) == 4
+	case ICCBased:
+		ok = len(dc.Profile) > 0 && (len(dc.Values) == 1 || len(dc.Values) == 3 || len(dc.Values) == 4)
+	default:
+		ok = false
+	}
+	if !ok {
+		return nil
+	}
+	return &dc
+}
+
+// getICCColorSpace 返回 ICCBased 颜色空间在页面资源中的名称，相同的配置文件只写入一次
+func (w *pdfPageWriter) getICCColorSpace(dc *DeviceColor) pdfName {
+	ref, ok := w.pdf.profiles[string(dc.Profile)]
+	if !ok {
+		alternate := map[int]pdfName{1: DeviceGray, 3: DeviceRGB, 4: DeviceCMYK}[len(dc.Values)]
+		stream := pdfStream{
+			dict: pdfDict{
+				"N":         len(dc.Values),
+				"Alternate": alternate,
+			},
+			stream: dc.Profile,
+		}
+		if w.pdf.compress {
+			stream.dict["Filter"] = pdfFilterFlate
+		}
+		ref = w.pdf.writeObject(stream)
+		w.pdf.profiles[string(dc.Profile)] = ref
+	}
+
+	if _, ok := w.resources["ColorSpace"]; !ok {
+		w.resources["ColorSpace"] = pdfDict{}
+	}
+	spaces := w.resources["ColorSpace"].(pdfDict)
+	for name, cs := range spaces {
+		if arr, ok := cs.(pdfArray); ok && len(arr) == 2 && arr[1] == ref {
+			return name
+		}
+	}
+	name := pdfName(fmt.Sprintf("CS%d", len(spaces)))
+	spaces[name] = pdfArray{pdfName(ICCBased), ref}
+	return name
+}
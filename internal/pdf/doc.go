@@ -0,0 +1,15 @@
+// Package pdf PDF 渲染器，修改自 github.com/tdewolff/canvas/renderers/pdf (MIT 许可，见 LICENSE)，
+// 对应 github.com/tdewolff/canvas v0.0.0-20260109131636-69e1540379c6。
+//
+// pdf.go、util.go 与上游相同，writer.go 只有以下修改：
+//   - pdfWriter 增加 devices、profiles，pdfPageWriter 增加 fillDevice、strokeDevice；
+//   - SetFill、SetStroke 的纯色改由 setColor 输出，当前颜色的比较包含设备颜色；
+//   - canvas.Point、canvas.Rect 字面量使用字段名 (go vet)。
+//
+// 新增的功能位于单独的文件中：
+//   - device.go：纯色可按 SetDeviceColors 设置的设备颜色以 DeviceGray、DeviceRGB、DeviceCMYK 或 ICCBased 颜色空间输出；
+//   - glyph.go：RenderGlyphs 直接输出已定位的字形，支持不可见文字 (文字绘制模式 3)。
+//
+// 上游代码引用的 golang.org/x/text 与 github.com/tdewolff/minify/v2 因此成为直接依赖，版本与 canvas 所需的相同。
+// 升级上游版本时复制 pdf.go、util.go、writer.go 后重新应用以上修改，并同步 go.mod 中这两个依赖的版本。
+package pdf
@@ -0,0 +1,34 @@
+package pdf
+
+import (
+	"fmt"
+
+	"github.com/tdewolff/canvas"
+	canvasText "github.com/tdewolff/canvas/text"
+)
+
+// RenderGlyphs 在 m 处以 face 输出一行已定位的字形，glyphs 的前进量决定字形位置；
+// invisible 为 true 时使用文字绘制模式 3，文字不可见但可以选择和搜索
+func (r *PDF) RenderGlyphs(face *canvas.FontFace, glyphs []canvasText.Glyph, invisible bool, m canvas.Matrix) {
+	if len(glyphs) == 0 {
+		return
+	}
+	r.w.StartTextObject()
+	if !invisible {
+		r.w.SetFill(face.Fill, m)
+	}
+	r.w.SetFont(face.Font, face.Size, canvasText.LeftToRight)
+	r.w.SetTextPosition(m.Shear(face.FauxItalic, 0.0))
+	switch {
+	case invisible:
+		r.w.SetTextRenderMode(3)
+	case 0.0 < face.FauxBold:
+		r.w.SetTextRenderMode(2)
+		r.w.SetStroke(face.Fill, m)
+		fmt.Fprintf(r.w, " %v w", dec(face.FauxBold*2.0))
+	default:
+		r.w.SetTextRenderMode(0)
+	}
+	r.w.WriteText(canvas.HorizontalTB, glyphs)
+	r.w.EndTextObject()
+}
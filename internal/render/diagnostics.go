@@ -0,0 +1,90 @@
+package render
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/zc310/ofd/internal/models"
+)
+
+// IssueKind 诊断问题的类型
+type IssueKind string
+
+const (
+	// IssueResource 引用的资源 (字体、位图、复合对象、绘制参数、模板等) 不存在
+	IssueResource IssueKind = "resource"
+	// IssueFont 没有可用的字体，文字未绘制
+	IssueFont IssueKind = "font"
+	// IssueImage 位图或蒙版无法读取或解码
+	IssueImage IssueKind = "image"
+	// IssueFeature 不支持或降级处理的特性
+	IssueFeature IssueKind = "feature"
+	// IssueSeal 签章无法提取或绘制
+	IssueSeal IssueKind = "seal"
+)
+
+// Issue 页面渲染中遇到的问题
+type Issue struct {
+	Kind IssueKind `json:"kind"`
+	// ID 相关的图元或资源 ID
+	ID      models.StRefID `json:"id,omitempty"`
+	Message string         `json:"message"`
+}
+
+// FontUsage 页面请求的字体及实际使用的字体
+type FontUsage struct {
+	ID         models.StRefID `json:"id"`
+	FontName   string         `json:"fontName"`
+	FamilyName string         `json:"familyName,omitempty"`
+	// Substitute 实际使用的字体名或字体文件，为空表示没有可用字体
+	Substitute string `json:"substitute"`
+	// Substituted 实际使用的字体不是按 FontName/FamilyName 找到的
+	Substituted bool `json:"substituted"`
+}
+
+// PageReport 单个页面的渲染诊断报告
+type PageReport struct {
+	// Page 页码，从 1 开始，由调用方设置
+	Page   int         `json:"page"`
+	PageID models.StID `json:"pageId"`
+	Fonts  []FontUsage `json:"fonts,omitempty"`
+	// Features 页面用到的需要关注的特性，如 Pattern、AxialShd、CompositeObject
+	Features []string `json:"features,omitempty"`
+	Issues   []Issue  `json:"issues,omitempty"`
+}
+
+// Report 返回最近一次绘制页面的诊断报告
+func (p *Document) Report() *PageReport {
+	return p.report
+}
+
+// issue 记录诊断问题并输出日志，同一页面中相同的问题只记录一次
+func (p *Document) issue(kind IssueKind, id models.StRefID, msg string) {
+	v := Issue{Kind: kind, ID: id, Message: msg}
+	if p.report != nil {
+		if slices.Contains(p.report.Issues, v) {
+			return
+		}
+		p.report.Issues = append(p.report.Issues, v)
+	}
+	slog.Warn(msg, "kind", kind, "id", id)
+}
+
+// useFeature 记录页面用到的特性
+func (p *Document) useFeature(name string) {
+	if p.report != nil && !slices.Contains(p.report.Features, name) {
+		p.report.Features = append(p.report.Features, name)
+	}
+}
+
+// useFont 记录页面用到的字体
+func (p *Document) useFont(id models.StRefID) {
+	if p.report == nil {
+		return
+	}
+	usage, ok := p.fonts.usage[id]
+	if !ok || slices.Contains(p.report.Fonts, usage) {
+		return
+	}
+	p.report.Fonts = append(p.report.Fonts, usage)
+}
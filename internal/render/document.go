@@ -1,25 +1,39 @@
 package render
 
 import (
+	"fmt"
 	"image/color"
-	"log/slog"
 
 	"github.com/tdewolff/canvas"
 	"github.com/zc310/ofd/internal/models"
 	"github.com/zc310/ofd/internal/parser"
+	"github.com/zc310/ofd/internal/pdf"
 )
 
 type Document struct {
 	*parser.Document
 	background color.Color
 	fonts      *Fonts
+	opts       Options
+	clip       *canvas.Path
+	profiles   profiles
+	devices    map[color.RGBA]pdf.DeviceColor
+	images     imageCache
+	report     *PageReport
 }
 
 func NewDocument(background color.Color, doc *parser.Document) *Document {
 	return &Document{background: background, fonts: NewFonts(doc), Document: doc}
 }
 
+// SetOptions 设置渲染选项
+func (p *Document) SetOptions(opts Options) {
+	p.opts = opts
+	p.fonts.configure(&p.opts)
+}
+
 func (p *Document) Draw(ctx *canvas.Context, page *parser.Page) error {
+	p.report = &PageReport{PageID: page.ID}
 	box := page.Area.PhysicalBox
 	ctx.SetFillColor(p.background)
 	ctx.DrawPath(0, 0, canvas.Rectangle(box.Width, box.Height))
@@ -29,6 +43,7 @@ func (p *Document) Draw(ctx *canvas.Context, page *parser.Page) error {
 }
 
 func (p *Document) Page(page *parser.Page) (*canvas.Canvas, error) {
+	p.report = &PageReport{PageID: page.ID}
 	box := page.Area.PhysicalBox
 	c := canvas.New(box.Width, box.Height)
 	ctx := canvas.NewContext(c)
@@ -41,34 +56,35 @@ func (p *Document) Page(page *parser.Page) (*canvas.Canvas, error) {
 }
 func (p *Document) PageContent(ctx *canvas.Context, page *parser.Page, seal bool) {
 	pb := page.Area.PhysicalBox
-	for _, template := range page.Template {
-		p.Template(ctx, template, pb)
-	}
-
-	var backgroundLayers, otherLayers []*models.Layer
-	if page.Content != nil {
-		for _, layer := range page.Content.Layer {
-			if layer.Type == "Background" {
-				backgroundLayers = append(backgroundLayers, layer)
-			} else {
-				otherLayers = append(otherLayers, layer)
+	var foregroundTemplates []models.Template
+	if !p.opts.HideTemplates {
+		for _, template := range page.Template {
+			if p.templateZOrder(template) == models.ZOrderForeground {
+				foregroundTemplates = append(foregroundTemplates, template)
+				continue
 			}
+			p.Template(ctx, template, pb)
 		}
 	}
 
-	for _, layer := range backgroundLayers {
-		p.Layer(ctx, layer, pb)
+	if page.Content != nil {
+		p.Layers(ctx, page.Content.Layer, pb)
 	}
-	for _, layer := range otherLayers {
-		p.Layer(ctx, layer, pb)
+
+	for _, template := range foregroundTemplates {
+		p.Template(ctx, template, pb)
 	}
-	if seal {
+
+	if seal && !p.opts.HideSeals {
+		for _, err := range p.Document.SealErrors[page.ID] {
+			p.issue(IssueSeal, 0, err.Error())
+		}
 		sealInfos := p.Document.Seals[page.ID]
 		var err error
 		if len(sealInfos) > 0 {
 			for _, info := range sealInfos {
 				if err = p.Seal(ctx, info, page.Area.PhysicalBox); err != nil {
-					slog.Error(err.Error())
+					p.issue(IssueSeal, 0, fmt.Sprintf("签章绘制失败: %v", err))
 				}
 			}
 		}
@@ -77,27 +93,63 @@ func (p *Document) PageContent(ctx *canvas.Context, page *parser.Page, seal bool
 	annot := p.Document.Annotations[page.ID]
 	if annot != nil {
 		for _, a := range annot.Annots {
-			p.Annot(ctx, a, pb)
+			if p.opts.annotVisible(a) {
+				p.Annot(ctx, a, pb)
+			}
 		}
 
 	}
 
 }
 
+// templateZOrder 返回模板的层次，页面中未指定时取模板页定义，默认为 Background
+func (p *Document) templateZOrder(template models.Template) string {
+	if template.ZOrder != "" {
+		return template.ZOrder
+	}
+	for _, tp := range p.CommonData.TemplatePages {
+		if tp.ID == models.StID(template.TemplateID) && tp.ZOrder != nil {
+			return *tp.ZOrder
+		}
+	}
+	return models.ZOrderBackground
+}
+
 func (p *Document) Template(ctx *canvas.Context, template models.Template, pb models.StBox) {
-	content := p.Templates[models.StID(template.TemplateID)]
-	var backgroundLayers, otherLayers []*models.Layer
-	for _, layer := range content.Content.Layer {
-		if layer.Type == "Background" {
+	content, ok := p.Templates[models.StID(template.TemplateID)]
+	if !ok {
+		p.issue(IssueResource, template.TemplateID, fmt.Sprintf("模板页 %d 不存在", template.TemplateID))
+		return
+	}
+	if content.Content == nil {
+		return
+	}
+	p.Layers(ctx, content.Content.Layer, pb)
+}
+
+// Layers 按 Background、Body/Custom、Foreground 的顺序绘制图层
+func (p *Document) Layers(ctx *canvas.Context, layers []*models.Layer, pb models.StBox) {
+	var backgroundLayers, bodyLayers, foregroundLayers []*models.Layer
+	for _, layer := range layers {
+		if !p.opts.layerVisible(layer) {
+			continue
+		}
+		switch layer.LayerType() {
+		case models.LayerTypeBackground:
 			backgroundLayers = append(backgroundLayers, layer)
-		} else {
-			otherLayers = append(otherLayers, layer)
+		case models.LayerTypeForeground:
+			foregroundLayers = append(foregroundLayers, layer)
+		default:
+			bodyLayers = append(bodyLayers, layer)
 		}
 	}
 	for _, layer := range backgroundLayers {
 		p.Layer(ctx, layer, pb)
 	}
-	for _, layer := range otherLayers {
+	for _, layer := range bodyLayers {
+		p.Layer(ctx, layer, pb)
+	}
+	for _, layer := range foregroundLayers {
 		p.Layer(ctx, layer, pb)
 	}
 }
@@ -105,40 +157,11 @@ func (p *Document) Template(ctx *canvas.Context, template models.Template, pb mo
 func (p *Document) Layer(ctx *canvas.Context, layer *models.Layer, pb models.StBox) {
 	var dp *models.DrawParam
 	if layer.DrawParam > 0 {
-		dp = p.Document.GetDrawParam(models.StID(layer.DrawParam))
-	}
-	var blockF func([]models.PageBlock)
-	blockF = func(pBlock []models.PageBlock) {
-		for _, block := range pBlock {
-			if len(block.PageBlock) > 0 {
-				blockF(block.PageBlock)
-			}
-			for _, object := range block.ImageObject {
-				p.Image(ctx, object, dp, pb)
-			}
-			for _, object := range block.PathObject {
-				p.Path(ctx, object, dp, pb)
-			}
-
-			for _, object := range block.TextObject {
-				p.Text(ctx, object, dp, pb)
-			}
-
+		if dp = p.Document.GetDrawParam(models.StID(layer.DrawParam)); dp == nil {
+			p.issue(IssueResource, layer.DrawParam, fmt.Sprintf("绘制参数 %d 不存在", layer.DrawParam))
 		}
 	}
-	blockF(layer.PageBlock)
-
-	for _, object := range layer.ImageObject {
-		p.Image(ctx, object, dp, pb)
-	}
-	for _, object := range layer.PathObject {
-		p.Path(ctx, object, dp, pb)
-	}
-
-	for _, object := range layer.TextObject {
-		p.Text(ctx, object, dp, pb)
-	}
-
+	p.PageBlock(ctx, &layer.CTPageBlock, dp, pb)
 }
 
 func (p *Document) Annot(ctx *canvas.Context, annot *models.Annot, pb models.StBox) {
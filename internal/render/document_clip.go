@@ -0,0 +1,187 @@
+package render
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/tdewolff/canvas"
+	canvasText "github.com/tdewolff/canvas/text"
+	"github.com/zc310/ofd/internal/models"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/vector"
+)
+
+// 裁剪区以画布坐标 (已应用 ctx.View()) 保存在 Document.clip 中，
+// 绘制时变换回当前坐标，与图元求交后绘制；位图使用裁剪区生成透明遮罩。
+
+// pushClip 将当前坐标中的 path 与现有裁剪区求交，返回恢复函数
+func (p *Document) pushClip(ctx *canvas.Context, path *canvas.Path) func() {
+	prev := p.clip
+	clip := path.Transform(ctx.View())
+	if prev != nil {
+		clip = prev.And(clip)
+	}
+	p.clip = clip
+	return func() {
+		p.clip = prev
+	}
+}
+
+// clipRect 使用页面坐标中的矩形设置裁剪区
+func (p *Document) clipRect(ctx *canvas.Context, box models.StBox, pb models.StBox) func() {
+	rect := canvas.Rectangle(box.Width, box.Height).Translate(box.X, pb.Height-(box.Y+box.Height))
+	return p.pushClip(ctx, rect)
+}
+
+// clipUnit 设置图元的裁剪区，同一 Clip 中的 Area 取并集，多个 Clip 之间取交集
+func (p *Document) clipUnit(ctx *canvas.Context, unit *models.CTGraphicUnit, pb models.StBox) func() {
+	prev := p.clip
+	if unit.Clips != nil {
+		for _, clip := range unit.Clips.Clip {
+			var area *canvas.Path
+			for _, a := range clip.Area {
+				pa := p.clipArea(a, unit.Boundary, pb)
+				if pa == nil {
+					continue
+				}
+				if area == nil {
+					area = pa
+				} else {
+					area = area.Or(pa)
+				}
+			}
+			if area != nil {
+				p.pushClip(ctx, area)
+			}
+		}
+	}
+	return func() {
+		p.clip = prev
+	}
+}
+
+// clipArea 返回裁剪区域在当前坐标中的路径，区域坐标相对于图元的外接矩形
+func (p *Document) clipArea(area models.ClipArea, box models.StBox, pb models.StBox) *canvas.Path {
+	m := pageMatrix(pb).Translate(box.X, box.Y)
+	if area.CTM != nil {
+		m = m.Mul(ctmMatrix(area.CTM))
+	}
+	switch {
+	case area.Path != nil:
+		path := p.newPath(area.Path)
+		if area.Path.CTM != nil {
+			path = path.Transform(ctmMatrix(area.Path.CTM))
+		}
+		if area.Path.Rule == "Even-Odd" {
+			path = path.Settle(canvas.EvenOdd)
+		}
+		return path.Transform(m)
+	case area.Text != nil:
+		path := p.textPath(area.Text)
+		if path == nil {
+			return nil
+		}
+		return path.Transform(m)
+	}
+	return nil
+}
+
+// localClip 返回当前坐标中的裁剪区，未设置裁剪区时返回 nil
+func (p *Document) localClip(ctx *canvas.Context) *canvas.Path {
+	if p.clip == nil {
+		return nil
+	}
+	return p.clip.Copy().Transform(ctx.View().Inv())
+}
+
+// drawPath 按当前样式绘制路径，描边先转换为轮廓再与裁剪区求交
+func (p *Document) drawPath(ctx *canvas.Context, path *canvas.Path) {
+	defer p.renderDeviceColors(ctx)()
+	clip := p.localClip(ctx)
+	if clip == nil {
+		ctx.DrawPath(0, 0, path)
+		return
+	}
+
+	ctx.Push()
+	defer ctx.Pop()
+	style := ctx.Style
+	ctx.Style.Stroke = canvas.Paint{}
+	ctx.FillRule = canvas.NonZero
+	if style.HasFill() {
+		fill := path
+		if style.FillRule == canvas.EvenOdd {
+			fill = fill.Settle(canvas.EvenOdd)
+		}
+		ctx.DrawPath(0, 0, fill.And(clip))
+	}
+	if style.HasStroke() {
+		ctx.Style.Fill = style.Stroke
+		ctx.DrawPath(0, 0, strokeOutline(style, path).And(clip))
+	}
+}
+
+// strokeOutline 返回按样式描边 (含虚线) 后的轮廓
+func strokeOutline(style canvas.Style, path *canvas.Path) *canvas.Path {
+	if len(style.Dashes) > 0 {
+		path = path.Dash(style.DashOffset, style.Dashes...)
+	}
+	return path.Stroke(style.StrokeWidth, style.StrokeCapper, style.StrokeJoiner, canvas.Tolerance)
+}
+
+// drawGlyphs 在基线位置绘制一行字形。渲染器支持 GlyphRenderer 且没有裁剪区时输出字形，
+// 否则以字形轮廓绘制；不可见的字形只输出到 GlyphRenderer
+func (p *Document) drawGlyphs(ctx *canvas.Context, face *canvas.FontFace, glyphs []canvasText.Glyph, invisible bool) {
+	defer p.renderDeviceColors(ctx)()
+	if r, ok := ctx.Renderer.(GlyphRenderer); ok && (invisible || p.clip == nil) {
+		r.RenderGlyphs(face, glyphs, invisible, ctx.CoordSystemView().Mul(ctx.View()))
+		return
+	} else if invisible {
+		return
+	}
+	ctx.Push()
+	defer ctx.Pop()
+	ctx.Style.Fill = face.Fill
+	ctx.Style.Stroke = canvas.Paint{}
+	p.drawPath(ctx, glyphPath(face, glyphs))
+}
+
+// drawImage 以每毫米 1 像素在原点绘制位图，存在裁剪区时生成透明遮罩
+func (p *Document) drawImage(ctx *canvas.Context, img image.Image) {
+	clip := p.localClip(ctx)
+	if clip == nil {
+		ctx.DrawImage(0, 0, img, canvas.DPMM(1.0))
+		return
+	}
+
+	b := img.Bounds()
+	rect := canvas.Rectangle(float64(b.Dx()), float64(b.Dy()))
+	if rect.Not(clip).Empty() {
+		ctx.DrawImage(0, 0, img, canvas.DPMM(1.0))
+		return
+	}
+	if rect.And(clip).Empty() {
+		return
+	}
+
+	// 低分辨率位图按整数倍放大，使遮罩不低于每毫米 shadingDPMM 像素，裁剪边缘不随位图像素呈锯齿
+	k := math.Ceil(shadingDPMM * math.Sqrt(math.Abs(ctx.View().Det())))
+	k = max(1, min(k, math.Floor(math.Sqrt(maxShadingPixels/float64(b.Dx()*b.Dy())))))
+	w, h := int(float64(b.Dx())*k), int(float64(b.Dy())*k)
+	src, sp := img, b.Min
+	if k > 1 {
+		scaled := image.NewRGBA(image.Rect(0, 0, w, h))
+		xdraw.NearestNeighbor.Scale(scaled, scaled.Bounds(), img, b, draw.Src, nil)
+		src, sp = scaled, image.Point{}
+	}
+
+	ras := vector.NewRasterizer(w, h)
+	clip.ToVectorRasterizer(ras, canvas.DPMM(k))
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	ras.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+
+	dst := image.NewRGBA(mask.Bounds())
+	draw.DrawMask(dst, dst.Bounds(), src, sp, mask, image.Point{}, draw.Over)
+	ctx.DrawImage(0, 0, dst, canvas.DPMM(k))
+}
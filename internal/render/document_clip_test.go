@@ -0,0 +1,133 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tdewolff/canvas"
+	"github.com/tdewolff/canvas/renderers/rasterizer"
+
+	"github.com/zc310/ofd/internal/models"
+	"github.com/zc310/ofd/internal/parser"
+)
+
+var (
+	clipRed   = color.RGBA{255, 0, 0, 255}
+	clipWhite = color.RGBA{255, 255, 255, 255}
+)
+
+// clipDocument 返回只有一个图元的文档，页面资源含字体 3 及红色图像 5
+func clipDocument(t *testing.T, object string) *Document {
+	return openDocument(t, testOFD(t, map[string]string{
+		"Doc_0/PublicRes.xml": testRes(`<ofd:Fonts><ofd:Font ID="3" FontName="DejaVu Sans"/></ofd:Fonts>` +
+			`<ofd:MultiMedias><ofd:MultiMedia ID="5" Type="Image" Format="PNG"><ofd:MediaFile>red.png</ofd:MediaFile></ofd:MultiMedia></ofd:MultiMedias>`),
+		"Doc_0/Res/red.png":              string(solidPNG(t, clipRed, 4, 4)),
+		"Doc_0/Pages/Page_0/Content.xml": testPage("", object),
+	}))
+}
+
+// redRect 返回外接矩形为 5 5 40 40、填满外接矩形的红色路径，clips 为其 Clips 元素内容
+func redRect(clips string) string {
+	return `<ofd:PathObject ID="10" Boundary="5 5 40 40" Fill="true" Stroke="false"><ofd:Clips>` + clips +
+		`</ofd:Clips><ofd:FillColor Value="255 0 0"/><ofd:AbbreviatedData>M 0 0 L 40 0 L 40 40 L 0 40 C</ofd:AbbreviatedData></ofd:PathObject>`
+}
+
+// clipPath 返回以路径为裁剪区域的 Area，attrs 为 Area 的属性
+func clipPath(attrs, data string) string {
+	return `<ofd:Area` + attrs + `><ofd:Path Boundary="0 0 40 40"><ofd:AbbreviatedData>` + data + `</ofd:AbbreviatedData></ofd:Path></ofd:Area>`
+}
+
+func TestClip_Path(t *testing.T) {
+	const dpmm = 4.0
+	// 裁剪区域坐标相对于图元的外接矩形
+	img := rasterize(t, clipDocument(t, redRect(`<ofd:Clip>`+clipPath("", "M 5 5 L 15 5 L 15 15 L 5 15 C")+`</ofd:Clip>`)), 1, dpmm)
+	assert.Equal(t, clipRed, at(img, dpmm, 15, 15))
+	assert.Equal(t, clipWhite, at(img, dpmm, 8, 8))
+	assert.Equal(t, clipWhite, at(img, dpmm, 22, 22))
+
+	// 同一 Clip 中的 Area 取并集
+	img = rasterize(t, clipDocument(t, redRect(`<ofd:Clip>`+clipPath("", "M 0 0 L 10 0 L 10 10 L 0 10 C")+
+		clipPath("", "M 30 30 L 40 30 L 40 40 L 30 40 C")+`</ofd:Clip>`)), 1, dpmm)
+	assert.Equal(t, clipRed, at(img, dpmm, 8, 8))
+	assert.Equal(t, clipRed, at(img, dpmm, 42, 42))
+	assert.Equal(t, clipWhite, at(img, dpmm, 25, 25))
+
+	// 多个 Clip 之间取交集
+	img = rasterize(t, clipDocument(t, redRect(`<ofd:Clip>`+clipPath("", "M 0 0 L 20 0 L 20 20 L 0 20 C")+`</ofd:Clip>`+
+		`<ofd:Clip>`+clipPath("", "M 10 10 L 30 10 L 30 30 L 10 30 C")+`</ofd:Clip>`)), 1, dpmm)
+	assert.Equal(t, clipRed, at(img, dpmm, 20, 20))
+	assert.Equal(t, clipWhite, at(img, dpmm, 10, 10))
+	assert.Equal(t, clipWhite, at(img, dpmm, 30, 30))
+}
+
+func TestClip_CTM(t *testing.T) {
+	const dpmm = 4.0
+	// Area 的 CTM 作用于裁剪路径：放大 2 倍后平移 5 毫米，覆盖外接矩形中的 15 至 25
+	img := rasterize(t, clipDocument(t, redRect(`<ofd:Clip>`+clipPath(` CTM="2 0 0 2 5 5"`, "M 5 5 L 10 5 L 10 10 L 5 10 C")+`</ofd:Clip>`)), 1, dpmm)
+	assert.Equal(t, clipRed, at(img, dpmm, 21, 21))
+	assert.Equal(t, clipRed, at(img, dpmm, 29, 29))
+	assert.Equal(t, clipWhite, at(img, dpmm, 18, 18))
+	assert.Equal(t, clipWhite, at(img, dpmm, 32, 32))
+}
+
+func TestClip_Text(t *testing.T) {
+	const dpmm = 4.0
+	// 以文字轮廓裁剪，只有字形内部可见
+	img := rasterize(t, clipDocument(t, redRect(`<ofd:Clip><ofd:Area><ofd:Text Font="3" Size="20"><ofd:TextCode X="2" Y="20">H</ofd:TextCode></ofd:Text></ofd:Area></ofd:Clip>`)), 1, dpmm)
+	red := 0
+	for x := 0.0; x < 50; x += 0.25 {
+		for y := 0.0; y < 50; y += 0.25 {
+			c := at(img, dpmm, x, y)
+			if c == clipRed {
+				red++
+				// 字形位于外接矩形中的 (2, 5) 至 (18, 20) 附近
+				assert.True(t, x > 5+2 && x < 5+18 && y > 5+4 && y < 5+21, "(%g, %g)", x, y)
+			}
+		}
+	}
+	assert.Greater(t, red, 100)
+	// H 的两竖之间为空白
+	assert.Equal(t, clipWhite, at(img, dpmm, 5+9, 5+8))
+}
+
+func TestClip_Image(t *testing.T) {
+	const dpmm = 4.0
+	img := rasterize(t, clipDocument(t, `<ofd:ImageObject ID="10" Boundary="5 5 40 40" CTM="40 0 0 40 0 0" ResourceID="5"><ofd:Clips><ofd:Clip>`+
+		clipPath("", "M 0 0 L 20 0 L 20 20 L 0 20 C")+`</ofd:Clip></ofd:Clips></ofd:ImageObject>`), 1, dpmm)
+	assert.Equal(t, clipRed, at(img, dpmm, 15, 15))
+	assert.Equal(t, clipWhite, at(img, dpmm, 30, 30))
+	assert.Equal(t, clipWhite, at(img, dpmm, 15, 30))
+}
+
+func TestClip_Seal(t *testing.T) {
+	const dpmm = 4.0
+	doc := clipDocument(t, "")
+	pb := models.StBox{Width: 50, Height: 50}
+	seal := func(data *parser.SealData, clip models.StBox) image.Image {
+		c := canvas.New(50, 50)
+		info := &parser.SealInfo{
+			StampAnnot: &models.StampAnnot{Boundary: models.StBox{X: 10, Y: 10, Width: 30, Height: 30}, Clip: clip},
+			SealData:   data,
+		}
+		assert.Nil(t, doc.Seal(canvas.NewContext(c), info, pb))
+		return rasterizer.Draw(c, canvas.DPMM(dpmm), canvas.DefaultColorSpace)
+	}
+	// OFD 格式的签章为填满页面的红色路径
+	ofdSeal := &parser.SealData{FileType: "ofd", Data: testOFD(t, map[string]string{
+		"Doc_0/Pages/Page_0/Content.xml": testPage("", `<ofd:PathObject ID="10" Boundary="0 0 50 50" Fill="true" Stroke="false"><ofd:FillColor Value="255 0 0"/><ofd:AbbreviatedData>M 0 0 L 50 0 L 50 50 L 0 50 C</ofd:AbbreviatedData></ofd:PathObject>`),
+	})}
+
+	for _, data := range []*parser.SealData{{FileType: "png", Data: solidPNG(t, clipRed, 4, 4)}, ofdSeal} {
+		img := seal(data, models.StBox{})
+		assert.Equal(t, clipRed, at(img, dpmm, 12, 12), data.FileType)
+		assert.Equal(t, clipRed, at(img, dpmm, 38, 38), data.FileType)
+
+		// Clip 相对于签章的 Boundary
+		img = seal(data, models.StBox{X: 10, Y: 10, Width: 10, Height: 10})
+		assert.Equal(t, clipRed, at(img, dpmm, 25, 25), data.FileType)
+		assert.Equal(t, uint8(0), at(img, dpmm, 15, 15).A, data.FileType)
+		assert.Equal(t, uint8(0), at(img, dpmm, 35, 35).A, data.FileType)
+	}
+}
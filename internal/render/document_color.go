@@ -0,0 +1,190 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sync"
+
+	"github.com/tdewolff/canvas"
+	"github.com/zc310/ofd/internal/icc"
+	"github.com/zc310/ofd/internal/models"
+	"github.com/zc310/ofd/internal/pdf"
+)
+
+// profiles 已解析的 ICC 配置文件，解析失败时保存 nil；data 为配置文件原始数据
+type profiles struct {
+	mu   sync.Mutex
+	m    map[models.StLoc]*icc.Profile
+	data map[models.StLoc][]byte
+}
+
+// DeviceColorRenderer 可以按设备颜色空间输出纯色的渲染器 (如 PDF)，灰度、CMYK 及 ICC 颜色在输出中保留原始分量
+type DeviceColorRenderer interface {
+	// SetDeviceColors 设置随后绘制中纯色 (预乘透明度) 对应的设备颜色，为 nil 时按 sRGB 输出
+	SetDeviceColors(colors map[color.RGBA]pdf.DeviceColor)
+}
+
+// deviceScope 开始绘制一个图元，图元中的纯色通过 setFillColor、setStrokeColor 记录设备颜色；
+// 返回恢复之前设备颜色的函数
+func (p *Document) deviceScope() func() {
+	prev := p.devices
+	p.devices = nil
+	return func() { p.devices = prev }
+}
+
+// setFillColor 设置纯色填充，dc 为颜色在设备颜色空间中的颜色
+func (p *Document) setFillColor(ctx *canvas.Context, c color.RGBA, dc *pdf.DeviceColor) {
+	ctx.SetFillColor(c)
+	p.addDeviceColor(c, dc)
+}
+
+// setStrokeColor 设置纯色勾边，dc 为颜色在设备颜色空间中的颜色
+func (p *Document) setStrokeColor(ctx *canvas.Context, c color.RGBA, dc *pdf.DeviceColor) {
+	ctx.SetStrokeColor(c)
+	p.addDeviceColor(c, dc)
+}
+
+// addDeviceColor 记录当前图元中纯色 c 对应的设备颜色。同一图元中 sRGB 相同的颜色以后记录的为准
+func (p *Document) addDeviceColor(c color.RGBA, dc *pdf.DeviceColor) {
+	if dc == nil || c.A == 0 {
+		return
+	}
+	if p.devices == nil {
+		p.devices = make(map[color.RGBA]pdf.DeviceColor)
+	}
+	p.devices[c] = *dc
+}
+
+// renderDeviceColors 将当前图元的设备颜色传给 DeviceColorRenderer，返回清除设备颜色的函数
+func (p *Document) renderDeviceColors(ctx *canvas.Context) func() {
+	r, ok := ctx.Renderer.(DeviceColorRenderer)
+	if !ok || len(p.devices) == 0 {
+		return func() {}
+	}
+	r.SetDeviceColors(p.devices)
+	return func() { r.SetDeviceColors(nil) }
+}
+
+// deviceColor 返回颜色在其颜色空间中的设备颜色，只给出 sRGB 颜色或为不含配置文件的 RGB 颜色时返回 nil
+func (p *Document) deviceColor(c *models.CTColor) *pdf.DeviceColor {
+	cs, value := p.colorOf(c)
+	if value == nil || len(value.Values) == 0 {
+		return nil
+	}
+	var space string
+	var bpc int
+	if cs != nil {
+		space, bpc = cs.Type, cs.BitsPerComponent
+	}
+	space, v := value.Components(space, bpc)
+	dc := &pdf.DeviceColor{Space: pdf.DeviceRGB, Values: v}
+	switch space {
+	case "GRAY":
+		dc.Space = pdf.DeviceGray
+	case "CMYK":
+		dc.Space = pdf.DeviceCMYK
+	default:
+		// 第四个分量为透明度
+		dc.Values = v[:3]
+	}
+	if cs != nil && p.profile(cs) != nil {
+		p.profiles.mu.Lock()
+		dc.Space, dc.Profile = pdf.ICCBased, p.profiles.data[cs.Profile]
+		p.profiles.mu.Unlock()
+	} else if dc.Space == pdf.DeviceRGB {
+		return nil
+	}
+	return dc
+}
+
+// colorSpace 返回颜色引用的颜色空间，未指定时使用文档默认颜色空间
+func (p *Document) colorSpace(id models.StRefID) *models.ColorSpace {
+	if id == 0 && p.CommonData.DefaultCS != nil {
+		id = *p.CommonData.DefaultCS
+	}
+	if id == 0 {
+		return nil
+	}
+	return p.ColorSpaces[models.StID(id)]
+}
+
+// colorOf 返回颜色的颜色空间及颜色值，未给出颜色值时使用调色板中的颜色
+func (p *Document) colorOf(c *models.CTColor) (*models.ColorSpace, *models.Color) {
+	cs := p.colorSpace(c.ColorSpace)
+	value := c.Value
+	if value == nil && cs != nil && cs.Palette != nil && c.Index >= 0 && c.Index < len(cs.Palette.CV) {
+		value = &cs.Palette.CV[c.Index]
+	}
+	return cs, value
+}
+
+// colorValue 按颜色空间将颜色转换为 sRGB，未给出颜色值且没有调色板时返回 nil
+func (p *Document) colorValue(c *models.CTColor) *color.RGBA {
+	cs, value := p.colorOf(c)
+	if value == nil {
+		return nil
+	}
+	v := p.toNRGBA(cs, value)
+	// 颜色透明度，在 0~255 之间取值。默认为 255，表示完全不透明
+	if c.Alpha != nil {
+		v.A = *c.Alpha
+	}
+	rgba := color.RGBAModel.Convert(v).(color.RGBA)
+	return &rgba
+}
+
+// toNRGBA 按颜色空间类型、分量位数及 ICC 配置文件转换颜色值，未指定颜色空间时按分量个数推断
+func (p *Document) toNRGBA(cs *models.ColorSpace, c *models.Color) color.NRGBA {
+	if len(c.Values) == 0 {
+		return color.NRGBA{R: c.R, G: c.G, B: c.B, A: c.A}
+	}
+	var space string
+	var bpc int
+	if cs != nil {
+		space, bpc = cs.Type, cs.BitsPerComponent
+	}
+	space, v := c.Components(space, bpc)
+	rgba := models.DeviceRGBA(space, v)
+	out := color.NRGBA{R: rgba.R, G: rgba.G, B: rgba.B, A: rgba.A}
+
+	if cs != nil {
+		if profile := p.profile(cs); profile != nil {
+			if r, g, b, ok := profile.ToSRGB(v); ok {
+				channel := func(f float64) uint8 {
+					return uint8(math.Round(max(0, min(f, 1)) * 255))
+				}
+				out.R, out.G, out.B = channel(r), channel(g), channel(b)
+			}
+		}
+	}
+	return out
+}
+
+// profile 返回颜色空间的 ICC 配置文件，不存在或无法解析时返回 nil
+func (p *Document) profile(cs *models.ColorSpace) *icc.Profile {
+	if cs.Profile == "" {
+		return nil
+	}
+	p.profiles.mu.Lock()
+	defer p.profiles.mu.Unlock()
+	if profile, ok := p.profiles.m[cs.Profile]; ok {
+		return profile
+	}
+	if p.profiles.m == nil {
+		p.profiles.m = make(map[models.StLoc]*icc.Profile)
+		p.profiles.data = make(map[models.StLoc][]byte)
+	}
+
+	var profile *icc.Profile
+	data, err := p.FileCache.ParseContent(cs.Profile.String())
+	if err == nil {
+		profile, err = icc.Parse(data)
+	}
+	if err != nil {
+		p.issue(IssueFeature, 0, fmt.Sprintf("颜色配置文件 %s 无法解析，按设备颜色空间处理: %v", cs.Profile, err))
+	}
+	p.profiles.m[cs.Profile] = profile
+	p.profiles.data[cs.Profile] = data
+	return profile
+}
@@ -0,0 +1,94 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tdewolff/canvas"
+
+	"github.com/zc310/ofd/internal/models"
+	"github.com/zc310/ofd/internal/parser"
+	"github.com/zc310/ofd/internal/pdf"
+)
+
+func TestToNRGBA(t *testing.T) {
+	p := &Document{}
+	value := func(s string) *models.Color {
+		var c models.Color
+		assert.Nil(t, c.UnmarshalXMLAttr(xml.Attr{Value: s}))
+		return &c
+	}
+	// 未指定颜色空间时四个分量为 R G B A
+	assert.Equal(t, color.NRGBA{156, 82, 35, 255}, p.toNRGBA(nil, value("156 82 35 255")))
+	assert.Equal(t, color.NRGBA{0, 255, 255, 0}, p.toNRGBA(nil, value("0 255 255 0")))
+	// 分量按颜色空间的位数缩放
+	cmyk := &models.ColorSpace{Type: "CMYK", BitsPerComponent: 16}
+	assert.Equal(t, color.NRGBA{0, 0, 0, 255}, p.toNRGBA(cmyk, value("0 0 0 65535")))
+	gray := &models.ColorSpace{Type: "GRAY", BitsPerComponent: 4}
+	assert.Equal(t, color.NRGBA{136, 136, 136, 255}, p.toNRGBA(gray, value("8")))
+	// RGB 颜色空间的第四个分量为透明度
+	rgb := &models.ColorSpace{Type: "RGB"}
+	assert.Equal(t, color.NRGBA{156, 82, 35, 128}, p.toNRGBA(rgb, value("156 82 35 128")))
+}
+
+func TestDeviceColor_PDF(t *testing.T) {
+	// 可以解析但不支持转换的灰度配置文件，按设备颜色空间绘制
+	profile := make([]byte, 132)
+	copy(profile[16:], "GRAYXYZ ")
+	copy(profile[36:], "acsp")
+	rect := func(x float64, cs, value string) string {
+		return fmt.Sprintf(`<ofd:PathObject ID="%d" Boundary="%g 5 10 10" Fill="true" Stroke="false"><ofd:FillColor%s Value="%s"/><ofd:AbbreviatedData>M 0 0 L 10 0 L 10 10 L 0 10 C</ofd:AbbreviatedData></ofd:PathObject>`,
+			10+int(x), x, cs, value)
+	}
+	data := testOFD(t, map[string]string{
+		"Doc_0/PublicRes.xml": testRes(`<ofd:ColorSpaces><ofd:ColorSpace ID="3" Type="CMYK"/><ofd:ColorSpace ID="4" Type="GRAY" BitsPerComponent="16" Profile="gray.icc"/></ofd:ColorSpaces>`),
+		"Doc_0/Res/gray.icc":  string(profile),
+		"Doc_0/Pages/Page_0/Content.xml": testPage("", rect(0, ` ColorSpace="3"`, "0 255 255 0")+
+			rect(12, ` ColorSpace="4"`, "32768")+
+			rect(24, "", "255 0 0")+
+			rect(36, ` ColorSpace="3"`, "0 0 0 255")+
+			`<ofd:PathObject ID="60" Boundary="0 30 50 10" Fill="false"><ofd:StrokeColor ColorSpace="3" Value="0 0 255 0"/><ofd:AbbreviatedData>M 0 5 L 50 5</ofd:AbbreviatedData></ofd:PathObject>`),
+	})
+
+	doc := openDocument(t, data)
+	var buf bytes.Buffer
+	r := pdf.New(&buf, 50, 50, &pdf.Options{})
+	assert.Nil(t, doc.Draw(canvas.NewContext(r), doc.Pages[0]))
+	assert.Nil(t, r.Close())
+	out := buf.String()
+	assert.Contains(t, out, " 0 1 1 0 k ")
+	assert.Contains(t, out, " 0 0 0 1 k ")
+	assert.Contains(t, out, " 0 0 1 0 K ")
+	// 与 CMYK 红色相同的 RGB 红色仍按 RGB 输出
+	assert.Contains(t, out, " 1 0 0 rg ")
+	assert.Regexp(t, `/CS0 cs \.5\d* scn `, out)
+	assert.Regexp(t, `/ColorSpace<</CS0\[/ICCBased \d+ 0 R\]>>`, out)
+	assert.Contains(t, out, "/Alternate/DeviceGray")
+	assert.Equal(t, 1, strings.Count(out, "/Alternate/"))
+
+	// OFD 格式的签章使用签章文档自身的颜色空间
+	seal := testOFD(t, map[string]string{
+		"Doc_0/PublicRes.xml":            testRes(`<ofd:ColorSpaces><ofd:ColorSpace ID="3" Type="CMYK"/></ofd:ColorSpaces>`),
+		"Doc_0/Pages/Page_0/Content.xml": testPage("", rect(0, ` ColorSpace="3"`, "255 0 0 0")),
+	})
+	buf.Reset()
+	r = pdf.New(&buf, 50, 50, &pdf.Options{})
+	assert.Nil(t, doc.Seal(canvas.NewContext(r), &parser.SealInfo{
+		StampAnnot: &models.StampAnnot{Boundary: models.StBox{Width: 50, Height: 50}},
+		SealData:   &parser.SealData{FileType: "ofd", Data: seal},
+	}, models.StBox{Width: 50, Height: 50}))
+	assert.Nil(t, r.Close())
+	assert.Contains(t, buf.String(), " 1 0 0 0 k ")
+
+	// 位图输出不受影响
+	img := rasterize(t, doc, 1, 4)
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, at(img, 4, 5, 10))
+	assert.Equal(t, color.RGBA{128, 128, 128, 255}, at(img, 4, 17, 10))
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, at(img, 4, 29, 10))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, at(img, 4, 41, 10))
+}
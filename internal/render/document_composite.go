@@ -0,0 +1,78 @@
+package render
+
+import (
+	"fmt"
+
+	"github.com/tdewolff/canvas"
+	"github.com/zc310/ofd/internal/models"
+)
+
+// PageBlock 绘制页块内容，嵌套页块先于本层图元绘制
+func (p *Document) PageBlock(ctx *canvas.Context, block *models.CTPageBlock, dp *models.DrawParam, pb models.StBox) {
+	for i := range block.PageBlock {
+		p.PageBlock(ctx, &block.PageBlock[i].CTPageBlock, dp, pb)
+	}
+	for _, object := range block.ImageObject {
+		p.Image(ctx, object, dp, pb)
+	}
+	for _, object := range block.PathObject {
+		p.Path(ctx, object, dp, pb)
+	}
+	for _, object := range block.TextObject {
+		p.Text(ctx, object, dp, pb)
+	}
+	for _, object := range block.CompositeObject {
+		p.Composite(ctx, object, dp, pb)
+	}
+}
+
+// Composite 绘制复合对象，引用的矢量图像内容经对象 CTM 变换后放置在 Boundary 中
+func (p *Document) Composite(ctx *canvas.Context, object models.CompositeObject, dp *models.DrawParam, pb models.StBox) {
+	unit, ok := p.CompositeUnits[models.StID(object.ResourceID)]
+	if !ok {
+		p.issue(IssueResource, object.ResourceID, fmt.Sprintf("复合对象 %d 引用的资源 %d 不存在", object.ID, object.ResourceID))
+		return
+	}
+	p.useFeature("CompositeObject")
+	if object.DrawParam > 0 {
+		if v := p.Document.GetDrawParam(models.StID(object.DrawParam)); v != nil {
+			dp = v
+		} else {
+			p.issue(IssueResource, object.DrawParam, fmt.Sprintf("绘制参数 %d 不存在", object.DrawParam))
+		}
+	}
+
+	ctx.Push()
+	defer ctx.Pop()
+	defer p.clipUnit(ctx, &object.CTGraphicUnit, pb)()
+	p.clipRect(ctx, object.Boundary, pb)
+	// 内容以 Height 为 0 的页面绘制，即 y 轴取反，再经 CTM、Boundary 变换回页面坐标
+	ctx.ComposeView(unitMatrix(&object.CTGraphicUnit, pb).Scale(1.0, -1.0))
+
+	if id := compositeImage(unit); id > 0 && (p.opts.CompositeImage || isEmptyBlock(&unit.Content)) {
+		p.imageBox(ctx, id, unit.Width, unit.Height)
+		return
+	}
+	p.PageBlock(ctx, &unit.Content, dp, models.StBox{})
+}
+
+// imageBox 将位图资源绘制到 (0, 0, width, height) 中，用于 y 轴已取反的嵌套内容
+func (p *Document) imageBox(ctx *canvas.Context, id models.StRefID, width, height float64) {
+	image := models.ImageObject{}
+	image.ResourceID = id
+	image.Boundary = models.StBox{Width: width, Height: height}
+	p.Image(ctx, image, nil, models.StBox{})
+}
+
+// compositeImage 返回矢量图像的替代位图，优先使用 Substitution
+func compositeImage(unit *models.CompositeGraphicUnit) models.StRefID {
+	if unit.Substitution > 0 {
+		return unit.Substitution
+	}
+	return unit.Thumbnail
+}
+
+func isEmptyBlock(block *models.CTPageBlock) bool {
+	return len(block.TextObject) == 0 && len(block.PathObject) == 0 && len(block.ImageObject) == 0 &&
+		len(block.CompositeObject) == 0 && len(block.PageBlock) == 0
+}
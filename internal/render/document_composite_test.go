@@ -0,0 +1,68 @@
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// compositeDocument 返回含复合对象的文档，资源在 DocumentRes 中。矢量图像 6 为 10x10，左半部为红色；
+// 位图 5 为蓝色，位图 7 为绿色
+func compositeDocument(t *testing.T, unit, object string, opts ...Options) *Document {
+	return openDocument(t, testOFD(t, map[string]string{
+		"Doc_0/Document.xml": testDocument(`<ofd:DocumentRes>DocumentRes.xml</ofd:DocumentRes>`, ""),
+		"Doc_0/DocumentRes.xml": testRes(`<ofd:MultiMedias>` +
+			`<ofd:MultiMedia ID="5" Type="Image" Format="PNG"><ofd:MediaFile>blue.png</ofd:MediaFile></ofd:MultiMedia>` +
+			`<ofd:MultiMedia ID="7" Type="Image" Format="PNG"><ofd:MediaFile>green.png</ofd:MediaFile></ofd:MultiMedia>` +
+			`</ofd:MultiMedias><ofd:CompositeGraphicUnits>` + unit + `</ofd:CompositeGraphicUnits>`),
+		"Doc_0/Res/blue.png":             string(solidPNG(t, color.RGBA{0, 0, 255, 255}, 2, 2)),
+		"Doc_0/Res/green.png":            string(solidPNG(t, color.RGBA{0, 255, 0, 255}, 2, 2)),
+		"Doc_0/Pages/Page_0/Content.xml": testPage("", object),
+	}), opts...)
+}
+
+const compositeContent = `<ofd:Content><ofd:PathObject ID="20" Boundary="0 0 5 10" Fill="true" Stroke="false"><ofd:FillColor Value="255 0 0"/><ofd:AbbreviatedData>M 0 0 L 5 0 L 5 10 L 0 10 C</ofd:AbbreviatedData></ofd:PathObject></ofd:Content>`
+
+func TestComposite(t *testing.T) {
+	const dpmm = 4.0
+	red, blue, green := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}, color.RGBA{0, 255, 0, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	// 放大 2 倍后放置在 10 10 20 20 中
+	object := `<ofd:CompositeObject ID="10" Boundary="10 10 20 20" CTM="2 0 0 2 0 0" ResourceID="6"/>`
+	unit := `<ofd:CompositeGraphicUnit ID="6" Width="10" Height="10"><ofd:Substitution>5</ofd:Substitution>` + compositeContent + `</ofd:CompositeGraphicUnit>`
+
+	// 默认绘制矢量内容
+	doc := compositeDocument(t, unit, object)
+	img := rasterize(t, doc, 1, dpmm)
+	assert.Equal(t, red, at(img, dpmm, 15, 20))
+	assert.Equal(t, white, at(img, dpmm, 25, 20))
+	assert.Equal(t, white, at(img, dpmm, 5, 20))
+	assert.Contains(t, doc.Report().Features, "CompositeObject")
+
+	// CompositeImage 使用替代位图
+	img = rasterize(t, compositeDocument(t, unit, object, Options{CompositeImage: true}), 1, dpmm)
+	assert.Equal(t, blue, at(img, dpmm, 15, 20))
+	assert.Equal(t, blue, at(img, dpmm, 25, 20))
+	assert.Equal(t, white, at(img, dpmm, 35, 20))
+
+	// 没有矢量内容时使用替代位图，优先使用 Substitution，其次 Thumbnail
+	img = rasterize(t, compositeDocument(t, `<ofd:CompositeGraphicUnit ID="6" Width="10" Height="10"><ofd:Thumbnail>7</ofd:Thumbnail><ofd:Substitution>5</ofd:Substitution></ofd:CompositeGraphicUnit>`, object), 1, dpmm)
+	assert.Equal(t, blue, at(img, dpmm, 25, 20))
+	img = rasterize(t, compositeDocument(t, `<ofd:CompositeGraphicUnit ID="6" Width="10" Height="10"><ofd:Thumbnail>7</ofd:Thumbnail></ofd:CompositeGraphicUnit>`, object), 1, dpmm)
+	assert.Equal(t, green, at(img, dpmm, 25, 20))
+
+	// 引用不存在的资源
+	doc = compositeDocument(t, unit, `<ofd:CompositeObject ID="10" Boundary="10 10 20 20" ResourceID="8"/>`)
+	rasterize(t, doc, 1, dpmm)
+	assert.Equal(t, []Issue{{Kind: IssueResource, ID: 8, Message: "复合对象 10 引用的资源 8 不存在"}}, doc.Report().Issues)
+}
+
+func TestComposite_Clip(t *testing.T) {
+	const dpmm = 4.0
+	unit := `<ofd:CompositeGraphicUnit ID="6" Width="10" Height="10">` + compositeContent + `</ofd:CompositeGraphicUnit>`
+	// 内容超出 Boundary 的部分被裁剪
+	img := rasterize(t, compositeDocument(t, unit, `<ofd:CompositeObject ID="10" Boundary="10 10 10 10" CTM="4 0 0 4 0 0" ResourceID="6"/>`), 1, dpmm)
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, at(img, dpmm, 15, 15))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, at(img, dpmm, 25, 15))
+}
@@ -1,9 +1,14 @@
 package render
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"log/slog"
-
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -11,44 +16,95 @@ import (
 	"github.com/tdewolff/font"
 	"github.com/zc310/ofd/internal/models"
 	"github.com/zc310/ofd/internal/parser"
-	"github.com/zc310/ofd/internal/utils"
 )
 
 var (
-	onceFonts  sync.Once
-	fontFamily *canvas.FontFamily
+	onceFonts      sync.Once
+	fontFamily     *canvas.FontFamily
+	fontFamilyName string
 )
 
+// defaultFonts 找不到任何匹配字体时使用的字体
+var defaultFonts = []string{"仿宋", "楷体", "黑体", "Cantarell", "Noto Sans", "Noto Serif", "DejaVu Serif", "Times"}
+
+// fontAliases 常用中文字体的其他名称，键为规范化后的名称
+var fontAliases = map[string][]string{
+	"宋体":            {"SimSun", "NSimSun", "Songti SC", "STSong", "Noto Serif CJK SC", "Source Han Serif SC", "AR PL UMing CN"},
+	"simsun":        {"宋体", "NSimSun", "Songti SC", "STSong", "Noto Serif CJK SC", "Source Han Serif SC", "AR PL UMing CN"},
+	"新宋体":           {"NSimSun", "SimSun"},
+	"黑体":            {"SimHei", "Heiti SC", "STHeiti", "Noto Sans CJK SC", "Source Han Sans SC", "WenQuanYi Zen Hei", "WenQuanYi Micro Hei"},
+	"simhei":        {"黑体", "Heiti SC", "STHeiti", "Noto Sans CJK SC", "Source Han Sans SC", "WenQuanYi Zen Hei", "WenQuanYi Micro Hei"},
+	"楷体":            {"KaiTi", "楷体_GB2312", "KaiTi_GB2312", "STKaiti", "Kaiti SC", "AR PL UKai CN"},
+	"kaiti":         {"楷体", "楷体_GB2312", "KaiTi_GB2312", "STKaiti", "Kaiti SC", "AR PL UKai CN"},
+	"楷体gb2312":      {"楷体", "KaiTi", "STKaiti", "AR PL UKai CN"},
+	"仿宋":            {"FangSong", "仿宋_GB2312", "FangSong_GB2312", "STFangsong"},
+	"fangsong":      {"仿宋", "仿宋_GB2312", "FangSong_GB2312", "STFangsong"},
+	"仿宋gb2312":      {"仿宋", "FangSong", "STFangsong"},
+	"微软雅黑":          {"Microsoft YaHei", "PingFang SC", "Noto Sans CJK SC", "Source Han Sans SC"},
+	"couriernew":    {"Courier", "Liberation Mono", "Nimbus Mono PS"},
+	"arial":         {"Liberation Sans", "Helvetica", "Nimbus Sans"},
+	"timesnewroman": {"Times", "Liberation Serif", "Nimbus Roman"},
+}
+
+// genericFonts 按 Serif/FixedWidth 属性及字符集选择的中文等通用字体，键为 serif、sans-serif、monospace 或字符集
+var genericFonts = map[string][]string{
+	"serif":      {"SimSun", "Songti SC", "STSong", "Noto Serif CJK SC", "Source Han Serif SC", "AR PL UMing CN"},
+	"sans-serif": {"SimHei", "Microsoft YaHei", "PingFang SC", "Heiti SC", "Noto Sans CJK SC", "Source Han Sans SC", "WenQuanYi Micro Hei", "WenQuanYi Zen Hei"},
+	"monospace":  {"NSimSun", "Noto Sans Mono CJK SC", "Source Han Mono SC"},
+	"big5":       {"PMingLiU", "MingLiU", "Noto Serif CJK TC", "Noto Sans CJK TC", "Source Han Sans TC", "AR PL UMing TW"},
+	"shift-jis":  {"MS Mincho", "MS Gothic", "Noto Serif CJK JP", "Noto Sans CJK JP", "Source Han Sans JP"},
+	"wansung":    {"Batang", "Gulim", "Noto Serif CJK KR", "Noto Sans CJK KR", "Source Han Sans KR"},
+	"johab":      {"Batang", "Gulim", "Noto Serif CJK KR", "Noto Sans CJK KR", "Source Han Sans KR"},
+}
+
+// latinFonts 只含西文字形的通用字体，在默认字体之后尝试
+var latinFonts = map[string][]string{
+	"serif":      {"Noto Serif", "DejaVu Serif", "Liberation Serif", "Times New Roman", "Times"},
+	"sans-serif": {"Noto Sans", "DejaVu Sans", "Liberation Sans", "Arial", "Helvetica"},
+	"monospace":  {"Noto Sans Mono", "DejaVu Sans Mono", "Liberation Mono", "Courier New", "Courier"},
+}
+
 type Fonts struct {
 	*parser.Document
 	Fonts map[models.StRefID]*canvas.FontFamily
+	// dirs 查找字体的目录，依次为 FontDirs 选项及系统字体目录
+	dirs []string
+	// fontMap 字体替换表，键为规范化后的字体名
+	fontMap map[string][]string
+	// usage 已加载字体的请求及实际使用情况
+	usage map[models.StRefID]FontUsage
 }
 
 func NewFonts(doc *parser.Document) *Fonts {
+	p := &Fonts{Document: doc, Fonts: make(map[models.StRefID]*canvas.FontFamily)}
+	p.configure(&Options{})
 	onceFonts.Do(func() {
-		fontFamily = canvas.NewFontFamily("default")
-		for _, name := range []string{"仿宋", "楷体", "黑体", "Cantarell", "Noto Sans", "Noto Serif", "DejaVu Serif", "Times"} {
-			if err := fontFamily.LoadSystemFont(name, canvas.FontRegular); err == nil {
-				break
-			}
-		}
+		fontFamily, fontFamilyName = p.find(defaultFonts)
 	})
-	return &Fonts{Document: doc, Fonts: make(map[models.StRefID]*canvas.FontFamily)}
+	return p
+}
+
+// configure 按渲染选项设置字体目录及替换表
+func (p *Fonts) configure(opts *Options) {
+	p.dirs = append(append([]string(nil), opts.FontDirs...), font.DefaultFontDirs()...)
+	p.fontMap = make(map[string][]string, len(opts.FontMap))
+	for name, targets := range opts.FontMap {
+		name = normalizeFontName(name)
+		p.fontMap[name] = append(p.fontMap[name], targets...)
+	}
+	clear(p.Fonts)
+	p.usage = make(map[models.StRefID]FontUsage)
 }
+
 func (p *Fonts) LoadFont(id models.StRefID) (*canvas.FontFamily, error) {
-	var err error
-	var f *canvas.FontFamily
-	if f = p.Fonts[id]; f != nil {
+	if f := p.Fonts[id]; f != nil {
 		return f, nil
 	}
 	ft := p.FontRes[models.StID(id)]
 	if ft == nil {
-		p.Fonts[id] = fontFamily
 		slog.Error(fmt.Sprintf("font %d not exist", id))
-		return fontFamily, nil
+		return p.defaultFamily(id, &models.Font{})
 	}
-	fontName := ft.FontName
-	f = canvas.NewFontFamily(fontName)
 
 	//if ft.FontFile != "" {
 	//	var buf []byte
@@ -63,40 +119,147 @@ func (p *Fonts) LoadFont(id models.StRefID) (*canvas.FontFamily, error) {
 	//	slog.Error(fmt.Sprintf("load font %s %s: %s", ft.FontName, ft.FontFile, err))
 	//}
 
-	fontStyle := canvas.FontRegular
-	if ft.Italic {
-		fontStyle = fontStyle | canvas.FontItalic
-	}
-	if ft.Bold {
-		fontStyle = fontStyle | canvas.FontBold
-	}
-	if err = f.LoadSystemFont(fontName, fontStyle); err == nil {
+	if f, name := p.find(p.candidates(ft)); f != nil {
+		p.use(id, ft, name)
 		p.Fonts[id] = f
 		return f, nil
 	}
-	if fontName == "宋体" || strings.ToLower(fontName) == "simsun" {
-		var filepath string
-		if filepath, err = utils.FindFirstFileInDirs(font.DefaultFontDirs(), "simsun.ttc"); err == nil {
-			if err = f.LoadFontFile(filepath, fontStyle); err == nil {
-				p.Fonts[id] = f
-				return f, nil
-			}
+	slog.Info(fmt.Sprintf("font %d %s %s not exist", id, ft.FontName, ft.FontFile))
+	return p.defaultFamily(id, ft)
+}
+
+// use 记录字体 id 实际使用的字体 name
+func (p *Fonts) use(id models.StRefID, ft *models.Font, name string) {
+	n := normalizeFontName(name)
+	p.usage[id] = FontUsage{
+		ID:          id,
+		FontName:    ft.FontName,
+		FamilyName:  ft.FamilyName,
+		Substitute:  name,
+		Substituted: n != normalizeFontName(ft.FontName) && n != normalizeFontName(ft.FamilyName),
+	}
+}
+
+// candidates 返回按优先级排列的候选字体：替换表、字体名及其别名、按字符集及 Serif/FixedWidth 选择的通用字体、
+// 默认字体 (替换表中的 default 或以 default 为名注册的字体)，最后为西文通用字体
+func (p *Fonts) candidates(ft *models.Font) []string {
+	var names []string
+	for _, name := range []string{ft.FontName, ft.FamilyName} {
+		if name == "" {
+			continue
 		}
+		names = append(names, p.fontMap[normalizeFontName(name)]...)
+		names = append(names, name)
+		names = append(names, fontAliases[normalizeFontName(name)]...)
 	}
-	if fontName == "黑体" || strings.ToLower(fontName) == "simhei" {
-		var filepath string
-		if filepath, err = utils.FindFirstFileInDirs(font.DefaultFontDirs(), "simhei.ttf"); err == nil {
-			if err = f.LoadFontFile(filepath, fontStyle); err == nil {
-				p.Fonts[id] = f
-				return f, nil
-			}
+	generic := "sans-serif"
+	if ft.FixedWidth {
+		generic = "monospace"
+	} else if ft.Serif {
+		generic = "serif"
+	}
+	for _, key := range []string{strings.ToLower(ft.Charset), generic} {
+		if key == "" {
+			continue
 		}
+		names = append(names, p.fontMap[normalizeFontName(key)]...)
+		names = append(names, key)
+		names = append(names, genericFonts[key]...)
 	}
-	slog.Info(fmt.Sprintf("font %d %s %s not exist", id, ft.FontName, ft.FontFile))
-	if fontFamily != nil {
-		p.Fonts[id] = fontFamily
-		return fontFamily, nil
+	names = append(names, p.fontMap["default"]...)
+	names = append(names, "default")
+	return append(names, latinFonts[generic]...)
+}
+
+// find 返回第一个能找到的候选字体及其名称，候选也可以是字体文件路径 (字体集合可加 #序号)
+func (p *Fonts) find(names []string) (*canvas.FontFamily, string) {
+	for _, name := range names {
+		var srcs []fontSource
+		if path, index, ok := fontFile(name); ok {
+			srcs = fileSource(path, index)
+		} else {
+			srcs = catalog.lookup(name, p.dirs)
+		}
+		if len(srcs) == 0 {
+			continue
+		}
+		f, err := catalog.family(name, srcs)
+		if err != nil {
+			slog.Error(err.Error())
+			continue
+		}
+		return f, name
 	}
+	return nil, ""
+}
 
-	return fontFamily, nil
+// defaultFamily 返回替换表中 default 指定或以 default 为名注册的字体，均没有时使用默认字体
+func (p *Fonts) defaultFamily(id models.StRefID, ft *models.Font) (*canvas.FontFamily, error) {
+	f, name := p.find(append(slices.Clip(p.fontMap["default"]), "default"))
+	if f == nil {
+		f, name = fontFamily, fontFamilyName
+	}
+	p.use(id, ft, name)
+	if f == nil {
+		return nil, fmt.Errorf("font %d: 没有可用的字体", id)
+	}
+	p.Fonts[id] = f
+	return f, nil
+}
+
+// fontFile 判断候选是否为存在的字体文件路径，path#n 表示字体集合中的第 n 个字体
+func fontFile(name string) (string, int, bool) {
+	if !strings.ContainsAny(name, `/\`) {
+		return "", 0, false
+	}
+	path, index := name, 0
+	if i := strings.LastIndexByte(name, '#'); i > 0 {
+		if n, err := strconv.Atoi(name[i+1:]); err == nil {
+			path, index = name[:i], n
+		}
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", 0, false
+	}
+	return filepath.Clean(path), index, true
+}
+
+// fileSource 读取字体文件中第 index 个字体的样式
+func fileSource(path string, index int) []fontSource {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	faces, err := readFontFaces(f)
+	if err != nil || index < 0 || index >= len(faces) {
+		slog.Error(fmt.Sprintf("font file %s#%d: %v", path, index, err))
+		return nil
+	}
+	return []fontSource{{path: path, index: index, style: faces[index].style}}
+}
+
+// ParseFontMap 读取类似 fontconfig 的字体替换表，每行为 "字体名 = 候选1, 候选2"，
+// 候选可以是字体名或字体文件路径，# 开头的行为注释。
+// 字体名也可以是 serif、sans-serif、monospace、字符集 (如 big5) 或 default
+func ParseFontMap(r io.Reader) (map[string][]string, error) {
+	m := make(map[string][]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		name, targets, ok := strings.Cut(s, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("字体替换表第%d行格式错误: %s", line, s)
+		}
+		for _, target := range strings.Split(targets, ",") {
+			if target = strings.TrimSpace(target); target != "" {
+				m[name] = append(m[name], target)
+			}
+		}
+	}
+	return m, scanner.Err()
 }
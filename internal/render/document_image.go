@@ -1,22 +1,41 @@
 package render
 
 import (
-	"log/slog"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
 
 	"github.com/tdewolff/canvas"
-	_ "github.com/xiaoqidun/jbig2"
+	"github.com/xiaoqidun/jbig2"
+	"github.com/zc310/ofd/internal/jpeg2000"
 	"github.com/zc310/ofd/internal/models"
 )
 
 func (p *Document) Image(ctx *canvas.Context, object models.ImageObject, dp *models.DrawParam, pb models.StBox) {
-	media, ok := p.Res[models.StID(object.ResourceID)]
-	if !ok {
+	id := object.ResourceID
+	if p.opts.Substitution && object.Substitution > 0 {
+		if _, ok := p.Res[models.StID(object.Substitution)]; ok {
+			id = object.Substitution
+		}
+	}
+	// 位图绘制在图元坐标的单位正方形中，未指定 CTM 时缩放到 Boundary
+	var m canvas.Matrix
+	if object.CTM != nil {
+		m = unitMatrix(&object.CTGraphicUnit, pb)
+	} else {
+		m = pageMatrix(pb).Translate(object.Boundary.X, object.Boundary.Y).Scale(object.Boundary.Width, object.Boundary.Height)
+	}
+	if m.Det() == 0 {
 		return
 	}
 
-	img, err := p.Document.Common.FileCache.ParseImage(string(media.MediaFile.Clean()))
-	if err != nil {
-		slog.Error(err.Error())
+	img := p.loadImage(imageKey{id: id, mask: object.ImageMask})
+	if img == nil {
 		return
 	}
 	imgBounds := img.Bounds()
@@ -27,16 +46,242 @@ func (p *Document) Image(ctx *canvas.Context, object models.ImageObject, dp *mod
 
 	ctx.Push()
 	defer ctx.Pop()
-	if object.CTM != nil {
-		tx, ty := object.CTM.Transform(0.0, 0.0)
-		yPos := pb.Height - (ty + object.Boundary.Y + object.Boundary.Height)
-		ctx.Translate(tx+object.Boundary.X, yPos)
-		ctx.Scale(object.CTM[0]/imgW, object.CTM[3]/imgH)
+	defer p.clipUnit(ctx, &object.CTGraphicUnit, pb)()
+	ctx.Push()
+	ctx.ComposeView(m.Translate(0.0, 1.0).Scale(1.0/imgW, -1.0/imgH))
+	p.drawImage(ctx, img)
+	ctx.Pop()
+
+	if object.Border != nil {
+		p.imageBorder(ctx, object.Border, m)
+	}
+}
+
+// imageIssue 记录位图资源不存在、无法解码或格式不支持的问题
+func (p *Document) imageIssue(id models.StRefID, err error) {
+	kind := IssueImage
+	if _, ok := p.Res[models.StID(id)]; !ok {
+		kind = IssueResource
+	} else if errors.Is(err, errRawCCITT) {
+		kind = IssueFeature
+	}
+	p.issue(kind, id, err.Error())
+}
+
+// loadImage 返回应用图像蒙版后的位图，无法加载时返回 nil。
+// 位图、蒙版及应用蒙版的结果在首次绘制时生成并缓存，无法加载的位图也记入缓存，问题只记录一次
+func (p *Document) loadImage(key imageKey) image.Image {
+	if img, ok := p.images.get(key); ok {
+		return img
+	}
+	var img image.Image
+	if key.mask == 0 {
+		var err error
+		if img, err = p.decodeResource(key.id); err != nil {
+			p.imageIssue(key.id, err)
+		}
+	} else if img = p.loadImage(imageKey{id: key.id}); img != nil {
+		maskKey := imageKey{id: key.mask}
+		mask, ok := p.images.get(maskKey)
+		if !ok {
+			var err error
+			if mask, err = p.decodeResource(key.mask); err != nil {
+				p.imageIssue(key.mask, fmt.Errorf("图像蒙版无法加载: %w", err))
+			}
+			p.images.put(maskKey, mask, p.opts.imageCacheSize())
+		}
+		if mask != nil {
+			img = applyMask(img, mask)
+		}
+	}
+	p.images.put(key, img, p.opts.imageCacheSize())
+	return img
+}
+
+// decodeResource 读取并解码多媒体资源中的位图
+func (p *Document) decodeResource(id models.StRefID) (image.Image, error) {
+	media, ok := p.Res[models.StID(id)]
+	if !ok {
+		return nil, fmt.Errorf("图像资源 %d 不存在", id)
+	}
+	data, err := p.Document.Common.FileCache.ParseContent(string(media.MediaFile.Clean()))
+	if err != nil {
+		return nil, fmt.Errorf("读取图像失败: %w", err)
+	}
+	var globals []byte
+	if loc, ok := p.JBIG2Globals[models.StID(id)]; ok {
+		if globals, err = p.Document.Common.FileCache.ParseContent(string(loc.Clean())); err != nil {
+			p.issue(IssueImage, id, fmt.Sprintf("JBIG2 全局段 %s 无法读取: %v", loc, err))
+		}
+	}
+	img, err := decodeImage(data, media.Format, globals)
+	if err != nil {
+		return nil, fmt.Errorf("解码图像 %s 失败: %w", media.MediaFile, err)
+	}
+	return img, nil
+}
+
+// decodeImage 按 MultiMedia 的 Format 解码位图，未指定或无法识别时按文件头识别。
+// 扫描件常见的 JBIG2 及 JPEG 2000 可以是不带文件头的原始码流；CCITT 数据需带 TIFF 封装，
+// 原始码流不含图像宽度，不支持
+func decodeImage(data []byte, format string, globals []byte) (image.Image, error) {
+	switch strings.ToUpper(strings.TrimSpace(format)) {
+	case "JBIG2", "JB2":
+		return decodeJBIG2(data, globals)
+	case "JPX", "JP2", "J2K", "J2C", "JPC", "JPEG2000":
+		return jpeg2000.Decode(bytes.NewReader(data))
+	case "CCITT", "G4", "CCITTFAX":
+		if !bytes.HasPrefix(data, []byte("II*\x00")) && !bytes.HasPrefix(data, []byte("MM\x00*")) {
+			return nil, errRawCCITT
+		}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// errRawCCITT 不带 TIFF 封装的 CCITT 数据缺少图像宽度，无法解码
+var errRawCCITT = errors.New("不支持不带 TIFF 封装的原始 CCITT 数据 (缺少图像宽度)")
+
+// jbig2Header JBIG2 文件头：顺序组织，页数未知
+var jbig2Header = []byte{0x97, 0x4A, 0x42, 0x32, 0x0D, 0x0A, 0x1A, 0x0A, 0x03}
+
+// decodeJBIG2 解码 JBIG2 数据。没有全局段的原始码流补上文件头后解码
+func decodeJBIG2(data, globals []byte) (image.Image, error) {
+	var (
+		d   *jbig2.Decoder
+		err error
+	)
+	if len(globals) > 0 {
+		// 无法解析的全局段会使解码器陷入死循环，先检查段头
+		if !validJBIG2Segments(globals) {
+			return nil, errors.New("JBIG2 全局段格式错误")
+		}
+		d, err = jbig2.NewDecoderWithGlobals(bytes.NewReader(data), globals)
 	} else {
-		yPos := pb.Height - (object.Boundary.Y + object.Boundary.Height)
-		ctx.Translate(object.Boundary.X, yPos)
-		ctx.Scale(object.Boundary.Width/imgW, object.Boundary.Height/imgH)
+		if !bytes.HasPrefix(data, jbig2Header[:8]) {
+			data = append(append([]byte{}, jbig2Header...), data...)
+		}
+		d, err = jbig2.NewDecoder(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
 	}
+	return d.Decode()
+}
 
-	ctx.DrawImage(0, 0, img, canvas.DPMM(1.0))
+// validJBIG2Segments 检查 data 由完整的 JBIG2 段组成，且每段的数据长度已知
+func validJBIG2Segments(data []byte) bool {
+	for len(data) > 0 {
+		// 段号、段标志、引用段数
+		if len(data) < 6 {
+			return false
+		}
+		number := binary.BigEndian.Uint32(data)
+		flags := data[4]
+		refs, n := int(data[5]>>5), 6
+		if refs == 7 {
+			if len(data) < 9 {
+				return false
+			}
+			refs = int(binary.BigEndian.Uint32(data[5:]) & 0x1FFFFFFF)
+			n = 9 + (refs+8)/8
+		}
+		// 引用段号、页面关联及数据长度
+		size := 4
+		switch {
+		case number <= 256:
+			size = 1
+		case number <= 65536:
+			size = 2
+		}
+		n += refs * size
+		if flags&0x40 != 0 {
+			n += 4
+		} else {
+			n++
+		}
+		if refs > len(data) || len(data) < n+4 {
+			return false
+		}
+		length := binary.BigEndian.Uint32(data[n:])
+		if length == 0xFFFFFFFF || uint64(len(data)-n-4) < uint64(length) {
+			return false
+		}
+		data = data[n+4+int(length):]
+	}
+	return true
+}
+
+// applyMask 以蒙版的灰度作为位图的透明度，蒙版按位图尺寸缩放
+func applyMask(img, mask image.Image) image.Image {
+	b, mb := img.Bounds(), mask.Bounds()
+	if b.Empty() || mb.Empty() {
+		return img
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		my := mb.Min.Y + y*mb.Dy()/b.Dy()
+		for x := 0; x < b.Dx(); x++ {
+			mx := mb.Min.X + x*mb.Dx()/b.Dx()
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			g := color.GrayModel.Convert(mask.At(mx, my)).(color.Gray)
+			c.A = uint8(uint16(c.A) * uint16(g.Y) / 255)
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+	return dst
+}
+
+// imageBorder 沿位图外框绘制边框，m 将单位正方形映射到当前坐标
+func (p *Document) imageBorder(ctx *canvas.Context, border *models.Border, m canvas.Matrix) {
+	// 边框按位图在页面上的实际宽高 (毫米) 构造，圆角半径不随 CTM 缩放
+	w := math.Hypot(m[0][0], m[1][0])
+	h := math.Hypot(m[0][1], m[1][1])
+	if w == 0 || h == 0 {
+		return
+	}
+	path := roundedRect(w, h, border.HorizonalCornerRadius, border.VerticalCornerRadius)
+	path = path.Transform(m.Scale(1.0/w, 1.0/h))
+
+	ctx.Push()
+	defer ctx.Pop()
+	defer p.deviceScope()()
+	ctx.SetFill(nil)
+	ctx.SetStrokeColor(canvas.Black)
+	if border.BorderColor != nil {
+		if value := p.colorValue(border.BorderColor); value != nil {
+			p.setStrokeColor(ctx, *value, p.deviceColor(border.BorderColor))
+		}
+	}
+	width := border.LineWidth
+	if width <= 0 {
+		width = 0.353
+	}
+	ctx.SetStrokeWidth(width)
+	ctx.SetStrokeCapper(canvas.ButtCap)
+	ctx.SetStrokeJoiner(canvas.MiterJoin)
+	if border.DashPattern != nil {
+		ctx.SetDashes(border.DashOffset, *border.DashPattern...)
+	}
+	p.drawPath(ctx, path)
+}
+
+// roundedRect 返回图元坐标 (y 轴向下) 中左上角在原点的圆角矩形
+func roundedRect(w, h, rx, ry float64) *canvas.Path {
+	rx, ry = min(max(rx, 0), w/2), min(max(ry, 0), h/2)
+	if rx == 0 || ry == 0 {
+		return canvas.Rectangle(w, h)
+	}
+	path := &canvas.Path{}
+	path.MoveTo(rx, 0)
+	path.LineTo(w-rx, 0)
+	path.ArcTo(rx, ry, 0, false, true, w, ry)
+	path.LineTo(w, h-ry)
+	path.ArcTo(rx, ry, 0, false, true, w-rx, h)
+	path.LineTo(rx, h)
+	path.ArcTo(rx, ry, 0, false, true, 0, h-ry)
+	path.LineTo(0, ry)
+	path.ArcTo(rx, ry, 0, false, true, rx, 0)
+	path.Close()
+	return path
 }
@@ -0,0 +1,299 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zc310/ofd/internal/models"
+)
+
+func TestImage_CTM(t *testing.T) {
+	const dpmm = 4.0
+	red := color.RGBA{255, 0, 0, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	render := func(ctm string) *Document {
+		return openDocument(t, testOFD(t, map[string]string{
+			"Doc_0/Document.xml":             testDocument(`<ofd:DocumentRes>DocumentRes.xml</ofd:DocumentRes>`, ""),
+			"Doc_0/DocumentRes.xml":          testRes(`<ofd:MultiMedias><ofd:MultiMedia ID="5" Type="Image" Format="PNG"><ofd:MediaFile>red.png</ofd:MediaFile></ofd:MultiMedia></ofd:MultiMedias>`),
+			"Doc_0/Res/red.png":              string(solidPNG(t, red, 4, 4)),
+			"Doc_0/Pages/Page_0/Content.xml": testPage("", `<ofd:ImageObject ID="10" Boundary="10 10 30 30" CTM="`+ctm+`" ResourceID="5"/>`),
+		}))
+	}
+	// CTM 将单位正方形缩放到 20x20 毫米
+	img := rasterize(t, render("20 0 0 20 0 0"), 1, dpmm)
+	assert.Equal(t, red, at(img, dpmm, 20, 20))
+	assert.Equal(t, white, at(img, dpmm, 35, 35))
+	// 旋转 90 度后平移回 Boundary 中
+	img = rasterize(t, render("0 20 -20 0 20 0"), 1, dpmm)
+	assert.Equal(t, red, at(img, dpmm, 15, 15))
+	assert.Equal(t, white, at(img, dpmm, 35, 15))
+}
+
+func TestImage_MaskBorder(t *testing.T) {
+	const dpmm = 10.0
+	red := func(c color.RGBA) bool { return c.R > 200 && c.G < 60 && c.B < 60 }
+	blue := func(c color.RGBA) bool { return c.R < 60 && c.G < 60 && c.B > 200 }
+	// 蒙版左半部为黑色，右半部为白色
+	mask := image.NewGray(image.Rect(0, 0, 4, 4))
+	for i := range mask.Pix {
+		if i%4 >= 2 {
+			mask.Pix[i] = 0xFF
+		}
+	}
+	var buf bytes.Buffer
+	assert.Nil(t, png.Encode(&buf, mask))
+	doc := openDocument(t, testOFD(t, map[string]string{
+		"Doc_0/Document.xml": testDocument(`<ofd:DocumentRes>DocumentRes.xml</ofd:DocumentRes>`, ""),
+		"Doc_0/DocumentRes.xml": testRes(`<ofd:MultiMedias>` +
+			`<ofd:MultiMedia ID="5" Type="Image" Format="PNG"><ofd:MediaFile>red.png</ofd:MediaFile></ofd:MultiMedia>` +
+			`<ofd:MultiMedia ID="6" Type="Image" Format="PNG"><ofd:MediaFile>mask.png</ofd:MediaFile></ofd:MultiMedia></ofd:MultiMedias>`),
+		"Doc_0/Res/red.png":  string(solidPNG(t, color.RGBA{255, 0, 0, 255}, 4, 4)),
+		"Doc_0/Res/mask.png": buf.String(),
+		"Doc_0/Pages/Page_0/Content.xml": testPage("", `<ofd:ImageObject ID="10" Boundary="10 10 20 20" CTM="20 0 0 20 0 0" ResourceID="5" ImageMask="6">`+
+			`<ofd:Border LineWidth="1" HorizonalCornerRadius="3" VerticalCornerRadius="3"><ofd:BorderColor Value="0 0 255"/></ofd:Border></ofd:ImageObject>`),
+	}))
+	img := rasterize(t, doc, 1, dpmm)
+
+	// 蒙版黑色部分完全透明，白色部分不透明
+	assert.Equal(t, 0, countNear(img, dpmm, 15, 20, 3, red))
+	assert.Greater(t, countNear(img, dpmm, 25, 20, 3, red), 0)
+	// 边框沿外框绘制，圆角处不绘制
+	assert.Greater(t, countNear(img, dpmm, 10, 20, 0.3, blue), 0)
+	assert.Greater(t, countNear(img, dpmm, 20, 30, 0.3, blue), 0)
+	assert.Equal(t, 0, countNear(img, dpmm, 10, 10, 0.3, blue))
+
+	// 应用蒙版的结果与原位图、蒙版分别缓存
+	for _, key := range []imageKey{{id: 5, mask: 6}, {id: 5}, {id: 6}} {
+		cached, ok := doc.images.get(key)
+		assert.True(t, ok, "%+v", key)
+		assert.NotNil(t, cached, "%+v", key)
+	}
+}
+
+func TestDecodeImage(t *testing.T) {
+	data, err := os.ReadFile("../jpeg2000/testdata/relax.jp2")
+	assert.Nil(t, err)
+
+	img, err := decodeImage(data, "", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, 400, 300), img.Bounds())
+
+	// 不带 JP2 文件头的原始码流
+	raw := data[bytes.Index(data, []byte{0xFF, 0x4F, 0xFF, 0x51}):]
+	img, err = decodeImage(raw, "JPX", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, 400, 300), img.Bounds())
+
+	// 无法识别的 Format 按文件头识别
+	var buf bytes.Buffer
+	assert.Nil(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 3, 2))))
+	img, err = decodeImage(buf.Bytes(), "PNG ", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, img.Bounds().Dx())
+
+	// 原始 CCITT 数据缺少图像宽度，不支持
+	_, err = decodeImage([]byte{0x26, 0xA0, 0x00}, "CCITT", nil)
+	assert.ErrorIs(t, err, errRawCCITT)
+}
+
+// gopher 返回 153x55 的黑白测试图像及其 CCITT G4 数据 (取自 golang.org/x/image/ccitt 的测试数据)
+func gopher(t *testing.T) (image.Image, []byte) {
+	f, err := os.Open("testdata/bw-gopher.png")
+	assert.Nil(t, err)
+	defer f.Close()
+	img, err := png.Decode(f)
+	assert.Nil(t, err)
+	data, err := os.ReadFile("testdata/bw-gopher.ccitt_group4")
+	assert.Nil(t, err)
+	return img, data
+}
+
+// assertBilevel 比较两幅黑白图像
+func assertBilevel(t *testing.T, want, got image.Image) {
+	if !assert.Equal(t, want.Bounds(), got.Bounds()) {
+		return
+	}
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			w, g := color.GrayModel.Convert(want.At(x, y)).(color.Gray), color.GrayModel.Convert(got.At(x, y)).(color.Gray)
+			if w.Y < 128 != (g.Y < 128) {
+				t.Errorf("(%d, %d): %v != %v", x, y, w, g)
+				return
+			}
+		}
+	}
+}
+
+// ccittTIFF 返回以 TIFF 封装的 CCITT G4 数据，单条带，0 为白色
+func ccittTIFF(width, height uint16, data []byte) []byte {
+	const entries = 8
+	offset := uint32(8 + 2 + entries*12 + 4)
+	b := append([]byte("II*\x00"), 8, 0, 0, 0)
+	b = binary.LittleEndian.AppendUint16(b, entries)
+	for _, e := range [][2]uint32{
+		{256, uint32(width)}, {257, uint32(height)}, {258, 1}, {259, 4}, {262, 0},
+		{273, offset}, {278, uint32(height)}, {279, uint32(len(data))},
+	} {
+		// LONG 类型，单个值
+		b = binary.LittleEndian.AppendUint16(b, uint16(e[0]))
+		b = binary.LittleEndian.AppendUint16(b, 4)
+		b = binary.LittleEndian.AppendUint32(b, 1)
+		b = binary.LittleEndian.AppendUint32(b, e[1])
+	}
+	b = append(b, 0, 0, 0, 0)
+	return append(b, data...)
+}
+
+func TestDecodeImage_CCITT(t *testing.T) {
+	want, data := gopher(t)
+	// 宽度取自 TIFF 封装
+	for _, format := range []string{"CCITT", "G4", ""} {
+		img, err := decodeImage(ccittTIFF(153, 55, data), format, nil)
+		if assert.Nil(t, err, format) {
+			assertBilevel(t, want, img)
+		}
+	}
+
+	// 不带 TIFF 封装的原始数据
+	_, err := decodeImage(data, "G4", nil)
+	assert.ErrorIs(t, err, errRawCCITT)
+}
+
+// jbig2Segment 返回 JBIG2 段，段头不引用其他段
+func jbig2Segment(number uint32, kind byte, page byte, data []byte) []byte {
+	seg := binary.BigEndian.AppendUint32(nil, number)
+	seg = append(seg, kind, 0, page)
+	seg = binary.BigEndian.AppendUint32(seg, uint32(len(data)))
+	return append(seg, data...)
+}
+
+// jbig2Gopher 返回不带文件头的 JBIG2 码流，页面为以 MMR 编码的 gopher 测试图像
+func jbig2Gopher(t *testing.T) []byte {
+	_, mmr := gopher(t)
+	// 宽、高、X、Y 分辨率，页面标志，条带信息
+	info := binary.BigEndian.AppendUint32(nil, 153)
+	info = binary.BigEndian.AppendUint32(info, 55)
+	info = append(info, make([]byte, 11)...)
+	// 宽、高、X、Y、组合方式，MMR 编码
+	region := binary.BigEndian.AppendUint32(nil, 153)
+	region = binary.BigEndian.AppendUint32(region, 55)
+	region = append(region, make([]byte, 9)...)
+	region = append(region, 1)
+	region = append(region, mmr...)
+
+	stream := jbig2Segment(0, 48, 1, info)
+	stream = append(stream, jbig2Segment(1, 38, 1, region)...)
+	return append(stream, jbig2Segment(2, 49, 1, nil)...)
+}
+
+func TestDecodeImage_JBIG2(t *testing.T) {
+	want, _ := gopher(t)
+	stream := jbig2Gopher(t)
+
+	// 不带文件头的原始码流
+	img, err := decodeImage(stream, "JBIG2", nil)
+	assert.Nil(t, err)
+	assertBilevel(t, want, img)
+
+	// 带文件头
+	img, err = decodeImage(append(append([]byte{}, jbig2Header...), stream...), "JB2", nil)
+	assert.Nil(t, err)
+	assertBilevel(t, want, img)
+
+	// 带全局段 (仅含文件结束段)
+	img, err = decodeImage(stream, "JBIG2", jbig2Segment(0, 51, 0, nil))
+	assert.Nil(t, err)
+	assertBilevel(t, want, img)
+
+	// 全局段无法解析
+	_, err = decodeImage(stream, "JBIG2", []byte{0xFF, 0xFF})
+	assert.NotNil(t, err)
+}
+
+func TestImage_JBIG2Globals(t *testing.T) {
+	const dpmm = 8.0
+	jbig2Document := func(globals string) *Document {
+		files := map[string]string{
+			"Doc_0/PublicRes.xml": testRes(`<ofd:MultiMedias>` +
+				`<ofd:MultiMedia ID="5" Type="Image" Format="JBIG2"><ofd:MediaFile>gopher.jb2</ofd:MediaFile></ofd:MultiMedia>` +
+				`<ofd:MultiMedia ID="6" Type="Image" Format="JBIG2Globals"><ofd:MediaFile>globals.jb2</ofd:MediaFile></ofd:MultiMedia>` +
+				`</ofd:MultiMedias>`),
+			"Doc_0/Res/gopher.jb2":           string(jbig2Gopher(t)),
+			"Doc_0/Pages/Page_0/Content.xml": testPage("", `<ofd:ImageObject ID="10" Boundary="0 0 38.25 13.75" CTM="38.25 0 0 13.75 0 0" ResourceID="5"/>`),
+		}
+		if globals != "" {
+			files["Doc_0/Res/globals.jb2"] = globals
+		}
+		return openDocument(t, testOFD(t, files))
+	}
+
+	// 同一资源文件中的 JBIG2Globals 作为全局段
+	doc := jbig2Document(string(jbig2Segment(0, 51, 0, nil)))
+	assert.Equal(t, models.StLoc("Doc_0/Res/globals.jb2"), doc.JBIG2Globals[5])
+	img := rasterize(t, doc, 1, dpmm)
+	assert.Empty(t, doc.Report().Issues)
+	// 每个像素为 1/4 毫米
+	want, _ := gopher(t)
+	for y := 0; y < 55; y++ {
+		for x := 0; x < 153; x++ {
+			w, g := color.GrayModel.Convert(want.At(x, y)).(color.Gray), at(img, dpmm, (float64(x)+0.5)/4, (float64(y)+0.5)/4)
+			if w.Y < 128 != (g.R < 128) {
+				t.Errorf("(%d, %d): %v != %v", x, y, w, g)
+				return
+			}
+		}
+	}
+
+	// 全局段无法解析时位图解码失败
+	doc = jbig2Document("\xFF\xFF")
+	rasterize(t, doc, 1, dpmm)
+	if assert.Len(t, doc.Report().Issues, 1) {
+		assert.Equal(t, IssueImage, doc.Report().Issues[0].Kind)
+	}
+	// 解码失败记入缓存，再次绘制时不重新解码
+	failed, ok := doc.images.get(imageKey{id: 5})
+	assert.True(t, ok)
+	assert.Nil(t, failed)
+
+	// 全局段文件不存在
+	doc = jbig2Document("")
+	rasterize(t, doc, 1, dpmm)
+	assert.NotEmpty(t, doc.Report().Issues)
+	assert.Contains(t, doc.Report().Issues[0].Message, "JBIG2 全局段")
+}
+
+func TestImage_CCITT(t *testing.T) {
+	_, data := gopher(t)
+	ccittDocument := func(data []byte) *Document {
+		return openDocument(t, testOFD(t, map[string]string{
+			"Doc_0/PublicRes.xml":            testRes(`<ofd:MultiMedias><ofd:MultiMedia ID="5" Type="Image" Format="CCITT"><ofd:MediaFile>gopher.g4</ofd:MediaFile></ofd:MultiMedia></ofd:MultiMedias>`),
+			"Doc_0/Res/gopher.g4":            string(data),
+			"Doc_0/Pages/Page_0/Content.xml": testPage("", `<ofd:ImageObject ID="10" Boundary="0 0 20 10" CTM="20 0 0 10 0 0" ResourceID="5"/>`),
+		}))
+	}
+
+	// 以 TIFF 封装的 CCITT 数据
+	doc := ccittDocument(ccittTIFF(153, 55, data))
+	rasterize(t, doc, 1, 4)
+	assert.Empty(t, doc.Report().Issues)
+	img, ok := doc.images.get(imageKey{id: 5})
+	if assert.True(t, ok) && assert.NotNil(t, img) {
+		assert.Equal(t, image.Rect(0, 0, 153, 55), img.Bounds())
+	}
+
+	// 原始 CCITT 数据记为不支持的特性
+	doc = ccittDocument(data)
+	rasterize(t, doc, 1, 4)
+	if assert.Len(t, doc.Report().Issues, 1) {
+		assert.Equal(t, IssueFeature, doc.Report().Issues[0].Kind)
+		assert.Equal(t, models.StRefID(5), doc.Report().Issues[0].ID)
+	}
+}
@@ -0,0 +1,28 @@
+package render
+
+import (
+	"github.com/tdewolff/canvas"
+	"github.com/zc310/ofd/internal/models"
+)
+
+// pageMatrix 返回 OFD 页面坐标 (y 轴向下) 到画布坐标的变换
+func pageMatrix(pb models.StBox) canvas.Matrix {
+	return canvas.Identity.Translate(0.0, pb.Height).Scale(1.0, -1.0)
+}
+
+// ctmMatrix 将 CTM (a b c d e f) 转换为画布变换矩阵
+func ctmMatrix(ctm *models.CTM) canvas.Matrix {
+	return canvas.Matrix{
+		{ctm[0], ctm[2], ctm[4]},
+		{ctm[1], ctm[3], ctm[5]},
+	}
+}
+
+// unitMatrix 返回图元坐标到画布坐标的变换，依次应用 CTM、Boundary 平移和页面 y 轴翻转
+func unitMatrix(unit *models.CTGraphicUnit, pb models.StBox) canvas.Matrix {
+	m := pageMatrix(pb).Translate(unit.Boundary.X, unit.Boundary.Y)
+	if unit.CTM != nil {
+		m = m.Mul(ctmMatrix(unit.CTM))
+	}
+	return m
+}
@@ -0,0 +1,124 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/tdewolff/canvas"
+	"github.com/tdewolff/canvas/renderers/rasterizer"
+	"github.com/zc310/ofd/internal/models"
+)
+
+const (
+	// maxPatternCells 单个区域最多平铺的底纹单元数，超出时以单元的平均颜色填充
+	maxPatternCells = 10000
+	// patternColorSize 计算平均颜色时单元长边的像素数
+	patternColorSize = 64.0
+)
+
+// Pattern 使用底纹单元平铺填充 area (当前坐标)，超出 area 的部分被裁剪
+func (p *Document) Pattern(ctx *canvas.Context, area *canvas.Path, pattern *models.CtPattern, unit *models.CTGraphicUnit, pb models.StBox) {
+	if pattern.Width <= 0 || pattern.Height <= 0 || area.Empty() {
+		return
+	}
+	p.useFeature("Pattern")
+	xStep, yStep := pattern.XStep, pattern.YStep
+	if xStep <= 0 {
+		xStep = pattern.Width
+	}
+	if yStep <= 0 {
+		yStep = pattern.Height
+	}
+
+	// 底纹坐标原点默认为图元坐标原点
+	origin := unitMatrix(unit, pb)
+	if pattern.RelativeTo == "Page" {
+		origin = pageMatrix(pb)
+	}
+	if pattern.CTM != nil {
+		origin = origin.Mul(ctmMatrix(pattern.CTM))
+	}
+	if origin.Det() == 0 {
+		return
+	}
+
+	box := area.Copy().Transform(origin.Inv()).FastBounds()
+	i0, i1 := int(math.Floor((box.X0-pattern.Width)/xStep)), int(math.Ceil(box.X1/xStep))
+	j0, j1 := int(math.Floor((box.Y0-pattern.Height)/yStep)), int(math.Ceil(box.Y1/yStep))
+	if n := (i1 - i0) * (j1 - j0); n > maxPatternCells {
+		p.issue(IssueFeature, 0, fmt.Sprintf("底纹单元过多 (%d)，以平均颜色填充", n))
+		ctx.Push()
+		defer ctx.Pop()
+		ctx.SetFillColor(p.patternColor(pattern, xStep, yStep))
+		ctx.SetStroke(nil)
+		ctx.FillRule = canvas.NonZero
+		p.drawPath(ctx, area)
+		return
+	}
+
+	ctx.Push()
+	defer ctx.Pop()
+	defer p.pushClip(ctx, area)()
+	for j := j0; j < j1; j++ {
+		for i := i0; i < i1; i++ {
+			m := origin.Translate(float64(i)*xStep, float64(j)*yStep)
+			// Row 行内相邻单元左右翻转，Column 列内相邻单元上下翻转
+			if odd(i) && (pattern.ReflectMethod == "Row" || pattern.ReflectMethod == "RowAndColumn") {
+				m = m.Translate(pattern.Width, 0.0).Scale(-1.0, 1.0)
+			}
+			if odd(j) && (pattern.ReflectMethod == "Column" || pattern.ReflectMethod == "RowAndColumn") {
+				m = m.Translate(0.0, pattern.Height).Scale(1.0, -1.0)
+			}
+			ctx.Push()
+			ctx.ComposeView(m.Scale(1.0, -1.0))
+			p.patternCell(ctx, pattern)
+			ctx.Pop()
+		}
+	}
+}
+
+// patternCell 绘制单个底纹单元，单元内容为空时使用缩略图
+func (p *Document) patternCell(ctx *canvas.Context, pattern *models.CtPattern) {
+	cell := &pattern.CellContent
+	if isEmptyBlock(&cell.CTPageBlock) {
+		if cell.Thumbnail > 0 {
+			p.imageBox(ctx, cell.Thumbnail, pattern.Width, pattern.Height)
+		}
+		return
+	}
+	p.PageBlock(ctx, &cell.CTPageBlock, nil, models.StBox{})
+}
+
+// patternColor 返回底纹单元按步长平铺后的平均颜色，用于单元过多时的近似填充
+func (p *Document) patternColor(pattern *models.CtPattern, xStep, yStep float64) color.RGBA {
+	// 单元内容不受当前裁剪区影响
+	clip := p.clip
+	p.clip = nil
+	defer func() { p.clip = clip }()
+
+	c := canvas.New(pattern.Width, pattern.Height)
+	ctx := canvas.NewContext(c)
+	ctx.Translate(0.0, pattern.Height)
+	p.patternCell(ctx, pattern)
+	img := rasterizer.Draw(c, canvas.DPMM(patternColorSize/max(pattern.Width, pattern.Height)), canvas.DefaultColorSpace)
+
+	b := img.Bounds()
+	if b.Empty() {
+		return canvas.Transparent
+	}
+	var sum [4]float64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			sum[0], sum[1], sum[2], sum[3] = sum[0]+float64(r), sum[1]+float64(g), sum[2]+float64(bl), sum[3]+float64(a)
+		}
+	}
+	// 步长大于单元时单元间的空隙透明
+	n := float64(b.Dx()*b.Dy()) / min(1, pattern.Width*pattern.Height/(xStep*yStep)) * 257
+	return color.RGBA{R: uint8(sum[0] / n), G: uint8(sum[1] / n), B: uint8(sum[2] / n), A: uint8(sum[3] / n)}
+}
+
+func odd(i int) bool {
+	return i%2 != 0
+}
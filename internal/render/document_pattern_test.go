@@ -0,0 +1,101 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// patternImage 绘制以底纹填充的路径，attrs 为 Pattern 的属性，cell 为单元中红色矩形的 x y w h
+func patternImage(t *testing.T, dpmm float64, boundary, attrs, cell string) (image.Image, *Document) {
+	var x, y, w, h float64
+	_, err := fmt.Sscan(cell, &x, &y, &w, &h)
+	assert.Nil(t, err)
+	doc := openDocument(t, testOFD(t, map[string]string{
+		"Doc_0/Pages/Page_0/Content.xml": testPage("", `<ofd:PathObject ID="10" Boundary="`+boundary+`" Fill="true" Stroke="false"><ofd:FillColor><ofd:Pattern`+attrs+`><ofd:CellContent>`+
+			`<ofd:PathObject ID="20" Boundary="`+cell+`" Fill="true" Stroke="false"><ofd:FillColor Value="255 0 0"/><ofd:AbbreviatedData>`+fmt.Sprintf("M 0 0 L %g 0 L %g %g L 0 %g C", w, w, h, h)+`</ofd:AbbreviatedData></ofd:PathObject>`+
+			`</ofd:CellContent></ofd:Pattern></ofd:FillColor><ofd:AbbreviatedData>M 0 0 L 50 0 L 50 50 L 0 50 C</ofd:AbbreviatedData></ofd:PathObject>`),
+	}))
+	return rasterize(t, doc, 1, dpmm), doc
+}
+
+func TestPattern_ReflectMethod(t *testing.T) {
+	const dpmm = 4.0
+	red, white := color.RGBA{255, 0, 0, 255}, color.RGBA{255, 255, 255, 255}
+
+	// 单元左半部为红色
+	img, doc := patternImage(t, dpmm, "0 0 40 40", ` Width="10" Height="10"`, "0 0 5 10")
+	assert.Equal(t, red, at(img, dpmm, 2, 5))
+	assert.Equal(t, white, at(img, dpmm, 7, 5))
+	assert.Equal(t, red, at(img, dpmm, 12, 5))
+	assert.Contains(t, doc.Report().Features, "Pattern")
+
+	// Row 行内奇数单元左右翻转
+	img, _ = patternImage(t, dpmm, "0 0 40 40", ` Width="10" Height="10" ReflectMethod="Row"`, "0 0 5 10")
+	assert.Equal(t, red, at(img, dpmm, 2, 5))
+	assert.Equal(t, white, at(img, dpmm, 12, 5))
+	assert.Equal(t, red, at(img, dpmm, 17, 5))
+	assert.Equal(t, red, at(img, dpmm, 22, 15))
+
+	// 单元上半部为红色，Column 列内奇数单元上下翻转
+	img, _ = patternImage(t, dpmm, "0 0 40 40", ` Width="10" Height="10" ReflectMethod="Column"`, "0 0 10 5")
+	assert.Equal(t, red, at(img, dpmm, 5, 2))
+	assert.Equal(t, white, at(img, dpmm, 5, 12))
+	assert.Equal(t, red, at(img, dpmm, 5, 17))
+	assert.Equal(t, red, at(img, dpmm, 15, 17))
+
+	// RowAndColumn 同时翻转
+	img, _ = patternImage(t, dpmm, "0 0 40 40", ` Width="10" Height="10" ReflectMethod="RowAndColumn"`, "0 0 5 5")
+	assert.Equal(t, red, at(img, dpmm, 2, 2))
+	assert.Equal(t, red, at(img, dpmm, 17, 2))
+	assert.Equal(t, red, at(img, dpmm, 2, 17))
+	assert.Equal(t, red, at(img, dpmm, 17, 17))
+	assert.Equal(t, white, at(img, dpmm, 12, 12))
+}
+
+func TestPattern_RelativeTo(t *testing.T) {
+	const dpmm = 4.0
+	red, white := color.RGBA{255, 0, 0, 255}, color.RGBA{255, 255, 255, 255}
+
+	// 默认以图元坐标为原点：单元从 x = 3 开始
+	img, _ := patternImage(t, dpmm, "3 3 37 37", ` Width="10" Height="10"`, "0 0 5 10")
+	assert.Equal(t, white, at(img, dpmm, 11, 10))
+	assert.Equal(t, red, at(img, dpmm, 16, 10))
+
+	// Page 以页面坐标为原点
+	img, _ = patternImage(t, dpmm, "3 3 37 37", ` Width="10" Height="10" RelativeTo="Page"`, "0 0 5 10")
+	assert.Equal(t, red, at(img, dpmm, 11, 10))
+	assert.Equal(t, white, at(img, dpmm, 16, 10))
+	// 图元外不绘制
+	assert.Equal(t, white, at(img, dpmm, 1, 10))
+}
+
+func TestPattern_Step(t *testing.T) {
+	const dpmm = 4.0
+	red, white := color.RGBA{255, 0, 0, 255}, color.RGBA{255, 255, 255, 255}
+
+	// XStep 大于单元宽度时单元间留空
+	img, _ := patternImage(t, dpmm, "0 0 40 40", ` Width="10" Height="10" XStep="15"`, "0 0 5 10")
+	assert.Equal(t, red, at(img, dpmm, 2, 5))
+	assert.Equal(t, white, at(img, dpmm, 12, 5))
+	assert.Equal(t, red, at(img, dpmm, 17, 5))
+	assert.Equal(t, red, at(img, dpmm, 32, 5))
+
+	// YStep
+	img, _ = patternImage(t, dpmm, "0 0 40 40", ` Width="10" Height="10" YStep="20"`, "0 0 10 10")
+	assert.Equal(t, red, at(img, dpmm, 2, 5))
+	assert.Equal(t, white, at(img, dpmm, 2, 15))
+	assert.Equal(t, red, at(img, dpmm, 2, 25))
+}
+
+func TestPattern_TooManyCells(t *testing.T) {
+	const dpmm = 4.0
+	// 单元过多时以平均颜色填充：单元全红，步长为单元的两倍，覆盖四分之一
+	img, doc := patternImage(t, dpmm, "0 0 40 40", ` Width="0.1" Height="0.1" XStep="0.2" YStep="0.2"`, "0 0 0.1 0.1")
+	assert.True(t, near(at(img, dpmm, 20, 20), color.RGBA{255, 191, 191, 255}, 2), "%v", at(img, dpmm, 20, 20))
+	assert.NotEmpty(t, doc.Report().Issues)
+	assert.Equal(t, IssueFeature, doc.Report().Issues[0].Kind)
+}
@@ -2,6 +2,7 @@ package render
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	"image/color"
 
@@ -11,7 +12,13 @@ import (
 	"github.com/zc310/ofd/internal/parser"
 )
 
+// Seal 在 StampAnnot.Boundary 中绘制签章外观，Clip 为相对于 Boundary 的裁剪区
 func (p *Document) Seal(ctx *canvas.Context, info *parser.SealInfo, pb models.StBox) error {
+	if clip := info.StampAnnot.Clip; clip.Width > 0 && clip.Height > 0 {
+		clip.X += info.StampAnnot.Boundary.X
+		clip.Y += info.StampAnnot.Boundary.Y
+		defer p.clipRect(ctx, clip, pb)()
+	}
 	if filetype.IsImage(info.SealData.Data) {
 		img, _, err := image.Decode(bytes.NewBuffer(info.SealData.Data))
 		if err != nil {
@@ -25,30 +32,30 @@ func (p *Document) Seal(ctx *canvas.Context, info *parser.SealInfo, pb models.St
 
 		ctx.Translate(info.StampAnnot.Boundary.X, pb.Height-(info.StampAnnot.Boundary.Y+info.StampAnnot.Boundary.Height))
 		ctx.Scale(info.StampAnnot.Boundary.Width/imgW, info.StampAnnot.Boundary.Height/imgH)
-		ctx.DrawImage(0, 0, img, canvas.DPMM(1.0))
+		p.drawImage(ctx, img)
 		return nil
 	}
-	if info.SealData.FileType == "ofd" {
-		var ofd parser.OFD
-		if err := ofd.Open(info.SealData.Data); err != nil {
-			return err
-		}
-		defer ofd.Close()
-		if len(ofd.Documents) == 0 || len(ofd.Documents[0].Pages) == 0 {
-			return nil
-		}
-		doc := NewDocument(color.Transparent, ofd.Documents[0])
-		tmp := p.fonts
-		defer func() {
-			p.fonts = tmp
-		}()
-		p.fonts = doc.fonts
-		ctx.Push()
-		defer ctx.Pop()
-		sealBox := ofd.Documents[0].Pages[0].PageContent.Area.PhysicalBox
-		ctx.Translate(info.StampAnnot.Boundary.X, pb.Height-(info.StampAnnot.Boundary.Y+info.StampAnnot.Boundary.Height))
-		ctx.Scale(info.StampAnnot.Boundary.Width/sealBox.Width, info.StampAnnot.Boundary.Height/sealBox.Height)
-		p.PageContent(ctx, ofd.Documents[0].Pages[0], false)
+	if info.SealData.FileType != "ofd" {
+		return fmt.Errorf("不支持的签章格式 %s", info.SealData.FileType)
+	}
+	var ofd parser.OFD
+	if err := ofd.Open(info.SealData.Data); err != nil {
+		return err
+	}
+	defer ofd.Close()
+	if len(ofd.Documents) == 0 || len(ofd.Documents[0].Pages) == 0 {
+		return nil
 	}
+	// 签章内容使用签章文档自身的资源绘制，诊断信息记入当前页面，并沿用签章的裁剪区
+	doc := NewDocument(color.Transparent, ofd.Documents[0])
+	doc.SetOptions(p.opts)
+	doc.report = p.report
+	doc.clip = p.clip
+	ctx.Push()
+	defer ctx.Pop()
+	sealBox := ofd.Documents[0].Pages[0].PageContent.Area.PhysicalBox
+	ctx.Translate(info.StampAnnot.Boundary.X, pb.Height-(info.StampAnnot.Boundary.Y+info.StampAnnot.Boundary.Height))
+	ctx.Scale(info.StampAnnot.Boundary.Width/sealBox.Width, info.StampAnnot.Boundary.Height/sealBox.Height)
+	doc.PageContent(ctx, ofd.Documents[0].Pages[0], false)
 	return nil
 }
@@ -0,0 +1,493 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/tdewolff/canvas"
+	"github.com/zc310/ofd/internal/models"
+)
+
+const (
+	// shadingDPMM 渐变位图每毫米像素数
+	shadingDPMM = 10.0
+	// maxShadingPixels 渐变位图最大像素数，超出时降低分辨率
+	maxShadingPixels = 16 << 20
+)
+
+// Shading 以 area (当前坐标) 裁剪后绘制渐变，渐变坐标为图元坐标。渲染器可以直接绘制的轴向、径向渐变
+// 以渐变填充输出 (PDF 中为矢量渐变)，其余在 area 的外接矩形中逐像素生成渐变位图
+func (p *Document) Shading(ctx *canvas.Context, area *canvas.Path, shd *models.CTColor, unit *models.CTGraphicUnit, pb models.StBox) {
+	if area.Empty() {
+		return
+	}
+	m := unitMatrix(unit, pb)
+	if m.Det() == 0 {
+		return
+	}
+
+	box := area.FastBounds()
+	rect := canvas.Rectangle(box.W(), box.H()).Translate(box.X0, box.Y0).Transform(m.Inv())
+	if gradient, path := p.vectorShading(shd, rect); gradient != nil {
+		if shd.AxialShd != nil {
+			p.useFeature("AxialShd")
+		} else {
+			p.useFeature("RadialShd")
+		}
+		ctx.Push()
+		defer ctx.Pop()
+		defer p.pushClip(ctx, area)()
+		ctx.ComposeView(m)
+		ctx.SetFillGradient(gradient)
+		ctx.SetStroke(nil)
+		ctx.FillRule = canvas.NonZero
+		if !path.Empty() {
+			p.drawPath(ctx, path)
+		}
+		return
+	}
+
+	res := shadingDPMM * math.Sqrt(math.Abs(ctx.View().Det()))
+	if n := box.W() * box.H() * res * res; n > maxShadingPixels {
+		res *= math.Sqrt(maxShadingPixels / n)
+	}
+	w, h := int(math.Ceil(box.W()*res)), int(math.Ceil(box.H()*res))
+	if w <= 0 || h <= 0 {
+		return
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	// toPixel 图元坐标到位图像素坐标 (y 轴向下) 的变换
+	toPixel := canvas.Identity.Scale(res, -res).Translate(-box.X0, -box.Y1).Mul(m)
+	switch {
+	case shd.AxialShd != nil:
+		p.useFeature("AxialShd")
+		fillFunc(img, toPixel, p.axialFunc(shd.AxialShd))
+	case shd.RadialShd != nil:
+		p.useFeature("RadialShd")
+		fillFunc(img, toPixel, p.radialFunc(shd.RadialShd))
+	case shd.GouraudShd != nil:
+		p.useFeature("GouraudShd")
+		gouraud := shd.GouraudShd
+		if gouraud.Extend != 0 && gouraud.BackColor != nil {
+			draw.Draw(img, img.Bounds(), image.NewUniform(p.ctColor(gouraud.BackColor)), image.Point{}, draw.Src)
+		}
+		fillTriangles(img, toPixel, p.gouraudTriangles(gouraud))
+	case shd.LaGourandShd != nil:
+		p.useFeature("LaGourandShd")
+		lattice := shd.LaGourandShd
+		if lattice.Extend != 0 && lattice.BackColor != nil {
+			draw.Draw(img, img.Bounds(), image.NewUniform(p.ctColor(lattice.BackColor)), image.Point{}, draw.Src)
+		}
+		fillTriangles(img, toPixel, p.latticeTriangles(lattice))
+	default:
+		return
+	}
+
+	ctx.Push()
+	defer ctx.Pop()
+	defer p.pushClip(ctx, area)()
+	ctx.Translate(box.X0, box.Y0)
+	ctx.Scale(1.0/res, 1.0/res)
+	p.drawImage(ctx, img)
+}
+
+// vectorShading 返回可由渲染器直接绘制的渐变，以及 path (图元坐标) 中按 Extend 应绘制的部分。
+// 仅支持 Direct 映射、不透明颜色段的轴向渐变和同心圆径向渐变，其余 (Repeat、Reflect、
+// 椭圆及非同心的径向渐变) 返回 nil
+func (p *Document) vectorShading(shd *models.CTColor, path *canvas.Path) (canvas.Gradient, *canvas.Path) {
+	var segments []models.Segment
+	var mapType string
+	switch {
+	case shd.AxialShd != nil:
+		segments, mapType = shd.AxialShd.Segment, shd.AxialShd.MapType
+	case shd.RadialShd != nil:
+		radial := shd.RadialShd
+		if radial.Eccentricity != 0 || radial.StartPoint != radial.EndPoint ||
+			radial.StartRadius < 0 || radial.StartRadius >= radial.EndRadius {
+			return nil, nil
+		}
+		segments, mapType = radial.Segment, radial.MapType
+	default:
+		return nil, nil
+	}
+	if mapType != "" && mapType != "Direct" {
+		return nil, nil
+	}
+	grad := p.gradientStops(segments)
+	if grad == nil {
+		return nil, nil
+	}
+
+	if axial := shd.AxialShd; axial != nil {
+		start := canvas.Point{X: axial.StartPoint.X, Y: axial.StartPoint.Y}
+		end := canvas.Point{X: axial.EndPoint.X, Y: axial.EndPoint.Y}
+		length := end.Sub(start).Length()
+		if length == 0 {
+			return nil, nil
+		}
+		if axial.Extend&3 != 3 {
+			path = path.And(axialBand(start, end, axial.Extend, path.FastBounds()))
+		}
+		return grad.ToLinear(start, end), path
+	}
+	radial := shd.RadialShd
+	c := canvas.Point{X: radial.StartPoint.X, Y: radial.StartPoint.Y}
+	if radial.Extend&1 == 0 && radial.StartRadius > 0 {
+		path = path.Not(canvas.Circle(radial.StartRadius).Translate(c.X, c.Y))
+	}
+	if radial.Extend&2 == 0 {
+		path = path.And(canvas.Circle(radial.EndRadius).Translate(c.X, c.Y))
+	}
+	return grad.ToRadial(c, radial.StartRadius, c, radial.EndRadius), path
+}
+
+// gradientStops 返回位置 0 至 1 的颜色段，颜色段含半透明颜色时返回 nil
+func (p *Document) gradientStops(segments []models.Segment) canvas.Grad {
+	stops := p.segmentStops(segments)
+	if len(stops) == 0 {
+		return nil
+	}
+	var grad canvas.Grad
+	if stops[0].pos > 0 {
+		grad = append(grad, canvas.Stop{Offset: 0, Color: color.RGBA(stops[0].color)})
+	}
+	for _, stop := range stops {
+		if stop.color.A != 255 {
+			return nil
+		}
+		grad = append(grad, canvas.Stop{Offset: max(0, min(1, stop.pos)), Color: color.RGBA(stop.color)})
+	}
+	if last := stops[len(stops)-1]; last.pos < 1 {
+		grad = append(grad, canvas.Stop{Offset: 1, Color: color.RGBA(last.color)})
+	}
+	return grad
+}
+
+// axialBand 返回轴向渐变在 bounds 中的绘制范围：Extend 未向起点 (1)、终点 (2) 延伸时
+// 以过起点、终点且垂直于轴线的直线为界
+func axialBand(start, end canvas.Point, extend int, bounds canvas.Rect) *canvas.Path {
+	length := end.Sub(start).Length()
+	u := end.Sub(start).Div(length)
+	v := canvas.Point{X: -u.Y, Y: u.X}
+	lo, hi := 0.0, length
+	qlo, qhi := math.Inf(1), math.Inf(-1)
+	for _, corner := range []canvas.Point{{X: bounds.X0, Y: bounds.Y0}, {X: bounds.X1, Y: bounds.Y0}, {X: bounds.X1, Y: bounds.Y1}, {X: bounds.X0, Y: bounds.Y1}} {
+		d := corner.Sub(start)
+		s, q := d.Dot(u), d.Dot(v)
+		qlo, qhi = min(qlo, q), max(qhi, q)
+		if extend&1 != 0 {
+			lo = min(lo, s)
+		}
+		if extend&2 != 0 {
+			hi = max(hi, s)
+		}
+	}
+	at := func(s, q float64) canvas.Point {
+		return start.Add(u.Mul(s)).Add(v.Mul(q))
+	}
+	band := &canvas.Path{}
+	band.MoveTo(at(lo, qlo).X, at(lo, qlo).Y)
+	band.LineTo(at(hi, qlo).X, at(hi, qlo).Y)
+	band.LineTo(at(hi, qhi).X, at(hi, qhi).Y)
+	band.LineTo(at(lo, qhi).X, at(lo, qhi).Y)
+	band.Close()
+	return band
+}
+
+// ctColor 返回颜色按颜色空间转换后的 sRGB 值，Alpha 为不透明度
+func (p *Document) ctColor(c *models.CTColor) color.NRGBA {
+	v := color.NRGBA{A: 255}
+	if value := p.colorValue(c); value != nil {
+		v = color.NRGBAModel.Convert(*value).(color.NRGBA)
+	}
+	return v
+}
+
+type shadingVertex struct {
+	x, y  float64
+	color color.NRGBA
+}
+
+// gouraudTriangles 按 EdgeFlag 组成三角形：0 开始新三角形，1 与前一三角形后两个顶点相连，2 与前一三角形第一、三个顶点相连
+func (p *Document) gouraudTriangles(shd *models.CTGouraudShd) [][3]shadingVertex {
+	vertex := func(i int) shadingVertex {
+		pt := shd.Point[i]
+		return shadingVertex{x: pt.X, y: pt.Y, color: p.ctColor(&pt.Color)}
+	}
+	var triangles [][3]shadingVertex
+	for i := 0; i < len(shd.Point); {
+		flag := shd.Point[i].EdgeFlag
+		if len(triangles) == 0 || flag == 0 {
+			if i+2 >= len(shd.Point) {
+				break
+			}
+			triangles = append(triangles, [3]shadingVertex{vertex(i), vertex(i + 1), vertex(i + 2)})
+			i += 3
+			continue
+		}
+		last := triangles[len(triangles)-1]
+		if flag == 1 {
+			triangles = append(triangles, [3]shadingVertex{last[1], last[2], vertex(i)})
+		} else {
+			triangles = append(triangles, [3]shadingVertex{last[0], last[2], vertex(i)})
+		}
+		i++
+	}
+	return triangles
+}
+
+// latticeTriangles 将每行 VerticesPerRow 个顶点的网格拆分为三角形
+func (p *Document) latticeTriangles(shd *models.CTLaGouraudShd) [][3]shadingVertex {
+	n := shd.VerticesPerRow
+	if n < 2 {
+		return nil
+	}
+	vertex := func(i int) shadingVertex {
+		pt := shd.Point[i]
+		return shadingVertex{x: pt.X, y: pt.Y, color: p.ctColor(&pt.Color)}
+	}
+	var triangles [][3]shadingVertex
+	for row := 0; (row+2)*n <= len(shd.Point); row++ {
+		for col := 0; col < n-1; col++ {
+			a := row*n + col
+			b, c, d := a+1, a+n, a+n+1
+			triangles = append(triangles,
+				[3]shadingVertex{vertex(a), vertex(b), vertex(c)},
+				[3]shadingVertex{vertex(b), vertex(c), vertex(d)})
+		}
+	}
+	return triangles
+}
+
+// fillTriangles 将三角形变换到像素坐标后按重心坐标插值颜色
+func fillTriangles(img *image.NRGBA, toPixel canvas.Matrix, triangles [][3]shadingVertex) {
+	bounds := img.Bounds()
+	for _, t := range triangles {
+		var v [3]shadingVertex
+		for i := range t {
+			pt := toPixel.Dot(canvas.Point{X: t[i].x, Y: t[i].y})
+			v[i] = shadingVertex{x: pt.X, y: pt.Y, color: t[i].color}
+		}
+		area := (v[1].x-v[0].x)*(v[2].y-v[0].y) - (v[2].x-v[0].x)*(v[1].y-v[0].y)
+		if area == 0 {
+			continue
+		}
+		x0 := max(int(math.Floor(min(v[0].x, v[1].x, v[2].x))), bounds.Min.X)
+		x1 := min(int(math.Ceil(max(v[0].x, v[1].x, v[2].x))), bounds.Max.X)
+		y0 := max(int(math.Floor(min(v[0].y, v[1].y, v[2].y))), bounds.Min.Y)
+		y1 := min(int(math.Ceil(max(v[0].y, v[1].y, v[2].y))), bounds.Max.Y)
+		for py := y0; py < y1; py++ {
+			y := float64(py) + 0.5
+			for px := x0; px < x1; px++ {
+				x := float64(px) + 0.5
+				w0 := ((v[1].x-x)*(v[2].y-y) - (v[2].x-x)*(v[1].y-y)) / area
+				w1 := ((v[2].x-x)*(v[0].y-y) - (v[0].x-x)*(v[2].y-y)) / area
+				w2 := 1 - w0 - w1
+				const eps = -1e-9
+				if w0 < eps || w1 < eps || w2 < eps {
+					continue
+				}
+				img.SetNRGBA(px, py, color.NRGBA{
+					R: mixChannel(v[0].color.R, v[1].color.R, v[2].color.R, w0, w1, w2),
+					G: mixChannel(v[0].color.G, v[1].color.G, v[2].color.G, w0, w1, w2),
+					B: mixChannel(v[0].color.B, v[1].color.B, v[2].color.B, w0, w1, w2),
+					A: mixChannel(v[0].color.A, v[1].color.A, v[2].color.A, w0, w1, w2),
+				})
+			}
+		}
+	}
+}
+
+func mixChannel(a, b, c uint8, wa, wb, wc float64) uint8 {
+	return uint8(math.Round(max(0, min(255, float64(a)*wa+float64(b)*wb+float64(c)*wc))))
+}
+
+type colorStop struct {
+	pos   float64
+	color color.NRGBA
+}
+
+// segmentStops 返回颜色段，未指定 Position 的颜色段在相邻已知位置间均匀分布，首尾默认为 0 和 1
+func (p *Document) segmentStops(segments []models.Segment) []colorStop {
+	n := len(segments)
+	if n == 0 {
+		return nil
+	}
+	stops := make([]colorStop, n)
+	known := make([]bool, n)
+	for i, segment := range segments {
+		stops[i].color = p.ctColor(&segment.Color)
+		if segment.Position != nil {
+			stops[i].pos, known[i] = *segment.Position, true
+		}
+	}
+	if !known[0] {
+		stops[0].pos, known[0] = 0, true
+	}
+	if !known[n-1] {
+		stops[n-1].pos, known[n-1] = 1, true
+	}
+	for i := 0; i < n-1; {
+		j := i + 1
+		for !known[j] {
+			j++
+		}
+		for k := i + 1; k < j; k++ {
+			stops[k].pos = stops[i].pos + (stops[j].pos-stops[i].pos)*float64(k-i)/float64(j-i)
+		}
+		i = j
+	}
+	for i := 1; i < n; i++ {
+		stops[i].pos = max(stops[i].pos, stops[i-1].pos)
+	}
+	return stops
+}
+
+// stopColor 返回位置 t 处的颜色
+func stopColor(stops []colorStop, t float64) color.NRGBA {
+	if t <= stops[0].pos {
+		return stops[0].color
+	}
+	for i := 1; i < len(stops); i++ {
+		if t > stops[i].pos {
+			continue
+		}
+		a, b := stops[i-1], stops[i]
+		if b.pos == a.pos {
+			return b.color
+		}
+		f := (t - a.pos) / (b.pos - a.pos)
+		return color.NRGBA{
+			R: mixChannel(a.color.R, b.color.R, 0, 1-f, f, 0),
+			G: mixChannel(a.color.G, b.color.G, 0, 1-f, f, 0),
+			B: mixChannel(a.color.B, b.color.B, 0, 1-f, f, 0),
+			A: mixChannel(a.color.A, b.color.A, 0, 1-f, f, 0),
+		}
+	}
+	return stops[len(stops)-1].color
+}
+
+// mapPosition 按 MapType 将轴线上的距离 d (0 ≤ d ≤ length) 映射为颜色段位置，
+// Repeat、Reflect 以 MapUnit (默认为轴线长度) 为区间重复或反射
+func mapPosition(mapType string, mapUnit, d, length float64) float64 {
+	if length <= 0 {
+		return 0
+	}
+	if mapUnit <= 0 {
+		mapUnit = length
+	}
+	switch mapType {
+	case "Repeat":
+		f := d / mapUnit
+		if f > 0 && f == math.Floor(f) {
+			return 1
+		}
+		return f - math.Floor(f)
+	case "Reflect":
+		k := math.Floor(d / mapUnit)
+		f := d/mapUnit - k
+		if int(k)%2 == 1 {
+			f = 1 - f
+		}
+		return f
+	default:
+		return d / length
+	}
+}
+
+// axialFunc 返回轴向渐变在图元坐标中的取色函数，Extend 1 向起点外、2 向终点外、3 向两侧延伸
+func (p *Document) axialFunc(shd *models.CTAxialShd) func(x, y float64) (color.NRGBA, bool) {
+	stops := p.segmentStops(shd.Segment)
+	dx, dy := shd.EndPoint.X-shd.StartPoint.X, shd.EndPoint.Y-shd.StartPoint.Y
+	length := math.Hypot(dx, dy)
+	return func(x, y float64) (color.NRGBA, bool) {
+		if len(stops) == 0 || length == 0 {
+			return color.NRGBA{}, false
+		}
+		d := ((x-shd.StartPoint.X)*dx + (y-shd.StartPoint.Y)*dy) / length
+		if d < 0 {
+			if shd.Extend&1 == 0 {
+				return color.NRGBA{}, false
+			}
+			d = 0
+		} else if d > length {
+			if shd.Extend&2 == 0 {
+				return color.NRGBA{}, false
+			}
+			d = length
+		}
+		return stopColor(stops, mapPosition(shd.MapType, shd.MapUnit, d, length)), true
+	}
+}
+
+// radialFunc 返回径向渐变在图元坐标中的取色函数，Eccentricity 和 Angle 决定椭圆的离心率和长轴方向
+func (p *Document) radialFunc(shd *models.CTRadialShd) func(x, y float64) (color.NRGBA, bool) {
+	stops := p.segmentStops(shd.Segment)
+	// 将椭圆变换为圆：旋转长轴到 x 轴，再拉伸短轴
+	k := math.Sqrt(1 - math.Min(shd.Eccentricity*shd.Eccentricity, 0.9999))
+	norm := canvas.Identity.Scale(1.0, 1.0/k).Rotate(-shd.Angle)
+	c0 := norm.Dot(canvas.Point{X: shd.StartPoint.X, Y: shd.StartPoint.Y})
+	c1 := norm.Dot(canvas.Point{X: shd.EndPoint.X, Y: shd.EndPoint.Y})
+	r0, r1 := shd.StartRadius, shd.EndRadius
+	cd := c1.Sub(c0)
+	dr := r1 - r0
+	a := cd.Dot(cd) - dr*dr
+	length := cd.Length()
+	if length == 0 {
+		length = math.Abs(dr)
+	}
+	valid := func(t float64) bool {
+		if r0+t*dr < 0 {
+			return false
+		}
+		return (t >= 0 || shd.Extend&1 != 0) && (t <= 1 || shd.Extend&2 != 0)
+	}
+	return func(x, y float64) (color.NRGBA, bool) {
+		if len(stops) == 0 {
+			return color.NRGBA{}, false
+		}
+		pd := norm.Dot(canvas.Point{X: x, Y: y}).Sub(c0)
+		b := pd.Dot(cd) + r0*dr
+		c := pd.Dot(pd) - r0*r0
+		// 求解 |pd - t·cd| = r0 + t·dr，取满足条件的最大 t
+		t, ok := 0.0, false
+		if math.Abs(a) < 1e-12 {
+			if b != 0 {
+				t = c / (2 * b)
+				ok = valid(t)
+			}
+		} else if disc := b*b - a*c; disc >= 0 {
+			t1, t2 := (b+math.Sqrt(disc))/a, (b-math.Sqrt(disc))/a
+			if t1 < t2 {
+				t1, t2 = t2, t1
+			}
+			if valid(t1) {
+				t, ok = t1, true
+			} else if valid(t2) {
+				t, ok = t2, true
+			}
+		}
+		if !ok {
+			return color.NRGBA{}, false
+		}
+		t = math.Max(0, math.Min(1, t))
+		return stopColor(stops, mapPosition(shd.MapType, shd.MapUnit, t*length, length)), true
+	}
+}
+
+// fillFunc 按像素中心在图元坐标中取色
+func fillFunc(img *image.NRGBA, toPixel canvas.Matrix, f func(x, y float64) (color.NRGBA, bool)) {
+	inv := toPixel.Inv()
+	bounds := img.Bounds()
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			pt := inv.Dot(canvas.Point{X: float64(px) + 0.5, Y: float64(py) + 0.5})
+			if c, ok := f(pt.X, pt.Y); ok {
+				img.SetNRGBA(px, py, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tdewolff/canvas"
+
+	"github.com/zc310/ofd/internal/pdf"
+)
+
+// shadingDocument 返回以 shd 填充整个页面的文档
+func shadingDocument(t *testing.T, shd string) *Document {
+	return openDocument(t, testOFD(t, map[string]string{
+		"Doc_0/Pages/Page_0/Content.xml": testPage("", `<ofd:PathObject ID="10" Boundary="0 0 50 50" Fill="true" Stroke="false"><ofd:FillColor>`+shd+
+			`</ofd:FillColor><ofd:AbbreviatedData>M 0 0 L 50 0 L 50 50 L 0 50 C</ofd:AbbreviatedData></ofd:PathObject>`),
+	}))
+}
+
+// shadingPDF 返回第一页的 PDF 输出
+func shadingPDF(t *testing.T, doc *Document) string {
+	var buf bytes.Buffer
+	r := pdf.New(&buf, 50, 50, nil)
+	assert.Nil(t, doc.Draw(canvas.NewContext(r), doc.Pages[0]))
+	assert.Nil(t, r.Close())
+	return buf.String()
+}
+
+const shadingSegments = `<ofd:Segment Position="0"><ofd:Color Value="255 255 0"/></ofd:Segment><ofd:Segment Position="1"><ofd:Color Value="0 0 255"/></ofd:Segment>`
+
+func TestShading_Vector(t *testing.T) {
+	const dpmm = 4.0
+	yellow, blue := color.RGBA{255, 255, 0, 255}, color.RGBA{0, 0, 255, 255}
+	white := color.RGBA{255, 255, 255, 255}
+
+	// Direct 轴向渐变以矢量渐变输出，Extend 为 0 时轴线外不绘制
+	doc := shadingDocument(t, `<ofd:AxialShd StartPoint="10 25" EndPoint="40 25">`+shadingSegments+`</ofd:AxialShd>`)
+	out := shadingPDF(t, doc)
+	assert.Contains(t, out, "/ShadingType 2")
+	assert.NotContains(t, out, "/Image")
+	img := rasterize(t, doc, 1, dpmm)
+	assert.True(t, near(at(img, dpmm, 10.5, 25), yellow, 16))
+	assert.True(t, near(at(img, dpmm, 39.5, 25), blue, 16))
+	assert.True(t, near(at(img, dpmm, 25, 25), color.RGBA{128, 128, 128, 255}, 8))
+	assert.Equal(t, white, at(img, dpmm, 5, 25))
+	assert.Equal(t, white, at(img, dpmm, 45, 25))
+
+	// Extend 3 向两侧延伸
+	doc = shadingDocument(t, `<ofd:AxialShd StartPoint="10 25" EndPoint="40 25" Extend="3">`+shadingSegments+`</ofd:AxialShd>`)
+	img = rasterize(t, doc, 1, dpmm)
+	assert.True(t, near(at(img, dpmm, 5, 25), yellow, 0))
+	assert.True(t, near(at(img, dpmm, 45, 25), blue, 0))
+
+	// 同心圆径向渐变：起始圆内及终止圆外不绘制
+	doc = shadingDocument(t, `<ofd:RadialShd StartPoint="25 25" StartRadius="5" EndPoint="25 25" EndRadius="20">`+shadingSegments+`</ofd:RadialShd>`)
+	out = shadingPDF(t, doc)
+	assert.Contains(t, out, "/ShadingType 3")
+	assert.NotContains(t, out, "/Image")
+	img = rasterize(t, doc, 1, dpmm)
+	assert.Equal(t, white, at(img, dpmm, 25, 25))
+	assert.True(t, near(at(img, dpmm, 30.5, 25), yellow, 16))
+	assert.True(t, near(at(img, dpmm, 44.5, 25), blue, 16))
+	assert.Equal(t, white, at(img, dpmm, 48, 48))
+
+	// 颜色段未覆盖 0 至 1 时两端延续首尾颜色
+	doc = shadingDocument(t, `<ofd:AxialShd StartPoint="0 25" EndPoint="50 25"><ofd:Segment Position="0.5"><ofd:Color Value="255 255 0"/></ofd:Segment><ofd:Segment Position="1"><ofd:Color Value="0 0 255"/></ofd:Segment></ofd:AxialShd>`)
+	img = rasterize(t, doc, 1, dpmm)
+	assert.True(t, near(at(img, dpmm, 20, 25), yellow, 0))
+	assert.True(t, near(at(img, dpmm, 37.5, 25), color.RGBA{128, 128, 128, 255}, 8))
+}
+
+func TestShading_Raster(t *testing.T) {
+	for name, shd := range map[string]string{
+		"Repeat":        `<ofd:AxialShd StartPoint="10 25" EndPoint="40 25" MapType="Repeat" MapUnit="10">` + shadingSegments + `</ofd:AxialShd>`,
+		"Reflect":       `<ofd:RadialShd StartPoint="25 25" EndPoint="25 25" EndRadius="20" MapType="Reflect" MapUnit="5">` + shadingSegments + `</ofd:RadialShd>`,
+		"Eccentricity":  `<ofd:RadialShd StartPoint="25 25" EndPoint="25 25" EndRadius="20" Eccentricity="0.5">` + shadingSegments + `</ofd:RadialShd>`,
+		"NotConcentric": `<ofd:RadialShd StartPoint="20 25" EndPoint="25 25" EndRadius="20">` + shadingSegments + `</ofd:RadialShd>`,
+		"Alpha":         `<ofd:AxialShd StartPoint="10 25" EndPoint="40 25"><ofd:Segment><ofd:Color Value="255 255 0" Alpha="128"/></ofd:Segment><ofd:Segment><ofd:Color Value="0 0 255"/></ofd:Segment></ofd:AxialShd>`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			// 渲染器无法直接绘制的渐变以位图输出
+			out := shadingPDF(t, shadingDocument(t, shd))
+			assert.Contains(t, out, "/Image")
+			assert.NotContains(t, out, "/ShadingType")
+		})
+	}
+}
+
+func TestShading_MapType(t *testing.T) {
+	const dpmm = 4.0
+	yellow := func(c color.RGBA) bool { return c.R > 200 && c.G > 200 && c.B < 60 }
+	blue := func(c color.RGBA) bool { return c.R < 60 && c.G < 60 && c.B > 200 }
+
+	// Direct: 起点黄色，终点蓝色
+	img := rasterize(t, shadingDocument(t, `<ofd:AxialShd StartPoint="10 25" EndPoint="40 25">`+shadingSegments+`</ofd:AxialShd>`), 1, dpmm)
+	assert.True(t, yellow(at(img, dpmm, 10+0.5, 25)))
+	assert.True(t, blue(at(img, dpmm, 40-0.5, 25)))
+
+	// Repeat: 以 10 毫米为区间重复
+	img = rasterize(t, shadingDocument(t, `<ofd:AxialShd StartPoint="10 25" EndPoint="40 25" MapType="Repeat" MapUnit="10">`+shadingSegments+`</ofd:AxialShd>`), 1, dpmm)
+	assert.True(t, yellow(at(img, dpmm, 10+10+0.5, 25)))
+	assert.True(t, blue(at(img, dpmm, 10+20-0.5, 25)))
+
+	// Reflect: 奇数区间反向，轴线外不延伸
+	img = rasterize(t, shadingDocument(t, `<ofd:AxialShd StartPoint="10 25" EndPoint="40 25" MapType="Reflect" MapUnit="10">`+shadingSegments+`</ofd:AxialShd>`), 1, dpmm)
+	assert.True(t, yellow(at(img, dpmm, 10+0.5, 25)))
+	assert.True(t, blue(at(img, dpmm, 10+10+0.5, 25)))
+	assert.True(t, yellow(at(img, dpmm, 10+20-0.5, 25)))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, at(img, dpmm, 45, 25))
+}
+
+// gouraudPoint 返回高洛德渐变的顶点
+func gouraudPoint(x, y float64, flag int, value string) string {
+	return fmt.Sprintf(`<ofd:Point X="%g" Y="%g" EdgeFlag="%d"><ofd:Color Value="%s"/></ofd:Point>`, x, y, flag, value)
+}
+
+func TestShading_Gouraud(t *testing.T) {
+	const dpmm = 4.0
+	white := color.RGBA{255, 255, 255, 255}
+	triangle := gouraudPoint(0, 0, 0, "255 0 0") + gouraudPoint(40, 0, 0, "0 255 0") + gouraudPoint(0, 40, 0, "0 0 255")
+
+	doc := shadingDocument(t, `<ofd:GouraudShd>`+triangle+`</ofd:GouraudShd>`)
+	img := rasterize(t, doc, 1, dpmm)
+	assert.True(t, near(at(img, dpmm, 0.5, 0.5), color.RGBA{255, 0, 0, 255}, 16))
+	assert.True(t, near(at(img, dpmm, 39, 0.5), color.RGBA{0, 255, 0, 255}, 16))
+	assert.True(t, near(at(img, dpmm, 0.5, 39), color.RGBA{0, 0, 255, 255}, 16))
+	// 重心处为三种颜色的平均值
+	assert.True(t, near(at(img, dpmm, 40.0/3, 40.0/3), color.RGBA{85, 85, 85, 255}, 8))
+	assert.Equal(t, white, at(img, dpmm, 30, 30))
+	assert.Contains(t, doc.Report().Features, "GouraudShd")
+
+	// EdgeFlag 1 与前一三角形的后两个顶点组成三角形
+	img = rasterize(t, shadingDocument(t, `<ofd:GouraudShd>`+triangle+gouraudPoint(40, 40, 1, "0 0 0")+`</ofd:GouraudShd>`), 1, dpmm)
+	assert.True(t, near(at(img, dpmm, 39, 39), color.RGBA{0, 0, 0, 255}, 24))
+	assert.NotEqual(t, white, at(img, dpmm, 35, 20))
+	assert.NotEqual(t, white, at(img, dpmm, 20, 35))
+
+	// EdgeFlag 2 与前一三角形的第一、三个顶点组成三角形
+	img = rasterize(t, shadingDocument(t, `<ofd:GouraudShd>`+triangle+gouraudPoint(40, 40, 2, "0 0 0")+`</ofd:GouraudShd>`), 1, dpmm)
+	assert.True(t, near(at(img, dpmm, 38, 39.5), color.RGBA{0, 0, 0, 255}, 24))
+	assert.Equal(t, white, at(img, dpmm, 35, 20))
+	assert.NotEqual(t, white, at(img, dpmm, 20, 35))
+
+	// Extend 不为 0 时三角形外以 BackColor 填充
+	back := `<ofd:BackColor Value="0 255 0"/>`
+	img = rasterize(t, shadingDocument(t, `<ofd:GouraudShd Extend="1">`+triangle+back+`</ofd:GouraudShd>`), 1, dpmm)
+	assert.Equal(t, color.RGBA{0, 255, 0, 255}, at(img, dpmm, 30, 30))
+	img = rasterize(t, shadingDocument(t, `<ofd:GouraudShd>`+triangle+back+`</ofd:GouraudShd>`), 1, dpmm)
+	assert.Equal(t, white, at(img, dpmm, 30, 30))
+}
+
+func TestShading_Lattice(t *testing.T) {
+	const dpmm = 4.0
+	white := color.RGBA{255, 255, 255, 255}
+	point := func(x, y float64, value string) string {
+		return fmt.Sprintf(`<ofd:Point X="%g" Y="%g"><ofd:Color Value="%s"/></ofd:Point>`, x, y, value)
+	}
+	// 两行三列的网格：上行红色，下行蓝色
+	grid := point(0, 0, "255 0 0") + point(20, 0, "255 0 0") + point(40, 0, "255 0 0") +
+		point(0, 40, "0 0 255") + point(20, 40, "0 0 255") + point(40, 40, "0 0 255")
+
+	doc := shadingDocument(t, `<ofd:LaGourandShd VerticesPerRow="3">`+grid+`</ofd:LaGourandShd>`)
+	img := rasterize(t, doc, 1, dpmm)
+	assert.True(t, near(at(img, dpmm, 30, 0.5), color.RGBA{255, 0, 0, 255}, 8))
+	assert.True(t, near(at(img, dpmm, 10, 39.5), color.RGBA{0, 0, 255, 255}, 8))
+	assert.True(t, near(at(img, dpmm, 10, 20), color.RGBA{128, 0, 128, 255}, 8))
+	assert.True(t, near(at(img, dpmm, 30, 20), color.RGBA{128, 0, 128, 255}, 8))
+	assert.Equal(t, white, at(img, dpmm, 45, 20))
+	assert.Equal(t, white, at(img, dpmm, 20, 45))
+	assert.Contains(t, doc.Report().Features, "LaGourandShd")
+
+	// 不完整的行被忽略
+	img = rasterize(t, shadingDocument(t, `<ofd:LaGourandShd VerticesPerRow="3">`+grid+point(0, 50, "0 0 0")+`</ofd:LaGourandShd>`), 1, dpmm)
+	assert.Equal(t, white, at(img, dpmm, 1, 45))
+
+	// Extend 不为 0 时网格外以 BackColor 填充
+	img = rasterize(t, shadingDocument(t, `<ofd:LaGourandShd VerticesPerRow="3" Extend="1"><ofd:BackColor Value="0 255 0"/>`+grid+`</ofd:LaGourandShd>`), 1, dpmm)
+	assert.Equal(t, color.RGBA{0, 255, 0, 255}, at(img, dpmm, 45, 20))
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/tdewolff/canvas"
 	"github.com/zc310/ofd/internal/models"
+	"github.com/zc310/ofd/internal/pdf"
 )
 
 func (p *Document) updateDrawParams(ctx *canvas.Context, dp *models.DrawParam) (*CTColor, *CTColor) {
@@ -12,8 +13,10 @@ func (p *Document) updateDrawParams(ctx *canvas.Context, dp *models.DrawParam) (
 		return nil, nil
 	}
 
-	if dp.StrokeColor != nil && dp.StrokeColor.Value != nil {
-		ctx.SetStrokeColor(dp.StrokeColor.Value.RGBA)
+	if dp.StrokeColor != nil {
+		if value := p.colorValue(dp.StrokeColor); value != nil {
+			p.setStrokeColor(ctx, *value, p.deviceColor(dp.StrokeColor))
+		}
 	}
 	ctx.SetStrokeWidth(max(dp.LineWidth, 1))
 	if dp.DashPattern != nil {
@@ -27,61 +30,44 @@ func (p *Document) updateDrawParams(ctx *canvas.Context, dp *models.DrawParam) (
 }
 
 type CTColor struct {
-	Value    *color.RGBA
-	AxialShd canvas.Gradient
+	Value *color.RGBA
+	// Device 纯色在设备颜色空间中的颜色
+	Device  *pdf.DeviceColor
+	Pattern *models.CtPattern
+	// Shading 需要逐像素计算的渐变
+	Shading *models.CTColor
+}
+
+// areaPaint 是否需要以区域方式绘制 (底纹或逐像素渐变)
+func (c *CTColor) areaPaint() bool {
+	return c != nil && (c.Pattern != nil || c.Shading != nil)
+}
+
+// paintArea 使用底纹或渐变填充 area (当前坐标)
+func (p *Document) paintArea(ctx *canvas.Context, area *canvas.Path, c *CTColor, unit *models.CTGraphicUnit, pb models.StBox) {
+	// 底纹和渐变中的颜色与图元的纯色无关
+	defer p.deviceScope()()
+	switch {
+	case c.Pattern != nil:
+		p.Pattern(ctx, area, c.Pattern, unit, pb)
+	case c.Shading != nil:
+		p.Shading(ctx, area, c.Shading, unit, pb)
+	}
 }
 
 func (p *Document) updateCtColor(object *models.CTColor) *CTColor {
 	if object == nil {
 		return nil
 	}
-	cc := &CTColor{}
-	if object.Value != nil {
-		cc.Value = &object.Value.RGBA
-		// 颜色透明度，在 0~255 之间取值。默认为 255，表示完可选全不透明
-		if object.Alpha != nil && *object.Alpha < 255 {
-			cc.Value.A = 255 - *object.Alpha
-		}
-	}
+	cc := &CTColor{Value: p.colorValue(object), Device: p.deviceColor(object)}
 
 	// 底纹填充
-	pattern := object.Pattern
-	if pattern != nil {
-
+	cc.Pattern = object.Pattern
+	// 轴向、径向及高洛德渐变
+	if object.AxialShd != nil || object.RadialShd != nil || object.GouraudShd != nil || object.LaGourandShd != nil {
+		cc.Shading = object
 	}
 
-	//轴向渐变
-	axialShd := object.AxialShd
-	if axialShd != nil {
-		startPoint := axialShd.StartPoint
-		endPoint := axialShd.EndPoint
-		start := canvas.Point{X: startPoint.X, Y: startPoint.Y}
-		end := canvas.Point{X: endPoint.X, Y: endPoint.Y}
-		gradient := canvas.NewLinearGradient(start, end)
-		if len(axialShd.Segment) == 2 && axialShd.Segment[0].Position == 0 && axialShd.Segment[1].Position == 0 {
-			axialShd.Segment[1].Position = 1
-		}
-		for _, segment := range axialShd.Segment {
-			gradient.Add(segment.Position, segment.Color.Value.RGBA)
-		}
-
-		cc.AxialShd = gradient
-	}
-	///径向渐变
-	shd := object.RadialShd
-	if shd != nil {
-		startPoint := shd.StartPoint
-		endPoint := shd.EndPoint
-		start := canvas.Point{X: startPoint.X, Y: startPoint.Y}
-		end := canvas.Point{X: endPoint.X, Y: endPoint.Y}
-		gradient := canvas.NewRadialGradient(start, shd.StartRadius, end, shd.EndRadius)
-
-		for _, segment := range shd.Segment {
-			gradient.Add(segment.Position, segment.Color.Value.RGBA)
-		}
-		cc.AxialShd = gradient
-
-	}
 	return cc
 }
 
@@ -105,7 +91,9 @@ func getLineJoin(capStr string) canvas.Joiner {
 		return canvas.MiterJoin
 	}
 }
-func (p *Document) updateCtPathStyle(ctx *canvas.Context, object *models.CtPath, dp *models.DrawParam) {
+
+// updateCtPathStyle 设置路径样式，返回需要以区域方式绘制的填充和勾边颜色
+func (p *Document) updateCtPathStyle(ctx *canvas.Context, object *models.CtPath, dp *models.DrawParam) (fillArea, strokeArea *CTColor) {
 	if object == nil {
 		return
 	}
@@ -123,11 +111,12 @@ func (p *Document) updateCtPathStyle(ctx *canvas.Context, object *models.CtPath,
 				if object.Alpha != nil {
 					fillColor.A = 255 - *object.Alpha
 				}
-				ctx.SetFillColor(fillColor)
+				p.setFillColor(ctx, fillColor, fill.Device)
 			}
 
-			if fill.AxialShd != nil {
-				ctx.SetFillGradient(fill.AxialShd)
+			if fill.areaPaint() {
+				fillArea = fill
+				ctx.SetFill(nil)
 			}
 		}
 		if object.Rule == "Even-Odd" {
@@ -145,10 +134,10 @@ func (p *Document) updateCtPathStyle(ctx *canvas.Context, object *models.CtPath,
 		if stroke != nil {
 			if stroke.Value != nil {
 				strokeColor = *stroke.Value
-				ctx.SetStrokeColor(strokeColor)
+				p.setStrokeColor(ctx, strokeColor, stroke.Device)
 			}
-			if stroke.AxialShd != nil {
-				ctx.SetStrokeGradient(stroke.AxialShd)
+			if stroke.areaPaint() {
+				strokeArea = stroke
 			}
 		} else {
 			ctx.SetStrokeColor(strokeColor)
@@ -169,4 +158,5 @@ func (p *Document) updateCtPathStyle(ctx *canvas.Context, object *models.CtPath,
 	if object.DashPattern != nil {
 		ctx.SetDashes(object.DashOffset, *object.DashPattern...)
 	}
+	return
 }
@@ -0,0 +1,160 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zc310/ofd/internal/models"
+	"github.com/zc310/ofd/internal/parser"
+)
+
+// filledRect 返回以 value 填充的矩形路径
+func filledRect(id int, box, value string) string {
+	return fmt.Sprintf(`<ofd:PathObject ID="%d" Boundary="%s" Fill="true" Stroke="false"><ofd:FillColor Value="%s"/><ofd:AbbreviatedData>M 0 0 L 30 0 L 30 30 L 0 30 C</ofd:AbbreviatedData></ofd:PathObject>`,
+		id, box, value)
+}
+
+func TestTemplate_ZOrder(t *testing.T) {
+	const dpmm = 4.0
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	// 模板为 0 至 30 的红色矩形，正文为 20 至 50 的蓝色矩形，重叠部分的颜色表示绘制顺序
+	render := func(tplZOrder, pageZOrder string) color.RGBA {
+		if tplZOrder != "" {
+			tplZOrder = ` ZOrder="` + tplZOrder + `"`
+		}
+		if pageZOrder != "" {
+			pageZOrder = ` ZOrder="` + pageZOrder + `"`
+		}
+		doc := openDocument(t, testOFD(t, map[string]string{
+			"Doc_0/Document.xml":             testDocument(`<ofd:TemplatePage ID="3" BaseLoc="Tpls/Tpl_0/Content.xml"`+tplZOrder+`/>`, ""),
+			"Doc_0/Tpls/Tpl_0/Content.xml":   testPage("", filledRect(20, "0 0 30 30", "255 0 0")),
+			"Doc_0/Pages/Page_0/Content.xml": testPage(`<ofd:Template TemplateID="3"`+pageZOrder+`/>`, filledRect(10, "20 20 30 30", "0 0 255")),
+		}))
+		img := rasterize(t, doc, 1, dpmm)
+		assert.Equal(t, red, at(img, dpmm, 10, 10))
+		assert.Equal(t, blue, at(img, dpmm, 40, 40))
+		return at(img, dpmm, 25, 25)
+	}
+
+	// 默认为 Background，正文覆盖模板
+	c := render("", "")
+	assert.Equal(t, blue, c)
+	// 模板页定义为 Foreground
+	c = render("Foreground", "")
+	assert.Equal(t, red, c)
+	// 页面中的 ZOrder 优先于模板页定义
+	c = render("Foreground", "Background")
+	assert.Equal(t, blue, c)
+	c = render("", "Foreground")
+	assert.Equal(t, red, c)
+
+	// 引用不存在的模板页
+	doc := openDocument(t, testOFD(t, map[string]string{
+		"Doc_0/Pages/Page_0/Content.xml": testPage(`<ofd:Template TemplateID="7"/>`, filledRect(10, "20 20 30 30", "0 0 255")),
+	}))
+	rasterize(t, doc, 1, dpmm)
+	assert.Equal(t, []Issue{{Kind: IssueResource, ID: 7, Message: "模板页 7 不存在"}}, doc.Report().Issues)
+}
+
+func TestLayers_Order(t *testing.T) {
+	const dpmm = 4.0
+	// 背景层写在最后，前景层写在最前，仍按背景、正文、前景的顺序绘制
+	doc := openDocument(t, testOFD(t, map[string]string{
+		"Doc_0/Pages/Page_0/Content.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:Page xmlns:ofd="http://www.ofdspec.org/2016"><ofd:Content>` +
+			`<ofd:Layer ID="2" Type="Foreground">` + filledRect(10, "40 0 30 30", "0 255 0") + `</ofd:Layer>` +
+			`<ofd:Layer ID="3">` + filledRect(11, "0 0 30 30", "0 0 255") + `</ofd:Layer>` +
+			`<ofd:Layer ID="4" Type="Background">` + filledRect(12, "20 20 30 30", "255 0 0") + `</ofd:Layer>` +
+			`</ofd:Content></ofd:Page>`,
+	}))
+	img := rasterize(t, doc, 1, dpmm)
+	// 正文覆盖背景，前景覆盖背景
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, at(img, dpmm, 25, 25))
+	assert.Equal(t, color.RGBA{0, 255, 0, 255}, at(img, dpmm, 45, 25))
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, at(img, dpmm, 45, 45))
+}
+
+// square 返回以 value 填充的 10x10 矩形路径
+func square(id int, x, y float64, value string) string {
+	return fmt.Sprintf(`<ofd:PathObject ID="%d" Boundary="%g %g 10 10" Fill="true" Stroke="false"><ofd:FillColor Value="%s"/><ofd:AbbreviatedData>M 0 0 L 10 0 L 10 10 L 0 10 C</ofd:AbbreviatedData></ofd:PathObject>`,
+		id, x, y, value)
+}
+
+// annot 返回外观为 10x10 矩形的注释
+func annot(id int, attrs string, x, y float64, value string) string {
+	return fmt.Sprintf(`<ofd:Annot ID="%d"%s><ofd:Appearance Boundary="%g %g 10 10">%s</ofd:Appearance></ofd:Annot>`,
+		id, attrs, x, y, square(id+1, 0, 0, value))
+}
+
+func TestOptions(t *testing.T) {
+	const dpmm = 4.0
+	white := color.RGBA{255, 255, 255, 255}
+	// 各部分为页面上不同位置的 10x10 矩形
+	parts := []struct {
+		name string
+		x, y float64
+		c    color.RGBA
+	}{
+		{"template", 0, 0, color.RGBA{255, 0, 0, 255}},
+		{"background", 20, 0, color.RGBA{0, 255, 0, 255}},
+		{"body", 40, 0, color.RGBA{0, 0, 255, 255}},
+		{"foreground", 0, 20, color.RGBA{255, 255, 0, 255}},
+		{"custom", 20, 20, color.RGBA{0, 255, 255, 255}},
+		{"seal", 40, 20, color.RGBA{128, 0, 0, 255}},
+		{"watermark", 0, 40, color.RGBA{0, 0, 0, 255}},
+		{"stamp", 20, 40, color.RGBA{255, 0, 255, 255}},
+		{"noprint", 40, 40, color.RGBA{128, 128, 128, 255}},
+	}
+	value := func(c color.RGBA) string { return fmt.Sprintf("%d %d %d", c.R, c.G, c.B) }
+	layer := func(id int, typ string, i int) string {
+		return fmt.Sprintf(`<ofd:Layer ID="%d" Type="%s">`, id, typ) + square(id+1, parts[i].x, parts[i].y, value(parts[i].c)) + `</ofd:Layer>`
+	}
+	data := testOFD(t, map[string]string{
+		"Doc_0/Document.xml":           testDocument(`<ofd:TemplatePage ID="3" BaseLoc="Tpls/Tpl_0/Content.xml"/>`, `<ofd:Annotations>Annotations.xml</ofd:Annotations>`),
+		"Doc_0/Tpls/Tpl_0/Content.xml": testPage("", square(20, 0, 0, value(parts[0].c))),
+		"Doc_0/Pages/Page_0/Content.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:Page xmlns:ofd="http://www.ofdspec.org/2016"><ofd:Template TemplateID="3"/><ofd:Content>` +
+			layer(30, "Background", 1) + layer(40, "Body", 2) + layer(50, "Foreground", 3) + layer(60, "Custom", 4) + `</ofd:Content></ofd:Page>`,
+		"Doc_0/Annotations.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:Annotations xmlns:ofd="http://www.ofdspec.org/2016"><ofd:Page PageID="1"><ofd:FileLoc>Pages/Page_0/Annotation.xml</ofd:FileLoc></ofd:Page></ofd:Annotations>`,
+		"Doc_0/Pages/Page_0/Annotation.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:PageAnnot xmlns:ofd="http://www.ofdspec.org/2016">` +
+			annot(70, ` Type="Watermark"`, parts[6].x, parts[6].y, value(parts[6].c)) +
+			annot(80, ` Type="Stamp"`, parts[7].x, parts[7].y, value(parts[7].c)) +
+			annot(90, ` Type="Stamp" Print="false"`, parts[8].x, parts[8].y, value(parts[8].c)) + `</ofd:PageAnnot>`,
+	})
+
+	// hidden 为应当不绘制的部分
+	check := func(opts Options, hidden ...string) {
+		t.Helper()
+		doc := openDocument(t, data, opts)
+		doc.Seals[1] = []*parser.SealInfo{{
+			StampAnnot: &models.StampAnnot{PageRef: 1, Boundary: models.StBox{X: parts[5].x, Y: parts[5].y, Width: 10, Height: 10}},
+			SealData:   &parser.SealData{FileType: "png", Data: solidPNG(t, parts[5].c, 2, 2)},
+		}}
+		img := rasterize(t, doc, 1, dpmm)
+		for _, part := range parts {
+			want := part.c
+			for _, h := range hidden {
+				if h == part.name {
+					want = white
+				}
+			}
+			assert.Equal(t, want, at(img, dpmm, part.x+5, part.y+5), part.name)
+		}
+	}
+
+	check(Options{})
+	check(Options{HideTemplates: true}, "template")
+	check(Options{HideLayers: map[string]bool{"Background": true, "Custom": true}}, "background", "custom")
+	// 模板中的图层同样按类型隐藏，模板只有正文图层
+	check(Options{HideLayers: map[string]bool{"Body": true, "Foreground": true}}, "template", "body", "foreground")
+	check(Options{HideSeals: true}, "seal")
+	// 隐藏水印，保留印章
+	check(Options{HideAnnots: map[models.AnnotType]bool{"Watermark": true}}, "watermark")
+	// 打印时不绘制 Print 为 false 的注释
+	check(Options{Print: true}, "noprint")
+}
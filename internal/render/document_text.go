@@ -1,7 +1,13 @@
 package render
 
 import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+
 	"github.com/tdewolff/canvas"
+	canvasText "github.com/tdewolff/canvas/text"
+	"github.com/tdewolff/font"
 
 	"github.com/zc310/ofd/internal/models"
 )
@@ -9,99 +15,340 @@ import (
 func (p *Document) Text(ctx *canvas.Context, object models.TextObject, dp *models.DrawParam, pb models.StBox) {
 	ctx.Push()
 	defer ctx.Pop()
+	defer p.clipUnit(ctx, &object.CTGraphicUnit, pb)()
+	defer p.deviceScope()()
 
-	var ft *canvas.FontFamily
-	var err error
-	ft, err = p.fonts.LoadFont(object.Font)
-	if err != nil {
+	fill, stroke := p.updateDrawParams(ctx, dp)
+	if object.FillColor != nil {
+		fill = p.updateCtColor(object.FillColor)
+	}
+	if object.StrokeColor != nil {
+		stroke = p.updateCtColor(object.StrokeColor)
+	}
+	// Fill 默认为 true；不填充的文字 (常见于扫描件的 OCR 文字层) 在位图中不绘制，
+	// 在 PDF 中以不可见文字输出，使其可以选择和搜索
+	filled := object.Fill != "false"
+
+	m := unitMatrix(&object.CTGraphicUnit, pb)
+	if m.Det() == 0 {
+		return
+	}
+	// textPath 已包含 CTM，其结果只需图元坐标到页面的变换
+	base := pageMatrix(pb).Translate(object.Boundary.X, object.Boundary.Y)
+	if filled && fill.areaPaint() {
+		if path := p.textPath(&object.CtText); path != nil {
+			p.paintArea(ctx, path.Transform(base), fill, &object.CTGraphicUnit, pb)
+		}
+	} else {
+		fillColor := canvas.Black
+		if fill != nil && fill.Value != nil {
+			fillColor = *fill.Value
+			p.addDeviceColor(fillColor, fill.Device)
+		}
+		face, err := p.textFace(&object.CtText, fillColor)
+		if err != nil {
+			return
+		}
+		for _, code := range object.TextCode {
+			for _, run := range textRuns(&object.CtText, code, face) {
+				ctx.Push()
+				ctx.ComposeView(m.Mul(run.view))
+				p.drawGlyphs(ctx, face, run.glyphs, !filled)
+				ctx.Pop()
+			}
+		}
+	}
+
+	if object.Stroke {
+		p.strokeText(ctx, &object.CtText, dp, stroke, base, pb)
+	}
+}
+
+// strokeText 沿字形轮廓勾边，m 为图元坐标到画布坐标的变换
+func (p *Document) strokeText(ctx *canvas.Context, object *models.CtText, dp *models.DrawParam, stroke *CTColor, m canvas.Matrix, pb models.StBox) {
+	path := p.textPath(object)
+	if path == nil {
+		return
+	}
+	ctx.Push()
+	defer ctx.Pop()
+	ctx.ComposeView(m)
+	ctx.SetFill(nil)
+	ctx.SetStrokeColor(canvas.Black)
+	if stroke != nil && stroke.Value != nil {
+		p.setStrokeColor(ctx, *stroke.Value, stroke.Device)
+	}
+	width := object.LineWidth
+	if width <= 0 && dp != nil {
+		width = dp.LineWidth
+	}
+	if width <= 0 {
+		width = 0.353
+	}
+	ctx.SetStrokeWidth(width)
+	if object.Cap != "" {
+		ctx.SetStrokeCapper(getLineCap(object.Cap))
+	}
+	if object.Join != "" {
+		ctx.SetStrokeJoiner(getLineJoin(object.Join))
+	}
+	if object.DashPattern != nil {
+		ctx.SetDashes(object.DashOffset, *object.DashPattern...)
+	}
+
+	if stroke.areaPaint() {
+		outline := strokeOutline(ctx.Style, path)
+		ctx.Style.Stroke = canvas.Paint{}
+		p.paintArea(ctx, outline.Transform(m), stroke, &object.CTGraphicUnit, pb)
 		return
 	}
+	p.drawPath(ctx, path)
+}
 
-	if object.Weight == 0 {
-		object.Weight = 400
+// textFace 按文字对象的字体、字号、粗细和倾斜创建字体，字体缺少对应粗细时以加粗轮廓模拟
+func (p *Document) textFace(object *models.CtText, args ...interface{}) (*canvas.FontFace, error) {
+	if _, ok := p.FontRes[models.StID(object.Font)]; !ok {
+		p.issue(IssueResource, object.Font, fmt.Sprintf("字体资源 %d 不存在", object.Font))
 	}
-	if object.HScale == 0 {
-		object.HScale = 1.0
+	ft, err := p.fonts.LoadFont(object.Font)
+	p.useFont(object.Font)
+	if err != nil {
+		p.issue(IssueFont, object.Font, err.Error())
+		return nil, err
 	}
-	fontStyle := canvas.FontRegular
-	if object.Weight >= 700 {
-		fontStyle |= canvas.FontBold
+	weight := object.Weight
+	if weight <= 0 {
+		weight = 400
+		if res := p.FontRes[models.StID(object.Font)]; res != nil && res.Bold {
+			weight = 700
+		}
 	}
+	fontStyle := fontWeight(weight)
 	if object.Italic {
 		fontStyle |= canvas.FontItalic
 	}
+	args = append(args, fontStyle, canvas.FontNormal)
+	face := ft.Face(object.Size*2.83465, args...)
+	if weight >= 400 && face.FauxBold < 0 {
+		// 字体族只有粗体时不减细常规文字
+		face.FauxBold = 0
+	}
+	return face, nil
+}
 
-	strokeColor := canvas.Black
+// fontWeight 将 100 至 900 的字重转换为字体样式
+func fontWeight(weight int) canvas.FontStyle {
+	switch {
+	case weight < 150:
+		return canvas.FontThin
+	case weight < 250:
+		return canvas.FontExtraLight
+	case weight < 350:
+		return canvas.FontLight
+	case weight < 450:
+		return canvas.FontRegular
+	case weight < 550:
+		return canvas.FontMedium
+	case weight < 650:
+		return canvas.FontSemiBold
+	case weight < 750:
+		return canvas.FontBold
+	case weight < 850:
+		return canvas.FontExtraBold
+	}
+	return canvas.FontBlack
+}
 
-	fill, stroke := p.updateDrawParams(ctx, dp)
+// GlyphRenderer 可以直接输出已定位字形的渲染器 (如 PDF)，文字在输出中可以选择和搜索；
+// 其他渲染器以字形轮廓绘制文字。m 将基线坐标 (y 轴向上) 映射到渲染器坐标，
+// invisible 表示文字不可见，只用于选择和搜索
+type GlyphRenderer interface {
+	RenderGlyphs(face *canvas.FontFace, glyphs []canvasText.Glyph, invisible bool, m canvas.Matrix)
+}
 
-	if object.CTM != nil {
-		if scale := object.CTM.YScale(); scale > 0 {
-			object.Size *= scale
-		}
-	}
+// glyphRun 一行已定位的字形，字形前进量已按 DeltaX 调整；view 将基线坐标 (y 轴向上) 映射到文字对象坐标
+type glyphRun struct {
+	glyphs []canvasText.Glyph
+	view   canvas.Matrix
+}
 
-	var argsFont []interface{}
-	if object.FillColor != nil {
-		fill = p.updateCtColor(object.FillColor)
+// textRuns 将文字片段排版为若干文字行。
+// 字符不旋转时，DeltaY 为 0 的连续字符作为一行整体排版，按 DeltaX 调整字形间距；其余情况逐字排版。
+// 缺少的 DeltaX/DeltaY 表示不偏移，两者均未给出时字符沿阅读方向按字宽 (垂直方向按字号) 前进
+func textRuns(object *models.CtText, code models.TextCode, face *canvas.FontFace) []glyphRun {
+	runes := []rune(code.Value)
+	for i, r := range runes {
+		// 段落分隔符会使排版换行
+		if canvasText.IsParagraphSeparator(r) {
+			runes[i] = ' '
+		}
 	}
-	if object.StrokeColor != nil {
-		stroke = p.updateCtColor(object.StrokeColor)
+	hscale := object.HScale
+	if hscale <= 0 {
+		hscale = 1
 	}
-	if fill != nil {
-		if fill.Value != nil {
-			argsFont = append(argsFont, *fill.Value)
+	rd, cd := angle(object.ReadDirection), angle(object.CharDirection)
+	x, y := code.X, code.Y
+	natural := len(code.DeltaX) == 0 && len(code.DeltaY) == 0
+	var runs []glyphRun
+
+	if cd == 0 && (rd == 0 || !natural) {
+		missing := 0.0
+		if natural {
+			missing = math.NaN()
+		}
+		for start := 0; start < len(runes); {
+			end := start + 1
+			for end < len(runes) && delta(code.DeltaY, end-1, 0) == 0 {
+				end++
+			}
+			adv := make([]float64, end-start-1)
+			for i := range adv {
+				adv[i] = delta(code.DeltaX, start+i, missing)
+			}
+			glyphs, pos := fitRun(face, string(runes[start:end]), adv, hscale)
+			runs = append(runs, glyphRun{glyphs, canvas.Identity.Translate(x, y).Scale(hscale, -1.0)})
+			if last := end - 1; end < len(runes) {
+				if dx := delta(code.DeltaX, last, missing); !math.IsNaN(dx) {
+					x += pos[last-start] + dx
+				} else {
+					x += pos[end-start]
+				}
+				y += delta(code.DeltaY, last, 0)
+			}
+			start = end
 		}
+		return runs
+	}
 
-		if fill.AxialShd != nil {
-			argsFont = append(argsFont, fill.AxialShd)
+	// 沿阅读方向的单位向量
+	ux, uy := math.Cos(float64(rd)*math.Pi/180), math.Sin(float64(rd)*math.Pi/180)
+	prev := 0.0
+	for i, r := range runes {
+		glyphs, pos := fitRun(face, string(r), nil, hscale)
+		// 字符方向与阅读方向一致时按字宽前进，否则按字号前进
+		adv := object.Size
+		if (rd%180 == 0) == (cd%180 == 0) {
+			adv = pos[1]
 		}
+		if i > 0 {
+			// 逆向阅读时以当前字符的宽度后退
+			step := prev
+			if rd == 180 || rd == 270 {
+				step = adv
+			}
+			if !natural {
+				step = 0
+			}
+			x += delta(code.DeltaX, i-1, ux*step)
+			y += delta(code.DeltaY, i-1, uy*step)
+		}
+		prev = adv
+		runs = append(runs, glyphRun{glyphs, canvas.Identity.Translate(x, y).Rotate(float64(cd)).Scale(hscale, -1.0)})
 	}
-	if stroke != nil && stroke.Value != nil {
-		strokeColor = *stroke.Value
+	return runs
+}
+
+// angle 将角度规范到 [0, 360)
+func angle(v int) int {
+	return (v%360 + 360) % 360
+}
+
+// delta 返回第 i 个间距，未给出时返回 def
+func delta(values models.StArrayF, i int, def float64) float64 {
+	if i < len(values) {
+		return values[i]
 	}
-	ctx.SetStrokeColor(strokeColor)
+	return def
+}
 
-	argsFont = append(argsFont, fontStyle)
-	argsFont = append(argsFont, canvas.FontNormal)
-	face := ft.Face(object.Size*2.83465, argsFont...)
+// fitRun 整体排版一行文字，adv[i] 为第 i 个字符到下一字符的间距 (NaN 表示按字宽)，
+// 返回调整了前进量的字形，使字符落在指定位置，以及每个字符及行末在文字对象坐标中的水平位置
+func fitRun(face *canvas.FontFace, s string, adv []float64, hscale float64) ([]canvasText.Glyph, []float64) {
+	glyphs := face.Glyphs(s)
+	n := utf8.RuneCountInString(s)
+	// runeAt 将字节偏移 (字形的 Cluster) 映射为字符序号
+	runeAt := make([]int, len(s)+1)
+	k := 0
+	for j := range s {
+		for i := j; i < len(s) && (i == j || !utf8.RuneStart(s[i])); i++ {
+			runeAt[i] = k
+		}
+		k++
+	}
+	runeAt[len(s)] = n
 
-	bx, by := object.Boundary.X, object.Boundary.Y
-	h := pb.Height
+	pos := make([]float64, n+1)
+	// f 为字形单位对应的文字对象坐标长度
+	f := face.MmPerEm * hscale
+	x := 0.0
+	for j := range glyphs {
+		g := &glyphs[j]
+		r := runeAt[min(int(g.Cluster), len(s))]
+		next := n
+		if j+1 < len(glyphs) {
+			next = runeAt[min(int(glyphs[j+1].Cluster), len(s))]
+		}
+		if next <= r {
+			// 同一字符的多个字形
+			x += float64(g.XAdvance) * f
+			continue
+		}
+		// 字符到下一字符的目标间距，任一间距未给出时使用字形前进量
+		target := 0.0
+		for c := r; c < next && !math.IsNaN(target); c++ {
+			target += delta(adv, c, math.NaN())
+		}
+		if !math.IsNaN(target) {
+			g.XAdvance = int32(math.Round((pos[r] + target - x) / f))
+		}
+		x += float64(g.XAdvance) * f
+		for c := r + 1; c <= next; c++ {
+			pos[c] = x
+		}
+	}
+	pos[n] = x
+	return glyphs, pos
+}
 
-	for _, code := range object.TextCode {
-		posX, posY := code.X, code.Y
-		for i, r := range []rune(code.Value) {
-			s := string(r)
-			if i > 0 {
-				if di := i - 1; di < len(code.DeltaX) {
-					posX += code.DeltaX[di]
-				}
-				if di := i - 1; di < len(code.DeltaY) {
-					posY += code.DeltaY[di]
-				}
-			}
-			var cX, cY float64
-			if object.CTM == nil {
-				cX, cY = posX+bx, h-(posY+by)
-				ctx.DrawText(cX, cY, canvas.NewTextLine(face, s, canvas.Left))
-			} else {
-				if object.CTM.RotationAngle() != 0 {
-					ctx.Push()
-					angle := object.CTM.RotationAngleDegrees()
-					tx, ty := object.CTM.Transform(posX, posY)
-					finalX, finalY := tx+bx, h-(ty+by)
-					ctx.Translate(finalX, finalY)
-					ctx.Rotate(-angle)
-					ctx.DrawText(0, 0, canvas.NewTextLine(face, s, canvas.Left))
-					ctx.Pop()
-				} else {
-					tx, ty := object.CTM.Transform(posX, posY)
-					cX, cY = tx+bx, h-(ty+by)
-					ctx.DrawText(cX, cY, canvas.NewTextLine(face, s, canvas.Left))
-				}
-			}
+// glyphPath 返回一行字形的轮廓，坐标为基线坐标 (y 轴向上)，包含模拟粗体和斜体
+func glyphPath(face *canvas.FontFace, glyphs []canvasText.Glyph) *canvas.Path {
+	f := face.MmPerEm
+	path := &canvas.Path{}
+	var gx, gy int32
+	for _, g := range glyphs {
+		_ = face.Font.GlyphPath(path, g.ID, 0, f*float64(gx+g.XOffset), f*float64(gy+g.YOffset), f, font.NoHinting)
+		gx += g.XAdvance
+		gy += g.YAdvance
+	}
+	if face.FauxBold != 0 {
+		d := face.FauxBold * face.Size
+		if face.Font.IsTrueType {
+			// TrueType 轮廓为顺时针方向
+			d = -d
+		}
+		path = path.Offset(d, canvas.Tolerance)
+	}
+	if face.FauxItalic != 0 {
+		path = path.Transform(canvas.Identity.Shear(face.FauxItalic, 0))
+	}
+	return path
+}
 
+// textPath 返回文字的字形轮廓，坐标为文字对象坐标 (y 轴向下)
+func (p *Document) textPath(object *models.CtText) *canvas.Path {
+	face, err := p.textFace(object)
+	if err != nil {
+		return nil
+	}
+	path := &canvas.Path{}
+	for _, code := range object.TextCode {
+		for _, run := range textRuns(object, code, face) {
+			path = path.Append(glyphPath(face, run.glyphs).Transform(run.view))
 		}
 	}
+	if object.CTM != nil {
+		path = path.Transform(ctmMatrix(object.CTM))
+	}
+	return path
 }
@@ -0,0 +1,208 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tdewolff/canvas"
+	"github.com/tdewolff/canvas/renderers/rasterizer"
+
+	"github.com/zc310/ofd/internal/pdf"
+)
+
+// textOFD 构造每行一个文字对象的页面
+func textOFD(t testing.TB, lines int, attrs ...string) []byte {
+	var objects strings.Builder
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&objects, `<ofd:TextObject ID="%d" Boundary="1 %d 48 2" Font="3" Size="1"%s><ofd:TextCode X="0" Y="1" DeltaX="g 53 0.8">The quick brown fox jumps over the lazy dog 0123456789</ofd:TextCode></ofd:TextObject>`,
+			10+i, i, strings.Join(attrs, ""))
+	}
+	return testOFD(t, map[string]string{
+		"Doc_0/PublicRes.xml":            testRes(`<ofd:Fonts><ofd:Font ID="3" FontName="DejaVu Sans"/></ofd:Fonts>`),
+		"Doc_0/Pages/Page_0/Content.xml": testPage("", objects.String()),
+	})
+}
+
+func BenchmarkText(b *testing.B) {
+	doc := openDocument(b, textOFD(b, 48))
+	page := doc.Pages[0]
+	b.Run("PDF", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r := pdf.New(io.Discard, 50, 50, nil)
+			assert.Nil(b, doc.Draw(canvas.NewContext(r), page))
+			assert.Nil(b, r.Close())
+		}
+	})
+	b.Run("Image", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c, err := doc.Page(page)
+			assert.Nil(b, err)
+			rasterizer.Draw(c, canvas.DPMM(8), canvas.DefaultColorSpace)
+		}
+	})
+}
+
+func TestFitRun(t *testing.T) {
+	doc := openDocument(t, textOFD(t, 1))
+	ft, err := doc.fonts.LoadFont(3)
+	assert.Nil(t, err)
+	face := ft.Face(10)
+
+	// 第二个字符按字宽前进，其余按给定的间距
+	glyphs, pos := fitRun(face, "AVA", []float64{5, math.NaN()}, 1)
+	assert.Len(t, glyphs, 3)
+	assert.InDelta(t, 5, pos[1], 0.01)
+	natural := face.Glyphs("AVA")
+	assert.Equal(t, natural[1].XAdvance, glyphs[1].XAdvance)
+	assert.InDelta(t, 5+float64(natural[1].XAdvance)*face.MmPerEm, pos[2], 0.01)
+	// 水平缩放时位置仍为文字对象坐标
+	_, pos = fitRun(face, "AVA", []float64{5, 5}, 0.5)
+	assert.InDelta(t, 10, pos[2], 0.01)
+	// 多字节字符
+	_, pos = fitRun(face, "é.é", []float64{2, 3}, 1)
+	assert.InDelta(t, 2, pos[1], 0.01)
+	assert.InDelta(t, 5, pos[2], 0.01)
+}
+
+// darkPixels 统计第一行文字范围内的深色像素
+func darkPixels(t *testing.T, doc *Document) int {
+	img := rasterize(t, doc, 1, 8)
+	n := 0
+	for x := 8; x < 48*8; x++ {
+		for y := 0; y < 16; y++ {
+			if c := at(img, 1, float64(x), float64(y)); c.R < 128 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// textPDF 返回第一页的 PDF 输出
+func textPDF(t *testing.T, doc *Document) string {
+	var buf bytes.Buffer
+	r := pdf.New(&buf, 50, 50, &pdf.Options{SubsetFonts: true})
+	assert.Nil(t, doc.Draw(canvas.NewContext(r), doc.Pages[0]))
+	assert.Nil(t, r.Close())
+	return buf.String()
+}
+
+func TestText_Output(t *testing.T) {
+	doc := openDocument(t, textOFD(t, 1))
+	// 位图输出以字形轮廓绘制
+	assert.Greater(t, darkPixels(t, doc), 100)
+
+	// PDF 输出为文字，字形按 DeltaX 定位
+	out := textPDF(t, doc)
+	assert.Contains(t, out, "BT")
+	assert.Regexp(t, `\] ?TJ`, out)
+	assert.NotContains(t, out, " 3 Tr")
+}
+
+func TestText_Invisible(t *testing.T) {
+	doc := openDocument(t, textOFD(t, 1, ` Fill="false"`))
+	// 不填充的文字在位图中不绘制
+	assert.Equal(t, 0, darkPixels(t, doc))
+
+	// PDF 中以文字绘制模式 3 输出，可以选择和搜索
+	out := textPDF(t, doc)
+	assert.Contains(t, out, " 3 Tr")
+	assert.Regexp(t, `\] ?TJ`, out)
+
+	// 有裁剪区时同样输出不可见文字
+	data := testOFD(t, map[string]string{
+		"Doc_0/PublicRes.xml":            testRes(`<ofd:Fonts><ofd:Font ID="3" FontName="DejaVu Sans"/></ofd:Fonts>`),
+		"Doc_0/Pages/Page_0/Content.xml": testPage("", `<ofd:TextObject ID="10" Boundary="1 0 48 2" Font="3" Size="1" Fill="false"><ofd:Clips><ofd:Clip><ofd:Area><ofd:Path Boundary="0 0 20 2"><ofd:AbbreviatedData>M 0 0 L 20 0 L 20 2 L 0 2 C</ofd:AbbreviatedData></ofd:Path></ofd:Area></ofd:Clip></ofd:Clips><ofd:TextCode X="0" Y="1">Hidden</ofd:TextCode></ofd:TextObject>`),
+	})
+	doc = openDocument(t, data)
+	assert.Equal(t, 0, darkPixels(t, doc))
+	assert.Contains(t, textPDF(t, doc), " 3 Tr")
+}
+
+// textDocument 返回只有 object 的文档，字体 3 为 DejaVu Sans
+func textDocument(t *testing.T, object string) *Document {
+	return openDocument(t, testOFD(t, map[string]string{
+		"Doc_0/PublicRes.xml":            testRes(`<ofd:Fonts><ofd:Font ID="3" FontName="DejaVu Sans"/></ofd:Fonts>`),
+		"Doc_0/Pages/Page_0/Content.xml": testPage("", object),
+	}))
+}
+
+// textObject 返回外接矩形为 10 10 40 20、字号 3 的文字对象
+func textObject(attrs, children string) string {
+	return `<ofd:TextObject ID="10" Boundary="10 10 40 20" Font="3" Size="3"` + attrs + `>` + children + `</ofd:TextObject>`
+}
+
+func TestText_CTM(t *testing.T) {
+	const dpmm = 10.0
+	// CTM 旋转 45 度，字符沿右下方向排列
+	img := rasterize(t, textDocument(t, `<ofd:TextObject ID="10" Boundary="0 0 50 50" Font="3" Size="4" CTM="0.7071 0.7071 -0.7071 0.7071 0 0">`+
+		`<ofd:TextCode X="10" Y="10" DeltaX="8 8 8">HHHH</ofd:TextCode></ofd:TextObject>`), 1, dpmm)
+	ctm := func(x, y float64) (float64, float64) {
+		return 0.7071*x - 0.7071*y, 0.7071*x + 0.7071*y
+	}
+	for i := 0; i < 4; i++ {
+		x, y := ctm(10+8*float64(i)+1.5, 10-1.5)
+		assert.Greater(t, countNear(img, dpmm, x, y, 1, dark), 0, "char %d", i)
+	}
+	// 未旋转时的位置没有文字
+	assert.Equal(t, 0, countNear(img, dpmm, 30, 8.5, 1.5, dark))
+}
+
+func TestText_Run(t *testing.T) {
+	const dpmm = 10.0
+	// DeltaX 使用 g 压缩表示，字符按间距定位
+	img := rasterize(t, textDocument(t, textObject("", `<ofd:TextCode X="0" Y="3" DeltaX="10 g 2 5">IIII</ofd:TextCode>`)), 1, dpmm)
+	for _, x := range []float64{10, 20, 25, 30} {
+		assert.Greater(t, countNear(img, dpmm, x+0.5, 12, 0.5, dark), 0, "x=%v", x)
+	}
+	assert.Equal(t, 0, countNear(img, dpmm, 15, 12, 1.5, dark))
+
+	// 只给出 DeltaY 时字符竖排，X 方向不偏移
+	img = rasterize(t, textDocument(t, textObject("", `<ofd:TextCode X="0" Y="3" DeltaY="g 2 4">III</ofd:TextCode>`)), 1, dpmm)
+	for _, y := range []float64{12, 16, 20} {
+		assert.Greater(t, countNear(img, dpmm, 10.5, y, 0.5, dark), 0, "y=%v", y)
+	}
+	assert.Equal(t, 0, countNear(img, dpmm, 13.5, 16, 1, dark))
+
+	// CharDirection 为 180 时字形旋转到基线下方
+	img = rasterize(t, textDocument(t, textObject(` CharDirection="180"`, `<ofd:TextCode X="0" Y="3" DeltaX="10">II</ofd:TextCode>`)), 1, dpmm)
+	for _, x := range []float64{10, 20} {
+		assert.Greater(t, countNear(img, dpmm, x-0.5, 14, 0.5, dark), 0, "x=%v", x)
+		assert.Equal(t, 0, countNear(img, dpmm, x+0.5, 12, 0.5, dark), "x=%v", x)
+	}
+}
+
+func TestText_Mode(t *testing.T) {
+	const dpmm = 10.0
+	red := func(c color.RGBA) bool { return c.R > 200 && c.G < 100 && c.B < 100 }
+	render := func(attrs, children string) image.Image {
+		return rasterize(t, textDocument(t, textObject(attrs, children+`<ofd:TextCode X="0" Y="3">HHHH</ofd:TextCode>`)), 1, dpmm)
+	}
+	normal := countNear(render("", ""), dpmm, 14, 11.5, 4, dark)
+	assert.Greater(t, normal, 0)
+
+	// 不填充也不勾边的文字不可见
+	assert.Equal(t, 0, countNear(render(` Fill="false"`, ""), dpmm, 14, 11.5, 4, dark))
+
+	// 只勾边时绘制轮廓
+	img := render(` Fill="false" Stroke="true" LineWidth="0.1"`, `<ofd:StrokeColor Value="255 0 0"/>`)
+	assert.Greater(t, countNear(img, dpmm, 14, 11.5, 4, red), 0)
+	assert.Equal(t, 0, countNear(img, dpmm, 14, 11.5, 4, dark))
+
+	// 填充并勾边
+	img = render(` Stroke="true" LineWidth="0.1"`, `<ofd:StrokeColor Value="255 0 0"/>`)
+	assert.Greater(t, countNear(img, dpmm, 14, 11.5, 4, red), 0)
+	assert.Greater(t, countNear(img, dpmm, 14, 11.5, 4, dark), 0)
+
+	// 粗体 (字体缺少粗体时模拟加粗)
+	assert.Greater(t, countNear(render(` Weight="900"`, ""), dpmm, 14, 11.5, 4, dark), normal)
+	// 水平缩放压缩字宽
+	assert.Less(t, countNear(render(` HScale="0.5"`, ""), dpmm, 14, 11.5, 4, dark), normal)
+}
@@ -0,0 +1,340 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/tdewolff/canvas"
+)
+
+// fontSource 字体文件或内存中的字体，index 为字体集合 (TTC) 中的序号
+type fontSource struct {
+	path  string
+	data  []byte
+	index int
+	style canvas.FontStyle
+}
+
+// key 返回字体来源的唯一标识
+func (s *fontSource) key() string {
+	if s.data != nil {
+		return fmt.Sprintf("mem:%p#%d", s.data, s.index)
+	}
+	return s.path + "#" + strconv.Itoa(s.index)
+}
+
+// fontIndex 规范化字体名到字体来源的索引
+type fontIndex map[string][]fontSource
+
+// add 按名称索引字体，同名同样式的字体只保留先出现的
+func (idx fontIndex) add(name string, src fontSource) {
+	name = normalizeFontName(name)
+	if name == "" {
+		return
+	}
+	for _, s := range idx[name] {
+		if s.style == src.style {
+			return
+		}
+	}
+	idx[name] = append(idx[name], src)
+}
+
+// fontCatalog 字体目录，每个目录只扫描一次，按字体族名、全名及 PostScript 名 (含中文等本地化名称) 查找字体
+type fontCatalog struct {
+	mu       sync.Mutex
+	dirs     map[string]fontIndex
+	memory   fontIndex
+	families map[string]*canvas.FontFamily
+}
+
+var catalog = &fontCatalog{}
+
+// RegisterFont 注册内存中的字体 (TTF/OTF/TTC)，字体内的名称及 names 均可用于查找。
+// 注册的字体优先于字体目录中的同名字体，用于没有系统字体的环境
+func RegisterFont(data []byte, names ...string) error {
+	return catalog.register(data, names...)
+}
+
+func (c *fontCatalog) register(data []byte, names ...string) error {
+	faces, err := readFontFaces(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.memory == nil {
+		c.memory = make(fontIndex)
+	}
+	for _, face := range faces {
+		src := fontSource{data: data, index: face.index, style: face.style}
+		for _, name := range names {
+			c.memory.add(name, src)
+		}
+		for _, name := range face.names {
+			c.memory.add(name, src)
+		}
+	}
+	return nil
+}
+
+// lookup 在内存字体及各目录中查找字体，返回第一个找到的字体族的各个样式
+func (c *fontCatalog) lookup(name string, dirs []string) []fontSource {
+	name = normalizeFontName(name)
+	c.mu.Lock()
+	srcs := c.memory[name]
+	c.mu.Unlock()
+	if len(srcs) > 0 {
+		return srcs
+	}
+	for _, dir := range dirs {
+		if srcs = c.dir(dir)[name]; len(srcs) > 0 {
+			return srcs
+		}
+	}
+	return nil
+}
+
+// dir 返回目录的字体索引，首次使用时扫描目录
+func (c *fontCatalog) dir(dir string) fontIndex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if idx, ok := c.dirs[dir]; ok {
+		return idx
+	}
+	if c.dirs == nil {
+		c.dirs = make(map[string]fontIndex)
+	}
+	idx := scanFontDir(dir)
+	c.dirs[dir] = idx
+	return idx
+}
+
+// family 加载字体来源为字体族，已加载的字体族在文档间共享
+func (c *fontCatalog) family(name string, srcs []fontSource) (*canvas.FontFamily, error) {
+	keys := make([]string, len(srcs))
+	for i := range srcs {
+		keys[i] = srcs[i].key()
+	}
+	key := strings.Join(keys, "|")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if f := c.families[key]; f != nil {
+		return f, nil
+	}
+	f := canvas.NewFontFamily(name)
+	var err error
+	for _, src := range srcs {
+		data := src.data
+		if data == nil {
+			if data, err = os.ReadFile(src.path); err != nil {
+				return nil, err
+			}
+		}
+		if err = f.LoadFont(data, src.index, src.style); err != nil {
+			return nil, fmt.Errorf("load font %s: %w", src.key(), err)
+		}
+	}
+	if c.families == nil {
+		c.families = make(map[string]*canvas.FontFamily)
+	}
+	c.families[key] = f
+	return f, nil
+}
+
+// scanFontDir 扫描目录下的字体文件，只读取字体的名称及样式信息
+func scanFontDir(dir string) fontIndex {
+	idx := make(fontIndex)
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ttf", ".otf", ".ttc", ".otc":
+		default:
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		faces, err := readFontFaces(f)
+		if err != nil {
+			return nil
+		}
+		for _, face := range faces {
+			src := fontSource{path: path, index: face.index, style: face.style}
+			for _, name := range face.names {
+				idx.add(name, src)
+			}
+		}
+		return nil
+	})
+	return idx
+}
+
+// normalizeFontName 规范化字体名，忽略大小写、空格、连字符和下划线
+func normalizeFontName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+}
+
+// fontFace 字体文件中一个字体的名称及样式
+type fontFace struct {
+	index int
+	names []string
+	style canvas.FontStyle
+}
+
+var errNotFont = errors.New("not a font file")
+
+// readFontFaces 读取字体文件或字体集合中各字体的名称 (name 表) 及样式 (OS/2 与 head 表)
+func readFontFaces(r io.ReaderAt) ([]fontFace, error) {
+	var head [12]byte
+	if _, err := r.ReadAt(head[:], 0); err != nil {
+		return nil, err
+	}
+	offsets := []uint32{0}
+	if string(head[:4]) == "ttcf" {
+		n := binary.BigEndian.Uint32(head[8:])
+		if n == 0 || n > 256 {
+			return nil, errNotFont
+		}
+		buf := make([]byte, 4*n)
+		if _, err := r.ReadAt(buf, 12); err != nil {
+			return nil, err
+		}
+		offsets = offsets[:0]
+		for i := uint32(0); i < n; i++ {
+			offsets = append(offsets, binary.BigEndian.Uint32(buf[4*i:]))
+		}
+	}
+	var faces []fontFace
+	for i, offset := range offsets {
+		face, err := readFontFace(r, int64(offset))
+		if err != nil {
+			return nil, err
+		}
+		face.index = i
+		faces = append(faces, face)
+	}
+	return faces, nil
+}
+
+func readFontFace(r io.ReaderAt, offset int64) (fontFace, error) {
+	var face fontFace
+	var header [12]byte
+	if _, err := r.ReadAt(header[:], offset); err != nil {
+		return face, err
+	}
+	switch string(header[:4]) {
+	case "\x00\x01\x00\x00", "OTTO", "true":
+	default:
+		return face, errNotFont
+	}
+	n := int(binary.BigEndian.Uint16(header[4:]))
+	records := make([]byte, 16*n)
+	if _, err := r.ReadAt(records, offset+12); err != nil {
+		return face, err
+	}
+	tables := make(map[string][]byte)
+	for i := 0; i < n; i++ {
+		rec := records[16*i:]
+		tag := string(rec[:4])
+		if tag != "name" && tag != "OS/2" && tag != "head" {
+			continue
+		}
+		length := binary.BigEndian.Uint32(rec[12:])
+		if length > 1<<20 {
+			return face, fmt.Errorf("%s table too large", tag)
+		}
+		data := make([]byte, length)
+		if _, err := r.ReadAt(data, int64(binary.BigEndian.Uint32(rec[8:]))); err != nil {
+			return face, err
+		}
+		tables[tag] = data
+	}
+
+	weight, italic := 400, false
+	if head := tables["head"]; len(head) >= 46 {
+		macStyle := binary.BigEndian.Uint16(head[44:])
+		if macStyle&1 != 0 {
+			weight = 700
+		}
+		italic = macStyle&2 != 0
+	}
+	if os2 := tables["OS/2"]; len(os2) >= 64 {
+		if w := int(binary.BigEndian.Uint16(os2[4:])); w >= 1 && w <= 1000 {
+			weight = w
+		}
+		italic = binary.BigEndian.Uint16(os2[62:])&1 != 0
+	}
+	face.style = fontWeight(weight)
+	if italic {
+		face.style |= canvas.FontItalic
+	}
+	face.names = fontNames(tables["name"])
+	return face, nil
+}
+
+// fontNames 返回 name 表中的字体族名 (1、16)、全名 (4) 及 PostScript 名 (6)，包括各语言的本地化名称
+func fontNames(name []byte) []string {
+	if len(name) < 6 {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint16(name[2:]))
+	storage := int(binary.BigEndian.Uint16(name[4:]))
+	var names []string
+	seen := make(map[string]bool)
+	for i := 0; i < count && 6+12*i+12 <= len(name); i++ {
+		rec := name[6+12*i:]
+		platform := binary.BigEndian.Uint16(rec)
+		encoding := binary.BigEndian.Uint16(rec[2:])
+		switch binary.BigEndian.Uint16(rec[6:]) {
+		case 1, 4, 6, 16:
+		default:
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(rec[8:]))
+		start := storage + int(binary.BigEndian.Uint16(rec[10:]))
+		if start+length > len(name) {
+			continue
+		}
+		raw := name[start : start+length]
+		var s string
+		switch {
+		case platform == 0 || platform == 3 && (encoding == 0 || encoding == 1 || encoding == 10):
+			u := make([]uint16, len(raw)/2)
+			for j := range u {
+				u[j] = binary.BigEndian.Uint16(raw[2*j:])
+			}
+			s = string(utf16.Decode(u))
+		case platform == 1 && encoding == 0:
+			s = string(raw)
+		default:
+			continue
+		}
+		if s = strings.TrimSpace(s); s != "" && !seen[s] {
+			seen[s] = true
+			names = append(names, s)
+		}
+	}
+	return names
+}
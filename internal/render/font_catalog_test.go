@@ -0,0 +1,86 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tdewolff/canvas"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"github.com/zc310/ofd/internal/models"
+)
+
+func TestReadFontFaces(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bold.ttf"), gobold.TTF, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 非字体文件被忽略
+	if err := os.WriteFile(filepath.Join(dir, "x.ttf"), []byte("not a font"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx := scanFontDir(dir)
+	// Go Bold 的 OS/2 字重为 600
+	srcs := idx[normalizeFontName("Go Bold")]
+	if len(srcs) != 1 || srcs[0].style != canvas.FontSemiBold {
+		t.Fatalf("Go Bold = %+v", srcs)
+	}
+	if srcs = idx["go"]; len(srcs) != 1 || srcs[0].path != filepath.Join(dir, "bold.ttf") {
+		t.Errorf("Go = %+v", srcs)
+	}
+}
+
+func TestFontsCandidates(t *testing.T) {
+	if err := RegisterFont(goregular.TTF, "OFD Test Sans"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterFont(gobold.TTF, "OFD Test Sans"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterFont(gomono.TTF, "ofd-test-mono"); err != nil {
+		t.Fatal(err)
+	}
+	srcs := catalog.lookup("ofd test sans", nil)
+	if len(srcs) != 2 || srcs[0].style != canvas.FontRegular || srcs[1].style != canvas.FontSemiBold {
+		t.Fatalf("OFD Test Sans: %d fonts", len(srcs))
+	}
+
+	fonts := &Fonts{Fonts: make(map[models.StRefID]*canvas.FontFamily)}
+	fonts.configure(&Options{FontMap: map[string][]string{
+		"楷体":        {"missing font", "OFD Test Sans"},
+		"monospace": {"OFD_Test_Mono"},
+	}})
+	// 替换表优先，其次为别名
+	names := fonts.candidates(&models.Font{FontName: "楷体"})
+	if names[0] != "missing font" || names[2] != "楷体" || names[3] != "KaiTi" {
+		t.Errorf("candidates = %v", names)
+	}
+	if f, name := fonts.find(names); f == nil || name != "OFD Test Sans" {
+		t.Errorf("mapped font = %s", name)
+	}
+	// FixedWidth 字体使用 monospace 替换
+	names = fonts.candidates(&models.Font{FontName: "unknown", FixedWidth: true, Charset: "BIG5"})
+	if !strings.Contains(strings.Join(names, ","), "big5,PMingLiU") {
+		t.Errorf("candidates = %v", names)
+	}
+	if f, name := fonts.find(names); f == nil || name != "OFD_Test_Mono" {
+		t.Errorf("monospace font = %s", name)
+	}
+}
+
+func TestParseFontMap(t *testing.T) {
+	m, err := ParseFontMap(strings.NewReader("# 注释\n宋体 = SimSun, /fonts/simsun.ttc#1\n\nmonospace=Courier\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m["宋体"]) != 2 || m["宋体"][1] != "/fonts/simsun.ttc#1" || m["monospace"][0] != "Courier" {
+		t.Errorf("font map = %v", m)
+	}
+	if _, err = ParseFontMap(strings.NewReader("SimSun")); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,100 @@
+package render
+
+import (
+	"container/list"
+	"image"
+	"sync"
+
+	"github.com/zc310/ofd/internal/models"
+)
+
+// DefaultImageCacheSize 默认的位图缓存内存预算 (字节)
+const DefaultImageCacheSize = 64 << 20
+
+// imageKey 位图缓存的键：位图资源 ID 及应用的图像蒙版 ID (0 表示无蒙版)
+type imageKey struct {
+	id, mask models.StRefID
+}
+
+// imageCache 缓存解码后的位图，超出内存预算时淘汰最久未使用的位图；
+// 无法解码的位图记为失败，不占用预算也不淘汰
+type imageCache struct {
+	mu     sync.Mutex
+	size   int64
+	items  map[imageKey]*list.Element
+	lru    list.List
+	failed map[imageKey]struct{}
+}
+
+type imageEntry struct {
+	key  imageKey
+	img  image.Image
+	size int64
+}
+
+// get 返回已缓存的位图并标记为最近使用，已记为失败时返回 nil, true
+func (c *imageCache) get(key imageKey) (image.Image, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.failed[key]; ok {
+		return nil, true
+	}
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*imageEntry).img, true
+}
+
+// put 缓存位图，img 为 nil 时记为失败；单个位图超过预算时不缓存
+func (c *imageCache) put(key imageKey, img image.Image, budget int64) {
+	if img == nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.failed == nil {
+			c.failed = make(map[imageKey]struct{})
+		}
+		c.failed[key] = struct{}{}
+		return
+	}
+	size := imageSize(img)
+	if budget <= 0 || size > budget {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.items == nil {
+		c.items = make(map[imageKey]*list.Element)
+	}
+	if e, ok := c.items[key]; ok {
+		c.size -= e.Value.(*imageEntry).size
+		c.lru.Remove(e)
+	}
+	c.items[key] = c.lru.PushFront(&imageEntry{key: key, img: img, size: size})
+	c.size += size
+	for c.size > budget {
+		e := c.lru.Back()
+		entry := e.Value.(*imageEntry)
+		c.lru.Remove(e)
+		delete(c.items, entry.key)
+		c.size -= entry.size
+	}
+}
+
+// imageSize 估算位图像素数据占用的内存
+func imageSize(img image.Image) int64 {
+	b := img.Bounds()
+	pixels := int64(b.Dx()) * int64(b.Dy())
+	switch img.(type) {
+	case *image.Gray, *image.Alpha, *image.Paletted:
+		return pixels
+	case *image.Gray16, *image.Alpha16:
+		return pixels * 2
+	case *image.YCbCr:
+		return pixels * 3 / 2
+	case *image.RGBA64, *image.NRGBA64:
+		return pixels * 8
+	}
+	return pixels * 4
+}
@@ -0,0 +1,58 @@
+package render
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zc310/ofd/internal/models"
+)
+
+func TestImageCache(t *testing.T) {
+	var c imageCache
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10)) // 400 字节
+
+	c.put(imageKey{id: 1}, img, 1000)
+	c.put(imageKey{id: 2}, img, 1000)
+	_, ok := c.get(imageKey{id: 1})
+	assert.True(t, ok)
+	// 超出预算时淘汰最久未使用的 2
+	c.put(imageKey{id: 3}, img, 1000)
+	_, ok = c.get(imageKey{id: 2})
+	assert.False(t, ok)
+	for _, id := range []int{1, 3} {
+		_, ok := c.get(imageKey{id: models.StRefID(id)})
+		assert.True(t, ok, "image %d", id)
+	}
+	assert.Equal(t, int64(800), c.size)
+
+	// 超过预算的位图及禁用缓存时不缓存
+	c.put(imageKey{id: 4}, image.NewRGBA(image.Rect(0, 0, 20, 20)), 1000)
+	c.put(imageKey{id: 5}, img, -1)
+	for _, id := range []int{4, 5} {
+		_, ok := c.get(imageKey{id: models.StRefID(id)})
+		assert.False(t, ok, "image %d", id)
+	}
+
+	// 带蒙版的位图与原位图分别缓存
+	_, ok = c.get(imageKey{id: 1, mask: 6})
+	assert.False(t, ok)
+	c.put(imageKey{id: 1, mask: 6}, img, 1000)
+	_, ok = c.get(imageKey{id: 1, mask: 6})
+	assert.True(t, ok)
+}
+
+func TestImageCache_Failed(t *testing.T) {
+	var c imageCache
+	// 失败的位图在禁用缓存时也记录，且不占用预算
+	c.put(imageKey{id: 1}, nil, -1)
+	img, ok := c.get(imageKey{id: 1})
+	assert.True(t, ok)
+	assert.Nil(t, img)
+	assert.Equal(t, int64(0), c.size)
+
+	c.put(imageKey{id: 2}, image.NewRGBA(image.Rect(0, 0, 10, 10)), 400)
+	c.put(imageKey{id: 3}, image.NewRGBA(image.Rect(0, 0, 10, 10)), 400)
+	_, ok = c.get(imageKey{id: 1})
+	assert.True(t, ok)
+}
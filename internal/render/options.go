@@ -0,0 +1,52 @@
+package render
+
+import "github.com/zc310/ofd/internal/models"
+
+// Options 渲染选项，零值表示绘制全部内容
+type Options struct {
+	// HideTemplates 不绘制模板页
+	HideTemplates bool
+	// HideLayers 按图层类型隐藏，键为 Body, Background, Foreground, Custom
+	HideLayers map[string]bool
+	// HideSeals 不绘制签章
+	HideSeals bool
+	// HideAnnots 按注解类型隐藏，例如隐藏 Watermark 保留 Stamp
+	HideAnnots map[models.AnnotType]bool
+	// CompositeImage 复合对象使用 Substitution/Thumbnail 位图代替矢量内容绘制
+	CompositeImage bool
+	// Substitution 位图优先使用 Substitution 指定的替代资源 (如高分辨率打印版本)
+	Substitution bool
+	// ImageCacheSize 解码后位图缓存的内存预算 (字节)，0 使用 DefaultImageCacheSize，负数不缓存
+	ImageCacheSize int64
+	// Print 打印模式，不绘制 Print 属性为 false 的注解
+	Print bool
+	// FontDirs 查找字体的目录，优先于系统字体目录
+	FontDirs []string
+	// FontMap 字体替换表，键为 OFD 字体名 (FontName/FamilyName)、serif、sans-serif、monospace、字符集或 default，
+	// 值为依次尝试的字体名或字体文件路径
+	FontMap map[string][]string
+}
+
+// layerVisible 判断图层是否需要绘制
+func (p *Options) layerVisible(layer *models.Layer) bool {
+	return !p.HideLayers[layer.LayerType()]
+}
+
+// annotVisible 判断注解是否需要绘制
+func (p *Options) annotVisible(annot *models.Annot) bool {
+	if p.HideAnnots[annot.Type] || !annot.IsVisible() {
+		return false
+	}
+	if p.Print && !annot.IsPrint() {
+		return false
+	}
+	return true
+}
+
+// imageCacheSize 返回位图缓存的内存预算
+func (p *Options) imageCacheSize() int64 {
+	if p.ImageCacheSize == 0 {
+		return DefaultImageCacheSize
+	}
+	return p.ImageCacheSize
+}
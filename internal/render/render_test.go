@@ -0,0 +1,126 @@
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tdewolff/canvas"
+	"github.com/tdewolff/canvas/renderers/rasterizer"
+
+	"github.com/zc310/ofd/internal/parser"
+)
+
+const testOFDXML = `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:OFD xmlns:ofd="http://www.ofdspec.org/2016" Version="1.0" DocType="OFD"><ofd:DocBody><ofd:DocInfo><ofd:DocID>0123456789abcdef0123456789abcdef</ofd:DocID></ofd:DocInfo><ofd:DocRoot>Doc_0/Document.xml</ofd:DocRoot></ofd:DocBody></ofd:OFD>`
+
+// testOFD 构造 OFD 文档，files 的键为包内路径。未给出的 OFD.xml、Doc_0/Document.xml 及 Doc_0/PublicRes.xml
+// 使用默认内容：一个 50x50 毫米的页面，内容为 Doc_0/Pages/Page_0/Content.xml
+func testOFD(t testing.TB, files map[string]string) []byte {
+	defaults := map[string]string{
+		"OFD.xml":             testOFDXML,
+		"Doc_0/Document.xml":  testDocument("", ""),
+		"Doc_0/PublicRes.xml": testRes(""),
+	}
+	for name, data := range defaults {
+		if _, ok := files[name]; !ok {
+			files[name] = data
+		}
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		fw, err := w.Create(name)
+		assert.Nil(t, err)
+		_, err = fw.Write([]byte(data))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+// testDocument 返回文档根节点，common 插入 CommonData 中 (如 TemplatePage)，tail 插入 Pages 之后 (如 Annotations)
+func testDocument(common, tail string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:Document xmlns:ofd="http://www.ofdspec.org/2016"><ofd:CommonData><ofd:PageArea><ofd:PhysicalBox>0 0 50 50</ofd:PhysicalBox></ofd:PageArea><ofd:PublicRes>PublicRes.xml</ofd:PublicRes><ofd:MaxUnitID>1000</ofd:MaxUnitID>` +
+		common + `</ofd:CommonData><ofd:Pages><ofd:Page ID="1" BaseLoc="Pages/Page_0/Content.xml"/></ofd:Pages>` + tail + `</ofd:Document>`
+}
+
+// testRes 返回公共资源文件，res 为资源元素 (如 ColorSpaces、DrawParams)
+func testRes(res string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:Res xmlns:ofd="http://www.ofdspec.org/2016" BaseLoc="Res">` + res + `</ofd:Res>`
+}
+
+// testPage 返回只有一个正文图层的页面内容，head 插入 Content 之前 (如 Template)
+func testPage(head, objects string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:Page xmlns:ofd="http://www.ofdspec.org/2016">` + head + `<ofd:Content><ofd:Layer ID="2">` + objects + `</ofd:Layer></ofd:Content></ofd:Page>`
+}
+
+// openDocument 解析 OFD 文档并返回第一个文档的渲染器
+func openDocument(t testing.TB, data []byte, opts ...Options) *Document {
+	ofd, err := parser.NewOFD(data)
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = ofd.Close() })
+	assert.NotEmpty(t, ofd.Documents)
+	doc := NewDocument(color.White, ofd.Documents[0])
+	if len(opts) > 0 {
+		doc.SetOptions(opts[0])
+	}
+	return doc
+}
+
+// rasterize 以每毫米 dpmm 像素绘制第 n 页 (从 1 开始)
+func rasterize(t testing.TB, doc *Document, n int, dpmm float64) image.Image {
+	c, err := doc.Page(doc.Pages[n-1])
+	assert.Nil(t, err)
+	return rasterizer.Draw(c, canvas.DPMM(dpmm), canvas.DefaultColorSpace)
+}
+
+// at 返回 (x, y) 毫米处的像素颜色，y 轴向下
+func at(img image.Image, dpmm, x, y float64) color.RGBA {
+	return color.RGBAModel.Convert(img.At(int(x*dpmm), int(y*dpmm))).(color.RGBA)
+}
+
+// near 判断两个颜色各通道之差是否都不超过 d
+func near(a, b color.RGBA, d int) bool {
+	for _, v := range [][2]uint8{{a.R, b.R}, {a.G, b.G}, {a.B, b.B}, {a.A, b.A}} {
+		if diff := int(v[0]) - int(v[1]); diff > d || diff < -d {
+			return false
+		}
+	}
+	return true
+}
+
+// solidPNG 返回 w x h 的单色 PNG 图像
+func solidPNG(t *testing.T, c color.RGBA, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	assert.Nil(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// countNear 统计 (x, y) 毫米附近 r 毫米内满足条件的像素
+func countNear(img image.Image, dpmm, x, y, r float64, f func(c color.RGBA) bool) int {
+	n := 0
+	for py := int((y - r) * dpmm); py <= int((y+r)*dpmm); py++ {
+		for px := int((x - r) * dpmm); px <= int((x+r)*dpmm); px++ {
+			if f(color.RGBAModel.Convert(img.At(px, py)).(color.RGBA)) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// dark 是否为深色像素
+func dark(c color.RGBA) bool {
+	return c.A > 0 && c.R < 100 && c.G < 100 && c.B < 100
+}
@@ -0,0 +1,144 @@
+// Package sm3 实现 GB/T 32905-2016 SM3 密码杂凑算法，用于校验签名引用文件的杂凑值
+package sm3
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// Size SM3 杂凑值的字节数
+const Size = 32
+
+// BlockSize SM3 的分组字节数
+const BlockSize = 64
+
+var iv = [8]uint32{0x7380166f, 0x4914b2b9, 0x172442d7, 0xda8a0600, 0xa96f30bc, 0x163138aa, 0xe38dee4d, 0xb0fb0e4e}
+
+type digest struct {
+	h   [8]uint32
+	x   [BlockSize]byte
+	nx  int
+	len uint64
+}
+
+// New 返回计算 SM3 杂凑值的 hash.Hash
+func New() hash.Hash {
+	d := new(digest)
+	d.Reset()
+	return d
+}
+
+// Sum 返回 data 的 SM3 杂凑值
+func Sum(data []byte) [Size]byte {
+	d := new(digest)
+	d.Reset()
+	_, _ = d.Write(data)
+	var sum [Size]byte
+	d.checkSum(sum[:0])
+	return sum
+}
+
+func (d *digest) Reset() {
+	d.h = iv
+	d.nx = 0
+	d.len = 0
+}
+
+func (d *digest) Size() int { return Size }
+
+func (d *digest) BlockSize() int { return BlockSize }
+
+func (d *digest) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+	if d.nx > 0 {
+		c := copy(d.x[d.nx:], p)
+		d.nx += c
+		p = p[c:]
+		if d.nx < BlockSize {
+			return n, nil
+		}
+		d.block(d.x[:])
+		d.nx = 0
+	}
+	for len(p) >= BlockSize {
+		d.block(p[:BlockSize])
+		p = p[BlockSize:]
+	}
+	d.nx = copy(d.x[:], p)
+	return n, nil
+}
+
+func (d *digest) Sum(in []byte) []byte {
+	// 在副本上填充，调用方可以继续写入
+	d0 := *d
+	return d0.checkSum(in)
+}
+
+// checkSum 填充并返回 in 追加杂凑值后的结果
+func (d *digest) checkSum(in []byte) []byte {
+	length := d.len
+	var tmp [BlockSize + 8]byte
+	tmp[0] = 0x80
+	pad := 56 - int(length%BlockSize)
+	if pad <= 0 {
+		pad += BlockSize
+	}
+	binary.BigEndian.PutUint64(tmp[pad:], length<<3)
+	_, _ = d.Write(tmp[:pad+8])
+	for _, v := range d.h {
+		in = binary.BigEndian.AppendUint32(in, v)
+	}
+	return in
+}
+
+func p0(x uint32) uint32 { return x ^ bits.RotateLeft32(x, 9) ^ bits.RotateLeft32(x, 17) }
+
+func p1(x uint32) uint32 { return x ^ bits.RotateLeft32(x, 15) ^ bits.RotateLeft32(x, 23) }
+
+// block 压缩一个分组
+func (d *digest) block(b []byte) {
+	var w [68]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = binary.BigEndian.Uint32(b[4*i:])
+	}
+	for j := 16; j < 68; j++ {
+		w[j] = p1(w[j-16]^w[j-9]^bits.RotateLeft32(w[j-3], 15)) ^ bits.RotateLeft32(w[j-13], 7) ^ w[j-6]
+	}
+
+	a, b1, c, dd, e, f, g, h := d.h[0], d.h[1], d.h[2], d.h[3], d.h[4], d.h[5], d.h[6], d.h[7]
+	for j := 0; j < 64; j++ {
+		var t, ff, gg uint32
+		if j < 16 {
+			t = 0x79cc4519
+			ff = a ^ b1 ^ c
+			gg = e ^ f ^ g
+		} else {
+			t = 0x7a879d8a
+			ff = (a & b1) | (a & c) | (b1 & c)
+			gg = (e & f) | (^e & g)
+		}
+		a12 := bits.RotateLeft32(a, 12)
+		ss1 := bits.RotateLeft32(a12+e+bits.RotateLeft32(t, j%32), 7)
+		ss2 := ss1 ^ a12
+		tt1 := ff + dd + ss2 + (w[j] ^ w[j+4])
+		tt2 := gg + h + ss1 + w[j]
+		dd = c
+		c = bits.RotateLeft32(b1, 9)
+		b1 = a
+		a = tt1
+		h = g
+		g = bits.RotateLeft32(f, 19)
+		f = e
+		e = p0(tt2)
+	}
+	d.h[0] ^= a
+	d.h[1] ^= b1
+	d.h[2] ^= c
+	d.h[3] ^= dd
+	d.h[4] ^= e
+	d.h[5] ^= f
+	d.h[6] ^= g
+	d.h[7] ^= h
+}
@@ -0,0 +1,32 @@
+package sm3
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	// GB/T 32905-2016 附录 A 的示例
+	tests := []struct {
+		in, want string
+	}{
+		{"abc", "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"},
+		{strings.Repeat("abcd", 16), "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732"},
+	}
+	for _, tt := range tests {
+		sum := Sum([]byte(tt.in))
+		if got := hex.EncodeToString(sum[:]); got != tt.want {
+			t.Errorf("Sum(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+
+		// 分段写入
+		h := New()
+		for i := 0; i < len(tt.in); i += 7 {
+			_, _ = h.Write([]byte(tt.in[i:min(i+7, len(tt.in))]))
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != tt.want {
+			t.Errorf("New().Sum(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+// PartError 包内部件 (文件) 缺失、无法读取或格式错误，Path 为部件在包内的路径
+type PartError struct {
+	Path string
+	Err  error
+}
+
+func (e *PartError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *PartError) Unwrap() error {
+	return e.Err
+}
+
+// XMLError 部件的 XML 格式错误，Line 为出错的行号 (未知时为 0)。
+// 由 ParseXMLContent 返回时包装在 PartError 中
+type XMLError struct {
+	Path string
+	Line int
+	Err  error
+}
+
+func (e *XMLError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("解析XML失败 (第%d行): %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("解析XML失败: %v", e.Err)
+}
+
+func (e *XMLError) Unwrap() error {
+	return e.Err
+}
+
+// newXMLError 返回 XML 解码错误，行号优先取语法错误中的行号
+func newXMLError(path string, decoder *xml.Decoder, err error) error {
+	xe := &XMLError{Path: path, Err: err}
+	var se *xml.SyntaxError
+	if errors.As(err, &se) {
+		xe.Line = se.Line
+	} else {
+		xe.Line, _ = decoder.InputPos()
+	}
+	return &PartError{Path: path, Err: xe}
+}
@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"path"
+	"strings"
+)
+
+// Resolve 返回 loc 在包内的路径 (不含开头的 /)，相对路径基于目录 dir
+func Resolve(dir, loc string) string {
+	loc = strings.TrimSpace(loc)
+	if !strings.HasPrefix(loc, "/") {
+		loc = path.Join(dir, loc)
+	}
+	return strings.TrimPrefix(path.Join("/", loc), "/")
+}
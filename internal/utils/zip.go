@@ -59,59 +59,45 @@ func (p *ZipFileCache) FindFile(fileName string) (*zip.File, error) {
 		return file, nil
 	}
 
-	return nil, fmt.Errorf("%w: %s", os.ErrNotExist, fileName)
+	return nil, &PartError{Path: fileName, Err: fmt.Errorf("部件不存在: %w", os.ErrNotExist)}
 }
 
 // ParseXMLContent 解析XML文件内容
 func (p *ZipFileCache) ParseXMLContent(fileName string, target interface{}) error {
 	zf, err := p.FindFile(fileName)
 	if err != nil {
-		return fmt.Errorf("查找文档失败: %w", err)
+		return err
 	}
 	rc, err := zf.Open()
 	if err != nil {
-		return fmt.Errorf("打开文档失败: %w", err)
+		return &PartError{Path: fileName, Err: fmt.Errorf("打开文档失败: %w", err)}
 	}
 	defer rc.Close()
 
 	decoder := xml.NewDecoder(io.LimitReader(rc, int64(zf.UncompressedSize64)+1024))
 	if err = decoder.Decode(target); err != nil {
-		return fmt.Errorf("解析XML失败: %w", err)
+		return newXMLError(fileName, decoder, err)
 	}
 
 	return nil
 }
 
-func (p *ZipFileCache) ParseImage(fileName string) (image.Image, error) {
+func (p *ZipFileCache) ParseContent(fileName string) ([]byte, error) {
 	zf, err := p.FindFile(fileName)
 	if err != nil {
-		return nil, fmt.Errorf("查找图像失败: %w", err)
+		return nil, err
 	}
 	rc, err := zf.Open()
 	if err != nil {
-		return nil, fmt.Errorf("打开文档失败: %w", err)
+		return nil, &PartError{Path: fileName, Err: fmt.Errorf("打开文档失败: %w", err)}
 	}
 	defer rc.Close()
 
-	var img image.Image
-	img, _, err = image.Decode(rc)
+	data, err := io.ReadAll(rc)
 	if err != nil {
-		return nil, err
+		return nil, &PartError{Path: fileName, Err: fmt.Errorf("读取文档失败: %w", err)}
 	}
-	return img, nil
-}
-func (p *ZipFileCache) ParseContent(fileName string) ([]byte, error) {
-	zf, err := p.FindFile(fileName)
-	if err != nil {
-		return nil, fmt.Errorf("查找文件失败: %w", err)
-	}
-	rc, err := zf.Open()
-	if err != nil {
-		return nil, fmt.Errorf("打开文档失败: %w", err)
-	}
-	defer rc.Close()
-
-	return io.ReadAll(rc)
+	return data, nil
 }
 
 func ExtractFirstImage(file string) (image.Image, error) {
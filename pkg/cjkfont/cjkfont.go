@@ -0,0 +1,29 @@
+// Package cjkfont 内置 GNU Unifont 的 GB2312 子集 (SIL Open Font License 1.1，见 OFL.txt)，
+// 导入后注册为默认字体，在没有中文系统字体的环境 (如精简的容器镜像) 中也能确定地绘制文字：
+//
+//	import _ "github.com/zc310/ofd/pkg/cjkfont"
+//
+// 找不到文档字体及中文通用字体时使用该字体，也可以在字体替换表中按 Name 指定
+package cjkfont
+
+//go:generate go run gen.go -src unifont-13.0.05.ttf
+
+import (
+	_ "embed"
+
+	"github.com/zc310/ofd/internal/render"
+)
+
+// Name 注册的字体名
+const Name = "Unifont GB2312"
+
+// TTF 字体数据，包含 GB2312 字符集、ASCII、Latin-1 及常用标点
+//
+//go:embed unifont_gb2312.ttf
+var TTF []byte
+
+func init() {
+	if err := render.RegisterFont(TTF, Name, "default"); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,79 @@
+package cjkfont_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/tdewolff/font"
+
+	"github.com/zc310/ofd/pkg/cjkfont"
+	"github.com/zc310/ofd/pkg/converter"
+)
+
+func TestTTF(t *testing.T) {
+	sfnt, err := font.ParseFont(cjkfont.TTF, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range "重庆增值税电子普通发票：ABC123，" {
+		if sfnt.GlyphIndex(r) == 0 {
+			t.Errorf("missing glyph %q", r)
+		}
+	}
+}
+
+// render 以每毫米 dpmm 像素渲染第 1 页
+func render(t *testing.T, dpmm float64, opts ...converter.Option) image.Image {
+	var page image.Image
+	err := converter.Image("../../test/testdata/999.ofd", append([]converter.Option{
+		converter.ImageWriter(func(i int, img image.Image) error {
+			page = img
+			return nil
+		}),
+		converter.Page(1),
+		converter.BgColor(color.White),
+		converter.DPI(dpmm * 25.4),
+	}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func TestDefaultFont(t *testing.T) {
+	const dpmm = 5.0
+	var report converter.Report
+	page := render(t, dpmm, converter.Diagnostics(&report))
+	// 标题 "重庆增值税电子普通发票" 使用楷体
+	var title *converter.FontUsage
+	for i, f := range report.Pages[0].Fonts {
+		if f.ID == 2 {
+			title = &report.Pages[0].Fonts[i]
+		}
+	}
+	if title == nil || title.FontName != "楷体" {
+		t.Fatalf("fonts = %+v", report.Pages[0].Fonts)
+	}
+	if title.Substitute != "default" {
+		t.Skipf("使用系统字体 %s", title.Substitute)
+	}
+
+	// 没有楷体等中文字体时使用内置字体，与在替换表中指定内置字体的结果相同
+	want := render(t, dpmm, converter.FontMap(map[string][]string{"楷体": {cjkfont.Name}, "宋体": {cjkfont.Name}, "Courier New": {cjkfont.Name}}))
+	b := page.Bounds()
+	n := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if page.At(x, y) != want.At(x, y) {
+				t.Fatalf("(%d, %d) differs from %s", x, y, cjkfont.Name)
+			}
+			if r, g, b, _ := page.At(x, y).RGBA(); x >= int(90*dpmm) && x < int(120*dpmm) && y >= int(8*dpmm) && y < int(13*dpmm) && (r>>8 < 200 || g>>8 < 200 || b>>8 < 200) {
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		t.Error("title not drawn")
+	}
+}
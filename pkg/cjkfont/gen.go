@@ -0,0 +1,81 @@
+//go:build ignore
+
+// gen 从 GNU Unifont 生成 GB2312 子集字体：
+//
+//	go run gen.go -src unifont-13.0.05.ttf
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/tdewolff/font"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func main() {
+	src := flag.String("src", "unifont-13.0.05.ttf", "Unifont 字体文件")
+	dst := flag.String("o", "unifont_gb2312.ttf", "输出文件")
+	flag.Parse()
+
+	b, err := os.ReadFile(*src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sfnt, err := font.ParseFont(b, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var runes []rune
+	for _, r := range [][2]rune{
+		{0x20, 0x7E},     // ASCII
+		{0xA0, 0xFF},     // Latin-1
+		{0x2000, 0x206F}, // 常用标点
+		{0x2100, 0x215F}, // 字母式符号、数字形式
+		{0x2460, 0x24FF}, // 带圈数字
+		{0x3000, 0x303F}, // 中日韩符号和标点
+		{0xFF00, 0xFFEF}, // 全角字符
+	} {
+		for c := r[0]; c <= r[1]; c++ {
+			runes = append(runes, c)
+		}
+	}
+	// GB2312 全部字符
+	dec := simplifiedchinese.GBK.NewDecoder()
+	for hi := 0xA1; hi <= 0xF7; hi++ {
+		for lo := 0xA1; lo <= 0xFE; lo++ {
+			s, err := dec.Bytes([]byte{byte(hi), byte(lo)})
+			if err != nil {
+				continue
+			}
+			for _, c := range string(s) {
+				if c != 0xFFFD {
+					runes = append(runes, c)
+				}
+			}
+		}
+	}
+
+	seen := map[uint16]bool{0: true}
+	glyphs := []uint16{0}
+	for _, c := range runes {
+		if id := sfnt.GlyphIndex(c); id != 0 && !seen[id] {
+			seen[id] = true
+			glyphs = append(glyphs, id)
+		}
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	subset, err := sfnt.Subset(glyphs, font.SubsetOptions{Tables: font.KeepAllTables})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 保留原字体的版权及许可证信息
+	subset.Tables["name"] = sfnt.Tables["name"]
+	if err = os.WriteFile(*dst, subset.Write(), 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d glyphs", len(glyphs))
+}
@@ -0,0 +1,56 @@
+package converter
+
+import (
+	"time"
+)
+
+// Attachment 文档附件，Data 为附件内容
+type Attachment struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Format       string     `json:"format,omitempty"`
+	Usage        string     `json:"usage,omitempty"`
+	CreationDate *time.Time `json:"creationDate,omitempty"`
+	ModDate      *time.Time `json:"modDate,omitempty"`
+	// Path 附件在包内的路径
+	Path string `json:"path"`
+	Size int    `json:"size"`
+	Data []byte `json:"-"`
+}
+
+// Attachments 读取文档的附件 (如电子发票的原始 XML 数据)，支持 Strict 选项
+func Attachments(input interface{}, opts ...Option) ([]Attachment, error) {
+	conv := newConverter(opts...)
+	ofd, doc, err := conv.open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer closeOFD(ofd)
+
+	list, err := doc.ParseAttachments()
+	if err != nil {
+		return nil, err
+	}
+	attachments := make([]Attachment, 0, len(list))
+	for _, a := range list {
+		data, err := doc.FileCache.ParseContent(a.FileLoc.String())
+		if err != nil {
+			return nil, err
+		}
+		attachment := Attachment{
+			ID:           a.ID,
+			Name:         a.Name,
+			Usage:        a.Usage,
+			CreationDate: date(a.CreationDate),
+			ModDate:      date(a.ModDate),
+			Path:         a.FileLoc.String(),
+			Size:         len(data),
+			Data:         data,
+		}
+		if a.Format != nil {
+			attachment.Format = *a.Format
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
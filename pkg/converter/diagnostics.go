@@ -0,0 +1,78 @@
+package converter
+
+import (
+	"github.com/zc310/ofd/internal/parser"
+	"github.com/zc310/ofd/internal/render"
+)
+
+type (
+	// PageReport 单个页面的渲染诊断报告
+	PageReport = render.PageReport
+	// FontUsage 页面请求的字体及实际使用的字体
+	FontUsage = render.FontUsage
+	// Issue 页面渲染中遇到的问题
+	Issue = render.Issue
+	// IssueKind 诊断问题的类型
+	IssueKind = render.IssueKind
+)
+
+const (
+	IssueResource = render.IssueResource
+	IssueFont     = render.IssueFont
+	IssueImage    = render.IssueImage
+	IssueFeature  = render.IssueFeature
+	IssueSeal     = render.IssueSeal
+)
+
+// Report 文档转换的诊断报告，按转换的页面顺序排列
+type Report struct {
+	// Warnings 宽松模式下解析文档时跳过的部件
+	Warnings []string     `json:"warnings,omitempty"`
+	Pages    []PageReport `json:"pages"`
+}
+
+// HasIssues 是否有跳过的部件、页面存在问题或使用了替换字体
+func (r *Report) HasIssues() bool {
+	if len(r.Warnings) > 0 {
+		return true
+	}
+	for _, page := range r.Pages {
+		if len(page.Issues) > 0 {
+			return true
+		}
+		for _, f := range page.Fonts {
+			if f.Substituted || f.Substitute == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Diagnostics 转换时收集各页面的诊断报告 (字体替换、资源缺失、图像无法解码、
+// 签章未绘制及用到的底纹、渐变、复合对象等)，写入 r
+func Diagnostics(r *Report) Option {
+	return func(c *Converter) {
+		c.report = r
+	}
+}
+
+// warnings 记录解析文档时跳过的部件
+func (c *Converter) warnings(doc *parser.Document) {
+	if c.report == nil {
+		return
+	}
+	for _, err := range doc.Warnings {
+		c.report.Warnings = append(c.report.Warnings, err.Error())
+	}
+}
+
+// collect 记录刚绘制完成的页面的诊断报告，page 从 1 开始
+func (c *Converter) collect(doc *render.Document, page int) {
+	if c.report == nil || doc.Report() == nil {
+		return
+	}
+	report := *doc.Report()
+	report.Page = page
+	c.report.Pages = append(c.report.Pages, report)
+}
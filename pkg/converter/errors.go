@@ -0,0 +1,23 @@
+package converter
+
+import (
+	"github.com/zc310/ofd/internal/parser"
+)
+
+// 转换失败的原因，可以用 errors.Is 判断
+var (
+	// ErrNoDocument OFD 包中没有文档
+	ErrNoDocument = parser.ErrNoDocument
+	// ErrNoPages 文档没有页面
+	ErrNoPages = parser.ErrNoPages
+	// ErrPageOutOfRange Page 选项指定的页码超出文档的页数
+	ErrPageOutOfRange = parser.ErrPageOutOfRange
+)
+
+type (
+	// PartError 包内部件 (如 OFD.xml、页面、资源文件) 缺失、无法读取或格式错误，
+	// 可以用 errors.As 取得部件路径，缺失的部件可以用 errors.Is(err, fs.ErrNotExist) 判断
+	PartError = parser.PartError
+	// XMLError 部件的 XML 格式错误，可以用 errors.As 取得部件路径及行号
+	XMLError = parser.XMLError
+)
@@ -0,0 +1,39 @@
+package converter
+
+import (
+	"io"
+
+	"github.com/zc310/ofd/internal/render"
+)
+
+// FontDirs 设置查找字体的目录，优先于系统字体目录。每个目录只在首次使用时扫描一次
+func FontDirs(dirs ...string) Option {
+	return func(c *Converter) {
+		c.render.FontDirs = append(c.render.FontDirs, dirs...)
+	}
+}
+
+// FontMap 设置字体替换表，键为 OFD 字体名 (FontName/FamilyName)，值为依次尝试的字体名或字体文件路径。
+// 键也可以是 serif、sans-serif、monospace (按字体的 Serif/FixedWidth 属性选择)、字符集 (如 prc、big5)
+// 或 default (找不到文档字体及中文通用字体时使用)
+func FontMap(m map[string][]string) Option {
+	return func(c *Converter) {
+		if c.render.FontMap == nil {
+			c.render.FontMap = make(map[string][]string)
+		}
+		for name, targets := range m {
+			c.render.FontMap[name] = append(c.render.FontMap[name], targets...)
+		}
+	}
+}
+
+// ParseFontMap 读取字体替换表，每行为 "字体名 = 候选1, 候选2"，# 开头的行为注释
+func ParseFontMap(r io.Reader) (map[string][]string, error) {
+	return render.ParseFontMap(r)
+}
+
+// RegisterFont 注册内存中的字体 (TTF/OTF/TTC)，可以按字体内的名称或 names 使用，
+// 以 default 为名注册的字体在找不到文档字体及中文通用字体时使用 (见 pkg/cjkfont)
+func RegisterFont(data []byte, names ...string) error {
+	return render.RegisterFont(data, names...)
+}
@@ -7,11 +7,14 @@ import (
 	"image/color"
 	"io"
 	"log/slog"
+	"strconv"
+	"strings"
 
 	"github.com/nao1215/imaging"
 	"github.com/tdewolff/canvas"
 	"github.com/tdewolff/canvas/renderers"
 	"github.com/tdewolff/canvas/renderers/rasterizer"
+	"github.com/zc310/ofd/internal/models"
 	"github.com/zc310/ofd/internal/parser"
 	"github.com/zc310/ofd/internal/render"
 )
@@ -22,9 +25,13 @@ type Converter struct {
 	format      string // png, jpeg
 	bgColor     color.Color
 	page        int
+	pages       []int
 	thumbnail   int
 	imageWriter func(page int, img image.Image) error
 	fileWriter  func(page int) (io.WriteCloser, error)
+	render      render.Options
+	report      *Report
+	mode        parser.ParseMode
 }
 
 // Option 配置选项类型
@@ -111,6 +118,87 @@ func Page(page int) Option {
 	}
 }
 
+// Pages 按顺序转换指定的页面，页码从 1 开始，同时设置 Page 时以 Page 为准
+func Pages(pages ...int) Option {
+	return func(c *Converter) {
+		c.pages = append([]int(nil), pages...)
+	}
+}
+
+// HideTemplates 不绘制模板页
+func HideTemplates() Option {
+	return func(c *Converter) {
+		c.render.HideTemplates = true
+	}
+}
+
+// HideLayers 按图层类型隐藏图层 (Body, Background, Foreground, Custom)
+func HideLayers(types ...string) Option {
+	return func(c *Converter) {
+		if c.render.HideLayers == nil {
+			c.render.HideLayers = make(map[string]bool)
+		}
+		for _, t := range types {
+			c.render.HideLayers[t] = true
+		}
+	}
+}
+
+// HideSeals 不绘制签章
+func HideSeals() Option {
+	return func(c *Converter) {
+		c.render.HideSeals = true
+	}
+}
+
+// HideAnnots 按注解类型隐藏注解 (Link, Path, Highlight, Stamp, Watermark)
+func HideAnnots(types ...string) Option {
+	return func(c *Converter) {
+		if c.render.HideAnnots == nil {
+			c.render.HideAnnots = make(map[models.AnnotType]bool)
+		}
+		for _, t := range types {
+			c.render.HideAnnots[models.AnnotType(t)] = true
+		}
+	}
+}
+
+// ForPrint 打印模式，不绘制禁止打印的注解
+func ForPrint() Option {
+	return func(c *Converter) {
+		c.render.Print = true
+	}
+}
+
+// CompositeImage 复合对象使用替代位图 (Substitution/Thumbnail) 绘制
+func CompositeImage() Option {
+	return func(c *Converter) {
+		c.render.CompositeImage = true
+	}
+}
+
+// Substitution 位图使用替代资源 (Substitution) 绘制，例如高分辨率打印版本
+func Substitution() Option {
+	return func(c *Converter) {
+		c.render.Substitution = true
+	}
+}
+
+// ImageCache 设置解码后位图缓存的内存预算 (字节)，负数表示不缓存
+func ImageCache(size int64) Option {
+	return func(c *Converter) {
+		c.render.ImageCacheSize = size
+	}
+}
+
+// Strict 以严格模式解析文档，任何格式错误或缺失的引用部件 (页面、资源、注释、签章等) 都返回错误。
+// 默认为宽松模式，跳过无法读取的部件，跳过的部件记入诊断报告的 Warnings
+func Strict() Option {
+	return func(c *Converter) {
+		c.mode = parser.Strict
+	}
+}
+
 // renderPage 渲染单个页面
 func (c *Converter) renderPage(pageIndex int, page *canvas.Canvas) error {
 	// 文件写入器处理
@@ -182,7 +270,7 @@ func Image(input interface{}, opts ...Option) error {
 	}
 
 	// 解析 OFD
-	ofd, err := parser.NewOFD(input)
+	ofd, err := parser.NewOFD(input, conv.mode)
 	if err != nil {
 		return fmt.Errorf("解析OFD失败: %w", err)
 	}
@@ -194,48 +282,81 @@ func Image(input interface{}, opts ...Option) error {
 
 	// 验证文档
 	if len(ofd.Documents) == 0 {
-		return errors.New("没有文档")
+		return ErrNoDocument
 	}
 
 	// 创建渲染文档
 	doc := render.NewDocument(conv.bgColor, ofd.Documents[0])
+	doc.SetOptions(conv.render)
+	conv.warnings(ofd.Documents[0])
 	if len(doc.Pages) == 0 {
-		return errors.New("文档没有页面")
+		return ErrNoPages
 	}
 
-	// 处理特定页码或所有页面
-	if conv.page > 0 {
-		return conv.renderSpecificPage(doc, conv.page)
+	pages, err := conv.selectPages(len(doc.Pages))
+	if err != nil {
+		return err
 	}
-	return conv.renderAllPages(doc)
-}
+	for _, n := range pages {
+		canvasPage, err := doc.Page(doc.Pages[n-1])
+		if err != nil {
+			return fmt.Errorf("处理第%d页失败: %w", n, err)
+		}
+		conv.collect(doc, n)
 
-// renderSpecificPage 渲染特定页面
-func (c *Converter) renderSpecificPage(doc *render.Document, pageNum int) error {
-	if pageNum > len(doc.Pages) {
-		return nil // 页码超出范围，静默返回
+		if err := conv.renderPage(n-1, canvasPage); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	pageIndex := pageNum - 1
-	canvasPage, err := doc.Page(doc.Pages[pageIndex])
-	if err != nil {
-		return fmt.Errorf("处理第%d页失败: %w", pageNum, err)
+// maxPages ParsePages 最多返回的页码数
+const maxPages = 100000
+
+// ParsePages 解析以逗号分隔的页码及页码范围，如 "1,3-5"，用于 Pages 选项
+func ParsePages(s string) ([]int, error) {
+	var pages []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil || start < 1 {
+			return nil, fmt.Errorf("页码格式错误: %q", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(strings.TrimSpace(to)); err != nil || end < start {
+				return nil, fmt.Errorf("页码格式错误: %q", part)
+			}
+		}
+		if len(pages)+end-start >= maxPages {
+			return nil, fmt.Errorf("页码过多: %q", s)
+		}
+		for n := start; n <= end; n++ {
+			pages = append(pages, n)
+		}
 	}
-
-	return c.renderPage(pageIndex, canvasPage)
+	return pages, nil
 }
 
-// renderAllPages 渲染所有页面
-func (c *Converter) renderAllPages(doc *render.Document) error {
-	for i := range doc.Pages {
-		canvasPage, err := doc.Page(doc.Pages[i])
-		if err != nil {
-			return fmt.Errorf("处理第%d页失败: %w", i+1, err)
+// selectPages 返回要转换的页码，count 为文档的页数
+func (c *Converter) selectPages(count int) ([]int, error) {
+	pages := c.pages
+	if c.page > 0 {
+		pages = []int{c.page}
+	}
+	if len(pages) == 0 {
+		pages = make([]int, count)
+		for i := range pages {
+			pages[i] = i + 1
 		}
-
-		if err := c.renderPage(i, canvasPage); err != nil {
-			return err
+		return pages, nil
+	}
+	for _, n := range pages {
+		if n < 1 || n > count {
+			return nil, fmt.Errorf("%w: 第%d页，文档共%d页", ErrPageOutOfRange, n, count)
 		}
 	}
-	return nil
+	return pages, nil
 }
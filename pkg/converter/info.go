@@ -0,0 +1,133 @@
+package converter
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/zc310/ofd/internal/models"
+	"github.com/zc310/ofd/internal/parser"
+)
+
+// DocumentInfo 文档的元数据 (OFD.xml 中的 DocInfo) 及页面、签名、附件概况
+type DocumentInfo struct {
+	Version        string       `json:"version"`
+	DocType        string       `json:"docType"`
+	DocID          string       `json:"docID"`
+	Title          string       `json:"title,omitempty"`
+	Author         string       `json:"author,omitempty"`
+	Subject        string       `json:"subject,omitempty"`
+	Abstract       string       `json:"abstract,omitempty"`
+	Creator        string       `json:"creator,omitempty"`
+	CreatorVersion string       `json:"creatorVersion,omitempty"`
+	DocUsage       string       `json:"docUsage,omitempty"`
+	CreationDate   *time.Time   `json:"creationDate,omitempty"`
+	ModDate        *time.Time   `json:"modDate,omitempty"`
+	Keywords       []string     `json:"keywords,omitempty"`
+	CustomData     []CustomData `json:"customData,omitempty"`
+	Pages          []PageInfo   `json:"pages"`
+	Signatures     int          `json:"signatures"`
+	Attachments    int          `json:"attachments"`
+	// Warnings 宽松模式下解析文档时跳过的部件
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// CustomData 用户自定义元数据，如电子发票的发票代码、金额等
+type CustomData struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PageInfo 页面的 ID 及物理区域大小，单位为毫米
+type PageInfo struct {
+	Page   int     `json:"page"`
+	ID     uint64  `json:"id"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// open 解析 OFD 并返回第一个文档，调用方负责关闭返回的 OFD
+func (c *Converter) open(input interface{}) (*parser.OFD, *parser.Document, error) {
+	ofd, err := parser.NewOFD(input, c.mode)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析OFD失败: %w", err)
+	}
+	if len(ofd.Documents) == 0 {
+		closeOFD(ofd)
+		return nil, nil, ErrNoDocument
+	}
+	c.warnings(ofd.Documents[0])
+	return ofd, ofd.Documents[0], nil
+}
+
+func closeOFD(ofd *parser.OFD) {
+	if err := ofd.Close(); err != nil {
+		slog.Error("关闭OFD文档失败", "error", err)
+	}
+}
+
+// Info 读取文档的元数据，支持 Strict 选项
+func Info(input interface{}, opts ...Option) (*DocumentInfo, error) {
+	conv := newConverter(opts...)
+	ofd, doc, err := conv.open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer closeOFD(ofd)
+
+	body := ofd.OFD.DocBodies[0]
+	info := &DocumentInfo{
+		Version:        ofd.OFD.Version,
+		DocType:        ofd.OFD.DocType,
+		DocID:          body.DocInfo.DocID,
+		Title:          value(body.DocInfo.Title),
+		Author:         value(body.DocInfo.Author),
+		Subject:        value(body.DocInfo.Subject),
+		Abstract:       value(body.DocInfo.Abstract),
+		Creator:        value(body.DocInfo.Creator),
+		CreatorVersion: value(body.DocInfo.CreatorVersion),
+		DocUsage:       value(body.DocInfo.DocUsage),
+		CreationDate:   date(body.DocInfo.CreationDate),
+		ModDate:        date(body.DocInfo.ModDate),
+		Signatures:     len(doc.Signs),
+		Pages:          make([]PageInfo, 0, len(doc.Pages)),
+	}
+	if body.DocInfo.Keywords != nil {
+		info.Keywords = body.DocInfo.Keywords.Keyword
+	}
+	if body.DocInfo.CustomDatas != nil {
+		for _, d := range body.DocInfo.CustomDatas.CustomData {
+			info.CustomData = append(info.CustomData, CustomData{Name: d.Name, Value: d.Value})
+		}
+	}
+	for i, page := range doc.Pages {
+		box := page.Area.PhysicalBox
+		info.Pages = append(info.Pages, PageInfo{Page: i + 1, ID: uint64(page.ID), Width: box.Width, Height: box.Height})
+	}
+	for _, err := range doc.Warnings {
+		info.Warnings = append(info.Warnings, err.Error())
+	}
+	attachments, err := doc.ParseAttachments()
+	if err != nil {
+		if conv.mode == parser.Strict {
+			return nil, err
+		}
+		info.Warnings = append(info.Warnings, err.Error())
+	}
+	info.Attachments = len(attachments)
+	return info, nil
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func date(t *models.DateTime) *time.Time {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return &t.Time
+}
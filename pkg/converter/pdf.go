@@ -3,18 +3,18 @@ package converter
 import (
 	"errors"
 	"fmt"
-	"image/color"
 	"io"
 	"log/slog"
 
 	"github.com/tdewolff/canvas"
-	"github.com/tdewolff/canvas/renderers/pdf"
 	"github.com/zc310/ofd/internal/parser"
+	"github.com/zc310/ofd/internal/pdf"
 	"github.com/zc310/ofd/internal/render"
 )
 
-func PDF(input interface{}, output io.Writer, opts ...interface{}) error {
-	ofd, err := parser.NewOFD(input)
+func PDF(input interface{}, output io.Writer, opts ...Option) error {
+	conv := newConverter(opts...)
+	ofd, err := parser.NewOFD(input, conv.mode)
 	if err != nil {
 		return err
 	}
@@ -24,26 +24,34 @@ func PDF(input interface{}, output io.Writer, opts ...interface{}) error {
 		}
 	}()
 	if len(ofd.Documents) == 0 {
-		return errors.New("没有文档")
+		return ErrNoDocument
 	}
 
-	doc := render.NewDocument(color.Transparent, ofd.Documents[0])
+	doc := render.NewDocument(conv.bgColor, ofd.Documents[0])
+	doc.SetOptions(conv.render)
+	conv.warnings(ofd.Documents[0])
 	if len(doc.Pages) == 0 {
-		return errors.New("文档没有页面")
+		return ErrNoPages
 	}
+	pages, err := conv.selectPages(len(doc.Pages))
+	if err != nil {
+		return err
+	}
+	pdfOpts := pdf.DefaultOptions
 	var pdfDoc *pdf.PDF
-	var c *canvas.Canvas
-	for i, page := range doc.Pages {
-		c, err = doc.Page(page)
-		if err != nil {
-			return fmt.Errorf("处理第%d页失败: %w", i+1, err)
-		}
+	for i, n := range pages {
+		page := doc.Pages[n-1]
+		box := page.Area.PhysicalBox
 		if i == 0 {
-			pdfDoc = pdf.New(output, c.W, c.H, nil)
+			pdfDoc = pdf.New(output, box.Width, box.Height, &pdfOpts)
 		} else {
-			pdfDoc.NewPage(c.W, c.H)
+			pdfDoc.NewPage(box.Width, box.Height)
+		}
+		// 直接绘制到 PDF，文字以定位字形输出
+		if err = doc.Draw(canvas.NewContext(pdfDoc), page); err != nil {
+			return fmt.Errorf("处理第%d页失败: %w", n, err)
 		}
-		c.RenderTo(pdfDoc)
+		conv.collect(doc, n)
 	}
 	if pdfDoc == nil {
 		return errors.New("PDF 文档创建失败")
@@ -0,0 +1,147 @@
+package converter
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/zc310/ofd/internal/models"
+)
+
+// PageText 页面中的文字
+type PageText struct {
+	Page int    `json:"page"`
+	Text string `json:"text"`
+}
+
+// Text 提取页面及模板中的文字，按基线分行，行内按从左到右排列。
+// 支持 Page、Pages、HideTemplates、HideLayers 及 Strict 选项
+func Text(input interface{}, opts ...Option) ([]PageText, error) {
+	conv := newConverter(opts...)
+	ofd, doc, err := conv.open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer closeOFD(ofd)
+	if len(doc.Pages) == 0 {
+		return nil, ErrNoPages
+	}
+	pages, err := conv.selectPages(len(doc.Pages))
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]PageText, 0, len(pages))
+	for _, n := range pages {
+		page := doc.Pages[n-1]
+		var t textCollector
+		if !conv.render.HideTemplates {
+			for _, tpl := range page.Template {
+				if content := doc.Templates[models.StID(tpl.TemplateID)]; content != nil {
+					t.content(content.Content, conv.render.HideLayers)
+				}
+			}
+		}
+		t.content(page.Content, conv.render.HideLayers)
+		list = append(list, PageText{Page: n, Text: t.String()})
+	}
+	return list, nil
+}
+
+// textRun 一个 TextCode 在页面坐标系中的位置，(x, y) 为第一个文字的基线起点
+type textRun struct {
+	x, y, width, size float64
+	text              string
+}
+
+type textCollector struct {
+	runs []textRun
+}
+
+func (p *textCollector) content(content *models.Content, hide map[string]bool) {
+	if content == nil {
+		return
+	}
+	for _, layer := range content.Layer {
+		if !hide[layer.LayerType()] {
+			p.block(&layer.CTPageBlock)
+		}
+	}
+}
+
+func (p *textCollector) block(block *models.CTPageBlock) {
+	for i := range block.TextObject {
+		p.text(&block.TextObject[i].CtText)
+	}
+	for i := range block.PageBlock {
+		p.block(&block.PageBlock[i].CTPageBlock)
+	}
+}
+
+func (p *textCollector) text(object *models.CtText) {
+	size := object.Size
+	if object.CTM != nil {
+		// 字号按 CTM 的纵向缩放换算到页面坐标
+		size *= math.Hypot(object.CTM[2], object.CTM[3])
+	}
+	for _, code := range object.TextCode {
+		// 连续的空白 (包括 XML 中的换行) 合并为一个空格
+		value := strings.Join(strings.Fields(code.Value), " ")
+		if value == "" {
+			continue
+		}
+		x, y := code.X, code.Y
+		width := float64(len([]rune(code.Value))) * object.Size
+		if len(code.DeltaX) > 0 {
+			width = object.Size
+			for _, dx := range code.DeltaX {
+				width += dx
+			}
+		}
+		if ctm := object.CTM; ctm != nil {
+			x, y = ctm[0]*code.X+ctm[2]*code.Y+ctm[4], ctm[1]*code.X+ctm[3]*code.Y+ctm[5]
+			width *= math.Hypot(ctm[0], ctm[1])
+		}
+		p.runs = append(p.runs, textRun{
+			x:     object.Boundary.X + x,
+			y:     object.Boundary.Y + y,
+			width: width,
+			size:  size,
+			text:  value,
+		})
+	}
+}
+
+// String 按基线将文字分行，同一行中间距较大的文字以空格分隔
+func (p *textCollector) String() string {
+	runs := p.runs
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].y < runs[j].y })
+	var lines [][]textRun
+	for _, run := range runs {
+		if n := len(lines); n > 0 {
+			first := lines[n-1][0]
+			if math.Abs(run.y-first.y) <= math.Max(first.size, run.size)/2 {
+				lines[n-1] = append(lines[n-1], run)
+				continue
+			}
+		}
+		lines = append(lines, []textRun{run})
+	}
+
+	var sb strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sort.SliceStable(line, func(i, j int) bool { return line[i].x < line[j].x })
+		end := math.Inf(-1)
+		for j, run := range line {
+			if j > 0 && run.x-end > run.size/2 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(run.text)
+			end = math.Max(end, run.x+run.width)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,124 @@
+package converter
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"slices"
+	"strings"
+
+	"github.com/zc310/ofd/internal/models"
+	"github.com/zc310/ofd/internal/sm3"
+)
+
+// SignatureResult 签名的校验结果
+type SignatureResult struct {
+	ID       uint64 `json:"id"`
+	Provider string `json:"provider"`
+	Company  string `json:"company,omitempty"`
+	// Method 签名算法，如 SM2 的 OID 1.2.156.10197.1.501
+	Method   string `json:"method,omitempty"`
+	DateTime string `json:"dateTime,omitempty"`
+	// CheckMethod 引用文件的杂凑算法，未指定时为 MD5
+	CheckMethod string `json:"checkMethod"`
+	// Pages 签章所在页面的 ID
+	Pages      []uint64          `json:"pages,omitempty"`
+	References []ReferenceResult `json:"references"`
+	// DigestsMatch 所有引用文件的杂凑值均一致，没有引用文件时为 false。
+	// 只说明签名后文件未被修改，不表示签名值及证书有效
+	DigestsMatch bool `json:"digestsMatch"`
+}
+
+// ReferenceResult 签名引用文件的杂凑值校验结果
+type ReferenceResult struct {
+	File  string `json:"file"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// checkMethods 杂凑算法，键为小写的算法名或 OID
+var checkMethods = map[string]func() hash.Hash{
+	"md5":                    md5.New,
+	"1.2.840.113549.2.5":     md5.New,
+	"sha1":                   sha1.New,
+	"1.3.14.3.2.26":          sha1.New,
+	"sha256":                 sha256.New,
+	"2.16.840.1.101.3.4.2.1": sha256.New,
+	"sm3":                    sm3.New,
+	"1.2.156.10197.1.401":    sm3.New,
+}
+
+// Verify 校验各签名引用文件的杂凑值，检查签名后文档是否被修改 (包括删除被引用的文件)。
+// 不验证签名值及签名证书，结果按签名 ID 排列
+func Verify(input interface{}, opts ...Option) ([]SignatureResult, error) {
+	conv := newConverter(opts...)
+	ofd, doc, err := conv.open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer closeOFD(ofd)
+
+	ids := make([]models.StID, 0, len(doc.Signs))
+	for id := range doc.Signs {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	results := make([]SignatureResult, 0, len(ids))
+	for _, id := range ids {
+		info := doc.Signs[id].SignedInfo
+		result := SignatureResult{
+			ID:           uint64(id),
+			Provider:     info.Provider.ProviderName,
+			Company:      info.Provider.Company,
+			Method:       info.SignatureMethod,
+			DateTime:     info.SignatureDateTime,
+			CheckMethod:  info.References.CheckMethod,
+			DigestsMatch: len(info.References.Reference) > 0,
+		}
+		if result.CheckMethod == "" {
+			result.CheckMethod = "MD5"
+		}
+		for _, annot := range info.StampAnnot {
+			result.Pages = append(result.Pages, uint64(annot.PageRef))
+		}
+		newHash := checkMethods[strings.ToLower(strings.TrimSpace(result.CheckMethod))]
+		for _, ref := range info.References.Reference {
+			r := ReferenceResult{File: ref.FileRef.String()}
+			if err := checkReference(doc.FileCache.ParseContent, newHash, ref); err != nil {
+				r.Error = err.Error()
+			} else {
+				r.Valid = true
+			}
+			result.DigestsMatch = result.DigestsMatch && r.Valid
+			result.References = append(result.References, r)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// checkReference 比较引用文件的杂凑值与签名中记录的 CheckValue (Base64)
+func checkReference(read func(string) ([]byte, error), newHash func() hash.Hash, ref models.Reference) error {
+	if newHash == nil {
+		return fmt.Errorf("不支持的杂凑算法")
+	}
+	want, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(ref.CheckValue)))
+	if err != nil {
+		return fmt.Errorf("CheckValue 格式错误: %w", err)
+	}
+	data, err := read(ref.FileRef.String())
+	if err != nil {
+		return err
+	}
+	h := newHash()
+	h.Write(data)
+	if !bytes.Equal(h.Sum(nil), want) {
+		return fmt.Errorf("杂凑值不一致，文件已被修改")
+	}
+	return nil
+}
@@ -0,0 +1,449 @@
+// Package editor 合并、拆分 OFD 文件及编辑页面。
+//
+// 文档读取后以 XML 元素树的形式保存在内存中，保存时重新组织包的结构：
+// 资源文件按内容命名并存放在 Doc_0/Res 目录，页面、模板、注释及附件按顺序编号。
+// 签名只对原文件有效，编辑后的文档不保留签名，自定义标签、扩展信息及版本也不保留
+package editor
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/zc310/ofd/internal/utils"
+)
+
+// Document 可编辑的 OFD 文档 (包中的第一个文档)
+type Document struct {
+	version string
+	docInfo *node
+	// common CommonData 中除 MaxUnitID、资源、模板及 DefaultCS 以外的元素，如 PageArea
+	common []*node
+	// defaultCS CommonData/DefaultCS
+	defaultCS *node
+	templates []*template
+	pages     []*page
+	// res 资源元素 (Font、ColorSpace、DrawParam、MultiMedia、CompositeGraphicUnit)，
+	// 其中引用的文件名为 files 中的键
+	res []*node
+	// files 资源文件，键为按内容命名的文件名
+	files       map[string][]byte
+	attachments []*attachment
+	// outlines 等文档级元素，键为元素名
+	extras map[string]*node
+	// maxID 文档中最大的 ID，新对象的 ID 从 maxID+1 开始
+	maxID uint64
+
+	// Signatures 原文件中的签名，保存时不保留
+	Signatures []Signature
+	// Warnings 读取时跳过的部件及保存时不保留的内容 (签名除外)
+	Warnings []string
+}
+
+// Signature 原文件中的签名。签名保护的是原文件的内容，编辑后的文档不保留签名
+type Signature struct {
+	// Source 签名所在的文件，合并时为输入文档的序号 (从 0 开始)
+	Source   int    `json:"source"`
+	ID       string `json:"id"`
+	Provider string `json:"provider,omitempty"`
+	// Pages 签章所在的页码 (原文件中的页码)
+	Pages []int `json:"pages,omitempty"`
+}
+
+// page 页面，entry 为 Document.xml 中的 Page 元素，content 为页面内容，annot 为页面的注释 (PageAnnot)
+type page struct {
+	entry   *node
+	content *node
+	annot   *node
+}
+
+// template 模板页，entry 为 Document.xml 中的 TemplatePage 元素
+type template struct {
+	entry   *node
+	content *node
+}
+
+// attachment 附件，entry 为 Attachments.xml 中的 Attachment 元素，name 为保存时的文件名
+type attachment struct {
+	entry *node
+	name  string
+	data  []byte
+}
+
+// extraNames Document.xml 中保留的文档级元素，按保存时的顺序排列
+var extraNames = []string{"Outlines", "Permissions", "Actions", "VPreferences", "Bookmarks"}
+
+// NumPages 返回页数
+func (d *Document) NumPages() int {
+	return len(d.pages)
+}
+
+// Open 读取 OFD 文件，input 为文件路径 (string) 或文件数据 ([]byte)。
+// 文档、页面、模板及资源文件无法读取时返回错误，注释、附件及资源引用的文件缺失时记入 Warnings
+func Open(input interface{}) (*Document, error) {
+	var zr *zip.Reader
+	switch v := input.(type) {
+	case string:
+		rc, err := zip.OpenReader(filepath.Clean(v))
+		if err != nil {
+			return nil, fmt.Errorf("打开OFD文件失败: %w", err)
+		}
+		defer rc.Close()
+		zr = &rc.Reader
+	case []byte:
+		var err error
+		if zr, err = zip.NewReader(bytes.NewReader(v), int64(len(v))); err != nil {
+			return nil, fmt.Errorf("从字节数据创建zip reader失败: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的类型: %T, 请提供文件路径(string)或文件数据([]byte)", input)
+	}
+	l := &loader{
+		files:  utils.NewZipFileCache(zr),
+		doc:    &Document{files: make(map[string][]byte), extras: make(map[string]*node)},
+		loaded: make(map[string]bool),
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l.doc, nil
+}
+
+type loader struct {
+	files *utils.ZipFileCache
+	doc   *Document
+	// pageIDs 页面 ID 对应的页码
+	pageIDs map[string]int
+	// loaded 已读取的资源文件，多个页面可以引用同一个资源文件
+	loaded map[string]bool
+}
+
+func (l *loader) warn(format string, args ...interface{}) {
+	l.doc.Warnings = append(l.doc.Warnings, fmt.Sprintf(format, args...))
+}
+
+// part 读取 XML 部件，root 为应有的根元素名
+func (l *loader) part(name, root string) (*node, error) {
+	data, err := l.files.ParseContent(name)
+	if err != nil {
+		return nil, err
+	}
+	n, err := parseNode(name, data)
+	if err != nil {
+		return nil, err
+	}
+	if n.name != root {
+		return nil, &utils.PartError{Path: name, Err: fmt.Errorf("根元素应为 %s，实际为 %s", root, n.name)}
+	}
+	return n, nil
+}
+
+func (l *loader) load() error {
+	root, err := l.part("OFD.xml", "OFD")
+	if err != nil {
+		return err
+	}
+	d := l.doc
+	d.version = root.attr("Version")
+	bodies := root.all("DocBody")
+	if len(bodies) == 0 {
+		return ErrNoDocument
+	}
+	if len(bodies) > 1 {
+		l.warn("包中有 %d 个文档，只保留第一个", len(bodies))
+	}
+	body := bodies[0]
+	if d.docInfo = body.child("DocInfo"); d.docInfo == nil {
+		d.docInfo = newNode("DocInfo")
+	}
+	docRoot := body.child("DocRoot")
+	if docRoot == nil || strings.TrimSpace(docRoot.text) == "" {
+		return ErrNoDocument
+	}
+	loc := utils.Resolve("/", docRoot.text)
+	doc, err := l.part(loc, "Document")
+	if err != nil {
+		return err
+	}
+	dir := path.Dir(loc)
+
+	var declared uint64
+	if common := doc.child("CommonData"); common != nil {
+		for _, e := range common.children {
+			switch e.name {
+			case "MaxUnitID":
+				declared = parseID(e.text)
+			case "PublicRes", "DocumentRes":
+				if err = l.resources(utils.Resolve(dir, e.text)); err != nil {
+					return err
+				}
+			case "TemplatePage":
+				t := &template{entry: e}
+				if t.content, err = l.content(utils.Resolve(dir, e.attr("BaseLoc"))); err != nil {
+					return err
+				}
+				e.removeAttr("BaseLoc")
+				d.templates = append(d.templates, t)
+			case "DefaultCS":
+				d.defaultCS = e
+			default:
+				d.common = append(d.common, e)
+			}
+		}
+	}
+	if pages := doc.child("Pages"); pages != nil {
+		l.pageIDs = make(map[string]int)
+		for _, e := range pages.all("Page") {
+			p := &page{entry: e}
+			if p.content, err = l.content(utils.Resolve(dir, e.attr("BaseLoc"))); err != nil {
+				return err
+			}
+			e.removeAttr("BaseLoc")
+			// 页面未指定大小时使用文档的默认大小，使页面移到其他文档后大小不变
+			if p.content.child("Area") == nil {
+				if area := d.pageArea(); area != nil {
+					p.setArea(area.clone())
+				}
+			}
+			d.pages = append(d.pages, p)
+			l.pageIDs[strings.TrimSpace(e.attr("ID"))] = len(d.pages)
+		}
+	}
+	for _, e := range doc.children {
+		if strings.TrimSpace(e.text) == "" && len(e.children) == 0 {
+			continue
+		}
+		switch e.name {
+		case "Annotations":
+			l.annotations(utils.Resolve(dir, e.text))
+		case "Attachments":
+			l.attachments(utils.Resolve(dir, e.text))
+		case "CustomTags":
+			l.warn("不保留自定义标签: %s", utils.Resolve(dir, e.text))
+		case "Extensions":
+			l.warn("不保留扩展信息: %s", utils.Resolve(dir, e.text))
+		case "CommonData", "Pages":
+		default:
+			d.extras[e.name] = e
+		}
+	}
+	if e := body.child("Signatures"); e != nil && strings.TrimSpace(e.text) != "" {
+		l.signatures(utils.Resolve("/", e.text))
+	}
+	if body.child("Versions") != nil {
+		l.warn("不保留文档的版本")
+	}
+
+	d.maxID = declared
+	d.walk(func(n *node) {
+		d.maxID = max(d.maxID, maxID(n))
+	})
+	return nil
+}
+
+// content 读取页面或模板的内容，页面资源并入文档的资源
+func (l *loader) content(loc string) (*node, error) {
+	n, err := l.part(loc, "Page")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range n.all("PageRes") {
+		if err = l.resources(utils.Resolve(path.Dir(loc), e.text)); err != nil {
+			return nil, err
+		}
+	}
+	n.removeAll("PageRes")
+	return n, nil
+}
+
+// resources 读取资源文件，资源引用的文件按内容命名后存入 files
+func (l *loader) resources(loc string) error {
+	if l.loaded[loc] {
+		return nil
+	}
+	l.loaded[loc] = true
+	res, err := l.part(loc, "Res")
+	if err != nil {
+		return err
+	}
+	base := path.Dir(loc)
+	if s := res.attr("BaseLoc"); s != "" {
+		base = utils.Resolve(base, s)
+	}
+	for _, group := range res.children {
+		for _, e := range group.children {
+			switch e.name {
+			case "Font":
+				if f := e.child("FontFile"); f != nil {
+					f.text = l.file(utils.Resolve(base, f.text), f.text)
+				}
+			case "MultiMedia":
+				if f := e.child("MediaFile"); f != nil {
+					f.text = l.file(utils.Resolve(base, f.text), f.text)
+				}
+			case "ColorSpace":
+				if s := e.attr("Profile"); s != "" {
+					e.setAttr("Profile", l.file(utils.Resolve(base, s), s))
+				}
+			case "DrawParam", "CompositeGraphicUnit":
+			default:
+				continue
+			}
+			l.doc.res = append(l.doc.res, e)
+		}
+	}
+	return nil
+}
+
+// file 读取资源引用的文件，返回按内容命名的文件名，文件缺失时返回原来的引用
+func (l *loader) file(loc, ref string) string {
+	data, err := l.files.ParseContent(loc)
+	if err != nil {
+		l.warn("%v", err)
+		return ref
+	}
+	return l.doc.addFile(data, path.Ext(loc))
+}
+
+// addFile 保存资源文件，文件名为内容的 SHA-256 摘要 (前 16 位) 加扩展名，相同内容的文件只保存一份
+func (d *Document) addFile(data []byte, ext string) string {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:8]) + strings.ToLower(ext)
+	d.files[name] = data
+	return name
+}
+
+// annotations 读取注释索引及各页面的注释
+func (l *loader) annotations(loc string) {
+	list, err := l.part(loc, "Annotations")
+	if err != nil {
+		l.warn("%v", err)
+		return
+	}
+	for _, e := range list.all("Page") {
+		i, ok := l.pageIDs[strings.TrimSpace(e.attr("PageID"))]
+		f := e.child("FileLoc")
+		if !ok || f == nil {
+			l.warn("%s: 注释所在的页面 %s 不存在", loc, e.attr("PageID"))
+			continue
+		}
+		annot, err := l.part(utils.Resolve(path.Dir(loc), f.text), "PageAnnot")
+		if err != nil {
+			l.warn("%v", err)
+			continue
+		}
+		if p := l.doc.pages[i-1]; p.annot == nil {
+			p.annot = annot
+		} else {
+			p.annot.add(annot.children...)
+		}
+	}
+}
+
+// attachments 读取附件列表及附件文件
+func (l *loader) attachments(loc string) {
+	list, err := l.part(loc, "Attachments")
+	if err != nil {
+		l.warn("%v", err)
+		return
+	}
+	for _, e := range list.all("Attachment") {
+		f := e.child("FileLoc")
+		if f == nil {
+			continue
+		}
+		name := utils.Resolve(path.Dir(loc), f.text)
+		data, err := l.files.ParseContent(name)
+		if err != nil {
+			l.warn("%v", err)
+			continue
+		}
+		l.doc.attachments = append(l.doc.attachments, &attachment{entry: e, name: path.Base(name), data: data})
+	}
+}
+
+// signatures 读取签名的提供者及签章所在的页面
+func (l *loader) signatures(loc string) {
+	list, err := l.part(loc, "Signatures")
+	if err != nil {
+		l.warn("%v", err)
+		return
+	}
+	for _, e := range list.all("Signature") {
+		sig := Signature{ID: e.attr("ID")}
+		if s := e.attr("BaseLoc"); s != "" {
+			if n, err := l.part(utils.Resolve(path.Dir(loc), s), "Signature"); err == nil {
+				n.walk(func(e *node) {
+					switch e.name {
+					case "Provider":
+						sig.Provider = e.attr("ProviderName")
+					case "StampAnnot":
+						if i, ok := l.pageIDs[strings.TrimSpace(e.attr("PageRef"))]; ok {
+							sig.Pages = append(sig.Pages, i)
+						}
+					}
+				})
+			}
+		}
+		l.doc.Signatures = append(l.doc.Signatures, sig)
+	}
+}
+
+// pageArea 返回文档的默认页面区域
+func (d *Document) pageArea() *node {
+	for _, e := range d.common {
+		if e.name == "PageArea" {
+			return e
+		}
+	}
+	return nil
+}
+
+// setArea 设置页面区域，Area 位于 Template 与 Content 之间
+func (p *page) setArea(area *node) {
+	area.name = "Area"
+	c := p.content
+	c.removeAll("Area")
+	i := 0
+	for i < len(c.children) && c.children[i].name == "Template" {
+		i++
+	}
+	c.children = append(c.children[:i], append([]*node{area}, c.children[i:]...)...)
+}
+
+// walk 访问文档中属于文档 ID 空间的各个元素树
+func (d *Document) walk(f func(n *node)) {
+	for _, e := range d.common {
+		f(e)
+	}
+	if d.defaultCS != nil {
+		f(d.defaultCS)
+	}
+	for _, t := range d.templates {
+		f(t.entry)
+		f(t.content)
+	}
+	for _, p := range d.pages {
+		f(p.entry)
+		f(p.content)
+		if p.annot != nil {
+			f(p.annot)
+		}
+	}
+	for _, e := range d.res {
+		f(e)
+	}
+	for _, a := range d.attachments {
+		f(a.entry)
+	}
+	for _, name := range extraNames {
+		if e := d.extras[name]; e != nil {
+			f(e)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package editor
+
+import (
+	"github.com/zc310/ofd/internal/parser"
+)
+
+// 编辑失败的原因，可以用 errors.Is 判断
+var (
+	// ErrNoDocument OFD 包中没有文档
+	ErrNoDocument = parser.ErrNoDocument
+	// ErrNoPages 没有指定页面
+	ErrNoPages = parser.ErrNoPages
+	// ErrPageOutOfRange 页码超出文档的页数
+	ErrPageOutOfRange = parser.ErrPageOutOfRange
+)
+
+type (
+	// PartError 包内部件缺失、无法读取或格式错误，可以用 errors.As 取得部件路径
+	PartError = parser.PartError
+	// XMLError 部件的 XML 格式错误，可以用 errors.As 取得部件路径及行号
+	XMLError = parser.XMLError
+)
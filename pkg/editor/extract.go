@@ -0,0 +1,178 @@
+package editor
+
+import (
+	"fmt"
+)
+
+// Extract 返回由指定页面 (从 1 开始，按给定的顺序) 组成的新文档，文档本身不会被修改。
+//
+// 新文档只包含这些页面直接或间接引用的模板、资源 (字体、绘制参数、颜色空间、多媒体、复合对象) 及页面的注释，
+// 对象保持原来的 ID；大纲、书签及动作中指向其他页面的部分被去掉，附件不保留。文档信息沿用原文档，生成新的 DocID 并去掉自定义元数据
+func (d *Document) Extract(pages ...int) (*Document, error) {
+	if len(pages) == 0 {
+		return nil, ErrNoPages
+	}
+	e := &Document{
+		version:    d.version,
+		docInfo:    derivedDocInfo(d.docInfo),
+		files:      make(map[string][]byte, len(d.files)),
+		extras:     make(map[string]*node),
+		maxID:      d.maxID,
+		Signatures: append([]Signature(nil), d.Signatures...),
+		Warnings:   append([]string(nil), d.Warnings...),
+	}
+	if len(d.attachments) > 0 {
+		e.Warnings = append(e.Warnings, fmt.Sprintf("不保留 %d 个附件", len(d.attachments)))
+	}
+	for name, data := range d.files {
+		e.files[name] = data
+	}
+	seen := make(map[int]bool)
+	for _, i := range pages {
+		if err := d.checkPage(i); err != nil {
+			return nil, err
+		}
+		if seen[i] {
+			return nil, fmt.Errorf("第 %d 页重复", i)
+		}
+		seen[i] = true
+		p := d.pages[i-1]
+		np := &page{entry: p.entry.clone(), content: p.content.clone()}
+		if p.annot != nil {
+			np.annot = p.annot.clone()
+		}
+		e.pages = append(e.pages, np)
+	}
+	for _, n := range d.common {
+		e.common = append(e.common, n.clone())
+	}
+	if d.defaultCS != nil {
+		e.defaultCS = d.defaultCS.clone()
+	}
+	for _, t := range d.templates {
+		e.templates = append(e.templates, &template{entry: t.entry.clone(), content: t.content.clone()})
+	}
+	for _, r := range d.res {
+		e.res = append(e.res, r.clone())
+	}
+	for name, n := range d.extras {
+		e.extras[name] = n.clone()
+	}
+	e.prune()
+	return e, nil
+}
+
+// prune 去掉页面不再引用的模板及资源，以及注释、大纲、书签及动作中指向不存在页面的部分
+func (d *Document) prune() {
+	used := d.references()
+	templates := d.templates[:0]
+	for _, t := range d.templates {
+		if used[parseID(t.entry.attr("ID"))] {
+			templates = append(templates, t)
+		}
+	}
+	d.templates = templates
+	res := d.res[:0]
+	for _, r := range d.res {
+		if used[parseID(r.attr("ID"))] {
+			res = append(res, r)
+		}
+	}
+	d.res = res
+
+	kept := make(map[uint64]bool, len(d.pages))
+	for _, p := range d.pages {
+		kept[parseID(p.entry.attr("ID"))] = true
+	}
+	for _, p := range d.pages {
+		if p.annot != nil {
+			dropDangling(p.annot, kept)
+		}
+	}
+	for name, n := range d.extras {
+		dropDangling(n, kept)
+		if len(n.children) == 0 && n.text == "" && len(n.attrs) == 0 {
+			delete(d.extras, name)
+		}
+	}
+}
+
+// references 返回页面、注释及 DefaultCS 直接或间接引用的模板及资源的 ID
+func (d *Document) references() map[uint64]bool {
+	objects := make(map[uint64]*node)
+	for _, t := range d.templates {
+		objects[parseID(t.entry.attr("ID"))] = t.content
+	}
+	for _, r := range d.res {
+		objects[parseID(r.attr("ID"))] = r
+	}
+
+	used := make(map[uint64]bool)
+	var queue []*node
+	for _, p := range d.pages {
+		queue = append(queue, p.content)
+		if p.annot != nil {
+			queue = append(queue, p.annot)
+		}
+	}
+	if d.defaultCS != nil {
+		queue = append(queue, d.defaultCS)
+	}
+	for len(queue) > 0 {
+		n := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		refs(n, func(id uint64) {
+			if used[id] {
+				return
+			}
+			used[id] = true
+			if o := objects[id]; o != nil {
+				queue = append(queue, o)
+			}
+		})
+	}
+	return used
+}
+
+// refs 访问元素及其后代中的引用 (不包括 ID)
+func refs(n *node, f func(id uint64)) {
+	n.walk(func(e *node) {
+		for _, a := range e.attrs {
+			if refAttrs[a.Name.Local] {
+				remapIDs(a.Value, func(id uint64) uint64 { f(id); return id })
+			}
+		}
+		if textRefs[e.name] && len(e.children) == 0 {
+			remapIDs(e.text, func(id uint64) uint64 { f(id); return id })
+		}
+	})
+}
+
+// dropDangling 去掉指向 kept 以外页面的动作及书签，以及因此为空的动作列表和大纲节点
+func dropDangling(n *node, kept map[uint64]bool) {
+	children := n.children[:0]
+	for _, c := range n.children {
+		if c.name == "Action" || c.name == "Bookmark" {
+			dangling := false
+			c.walk(func(e *node) {
+				if id := e.attr("PageID"); id != "" && !kept[parseID(id)] {
+					dangling = true
+				}
+			})
+			if dangling {
+				continue
+			}
+		}
+		linked := c.child("Actions") != nil || c.child("OutlineElem") != nil
+		dropDangling(c, kept)
+		if c.name == "Actions" && len(c.children) == 0 {
+			continue
+		}
+		// 原有的动作及子节点全部被去掉的大纲节点
+		if c.name == "OutlineElem" && linked && c.child("Actions") == nil && c.child("OutlineElem") == nil {
+			continue
+		}
+		children = append(children, c)
+	}
+	n.children = children
+}
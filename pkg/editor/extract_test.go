@@ -0,0 +1,128 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zc310/ofd/pkg/converter"
+	"github.com/zc310/ofd/pkg/validator"
+)
+
+func TestExtract(t *testing.T) {
+	d := open(t, "999.ofd")
+	e, err := d.Extract(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.templates) != 2 || e.templates[0].entry.attr("ID") != "9" || len(e.attachments) != 0 {
+		t.Errorf("templates = %d, attachments = %d", len(e.templates), len(e.attachments))
+	}
+	if e.pages[0].annot != nil || e.pages[1].annot == nil {
+		t.Error("annotations not moved with pages")
+	}
+	data := save(t, e)
+	info, err := converter.Info(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与合并一致，不保留描述原文件的自定义元数据
+	if len(info.Pages) != 2 || info.Pages[0].ID != 92 || info.Pages[1].ID != 10 || info.DocID == "050001700111_12235358" || len(info.CustomData) != 0 {
+		t.Errorf("info = %+v", info)
+	}
+	// 只有模板中原有的错误引用
+	if got := findings(t, data); got[validator.RuleDanglingRef] != 2 || got[validator.RuleMissingPart] != 0 || got[validator.RuleDuplicateID] != 0 {
+		t.Errorf("findings = %v", got)
+	}
+	if want, got := render(t, "../../test/testdata/999.ofd", 2), render(t, data, 1); !bytes.Equal(want, got) {
+		t.Error("page 1 differs from page 2 of 999.ofd")
+	}
+
+	// 第 2 页没有图像，不需要多媒体资源
+	e, err = d.Extract(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range e.res {
+		if r.name == "MultiMedia" {
+			t.Errorf("unused resource %s %s", r.name, r.attr("ID"))
+		}
+	}
+	if names := e.resFiles(); len(names) != 0 {
+		t.Errorf("files = %v", names)
+	}
+	if d.NumPages() != 5 {
+		t.Error("document modified")
+	}
+}
+
+func TestExtract_Annotations(t *testing.T) {
+	d := open(t, "ano.ofd")
+	e, err := d.Extract(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.pages[0].annot == nil || len(e.pages[0].annot.all("Annot")) != len(d.pages[0].annot.all("Annot")) {
+		t.Fatal("annotations not extracted")
+	}
+	// 原文件中的链接指向其他页面，提取后去掉
+	if got := findings(t, save(t, e)); len(got) != 0 {
+		t.Errorf("findings = %v", got)
+	}
+}
+
+func TestExtract_Outlines(t *testing.T) {
+	d := open(t, "helloworld.ofd")
+	// outline 返回大纲节点，page 不为空时带有跳转到该页的动作
+	outline := func(title, page string, children ...*node) *node {
+		n := newNode("OutlineElem", "Title", title)
+		if page != "" {
+			n.add(newNode("Actions").add(newNode("Action", "Event", "CLICK").add(
+				newNode("Goto").add(newNode("Dest", "Type", "XYZ", "PageID", page)))))
+		}
+		return n.add(children...)
+	}
+	d.extras["Outlines"] = newNode("Outlines").add(
+		outline("第一页", "1", outline("第一页小节", "1")),
+		outline("第二页", "2", outline("第二页小节", "2")),
+		outline("目录", "", outline("第二页", "2")),
+		outline("标题", ""))
+
+	e, err := d.Extract(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 动作及子节点都被去掉的节点一并去掉，原本没有动作的节点保留
+	var titles []string
+	e.extras["Outlines"].walk(func(n *node) {
+		if n.name == "OutlineElem" {
+			titles = append(titles, n.attr("Title"))
+		}
+	})
+	if got := strings.Join(titles, ", "); got != "第一页, 第一页小节, 标题" {
+		t.Errorf("outlines = %s", got)
+	}
+
+	// 大纲全部去掉时不保留 Outlines
+	d.extras["Outlines"] = newNode("Outlines").add(outline("第一页", "1"))
+	if e, err = d.Extract(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.extras["Outlines"]; ok {
+		t.Error("empty outlines kept")
+	}
+}
+
+func TestExtract_Errors(t *testing.T) {
+	d := open(t, "helloworld.ofd")
+	if _, err := d.Extract(); !errors.Is(err, ErrNoPages) {
+		t.Errorf("no pages: %v", err)
+	}
+	if _, err := d.Extract(3); !errors.Is(err, ErrPageOutOfRange) {
+		t.Errorf("out of range: %v", err)
+	}
+	if _, err := d.Extract(1, 1); err == nil {
+		t.Error("duplicate page: no error")
+	}
+}
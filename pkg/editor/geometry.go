@@ -0,0 +1,103 @@
+package editor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Box 矩形区域 (ST_Box)，单位为毫米
+type Box struct {
+	X, Y, Width, Height float64
+}
+
+func (b Box) String() string {
+	return formatFloats(b.X, b.Y, b.Width, b.Height)
+}
+
+// parseBox 解析 ST_Box
+func parseBox(s string) (Box, error) {
+	v, err := parseFloats(s, 4)
+	if err != nil {
+		return Box{}, fmt.Errorf("ST_Box 格式错误: %q", s)
+	}
+	return Box{X: v[0], Y: v[1], Width: v[2], Height: v[3]}, nil
+}
+
+// matrix 变换矩阵 (a b c d e f)，与 CTM 相同，(x, y) 变换为 (a x + c y + e, b x + d y + f)
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// parseMatrix 解析 CTM，s 为空时返回 def
+func parseMatrix(s string, def matrix) (matrix, error) {
+	if strings.TrimSpace(s) == "" {
+		return def, nil
+	}
+	v, err := parseFloats(s, 6)
+	if err != nil {
+		return matrix{}, fmt.Errorf("CTM 格式错误: %q", s)
+	}
+	return matrix(v), nil
+}
+
+func (m matrix) String() string {
+	return formatFloats(m[:]...)
+}
+
+func (m matrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// mul 返回先应用 n 再应用 m 的变换
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+// box 返回矩形变换后的外接矩形
+func (m matrix) box(b Box) Box {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range [][2]float64{{b.X, b.Y}, {b.X + b.Width, b.Y}, {b.X, b.Y + b.Height}, {b.X + b.Width, b.Y + b.Height}} {
+		x, y := m.apply(p[0], p[1])
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	return Box{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+}
+
+func parseFloats(s string, n int) ([]float64, error) {
+	fields := strings.Fields(s)
+	if len(fields) != n {
+		return nil, fmt.Errorf("应为 %d 个数值", n)
+	}
+	v := make([]float64, n)
+	for i, f := range fields {
+		var err error
+		if v[i], err = strconv.ParseFloat(f, 64); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// formatFloats 以空格分隔数值，保留 6 位小数以去掉计算误差
+func formatFloats(v ...float64) string {
+	s := make([]string, len(v))
+	for i, f := range v {
+		f = math.Round(f*1e6) / 1e6
+		if f == 0 {
+			f = 0 // 去掉 -0
+		}
+		s[i] = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,60 @@
+package editor
+
+import (
+	"strconv"
+	"strings"
+)
+
+// refAttrs 值为 ST_RefID 的属性
+var refAttrs = map[string]bool{
+	"Font": true, "DrawParam": true, "Relative": true, "ResourceID": true, "Substitution": true,
+	"ImageMask": true, "ColorSpace": true, "TemplateID": true, "Thumbnail": true, "PageID": true,
+}
+
+// textRefs 内容为 ST_RefID 的元素 (CommonData/DefaultCS 及 CompositeGraphicUnit 的 Thumbnail、Substitution)
+var textRefs = map[string]bool{
+	"DefaultCS": true, "Thumbnail": true, "Substitution": true,
+}
+
+// remap 将元素及其后代中的 ID 及引用替换为 f 的返回值，无法解析的值保持不变
+func remap(n *node, f func(id uint64) uint64) {
+	n.walk(func(e *node) {
+		for i, a := range e.attrs {
+			if a.Name.Local == "ID" || refAttrs[a.Name.Local] {
+				e.attrs[i].Value = remapIDs(a.Value, f)
+			}
+		}
+		if textRefs[e.name] && len(e.children) == 0 {
+			e.text = remapIDs(e.text, f)
+		}
+	})
+}
+
+// remapIDs 替换以空白分隔的 ID 列表
+func remapIDs(s string, f func(id uint64) uint64) string {
+	fields := strings.Fields(s)
+	for i, v := range fields {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || id == 0 {
+			return s
+		}
+		fields[i] = strconv.FormatUint(f(id), 10)
+	}
+	return strings.Join(fields, " ")
+}
+
+// maxID 返回元素及其后代中最大的 ID 或引用
+func maxID(n *node) uint64 {
+	var m uint64
+	remap(n, func(id uint64) uint64 {
+		m = max(m, id)
+		return id
+	})
+	return m
+}
+
+// parseID 解析 ST_ID，无法解析时返回 0
+func parseID(s string) uint64 {
+	id, _ := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	return id
+}
@@ -0,0 +1,157 @@
+package editor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Merge 按顺序合并各文档的所有页面，返回新的文档，输入的文档不会被修改。
+//
+// 各文档的 ID 依次加上前面文档的 MaxUnitID 以避免冲突；内容 (包括引用的文件) 相同的字体及多媒体资源只保留一份；
+// 大纲及书签依次合并，权限、视图首选项、文档动作、默认页面大小及文档信息取第一个文档，生成新的 DocID 并去掉自定义元数据。
+// 各文档都有默认颜色空间 (DefaultCS) 时沿用第一个文档的，否则去掉；
+// 默认颜色空间与合并后的不同的文档，其未指定颜色空间的颜色写明原来的默认颜色空间。
+// 各文档的签名记入返回文档的 Signatures，保存时不保留
+func Merge(docs ...*Document) (*Document, error) {
+	if len(docs) == 0 {
+		return nil, ErrNoDocument
+	}
+	m := &Document{version: docs[0].version, files: make(map[string][]byte), extras: make(map[string]*node)}
+	m.docInfo = derivedDocInfo(docs[0].docInfo)
+	for _, e := range docs[0].common {
+		m.common = append(m.common, e.clone())
+	}
+
+	keepCS := true
+	for _, d := range docs {
+		keepCS = keepCS && d.defaultCS != nil
+	}
+
+	// shared 已合并的字体及多媒体资源，键为去掉 ID 后的内容
+	shared := make(map[string]uint64)
+	for i, d := range docs {
+		offset := m.maxID
+		// deduped 与已合并的资源相同的资源在当前文档中的 ID
+		deduped := make(map[uint64]uint64)
+		var res []*node
+		for _, e := range d.res {
+			if e.name == "Font" || e.name == "MultiMedia" {
+				key := resKey(e)
+				if id, ok := shared[key]; ok {
+					deduped[parseID(e.attr("ID"))] = id
+					continue
+				}
+				shared[key] = parseID(e.attr("ID")) + offset
+			}
+			res = append(res, e)
+		}
+		f := func(id uint64) uint64 {
+			if v, ok := deduped[id]; ok {
+				return v
+			}
+			return id + offset
+		}
+		// cs 需要写明的默认颜色空间
+		var cs string
+		if d.defaultCS != nil && (i > 0 || !keepCS) {
+			cs = remapIDs(strings.TrimSpace(d.defaultCS.text), f)
+		}
+		clone := func(n *node) *node {
+			n = n.clone()
+			remap(n, f)
+			if cs != "" {
+				setColorSpace(n, cs)
+			}
+			return n
+		}
+
+		for _, e := range res {
+			m.res = append(m.res, clone(e))
+		}
+		for name, data := range d.files {
+			m.files[name] = data
+		}
+		for _, t := range d.templates {
+			m.templates = append(m.templates, &template{entry: clone(t.entry), content: clone(t.content)})
+		}
+		for _, p := range d.pages {
+			np := &page{entry: clone(p.entry), content: clone(p.content)}
+			if p.annot != nil {
+				np.annot = clone(p.annot)
+			}
+			m.pages = append(m.pages, np)
+		}
+		for _, a := range d.attachments {
+			m.attachments = append(m.attachments, &attachment{entry: clone(a.entry), name: a.name, data: a.data})
+		}
+		for _, name := range extraNames {
+			e := d.extras[name]
+			if e == nil {
+				continue
+			}
+			switch {
+			case m.extras[name] == nil:
+				m.extras[name] = clone(e)
+			case name == "Outlines" || name == "Bookmarks":
+				m.extras[name].add(clone(e).children...)
+			}
+		}
+		if keepCS && i == 0 {
+			m.defaultCS = clone(d.defaultCS)
+		}
+
+		for _, sig := range d.Signatures {
+			sig.Source = i
+			m.Signatures = append(m.Signatures, sig)
+		}
+		for _, w := range d.Warnings {
+			m.Warnings = append(m.Warnings, fmt.Sprintf("第 %d 个文档: %s", i+1, w))
+		}
+		m.maxID = offset + d.maxID
+	}
+	return m, nil
+}
+
+// colorElems 类型为 CT_Color 的元素
+var colorElems = map[string]bool{
+	"FillColor": true, "StrokeColor": true, "BorderColor": true, "BackColor": true, "Color": true,
+}
+
+// setColorSpace 为元素及其后代中未指定颜色空间的颜色设置颜色空间 cs
+func setColorSpace(n *node, cs string) {
+	n.walk(func(e *node) {
+		if colorElems[e.name] && e.attr("ColorSpace") == "" {
+			e.setAttr("ColorSpace", cs)
+		}
+	})
+}
+
+// resKey 返回去掉 ID 后的资源内容，引用的文件按内容命名，内容相同的资源 key 相同
+func resKey(e *node) string {
+	c := e.clone()
+	c.removeAttr("ID")
+	return string(c.marshal())
+}
+
+// derivedDocInfo 返回由原文档生成的新文档的文档信息：生成新的 DocID，
+// 并去掉自定义元数据，其描述的是原文件，如电子发票的发票号码
+func derivedDocInfo(info *node) *node {
+	info = info.clone()
+	setDocID(info)
+	info.removeAll("CustomDatas")
+	return info
+}
+
+// setDocID 为文档信息生成新的 DocID
+func setDocID(info *node) {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+	if e := info.child("DocID"); e != nil {
+		e.text = id
+		return
+	}
+	info.children = append([]*node{textNode("DocID", id)}, info.children...)
+}
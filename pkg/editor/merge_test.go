@@ -0,0 +1,236 @@
+package editor
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/zc310/ofd/pkg/converter"
+	"github.com/zc310/ofd/pkg/validator"
+)
+
+func open(t *testing.T, name string) *Document {
+	d, err := Open("../../test/testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// save 保存文档并返回文件数据
+func save(t *testing.T, d *Document) []byte {
+	var buf bytes.Buffer
+	if err := d.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// findings 返回各规则的问题数
+func findings(t *testing.T, data []byte) map[validator.Rule]int {
+	res, err := validator.Validate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[validator.Rule]int)
+	for _, f := range res.Findings {
+		m[f.Rule]++
+	}
+	return m
+}
+
+func TestMerge(t *testing.T) {
+	a, b := open(t, "999.ofd"), open(t, "helloworld.ofd")
+	m, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := save(t, m)
+
+	info, err := converter.Info(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Pages) != 7 {
+		t.Fatalf("pages = %d", len(info.Pages))
+	}
+	// helloworld 的页面没有 Area，使用其文档的默认大小而不是合并后文档的默认大小
+	if p := info.Pages[0]; p.Width != 210 || p.Height != 140 {
+		t.Errorf("page 1 = %+v", p)
+	}
+	if p := info.Pages[5]; p.Width != 210 || p.Height != 297 || p.ID != 789+1 {
+		t.Errorf("page 6 = %+v", p)
+	}
+	if info.DocID == "050001700111_12235358" || len(info.CustomData) != 0 || info.Signatures != 0 || info.Attachments != 1 {
+		t.Errorf("info = %+v", info)
+	}
+	if len(m.Signatures) != 1 || m.Signatures[0].Provider != "KingGrid" || len(m.Signatures[0].Pages) != 1 {
+		t.Errorf("signatures = %+v", m.Signatures)
+	}
+
+	// 合并不产生新的问题：999.ofd 模板的 5 个错误引用及 helloworld.ofd 的 2 个重复 ID 原样保留，
+	// helloworld.ofd 的 MaxUnitID 错误已修正
+	got := findings(t, data)
+	want := map[validator.Rule]int{validator.RuleDanglingRef: 5, validator.RuleDuplicateID: 2}
+	for _, rule := range []validator.Rule{validator.RuleDanglingRef, validator.RuleDuplicateID, validator.RuleMaxUnitID, validator.RuleMissingPart, validator.RuleRequired} {
+		if got[rule] != want[rule] {
+			t.Errorf("%s findings = %d, want %d", rule, got[rule], want[rule])
+		}
+	}
+
+	text, err := converter.Text(data, converter.Pages(1, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains([]byte(text[0].Text), []byte("发票号码：12235358")) || text[1].Text == "" {
+		t.Errorf("text = %+v", text)
+	}
+
+	// 输入的文档不变
+	if a.NumPages() != 5 || b.NumPages() != 2 || b.pages[0].entry.attr("ID") != "1" {
+		t.Error("input modified")
+	}
+}
+
+func TestMerge_Dedupe(t *testing.T) {
+	a := open(t, "999.ofd")
+	m, err := Merge(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := make(map[string]int)
+	for _, e := range m.res {
+		count[e.name]++
+	}
+	if count["Font"] != 4 || count["MultiMedia"] != 1 || count["ColorSpace"] != 2 {
+		t.Errorf("resources = %v", count)
+	}
+	if m.NumPages() != 10 || len(m.templates) != 10 || len(m.attachments) != 2 || m.maxID != 2*789 {
+		t.Errorf("pages = %d, templates = %d, attachments = %d, max id = %d", m.NumPages(), len(m.templates), len(m.attachments), m.maxID)
+	}
+	if len(m.Signatures) != 2 || m.Signatures[1].Source != 1 {
+		t.Errorf("signatures = %+v", m.Signatures)
+	}
+
+	data := save(t, m)
+	got := findings(t, data)
+	if got[validator.RuleDuplicateID] != 0 || got[validator.RuleMissingPart] != 0 || got[validator.RuleDanglingRef] != 10 {
+		t.Errorf("findings = %v", got)
+	}
+	// 第二个文档的页面引用合并后的字体及多媒体资源，显示效果不变
+	if want, got := render(t, "../../test/testdata/999.ofd", 2), render(t, data, 7); !bytes.Equal(want, got) {
+		t.Error("page 7 differs from page 2 of 999.ofd")
+	}
+}
+
+// render 返回页面图像的像素
+func render(t *testing.T, input interface{}, page int) []byte {
+	var pix []byte
+	err := converter.Image(input, converter.Page(page), converter.DPI(30), converter.ImageWriter(func(_ int, img image.Image) error {
+		pix = img.(*image.RGBA).Pix
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pix
+}
+
+func TestRemapIDs(t *testing.T) {
+	f := func(id uint64) uint64 { return id + 10 }
+	for s, want := range map[string]string{
+		"1":     "11",
+		" 2 3 ": "12 13",
+		"x":     "x",
+		"0":     "0",
+	} {
+		if got := remapIDs(s, f); got != want {
+			t.Errorf("remapIDs(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+// colorOFD 返回只有一页的文档，页面为填满的矩形。res 为公共资源中的颜色空间，defaultCS 为默认颜色空间
+func colorOFD(t *testing.T, res, defaultCS, fill string) []byte {
+	if defaultCS != "" {
+		defaultCS = `<ofd:DefaultCS>` + defaultCS + `</ofd:DefaultCS>`
+	}
+	files := map[string]string{
+		"OFD.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:OFD xmlns:ofd="http://www.ofdspec.org/2016" Version="1.1" DocType="OFD"><ofd:DocBody><ofd:DocInfo><ofd:DocID>1</ofd:DocID></ofd:DocInfo><ofd:DocRoot>Doc_0/Document.xml</ofd:DocRoot></ofd:DocBody></ofd:OFD>`,
+		"Doc_0/Document.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:Document xmlns:ofd="http://www.ofdspec.org/2016"><ofd:CommonData><ofd:MaxUnitID>10</ofd:MaxUnitID><ofd:PageArea><ofd:PhysicalBox>0 0 20 20</ofd:PhysicalBox></ofd:PageArea><ofd:PublicRes>PublicRes.xml</ofd:PublicRes>` +
+			defaultCS + `</ofd:CommonData><ofd:Pages><ofd:Page ID="1" BaseLoc="Pages/Page_0/Content.xml"/></ofd:Pages></ofd:Document>`,
+		"Doc_0/PublicRes.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:Res xmlns:ofd="http://www.ofdspec.org/2016" BaseLoc="Res"><ofd:ColorSpaces>` + res + `</ofd:ColorSpaces></ofd:Res>`,
+		"Doc_0/Pages/Page_0/Content.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<ofd:Page xmlns:ofd="http://www.ofdspec.org/2016"><ofd:Content><ofd:Layer ID="2"><ofd:PathObject ID="3" Boundary="0 0 20 20" Fill="true" Stroke="false"><ofd:FillColor Value="` +
+			fill + `"/><ofd:AbbreviatedData>M 0 0 L 20 0 L 20 20 L 0 20 C</ofd:AbbreviatedData></ofd:PathObject></ofd:Layer></ofd:Content></ofd:Page>`,
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMerge_DefaultCS(t *testing.T) {
+	type input struct {
+		data []byte
+		rgb  []byte
+	}
+	gray := input{colorOFD(t, `<ofd:ColorSpace ID="4" Type="GRAY"/>`, "4", "128"), []byte{128, 128, 128}}
+	cmyk := input{colorOFD(t, `<ofd:ColorSpace ID="4" Type="CMYK"/>`, "4", "0 255 255 0"), []byte{255, 0, 0}}
+	rgb := input{colorOFD(t, "", "", "0 0 255"), []byte{0, 0, 255}}
+	for _, inputs := range [][]input{{gray, cmyk}, {gray, cmyk, rgb}, {rgb, cmyk}} {
+		var docs []*Document
+		for _, in := range inputs {
+			d, err := Open(in.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			docs = append(docs, d)
+		}
+		m, err := Merge(docs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 各文档都有默认颜色空间时沿用第一个文档的
+		if keep := len(inputs) == 2 && inputs[0].rgb[0] == 128; (m.defaultCS != nil) != keep {
+			t.Errorf("%d documents: DefaultCS = %v", len(inputs), m.defaultCS)
+		}
+		// 合并后各页颜色不变
+		data := save(t, m)
+		for i, in := range inputs {
+			if got := centerRGB(t, data, i+1); !bytes.Equal(got, in.rgb) {
+				t.Errorf("%d documents: page %d = %v, want %v", len(inputs), i+1, got, in.rgb)
+			}
+		}
+	}
+}
+
+// centerRGB 返回页面中心像素的颜色
+func centerRGB(t *testing.T, data []byte, page int) []byte {
+	var rgb []byte
+	err := converter.Image(data, converter.Page(page), converter.DPI(30), converter.ImageWriter(func(_ int, img image.Image) error {
+		b := img.Bounds()
+		c := img.(*image.RGBA).RGBAAt((b.Min.X+b.Max.X)/2, (b.Min.Y+b.Max.Y)/2)
+		rgb = []byte{c.R, c.G, c.B}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rgb
+}
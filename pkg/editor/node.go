@@ -0,0 +1,220 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/zc310/ofd/internal/utils"
+)
+
+// namespace OFD 命名空间，保存时所有元素统一使用 ofd 前缀
+const namespace = "http://www.ofdspec.org/2016"
+
+// node XML 元素，名称及属性名均不含命名空间前缀，space 为元素的命名空间。
+// 保留元素及属性的顺序，使页面中图元的绘制顺序及模型未定义的内容在保存后不变
+type node struct {
+	name     string
+	space    string
+	attrs    []xml.Attr
+	text     string
+	children []*node
+}
+
+func newNode(name string, attrs ...string) *node {
+	n := &node{name: name, space: namespace}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.setAttr(attrs[i], attrs[i+1])
+	}
+	return n
+}
+
+// textNode 返回只含文字的元素
+func textNode(name, text string) *node {
+	return &node{name: name, space: namespace, text: text}
+}
+
+func (n *node) attr(name string) string {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *node) setAttr(name, value string) {
+	for i, a := range n.attrs {
+		if a.Name.Local == name {
+			n.attrs[i].Value = value
+			return
+		}
+	}
+	n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
+
+func (n *node) removeAttr(name string) {
+	for i, a := range n.attrs {
+		if a.Name.Local == name {
+			n.attrs = append(n.attrs[:i], n.attrs[i+1:]...)
+			return
+		}
+	}
+}
+
+// child 返回第一个名为 name 的子元素
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// all 返回所有名为 name 的子元素
+func (n *node) all(name string) []*node {
+	var list []*node
+	for _, c := range n.children {
+		if c.name == name {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// removeAll 删除所有名为 name 的子元素
+func (n *node) removeAll(name string) {
+	children := n.children[:0]
+	for _, c := range n.children {
+		if c.name != name {
+			children = append(children, c)
+		}
+	}
+	n.children = children
+}
+
+func (n *node) add(children ...*node) *node {
+	n.children = append(n.children, children...)
+	return n
+}
+
+// walk 按文档顺序访问元素及其所有后代
+func (n *node) walk(f func(n *node)) {
+	f(n)
+	for _, c := range n.children {
+		c.walk(f)
+	}
+}
+
+func (n *node) clone() *node {
+	c := &node{name: n.name, space: n.space, text: n.text, attrs: append([]xml.Attr(nil), n.attrs...)}
+	for _, child := range n.children {
+		c.children = append(c.children, child.clone())
+	}
+	return c
+}
+
+// parseNode 读取部件 name 的根元素，格式错误时返回包装了 XMLError 的 PartError
+func parseNode(name string, data []byte) (*node, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var stack []*node
+	var root *node
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			xe := &utils.XMLError{Path: name, Err: err}
+			var se *xml.SyntaxError
+			if errors.As(err, &se) {
+				xe.Line = se.Line
+			} else {
+				xe.Line, _ = decoder.InputPos()
+			}
+			return nil, &utils.PartError{Path: name, Err: xe}
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local, space: t.Name.Space}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+					continue
+				}
+				n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Local: a.Name.Local}, Value: a.Value})
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			// 含子元素时忽略用于缩进的空白
+			if len(n.children) > 0 && strings.TrimSpace(n.text) == "" {
+				n.text = ""
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, &utils.PartError{Path: name, Err: &utils.XMLError{Path: name, Err: errors.New("没有根元素")}}
+	}
+	return root, nil
+}
+
+// marshal 返回以 n 为根元素的 XML 文档
+func (n *node) marshal() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	n.encode(&buf, true, "")
+	return buf.Bytes()
+}
+
+// encode 写入元素，OFD 命名空间的元素使用 ofd 前缀，其他命名空间在与上级的默认命名空间不同时声明。
+// def 为上级元素的默认命名空间
+func (n *node) encode(buf *bytes.Buffer, root bool, def string) {
+	name := n.name
+	if n.space == namespace {
+		name = "ofd:" + n.name
+	}
+	buf.WriteByte('<')
+	buf.WriteString(name)
+	if root {
+		buf.WriteString(` xmlns:ofd="` + namespace + `"`)
+	}
+	if n.space != namespace && n.space != def {
+		buf.WriteString(` xmlns="`)
+		_ = xml.EscapeText(buf, []byte(n.space))
+		buf.WriteByte('"')
+		def = n.space
+	}
+	for _, a := range n.attrs {
+		buf.WriteByte(' ')
+		buf.WriteString(a.Name.Local)
+		buf.WriteString(`="`)
+		_ = xml.EscapeText(buf, []byte(a.Value))
+		buf.WriteByte('"')
+	}
+	if n.text == "" && len(n.children) == 0 {
+		buf.WriteString("/>")
+		return
+	}
+	buf.WriteByte('>')
+	_ = xml.EscapeText(buf, []byte(n.text))
+	for _, c := range n.children {
+		c.encode(buf, false, def)
+	}
+	buf.WriteString("</")
+	buf.WriteString(name)
+	buf.WriteByte('>')
+}
@@ -0,0 +1,350 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// graphicUnits 带 Boundary 的图元，Boundary 为图元坐标的原点及外接矩形
+var graphicUnits = map[string]bool{"TextObject": true, "PathObject": true, "ImageObject": true, "CompositeObject": true}
+
+// checkPage 检查页码 (从 1 开始)
+func (d *Document) checkPage(i int) error {
+	if i < 1 || i > len(d.pages) {
+		return fmt.Errorf("%w: 第 %d 页，文档共 %d 页", ErrPageOutOfRange, i, len(d.pages))
+	}
+	return nil
+}
+
+// newID 返回新对象的 ID
+func (d *Document) newID() string {
+	d.maxID++
+	return strconv.FormatUint(d.maxID, 10)
+}
+
+// Reorder 按 order 重新排列页面，order 为所有页码 (从 1 开始) 的一个排列，
+// 如 Reorder(3, 1, 2) 将第 3 页移到最前面
+func (d *Document) Reorder(order ...int) error {
+	if len(order) != len(d.pages) {
+		return fmt.Errorf("页码数 %d 与页数 %d 不一致", len(order), len(d.pages))
+	}
+	pages := make([]*page, len(order))
+	seen := make(map[int]bool, len(order))
+	for i, n := range order {
+		if err := d.checkPage(n); err != nil {
+			return err
+		}
+		if seen[n] {
+			return fmt.Errorf("第 %d 页重复", n)
+		}
+		seen[n] = true
+		pages[i] = d.pages[n-1]
+	}
+	d.pages = pages
+	return nil
+}
+
+// Delete 删除页面及其注释，并去掉不再引用的模板、资源以及大纲、书签及动作中指向这些页面的部分。
+// 不能删除所有页面
+func (d *Document) Delete(pages ...int) error {
+	deleted := make(map[int]bool, len(pages))
+	for _, n := range pages {
+		if err := d.checkPage(n); err != nil {
+			return err
+		}
+		deleted[n] = true
+	}
+	if len(deleted) == len(d.pages) {
+		return errors.New("不能删除所有页面")
+	}
+	kept := d.pages[:0]
+	for i, p := range d.pages {
+		if !deleted[i+1] {
+			kept = append(kept, p)
+		}
+	}
+	d.pages = kept
+	d.prune()
+	return nil
+}
+
+// InsertBlank 在第 at 页之前插入物理区域为 box 的空白页，at 为页数加 1 时添加到最后
+func (d *Document) InsertBlank(at int, box Box) error {
+	if at != len(d.pages)+1 {
+		if err := d.checkPage(at); err != nil {
+			return err
+		}
+	}
+	if box.Width <= 0 || box.Height <= 0 {
+		return fmt.Errorf("页面大小无效: %s", box)
+	}
+	p := &page{
+		entry:   newNode("Page", "ID", d.newID()),
+		content: newNode("Page").add(newNode("Area").add(textNode("PhysicalBox", box.String()))),
+	}
+	d.pages = append(d.pages[:at-1], append([]*page{p}, d.pages[at-1:]...)...)
+	return nil
+}
+
+// Rotate 将页面顺时针旋转 angle 度，angle 应为 90 的倍数 (可以为负数)。
+//
+// 页面区域按旋转后的方向调整，页面中的图元、引用的模板及注释外观的 Boundary 随之旋转，
+// 并在各图元的 CTM 前乘以旋转矩阵；页面及图元动作的区域同样旋转。与其他页面共用的模板复制为新的模板后再旋转。
+// 注释的 Parameters 由应用程序定义，目标区域 (Dest) 在其他部件中，均保持不变
+func (d *Document) Rotate(page, angle int) error {
+	if err := d.checkPage(page); err != nil {
+		return err
+	}
+	if angle%90 != 0 {
+		return fmt.Errorf("旋转角度应为 90 的倍数: %d", angle)
+	}
+	angle = (angle%360 + 360) % 360
+	if angle == 0 {
+		return nil
+	}
+	p := d.pages[page-1]
+	area := p.content.child("Area")
+	if area == nil || area.child("PhysicalBox") == nil {
+		return fmt.Errorf("第 %d 页没有页面区域", page)
+	}
+	physical, err := parseBox(area.child("PhysicalBox").text)
+	if err != nil {
+		return err
+	}
+	m := rotation(physical, angle)
+
+	// 先检查所有需要变换的元素，出错时不修改文档
+	var targets []*node
+	for _, t := range p.content.all("Template") {
+		if tpl := d.template(t.attr("TemplateID")); tpl != nil {
+			targets = append(targets, tpl.content)
+		}
+	}
+	targets = append(targets, p.content)
+	if p.annot != nil {
+		targets = append(targets, p.annot)
+	}
+	for _, n := range targets {
+		if err = transform(n.clone(), m); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range p.content.all("Template") {
+		tpl := d.template(t.attr("TemplateID"))
+		if tpl == nil {
+			continue
+		}
+		if d.templateShared(tpl.entry.attr("ID"), p) {
+			tpl = &template{entry: tpl.entry.clone(), content: tpl.content.clone()}
+			tpl.entry.setAttr("ID", d.newID())
+			d.templates = append(d.templates, tpl)
+			t.setAttr("TemplateID", tpl.entry.attr("ID"))
+		}
+		_ = transform(tpl.content, m)
+	}
+	_ = transform(p.content, m)
+	if p.annot != nil {
+		_ = transform(p.annot, m)
+	}
+	return nil
+}
+
+// rotation 返回将页面顺时针旋转 angle 度的变换，旋转后的物理区域原点不变
+func rotation(physical Box, angle int) matrix {
+	var m matrix
+	switch angle {
+	case 90:
+		m = matrix{0, 1, -1, 0, 0, 0}
+	case 180:
+		m = matrix{-1, 0, 0, -1, 0, 0}
+	default:
+		m = matrix{0, -1, 1, 0, 0, 0}
+	}
+	b := m.box(physical)
+	m[4], m[5] = physical.X-b.X, physical.Y-b.Y
+	return m
+}
+
+// template 返回 ID 为 id 的模板
+func (d *Document) template(id string) *template {
+	for _, t := range d.templates {
+		if t.entry.attr("ID") == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// templateShared 模板是否被 p 以外的页面使用，或被 p 多次使用
+func (d *Document) templateShared(id string, p *page) bool {
+	n := 0
+	for _, q := range d.pages {
+		for _, t := range q.content.all("Template") {
+			if t.attr("TemplateID") == id {
+				if q != p {
+					return true
+				}
+				n++
+			}
+		}
+	}
+	return n > 1
+}
+
+// transform 变换页面、模板或页面注释中的页面区域、图元及注释外观
+func transform(n *node, m matrix) error {
+	if area := n.child("Area"); area != nil {
+		for _, b := range area.children {
+			box, err := parseBox(b.text)
+			if err != nil {
+				return err
+			}
+			b.text = m.box(box).String()
+		}
+	}
+	if c := n.child("Content"); c != nil {
+		if err := transformUnits(c, m, Box{}, Box{}); err != nil {
+			return err
+		}
+	}
+	if err := transformActions(n, m); err != nil {
+		return err
+	}
+	for _, annot := range n.all("Annot") {
+		a := annot.child("Appearance")
+		if a == nil {
+			continue
+		}
+		box, err := parseBox(a.attr("Boundary"))
+		if err != nil {
+			return err
+		}
+		nb := m.box(box)
+		a.setAttr("Boundary", nb.String())
+		if err = transformUnits(a, m, box, nb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transformUnits 变换 n 之下的图元 (包括图层及页块中的图元)。
+// 图元的 Boundary 相对于 base 的原点，变换后相对于 nb 的原点 (注释外观中的图元)
+func transformUnits(n *node, m matrix, base, nb Box) error {
+	for _, c := range n.children {
+		if !graphicUnits[c.name] {
+			if c.name == "Layer" || c.name == "PageBlock" {
+				if err := transformUnits(c, m, base, nb); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		box, err := parseBox(c.attr("Boundary"))
+		if err != nil {
+			return err
+		}
+		// 图元在页面中的外接矩形
+		box.X, box.Y = box.X+base.X, box.Y+base.Y
+		r := m.box(box)
+		// 图元坐标原点不再是外接矩形的原点，以 local 补偿
+		x, y := m.apply(box.X, box.Y)
+		local := matrix{m[0], m[1], m[2], m[3], x - r.X, y - r.Y}
+
+		// 位图未指定 CTM 时缩放到 Boundary
+		def := identity
+		if c.name == "ImageObject" {
+			def = matrix{box.Width, 0, 0, box.Height, 0, 0}
+		}
+		ctm, err := parseMatrix(c.attr("CTM"), def)
+		if err != nil {
+			return err
+		}
+		// 裁剪区的坐标同样相对于 Boundary 的原点，但不应用图元的 CTM
+		var areas []*node
+		var areaCTMs []matrix
+		if clips := c.child("Clips"); clips != nil {
+			for _, clip := range clips.all("Clip") {
+				for _, a := range clip.all("Area") {
+					am, err := parseMatrix(a.attr("CTM"), identity)
+					if err != nil {
+						return err
+					}
+					areas = append(areas, a)
+					areaCTMs = append(areaCTMs, am)
+				}
+			}
+		}
+
+		// 动作区域的坐标同样相对于 Boundary 的原点
+		if err = transformActions(c, local); err != nil {
+			return err
+		}
+
+		r.X, r.Y = r.X-nb.X, r.Y-nb.Y
+		c.setAttr("Boundary", r.String())
+		c.setAttr("CTM", local.mul(ctm).String())
+		for i, a := range areas {
+			a.setAttr("CTM", local.mul(areaCTMs[i]).String())
+		}
+	}
+	return nil
+}
+
+// transformActions 变换 n 的动作区域中的点，圆弧的旋转角随之调整
+func transformActions(n *node, m matrix) error {
+	actions := n.child("Actions")
+	if actions == nil {
+		return nil
+	}
+	angle := math.Atan2(m[1], m[0]) * 180 / math.Pi
+	for _, action := range actions.all("Action") {
+		region := action.child("Region")
+		if region == nil {
+			continue
+		}
+		for _, area := range region.all("Area") {
+			if err := transformPoint(area, "Start", m); err != nil {
+				return err
+			}
+			for _, c := range area.children {
+				for _, name := range []string{"Point1", "Point2", "Point3", "EndPoint"} {
+					if err := transformPoint(c, name, m); err != nil {
+						return err
+					}
+				}
+				if c.name == "Arc" {
+					a, err := strconv.ParseFloat(strings.TrimSpace(c.attr("RotationAngle")), 64)
+					if err != nil && c.attr("RotationAngle") != "" {
+						return fmt.Errorf("圆弧旋转角格式错误: %q", c.attr("RotationAngle"))
+					}
+					a = math.Mod(a+angle, 360)
+					if a < 0 {
+						a += 360
+					}
+					c.setAttr("RotationAngle", formatFloats(a))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// transformPoint 变换 ST_Pos 属性，属性不存在时不处理
+func transformPoint(n *node, name string, m matrix) error {
+	s := n.attr(name)
+	if s == "" {
+		return nil
+	}
+	v, err := parseFloats(s, 2)
+	if err != nil {
+		return fmt.Errorf("ST_Pos 格式错误: %q", s)
+	}
+	x, y := m.apply(v[0], v[1])
+	n.setAttr(name, formatFloats(x, y))
+	return nil
+}
@@ -0,0 +1,256 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/zc310/ofd/pkg/converter"
+	"github.com/zc310/ofd/pkg/validator"
+)
+
+func TestRotate(t *testing.T) {
+	d := open(t, "999.ofd")
+	if err := d.Rotate(1, 90); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Rotate(2, -180); err != nil {
+		t.Fatal(err)
+	}
+	data := save(t, d)
+	info, err := converter.Info(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := info.Pages[0]; p.Width != 140 || p.Height != 210 {
+		t.Errorf("page 1 = %+v", p)
+	}
+	if p := info.Pages[1]; p.Width != 210 || p.Height != 297 {
+		t.Errorf("page 2 = %+v", p)
+	}
+	// 只有模板中原有的错误引用
+	if got := findings(t, data); got[validator.RuleDanglingRef] != 5 || len(got) != 1 {
+		t.Errorf("findings = %v", got)
+	}
+
+	// 旋转一周后与原页面相同 (保存时不保留签名，与未修改的文档保存后比较)
+	orig := save(t, open(t, "999.ofd"))
+	for i := 0; i < 3; i++ {
+		if err = d.Rotate(1, 90); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = d.Rotate(2, 180); err != nil {
+		t.Fatal(err)
+	}
+	data = save(t, d)
+	for _, page := range []int{1, 2} {
+		if want, got := render(t, orig, page), render(t, data, page); !bytes.Equal(want, got) {
+			t.Errorf("page %d differs after full rotation", page)
+		}
+	}
+}
+
+func TestRotate_SharedTemplate(t *testing.T) {
+	d := open(t, "999.ofd")
+	d.pages[1].content.child("Template").setAttr("TemplateID", "9")
+	orig := save(t, d)
+	n := len(d.templates)
+	if err := d.Rotate(1, 270); err != nil {
+		t.Fatal(err)
+	}
+	id := d.pages[0].content.child("Template").attr("TemplateID")
+	if len(d.templates) != n+1 || id == "9" || d.pages[1].content.child("Template").attr("TemplateID") != "9" {
+		t.Errorf("templates = %d, page 1 template = %s", len(d.templates), id)
+	}
+	if want, got := render(t, orig, 2), render(t, save(t, d), 2); !bytes.Equal(want, got) {
+		t.Error("shared template modified")
+	}
+}
+
+func TestRotate_Annotations(t *testing.T) {
+	d := open(t, "ano.ofd")
+	before := d.pages[0].annot.all("Annot")[0].child("Appearance").attr("Boundary")
+	if err := d.Rotate(1, 180); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.pages[0].annot.all("Annot")[0].child("Appearance").attr("Boundary"); got == before {
+		t.Errorf("appearance boundary not rotated: %s", got)
+	}
+	if err := d.Rotate(1, 180); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := render(t, "../../test/testdata/ano.ofd", 1), render(t, save(t, d), 1); !bytes.Equal(want, got) {
+		t.Error("page 1 differs after full rotation")
+	}
+}
+
+func TestRotate_Actions(t *testing.T) {
+	d := open(t, "helloworld.ofd")
+	region := func() *node {
+		return newNode("Actions").add(newNode("Action", "Event", "CLICK").add(newNode("Region").add(
+			newNode("Area", "Start", "10 20").add(
+				newNode("Line", "Point1", "30 20"),
+				newNode("Arc", "EndPoint", "30 40", "EllipseSize", "10 20", "RotationAngle", "300"),
+				newNode("Close")))))
+	}
+	p := d.pages[0]
+	p.content.add(region())
+	var unit *node
+	p.content.walk(func(n *node) {
+		if n.attr("ID") == "10005" {
+			unit = n
+		}
+	})
+	unit.add(region())
+	if err := d.Rotate(1, 90); err != nil {
+		t.Fatal(err)
+	}
+
+	// 页面为 210x297，(x, y) 旋转为 (297 - y, x)
+	area := p.content.child("Actions").child("Action").child("Region").child("Area")
+	arc := area.child("Arc")
+	if got := []string{area.attr("Start"), area.child("Line").attr("Point1"), arc.attr("EndPoint"), arc.attr("RotationAngle"), arc.attr("EllipseSize")}; got[0] != "277 10" ||
+		got[1] != "277 30" || got[2] != "257 30" || got[3] != "30" || got[4] != "10 20" {
+		t.Errorf("page region = %v", got)
+	}
+	// 图元动作区域相对于 Boundary 的原点：外接矩形 10 10 140 40 旋转为 247 10 40 140
+	area = unit.child("Actions").child("Action").child("Region").child("Area")
+	if got := []string{unit.attr("Boundary"), area.attr("Start"), area.child("Line").attr("Point1")}; got[0] != "247 10 40 140" ||
+		got[1] != "20 10" || got[2] != "20 30" {
+		t.Errorf("unit region = %v", got)
+	}
+
+	// 旋转一周后恢复原值
+	for i := 0; i < 3; i++ {
+		if err := d.Rotate(1, 90); err != nil {
+			t.Fatal(err)
+		}
+	}
+	area = p.content.child("Actions").child("Action").child("Region").child("Area")
+	if got := area.attr("Start") + ", " + area.child("Arc").attr("EndPoint") + ", " + area.child("Arc").attr("RotationAngle"); got != "10 20, 30 40, 300" {
+		t.Errorf("page region = %s", got)
+	}
+}
+
+func TestRotate_Errors(t *testing.T) {
+	d := open(t, "helloworld.ofd")
+	if err := d.Rotate(1, 45); err == nil {
+		t.Error("angle 45: no error")
+	}
+	if err := d.Rotate(0, 90); !errors.Is(err, ErrPageOutOfRange) {
+		t.Errorf("page 0: %v", err)
+	}
+}
+
+func TestReorder(t *testing.T) {
+	d := open(t, "999.ofd")
+	if err := d.Reorder(3, 1, 2, 5, 4); err != nil {
+		t.Fatal(err)
+	}
+	info, err := converter.Info(save(t, d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []uint64{271, 10, 92, 629, 450} {
+		if info.Pages[i].ID != id {
+			t.Errorf("page %d = %d, want %d", i+1, info.Pages[i].ID, id)
+		}
+	}
+	if err = d.Reorder(1, 2, 3); err == nil {
+		t.Error("too few pages: no error")
+	}
+	if err = d.Reorder(1, 1, 2, 3, 4); err == nil {
+		t.Error("duplicate page: no error")
+	}
+	if err = d.Reorder(1, 2, 3, 4, 6); !errors.Is(err, ErrPageOutOfRange) {
+		t.Errorf("out of range: %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	d := open(t, "999.ofd")
+	if err := d.Delete(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if d.NumPages() != 3 || len(d.templates) != 3 {
+		t.Errorf("pages = %d, templates = %d", d.NumPages(), len(d.templates))
+	}
+	// 第 1 页的图像及注释随页面删除
+	for _, r := range d.res {
+		if r.name == "MultiMedia" {
+			t.Errorf("unused resource %s %s", r.name, r.attr("ID"))
+		}
+	}
+	for _, p := range d.pages {
+		if p.annot != nil {
+			t.Error("annotation not deleted")
+		}
+	}
+	data := save(t, d)
+	if got := findings(t, data); got[validator.RuleDanglingRef] != 3 || len(got) != 1 {
+		t.Errorf("findings = %v", got)
+	}
+	if want, got := render(t, "../../test/testdata/999.ofd", 4), render(t, data, 2); !bytes.Equal(want, got) {
+		t.Error("page 2 differs from page 4 of 999.ofd")
+	}
+
+	if err := d.Delete(1, 2, 3); err == nil {
+		t.Error("delete all pages: no error")
+	}
+	if err := d.Delete(4); !errors.Is(err, ErrPageOutOfRange) {
+		t.Errorf("out of range: %v", err)
+	}
+	if d.NumPages() != 3 {
+		t.Error("document modified on error")
+	}
+}
+
+func TestDelete_Annotations(t *testing.T) {
+	d := open(t, "ano.ofd")
+	if err := d.Delete(2, 3); err != nil {
+		t.Fatal(err)
+	}
+	// 指向已删除页面的链接被去掉
+	if got := findings(t, save(t, d)); len(got) != 0 {
+		t.Errorf("findings = %v", got)
+	}
+}
+
+func TestInsertBlank(t *testing.T) {
+	d := open(t, "helloworld.ofd")
+	if err := d.InsertBlank(1, Box{Width: 100, Height: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.InsertBlank(4, Box{Width: 297, Height: 210}); err != nil {
+		t.Fatal(err)
+	}
+	data := save(t, d)
+	info, err := converter.Info(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Pages) != 4 {
+		t.Fatalf("pages = %d", len(info.Pages))
+	}
+	if p := info.Pages[0]; p.Width != 100 || p.Height != 50 {
+		t.Errorf("page 1 = %+v", p)
+	}
+	if p := info.Pages[3]; p.Width != 297 || p.Height != 210 || p.ID != info.Pages[0].ID+1 {
+		t.Errorf("page 4 = %+v", p)
+	}
+	if want, got := render(t, "../../test/testdata/helloworld.ofd", 1), render(t, data, 2); !bytes.Equal(want, got) {
+		t.Error("page 2 differs from page 1 of helloworld.ofd")
+	}
+	// 只有 helloworld.ofd 原有的重复 ID
+	if got := findings(t, data); got[validator.RuleDuplicateID] != 2 || len(got) != 1 {
+		t.Errorf("findings = %v", got)
+	}
+
+	if err = d.InsertBlank(6, Box{Width: 100, Height: 100}); !errors.Is(err, ErrPageOutOfRange) {
+		t.Errorf("out of range: %v", err)
+	}
+	if err = d.InsertBlank(1, Box{Width: 100}); err == nil {
+		t.Error("empty box: no error")
+	}
+}
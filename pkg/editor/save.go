@@ -0,0 +1,205 @@
+package editor
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resGroups 资源元素所在的分组，按 CT_Res 中的顺序排列
+var resGroups = []struct {
+	group, name string
+	// public 是否保存在公共资源文件中，否则保存在文档资源文件中
+	public bool
+}{
+	{"ColorSpaces", "ColorSpace", true},
+	{"DrawParams", "DrawParam", true},
+	{"Fonts", "Font", true},
+	{"MultiMedias", "MultiMedia", false},
+	{"CompositeGraphicUnits", "CompositeGraphicUnit", false},
+}
+
+// docDir 保存时文档所在的目录
+const docDir = "Doc_0"
+
+// Save 将文档保存为 OFD 文件
+func (d *Document) Save(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	s := &saver{zw: zw, modified: time.Now()}
+	d.save(s)
+	if s.err != nil {
+		return s.err
+	}
+	return zw.Close()
+}
+
+// SaveFile 将文档保存到文件 name
+func (d *Document) SaveFile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err = d.Save(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type saver struct {
+	zw       *zip.Writer
+	modified time.Time
+	err      error
+}
+
+// write 写入部件，出错后忽略之后的部件
+func (s *saver) write(name string, data []byte) {
+	if s.err != nil {
+		return
+	}
+	w, err := s.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: s.modified})
+	if err == nil {
+		_, err = w.Write(data)
+	}
+	if err != nil {
+		s.err = fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+}
+
+func (d *Document) save(s *saver) {
+	version := d.version
+	if version == "" {
+		version = "1.0"
+	}
+	ofd := newNode("OFD", "Version", version, "DocType", "OFD")
+	ofd.add(newNode("DocBody").add(d.docInfo, textNode("DocRoot", docDir+"/Document.xml")))
+	s.write("OFD.xml", ofd.marshal())
+
+	common := newNode("CommonData").add(textNode("MaxUnitID", strconv.FormatUint(d.maxID, 10)))
+	common.add(d.common...)
+	for _, f := range []struct {
+		elem, name string
+		public     bool
+	}{{"PublicRes", "PublicRes.xml", true}, {"DocumentRes", "DocumentRes.xml", false}} {
+		if res := d.resFile(f.public); res != nil {
+			s.write(docDir+"/"+f.name, res.marshal())
+			common.add(textNode(f.elem, f.name))
+		}
+	}
+	for i, t := range d.templates {
+		loc := fmt.Sprintf("Tpls/Tpl_%d/Content.xml", i)
+		entry := t.entry.clone()
+		entry.setAttr("BaseLoc", loc)
+		common.add(entry)
+		s.write(docDir+"/"+loc, t.content.marshal())
+	}
+	if d.defaultCS != nil {
+		common.add(d.defaultCS)
+	}
+
+	pages := newNode("Pages")
+	annots := newNode("Annotations")
+	for i, p := range d.pages {
+		loc := fmt.Sprintf("Pages/Page_%d/Content.xml", i)
+		entry := p.entry.clone()
+		entry.setAttr("BaseLoc", loc)
+		pages.add(entry)
+		s.write(docDir+"/"+loc, p.content.marshal())
+		if p.annot != nil && len(p.annot.children) > 0 {
+			loc = fmt.Sprintf("Page_%d/Annotation.xml", i)
+			annots.add(newNode("Page", "PageID", p.entry.attr("ID")).add(textNode("FileLoc", loc)))
+			s.write(docDir+"/Annots/"+loc, p.annot.marshal())
+		}
+	}
+
+	doc := newNode("Document").add(common, pages)
+	for _, name := range extraNames {
+		if e := d.extras[name]; e != nil {
+			doc.add(e)
+		}
+	}
+	if len(annots.children) > 0 {
+		s.write(docDir+"/Annots/Annotations.xml", annots.marshal())
+		doc.add(textNode("Annotations", "Annots/Annotations.xml"))
+	}
+	if len(d.attachments) > 0 {
+		list := newNode("Attachments")
+		names := make(map[string]bool)
+		for _, a := range d.attachments {
+			name := uniqueName(a.name, names)
+			entry := a.entry.clone()
+			entry.removeAll("FileLoc")
+			entry.add(textNode("FileLoc", name))
+			list.add(entry)
+			s.write(docDir+"/Attachs/"+name, a.data)
+		}
+		s.write(docDir+"/Attachs/Attachments.xml", list.marshal())
+		doc.add(textNode("Attachments", "Attachs/Attachments.xml"))
+	}
+	s.write(docDir+"/Document.xml", doc.marshal())
+
+	for _, name := range d.resFiles() {
+		s.write(docDir+"/Res/"+name, d.files[name])
+	}
+}
+
+// resFile 返回公共资源或文档资源文件，没有资源时返回 nil
+func (d *Document) resFile(public bool) *node {
+	res := newNode("Res", "BaseLoc", "Res")
+	for _, g := range resGroups {
+		if g.public != public {
+			continue
+		}
+		group := newNode(g.group)
+		for _, e := range d.res {
+			if e.name == g.name {
+				group.add(e)
+			}
+		}
+		if len(group.children) > 0 {
+			res.add(group)
+		}
+	}
+	if len(res.children) == 0 {
+		return nil
+	}
+	return res
+}
+
+// resFiles 返回资源引用的文件，按引用顺序排列
+func (d *Document) resFiles() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, e := range d.res {
+		e.walk(func(e *node) {
+			var name string
+			switch e.name {
+			case "FontFile", "MediaFile":
+				name = e.text
+			case "ColorSpace":
+				name = e.attr("Profile")
+			}
+			if _, ok := d.files[name]; ok && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		})
+	}
+	return names
+}
+
+// uniqueName 返回不与 names 中重复的文件名，重复时在扩展名前加序号
+func uniqueName(name string, names map[string]bool) string {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 2; names[name]; i++ {
+		name = stem + "_" + strconv.Itoa(i) + ext
+	}
+	names[name] = true
+	return name
+}
@@ -0,0 +1,107 @@
+package validator
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// element XML 元素，名称及属性名均不含命名空间前缀
+type element struct {
+	name     string
+	attrs    map[string]string
+	text     string
+	line     int
+	children []*element
+}
+
+// child 返回第一个名为 name 的子元素
+func (e *element) child(name string) *element {
+	for _, c := range e.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// all 返回所有名为 name 的子元素
+func (e *element) all(name string) []*element {
+	var list []*element
+	for _, c := range e.children {
+		if c.name == name {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// walk 按文档顺序访问元素及其所有后代
+func (e *element) walk(f func(e *element)) {
+	f(e)
+	for _, c := range e.children {
+		c.walk(f)
+	}
+}
+
+// syntaxError 带行号的 XML 解析错误
+type syntaxError struct {
+	line int
+	err  error
+}
+
+func (e *syntaxError) Error() string {
+	return e.err.Error()
+}
+
+// parseElement 读取 XML 文档的根元素
+func parseElement(r io.Reader) (*element, error) {
+	decoder := xml.NewDecoder(r)
+	var stack []*element
+	var root *element
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line, _ := decoder.InputPos()
+			var se *xml.SyntaxError
+			if errors.As(err, &se) {
+				line = se.Line
+			}
+			return nil, &syntaxError{line: line, err: err}
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			line, _ := decoder.InputPos()
+			e := &element{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr)), line: line}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+					continue
+				}
+				e.attrs[attr.Name.Local] = attr.Value
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			} else if root == nil {
+				root = e
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			e := stack[len(stack)-1]
+			e.text = strings.TrimSpace(e.text)
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, &syntaxError{err: errors.New("没有根元素")}
+	}
+	return root, nil
+}
@@ -0,0 +1,161 @@
+package validator
+
+// partKind 部件类型
+type partKind int
+
+const (
+	partOFD partKind = iota
+	partDocument
+	partRes
+	// partPage 页面及模板页内容
+	partPage
+	partAnnotations
+	partPageAnnot
+	partSignatures
+	partSignature
+	partAttachments
+	partCustomTags
+)
+
+// inDocument 部件中的 ID 及引用是否属于文档的 ID 空间 (签名、附件等使用各自的标识)
+func (k partKind) inDocument() bool {
+	switch k {
+	case partDocument, partRes, partPage, partPageAnnot:
+		return true
+	}
+	return false
+}
+
+// rootNames 各类部件的根元素
+var rootNames = map[partKind]string{
+	partOFD:         "OFD",
+	partDocument:    "Document",
+	partRes:         "Res",
+	partPage:        "Page",
+	partAnnotations: "Annotations",
+	partPageAnnot:   "PageAnnot",
+	partSignatures:  "Signatures",
+	partSignature:   "Signature",
+	partAttachments: "Attachments",
+	partCustomTags:  "CustomTags",
+}
+
+// requirement 元素必需的属性及子元素，severity 为空时按 error 处理
+type requirement struct {
+	attrs    []string
+	children []string
+	severity Severity
+}
+
+// idElements 定义文档内对象的元素，其 ID 属于文档的 ID 空间
+var idElements = map[string]bool{
+	"Page": true, "TemplatePage": true,
+	"Font": true, "MultiMedia": true, "DrawParam": true, "ColorSpace": true, "CompositeGraphicUnit": true,
+	"Layer": true, "PageBlock": true, "TextObject": true, "PathObject": true, "ImageObject": true, "CompositeObject": true,
+	"Annot": true,
+}
+
+// objectRequirements 页面、模板、复合对象及注释外观中的图元
+var objectRequirements = map[string]requirement{
+	"Layer":           {attrs: []string{"ID"}},
+	"PageBlock":       {attrs: []string{"ID"}},
+	"TextObject":      {attrs: []string{"ID", "Boundary", "Font", "Size"}, children: []string{"TextCode"}},
+	"PathObject":      {attrs: []string{"ID", "Boundary"}, children: []string{"AbbreviatedData"}},
+	"ImageObject":     {attrs: []string{"ID", "Boundary", "ResourceID"}},
+	"CompositeObject": {attrs: []string{"ID", "Boundary", "ResourceID"}},
+	"Template":        {attrs: []string{"TemplateID"}},
+	"Pattern":         {attrs: []string{"Width", "Height"}, children: []string{"CellContent"}},
+	"AxialShd":        {attrs: []string{"StartPoint", "EndPoint"}, children: []string{"Segment"}},
+	"RadialShd":       {attrs: []string{"StartPoint", "EndPoint", "EndRadius"}, children: []string{"Segment"}},
+	"Segment":         {children: []string{"Color"}},
+}
+
+// requirements 各类部件中元素必需的属性及子元素 (GB/T 33190-2016 中 use="required" 或 minOccurs 不为 0 的项)
+var requirements = map[partKind]map[string]requirement{
+	partOFD: {
+		"OFD":     {attrs: []string{"Version", "DocType"}, children: []string{"DocBody"}},
+		"DocBody": {children: []string{"DocInfo", "DocRoot"}},
+	},
+	partDocument: {
+		"Document":     {children: []string{"CommonData", "Pages"}},
+		"CommonData":   {children: []string{"MaxUnitID", "PageArea"}},
+		"PageArea":     {children: []string{"PhysicalBox"}},
+		"Page":         {attrs: []string{"ID", "BaseLoc"}},
+		"TemplatePage": {attrs: []string{"ID", "BaseLoc"}},
+		"OutlineElem":  {attrs: []string{"Title"}},
+		"Bookmark":     {attrs: []string{"Name"}, children: []string{"Dest"}},
+		"Dest":         {attrs: []string{"Type", "PageID"}},
+	},
+	partRes: merge(objectRequirements, map[string]requirement{
+		"Font":                 {attrs: []string{"ID", "FontName"}},
+		"MultiMedia":           {attrs: []string{"ID", "Type"}, children: []string{"MediaFile"}},
+		"DrawParam":            {attrs: []string{"ID"}},
+		"ColorSpace":           {attrs: []string{"ID", "Type"}},
+		"CompositeGraphicUnit": {attrs: []string{"ID", "Width", "Height"}, children: []string{"Content"}},
+	}),
+	partPage: merge(objectRequirements, map[string]requirement{
+		"Area": {children: []string{"PhysicalBox"}},
+	}),
+	partAnnotations: {
+		"Page": {attrs: []string{"PageID"}, children: []string{"FileLoc"}},
+	},
+	partPageAnnot: merge(objectRequirements, map[string]requirement{
+		"Annot": {attrs: []string{"ID", "Type"}, children: []string{"Appearance"}},
+		"Dest":  {attrs: []string{"Type", "PageID"}},
+	}),
+	partSignatures: {
+		"Signature": {attrs: []string{"ID", "BaseLoc"}},
+	},
+	partSignature: {
+		"Signature":  {children: []string{"SignedInfo", "SignedValue"}},
+		"SignedInfo": {children: []string{"Provider", "SignatureMethod", "References"}},
+		"Reference":  {attrs: []string{"FileRef"}, children: []string{"CheckValue"}},
+		"StampAnnot": {attrs: []string{"ID", "PageRef", "Boundary"}},
+	},
+	partAttachments: {
+		"Attachment": {attrs: []string{"ID", "Name"}, children: []string{"FileLoc"}},
+	},
+	partCustomTags: {
+		// 电子发票等行业标准常以 TypeID 代替 NameSpace，不影响显示
+		"CustomTag": {attrs: []string{"NameSpace"}, children: []string{"FileLoc"}, severity: SeverityWarning},
+	},
+}
+
+func merge(a, b map[string]requirement) map[string]requirement {
+	m := make(map[string]requirement, len(a)+len(b))
+	for k, v := range a {
+		m[k] = v
+	}
+	for k, v := range b {
+		m[k] = v
+	}
+	return m
+}
+
+// attrRef 以属性表示的引用，elem 为 * 时适用于所有元素
+type attrRef struct {
+	elem, attr string
+	kinds      []string
+}
+
+// attrRefs 文档中的 ST_RefID 属性及其应引用的对象 (以定义对象的元素名表示)
+var attrRefs = []attrRef{
+	{"TextObject", "Font", []string{"Font"}},
+	{"*", "DrawParam", []string{"DrawParam"}},
+	{"DrawParam", "Relative", []string{"DrawParam"}},
+	{"ImageObject", "ResourceID", []string{"MultiMedia"}},
+	{"ImageObject", "Substitution", []string{"MultiMedia"}},
+	{"ImageObject", "ImageMask", []string{"MultiMedia"}},
+	{"CompositeObject", "ResourceID", []string{"CompositeGraphicUnit"}},
+	{"Template", "TemplateID", []string{"TemplatePage"}},
+	{"*", "ColorSpace", []string{"ColorSpace"}},
+	{"CellContent", "Thumbnail", []string{"MultiMedia"}},
+	{"Dest", "PageID", []string{"Page"}},
+}
+
+// textRefs 以元素内容表示的引用，键为 父元素/元素
+var textRefs = map[string][]string{
+	"CommonData/DefaultCS":              {"ColorSpace"},
+	"CompositeGraphicUnit/Thumbnail":    {"MultiMedia"},
+	"CompositeGraphicUnit/Substitution": {"MultiMedia"},
+}
@@ -0,0 +1,459 @@
+// Package validator 按 GB/T 33190-2016 的结构规则检查 OFD 文件，输出可供程序处理的问题列表
+package validator
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/zc310/ofd/internal/models"
+	"github.com/zc310/ofd/internal/utils"
+)
+
+// Rule 校验规则
+type Rule string
+
+const (
+	// RuleXML 部件不是格式正确的 XML
+	RuleXML Rule = "xml"
+	// RuleRequired 缺少必需的元素或属性
+	RuleRequired Rule = "required"
+	// RuleInvalidValue 属性或元素的值不合法，如 ID 不是正整数
+	RuleInvalidValue Rule = "invalid-value"
+	// RuleDuplicateID 文档内 ID 重复
+	RuleDuplicateID Rule = "duplicate-id"
+	// RuleMaxUnitID 文档中的 ID 大于 MaxUnitID
+	RuleMaxUnitID Rule = "max-unit-id"
+	// RuleDanglingRef 引用 (StRefID) 的对象不存在或类型不符
+	RuleDanglingRef Rule = "dangling-ref"
+	// RuleMissingPart 引用的文件 (StLoc) 在包中不存在
+	RuleMissingPart Rule = "missing-part"
+	// RuleAbbreviatedData 路径的 AbbreviatedData 无法解析
+	RuleAbbreviatedData Rule = "abbreviated-data"
+)
+
+// Severity 问题的严重程度
+type Severity string
+
+const (
+	// SeverityError 不符合标准，可能导致无法正确显示
+	SeverityError Severity = "error"
+	// SeverityWarning 不符合标准，但一般不影响显示
+	SeverityWarning Severity = "warning"
+)
+
+// Finding 校验发现的问题
+type Finding struct {
+	Rule     Rule     `json:"rule"`
+	Severity Severity `json:"severity"`
+	// Path 问题所在的部件路径，Line 为行号 (未知时为 0)
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"`
+	// ID 相关的对象 ID 或引用的 ID
+	ID      uint64 `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	loc := f.Path
+	if f.Line > 0 {
+		loc += ":" + strconv.Itoa(f.Line)
+	}
+	return fmt.Sprintf("%s: %s [%s] %s", loc, f.Severity, f.Rule, f.Message)
+}
+
+// Result 校验结果
+type Result struct {
+	Findings []Finding `json:"findings"`
+}
+
+// Valid 是否没有 error 级别的问题
+func (r *Result) Valid() bool {
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate 校验 OFD 文件，input 为文件路径 (string) 或文件数据 ([]byte)。
+// 只有无法作为 ZIP 包读取时返回错误，其他问题均记入 Result
+func Validate(input interface{}) (*Result, error) {
+	var zr *zip.Reader
+	switch v := input.(type) {
+	case string:
+		rc, err := zip.OpenReader(filepath.Clean(v))
+		if err != nil {
+			return nil, fmt.Errorf("打开OFD文件失败: %w", err)
+		}
+		defer rc.Close()
+		zr = &rc.Reader
+	case []byte:
+		var err error
+		if zr, err = zip.NewReader(bytes.NewReader(v), int64(len(v))); err != nil {
+			return nil, fmt.Errorf("从字节数据创建zip reader失败: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的类型: %T, 请提供文件路径(string)或文件数据([]byte)", input)
+	}
+	v := &validator{files: utils.NewZipFileCache(zr), result: &Result{}}
+	v.validate()
+	return v.result, nil
+}
+
+// location 部件中的位置
+type location struct {
+	path string
+	line int
+}
+
+// definition 文档中定义的对象
+type definition struct {
+	kind string
+	at   location
+}
+
+// reference 文档中对对象的引用
+type reference struct {
+	id    uint64
+	kinds []string
+	at    location
+	attr  string
+}
+
+type validator struct {
+	files  *utils.ZipFileCache
+	result *Result
+
+	// 当前文档的对象及引用
+	ids  map[uint64]definition
+	refs []reference
+}
+
+func (v *validator) report(rule Rule, severity Severity, at location, id uint64, format string, args ...interface{}) {
+	v.result.Findings = append(v.result.Findings, Finding{
+		Rule:     rule,
+		Severity: severity,
+		Path:     at.path,
+		Line:     at.line,
+		ID:       id,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// exists 检查引用的文件是否存在，loc 为包内路径
+func (v *validator) exists(loc string, from location) bool {
+	if _, err := v.files.FindFile(loc); err != nil {
+		v.report(RuleMissingPart, SeverityError, from, 0, "引用的文件 %s 不存在", loc)
+		return false
+	}
+	return true
+}
+
+// load 读取 XML 部件并检查其中元素的必需属性及子元素，文档内的部件同时登记对象及引用
+func (v *validator) load(loc string, kind partKind, from location) *element {
+	zf, err := v.files.FindFile(loc)
+	if err != nil {
+		v.report(RuleMissingPart, SeverityError, from, 0, "引用的部件 %s 不存在", loc)
+		return nil
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		v.report(RuleXML, SeverityError, location{path: loc}, 0, "无法读取: %v", err)
+		return nil
+	}
+	defer rc.Close()
+	root, err := parseElement(io.LimitReader(rc, int64(zf.UncompressedSize64)+1024))
+	if err != nil {
+		line := 0
+		if xe, ok := err.(*syntaxError); ok {
+			line, err = xe.line, xe.err
+		}
+		v.report(RuleXML, SeverityError, location{path: loc, line: line}, 0, "XML 格式错误: %v", err)
+		return nil
+	}
+	if want := rootNames[kind]; root.name != want {
+		v.report(RuleRequired, SeverityError, location{path: loc, line: root.line}, 0, "根元素应为 %s，实际为 %s", want, root.name)
+		return nil
+	}
+	v.check(root, loc, kind, "")
+	return root
+}
+
+// check 递归检查元素
+func (v *validator) check(e *element, loc string, kind partKind, parent string) {
+	at := location{path: loc, line: e.line}
+	if rule, ok := requirements[kind][e.name]; ok {
+		severity := rule.severity
+		if severity == "" {
+			severity = SeverityError
+		}
+		for _, attr := range rule.attrs {
+			if strings.TrimSpace(e.attrs[attr]) == "" {
+				v.report(RuleRequired, severity, at, 0, "%s 缺少属性 %s", e.name, attr)
+			}
+		}
+		for _, child := range rule.children {
+			if e.child(child) == nil {
+				v.report(RuleRequired, severity, at, 0, "%s 缺少子元素 %s", e.name, child)
+			}
+		}
+	}
+	if kind.inDocument() {
+		if s, ok := e.attrs["ID"]; ok && idElements[e.name] {
+			if id, ok := v.parseID(s, at, "ID"); ok {
+				v.define(id, e.name, at)
+			}
+		}
+		for _, ref := range attrRefs {
+			if ref.elem != "*" && ref.elem != e.name {
+				continue
+			}
+			if s, ok := e.attrs[ref.attr]; ok {
+				v.refer(s, ref.kinds, at, e.name+"@"+ref.attr)
+			}
+		}
+		if kinds, ok := textRefs[parent+"/"+e.name]; ok {
+			v.refer(e.text, kinds, at, parent+"/"+e.name)
+		}
+		if e.name == "AbbreviatedData" {
+			if strings.TrimSpace(e.text) == "" {
+				v.report(RuleAbbreviatedData, SeverityError, at, 0, "AbbreviatedData 为空")
+			} else if _, err := models.ParsePathData(e.text); err != nil {
+				v.report(RuleAbbreviatedData, SeverityError, at, 0, "AbbreviatedData 无法解析: %v", err)
+			}
+		}
+	}
+	for _, child := range e.children {
+		v.check(child, loc, kind, e.name)
+	}
+}
+
+// parseID 解析 ST_ID/ST_RefID，应为正整数
+func (v *validator) parseID(s string, at location, name string) (uint64, bool) {
+	id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	if err != nil || id == 0 {
+		v.report(RuleInvalidValue, SeverityError, at, 0, "%s 应为正整数: %q", name, s)
+		return 0, false
+	}
+	return id, true
+}
+
+func (v *validator) define(id uint64, kind string, at location) {
+	if prev, ok := v.ids[id]; ok {
+		v.report(RuleDuplicateID, SeverityError, at, id, "%s 的 ID %d 与 %s:%d 的 %s 重复", kind, id, prev.at.path, prev.at.line, prev.kind)
+		return
+	}
+	v.ids[id] = definition{kind: kind, at: at}
+}
+
+// refer 登记引用，s 可以是以空白分隔的多个 ID
+func (v *validator) refer(s string, kinds []string, at location, attr string) {
+	for _, f := range strings.Fields(s) {
+		if id, ok := v.parseID(f, at, attr); ok {
+			v.refs = append(v.refs, reference{id: id, kinds: kinds, at: at, attr: attr})
+		}
+	}
+}
+
+// validate 校验包中的各个文档
+func (v *validator) validate() {
+	root := v.load(rootDocument, partOFD, location{path: rootDocument})
+	if root == nil {
+		return
+	}
+	if t := root.attrs["DocType"]; t != "" && t != "OFD" {
+		v.report(RuleInvalidValue, SeverityError, location{path: rootDocument, line: root.line}, 0, "DocType 应为 OFD: %q", t)
+	}
+	for _, body := range root.all("DocBody") {
+		docRoot := body.child("DocRoot")
+		if docRoot == nil || strings.TrimSpace(docRoot.text) == "" {
+			continue
+		}
+		v.document(utils.Resolve("/", docRoot.text), location{path: rootDocument, line: docRoot.line}, body)
+	}
+}
+
+// document 校验文档及其引用的部件，并检查 ID 与引用
+func (v *validator) document(loc string, from location, body *element) {
+	v.ids = make(map[uint64]definition)
+	v.refs = nil
+	doc := v.load(loc, partDocument, from)
+	if doc == nil {
+		return
+	}
+	dir := path.Dir(loc)
+	at := func(e *element) location { return location{path: loc, line: e.line} }
+
+	var maxUnitID uint64
+	var maxAt location
+	if common := doc.child("CommonData"); common != nil {
+		if e := common.child("MaxUnitID"); e != nil {
+			maxUnitID, _ = v.parseID(e.text, at(e), "MaxUnitID")
+			maxAt = at(e)
+		}
+		for _, e := range append(common.all("PublicRes"), common.all("DocumentRes")...) {
+			if strings.TrimSpace(e.text) != "" {
+				v.resource(utils.Resolve(dir, e.text), at(e))
+			}
+		}
+		for _, e := range common.all("TemplatePage") {
+			if s := e.attrs["BaseLoc"]; s != "" {
+				v.load(utils.Resolve(dir, s), partPage, at(e))
+			}
+		}
+	}
+	if pages := doc.child("Pages"); pages != nil {
+		for _, e := range pages.all("Page") {
+			if s := e.attrs["BaseLoc"]; s != "" {
+				v.load(utils.Resolve(dir, s), partPage, at(e))
+			}
+		}
+	}
+	if e := doc.child("Annotations"); e != nil && strings.TrimSpace(e.text) != "" {
+		v.annotations(utils.Resolve(dir, e.text), at(e))
+	}
+	if e := doc.child("Attachments"); e != nil && strings.TrimSpace(e.text) != "" {
+		v.fileList(utils.Resolve(dir, e.text), partAttachments, "Attachment", at(e))
+	}
+	if e := doc.child("CustomTags"); e != nil && strings.TrimSpace(e.text) != "" {
+		v.fileList(utils.Resolve(dir, e.text), partCustomTags, "CustomTag", at(e))
+	}
+	if e := doc.child("Extensions"); e != nil && strings.TrimSpace(e.text) != "" {
+		v.exists(utils.Resolve(dir, e.text), at(e))
+	}
+	if e := body.child("Signatures"); e != nil && strings.TrimSpace(e.text) != "" {
+		v.signatures(utils.Resolve("/", e.text), location{path: rootDocument, line: e.line})
+	}
+
+	v.checkRefs()
+	var maxID uint64
+	var maxDef definition
+	for id, def := range v.ids {
+		if id > maxID {
+			maxID, maxDef = id, def
+		}
+	}
+	if maxUnitID > 0 && maxID > maxUnitID {
+		v.report(RuleMaxUnitID, SeverityError, maxAt, maxID, "MaxUnitID %d 小于文档中最大的 ID %d (%s:%d 的 %s)",
+			maxUnitID, maxID, maxDef.at.path, maxDef.at.line, maxDef.kind)
+	}
+}
+
+// checkRefs 检查当前文档中的引用
+func (v *validator) checkRefs() {
+	for _, ref := range v.refs {
+		def, ok := v.ids[ref.id]
+		if !ok {
+			v.report(RuleDanglingRef, SeverityError, ref.at, ref.id, "%s 引用的 %s %d 不存在", ref.attr, strings.Join(ref.kinds, "/"), ref.id)
+			continue
+		}
+		matched := false
+		for _, kind := range ref.kinds {
+			matched = matched || kind == def.kind
+		}
+		if !matched {
+			v.report(RuleDanglingRef, SeverityError, ref.at, ref.id, "%s 引用的 %d 是 %s，应为 %s", ref.attr, ref.id, def.kind, strings.Join(ref.kinds, "/"))
+		}
+	}
+}
+
+// resource 校验资源文件及其中引用的文件，相对路径基于资源文件的 BaseLoc
+func (v *validator) resource(loc string, from location) {
+	res := v.load(loc, partRes, from)
+	if res == nil {
+		return
+	}
+	base := path.Dir(loc)
+	if s := res.attrs["BaseLoc"]; s != "" {
+		base = utils.Resolve(base, s)
+	}
+	res.walk(func(e *element) {
+		at := location{path: loc, line: e.line}
+		switch e.name {
+		case "MediaFile", "FontFile":
+			if strings.TrimSpace(e.text) != "" {
+				v.exists(utils.Resolve(base, e.text), at)
+			}
+		case "ColorSpace":
+			if s := e.attrs["Profile"]; s != "" {
+				v.exists(utils.Resolve(base, s), at)
+			}
+		}
+	})
+}
+
+// annotations 校验注释索引及各页面的注释文件
+func (v *validator) annotations(loc string, from location) {
+	list := v.load(loc, partAnnotations, from)
+	if list == nil {
+		return
+	}
+	for _, page := range list.all("Page") {
+		if id := page.attrs["PageID"]; id != "" {
+			v.refer(id, []string{"Page"}, location{path: loc, line: page.line}, "Page@PageID")
+		}
+		if e := page.child("FileLoc"); e != nil && strings.TrimSpace(e.text) != "" {
+			v.load(utils.Resolve(path.Dir(loc), e.text), partPageAnnot, location{path: loc, line: e.line})
+		}
+	}
+}
+
+// fileList 校验附件或自定义标签列表中引用的文件
+func (v *validator) fileList(loc string, kind partKind, name string, from location) {
+	list := v.load(loc, kind, from)
+	if list == nil {
+		return
+	}
+	for _, item := range list.all(name) {
+		if e := item.child("FileLoc"); e != nil && strings.TrimSpace(e.text) != "" {
+			v.exists(utils.Resolve(path.Dir(loc), e.text), location{path: loc, line: e.line})
+		}
+	}
+}
+
+// signatures 校验签名列表及各签名文件引用的文件，签章所在页面记入当前文档的引用
+func (v *validator) signatures(loc string, from location) {
+	list := v.load(loc, partSignatures, from)
+	if list == nil {
+		return
+	}
+	for _, item := range list.all("Signature") {
+		s := item.attrs["BaseLoc"]
+		if s == "" {
+			continue
+		}
+		sigLoc := utils.Resolve(path.Dir(loc), s)
+		sig := v.load(sigLoc, partSignature, location{path: loc, line: item.line})
+		if sig == nil {
+			continue
+		}
+		dir := path.Dir(sigLoc)
+		sig.walk(func(e *element) {
+			at := location{path: sigLoc, line: e.line}
+			switch e.name {
+			case "Reference":
+				if s := e.attrs["FileRef"]; s != "" {
+					v.exists(utils.Resolve(dir, s), at)
+				}
+			case "BaseLoc", "SignedValue":
+				if strings.TrimSpace(e.text) != "" {
+					v.exists(utils.Resolve(dir, e.text), at)
+				}
+			case "StampAnnot":
+				if s := e.attrs["PageRef"]; s != "" {
+					v.refer(s, []string{"Page"}, at, "StampAnnot@PageRef")
+				}
+			}
+		})
+	}
+}
+
+// rootDocument 包的入口文件
+const rootDocument = "OFD.xml"
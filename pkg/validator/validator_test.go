@@ -0,0 +1,126 @@
+package validator
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const ns = `xmlns:ofd="http://www.ofdspec.org/2016"`
+
+// minimal 最小的合法 OFD 包
+func minimal() map[string]string {
+	return map[string]string{
+		"OFD.xml": `<ofd:OFD ` + ns + ` Version="1.0" DocType="OFD"><ofd:DocBody>
+<ofd:DocInfo><ofd:DocID>1</ofd:DocID></ofd:DocInfo>
+<ofd:DocRoot>Doc_0/Document.xml</ofd:DocRoot>
+</ofd:DocBody></ofd:OFD>`,
+		"Doc_0/Document.xml": `<ofd:Document ` + ns + `>
+<ofd:CommonData><ofd:MaxUnitID>6</ofd:MaxUnitID>
+<ofd:PageArea><ofd:PhysicalBox>0 0 210 297</ofd:PhysicalBox></ofd:PageArea>
+<ofd:PublicRes>PublicRes.xml</ofd:PublicRes>
+</ofd:CommonData>
+<ofd:Pages><ofd:Page ID="1" BaseLoc="Pages/Page_0/Content.xml"/></ofd:Pages>
+</ofd:Document>`,
+		"Doc_0/PublicRes.xml": `<ofd:Res ` + ns + ` BaseLoc="Res">
+<ofd:Fonts><ofd:Font ID="2" FontName="宋体"/></ofd:Fonts>
+<ofd:MultiMedias><ofd:MultiMedia ID="3" Type="Image"><ofd:MediaFile>image.png</ofd:MediaFile></ofd:MultiMedia></ofd:MultiMedias>
+</ofd:Res>`,
+		"Doc_0/Res/image.png": "",
+		"Doc_0/Pages/Page_0/Content.xml": `<ofd:Page ` + ns + `><ofd:Content><ofd:Layer ID="4">
+<ofd:TextObject ID="5" Boundary="0 0 10 10" Font="2" Size="3"><ofd:TextCode X="0" Y="3">A</ofd:TextCode></ofd:TextObject>
+<ofd:ImageObject ID="6" Boundary="0 0 10 10" ResourceID="3"/>
+</ofd:Layer></ofd:Content></ofd:Page>`,
+	}
+}
+
+func pack(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestValidate(t *testing.T) {
+	r, err := Validate(pack(t, minimal()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Valid() || len(r.Findings) > 0 {
+		t.Fatalf("findings = %v", r.Findings)
+	}
+
+	const page = "Doc_0/Pages/Page_0/Content.xml"
+	tests := []struct {
+		name          string
+		file, old, to string
+		rule          Rule
+		path          string
+		line          int
+		id            uint64
+	}{
+		{"required", page, ` Size="3"`, ``, RuleRequired, page, 2, 0},
+		{"duplicate", page, `ImageObject ID="6"`, `ImageObject ID="5"`, RuleDuplicateID, page, 3, 5},
+		{"max unit id", page, `ImageObject ID="6"`, `ImageObject ID="60"`, RuleMaxUnitID, "Doc_0/Document.xml", 2, 60},
+		{"dangling font", page, `Font="2"`, `Font="9"`, RuleDanglingRef, page, 2, 9},
+		{"wrong kind", page, `ResourceID="3"`, `ResourceID="2"`, RuleDanglingRef, page, 3, 2},
+		{"missing media", "Doc_0/PublicRes.xml", `image.png`, `missing.png`, RuleMissingPart, "Doc_0/PublicRes.xml", 3, 0},
+		{"missing page", "Doc_0/Document.xml", `Page_0`, `Page_1`, RuleMissingPart, "Doc_0/Document.xml", 6, 0},
+		{"abbreviated data", page, `<ofd:ImageObject ID="6" Boundary="0 0 10 10" ResourceID="3"/>`,
+			`<ofd:PathObject ID="6" Boundary="0 0 10 10"><ofd:AbbreviatedData>M 0 L 1 1</ofd:AbbreviatedData></ofd:PathObject>`,
+			RuleAbbreviatedData, page, 3, 0},
+		{"xml", page, `</ofd:Layer>`, `</ofd:Lay>`, RuleXML, page, 4, 0},
+	}
+	for _, tt := range tests {
+		files := minimal()
+		if !strings.Contains(files[tt.file], tt.old) {
+			t.Fatalf("%s: %q not found", tt.name, tt.old)
+		}
+		files[tt.file] = strings.Replace(files[tt.file], tt.old, tt.to, 1)
+		r, err := Validate(pack(t, files))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Valid() || len(r.Findings) != 1 {
+			t.Errorf("%s: findings = %v", tt.name, r.Findings)
+			continue
+		}
+		f := r.Findings[0]
+		if f.Rule != tt.rule || f.Path != tt.path || f.Line != tt.line || f.ID != tt.id {
+			t.Errorf("%s: finding = %+v", tt.name, f)
+		}
+	}
+}
+
+func TestValidateSample(t *testing.T) {
+	r, err := Validate("../../test/testdata/999.ofd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 模板页图层的 DrawParam 引用了字体
+	want := Finding{Rule: RuleDanglingRef, Severity: SeverityError, Path: "Doc_0/Tpls/Tpl_0/Content.xml", Line: 4, ID: 4,
+		Message: "Layer@DrawParam 引用的 4 是 Font，应为 DrawParam"}
+	found := false
+	for _, f := range r.Findings {
+		found = found || f == want
+	}
+	if !found {
+		t.Errorf("findings = %v", r.Findings)
+	}
+
+	if _, err = Validate([]byte("not a zip")); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -1,16 +1,24 @@
 package test
 
 import (
+	"archive/zip"
+	"bytes"
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nao1215/imaging"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/font/gofont/gomono"
 
 	"github.com/zc310/ofd/pkg/converter"
 )
@@ -73,3 +81,300 @@ func TestRender_Image(t *testing.T) {
 		converter.PNG(),
 	))
 }
+func TestRender_Options(t *testing.T) {
+	const dpmm = 2.0
+	// 隐藏的部分与从文档中去掉该部分的渲染结果相同
+	ano := renderPages(t, "testdata/ano.ofd", dpmm)[1]
+	noAnnots := renderPages(t, patchOFD(t, "testdata/ano.ofd", "Doc_0/Document.xml", "<ofd:Annotations>Annotations.xml</ofd:Annotations>", ""), dpmm)[1]
+	assert.False(t, sameImage(ano, noAnnots))
+	assert.True(t, sameImage(noAnnots, renderPages(t, "testdata/ano.ofd", dpmm, converter.HideAnnots("Stamp"))[1]))
+	// 只隐藏其他类型的注释
+	assert.True(t, sameImage(ano, renderPages(t, "testdata/ano.ofd", dpmm, converter.HideAnnots("Watermark"))[1]))
+	// 页面只有正文图层及注释
+	blank := renderPages(t, "testdata/ano.ofd", dpmm, converter.HideLayers("Body"), converter.HideAnnots("Stamp"))[1]
+	white := image.NewRGBA(blank.Bounds())
+	draw.Draw(white, white.Bounds(), image.White, image.Point{}, draw.Src)
+	assert.True(t, sameImage(white, blank))
+
+	page := renderPages(t, "testdata/999.ofd", dpmm)[1]
+	noTemplate := renderPages(t, patchOFD(t, "testdata/999.ofd", "Doc_0/Pages/Page_0/Content.xml", `<ofd:Template TemplateID="9" ZOrder="Background" />`, ""), dpmm)[1]
+	assert.False(t, sameImage(page, noTemplate))
+	assert.True(t, sameImage(noTemplate, renderPages(t, "testdata/999.ofd", dpmm, converter.HideTemplates())[1]))
+	noSeal := renderPages(t, patchOFD(t, "testdata/999.ofd", "OFD.xml", "<ofd:Signatures>/Doc_0/Signs/Signatures.xml</ofd:Signatures>", ""), dpmm)[1]
+	assert.False(t, sameImage(page, noSeal))
+	assert.True(t, sameImage(noSeal, renderPages(t, "testdata/999.ofd", dpmm, converter.HideSeals())[1]))
+
+	// PDF 输出使用同样的选项
+	f, err := os.Create(filepath.Join(tmpDir, "ano_options.pdf"))
+	assert.Nil(t, err)
+	defer f.Close()
+	assert.Nil(t, converter.PDF("testdata/ano.ofd", f,
+		converter.HideTemplates(),
+		converter.HideLayers("Background"),
+		converter.HideAnnots("Watermark"),
+		converter.HideSeals(),
+		converter.ForPrint(),
+	))
+}
+
+// sameImage 两幅图像的大小及像素是否相同
+func sameImage(a, b image.Image) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	r := a.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if color.RGBAModel.Convert(a.At(x, y)) != color.RGBAModel.Convert(b.At(x, y)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// renderPages 以每毫米 dpmm 像素渲染全部页面，页码从 1 开始
+func renderPages(t *testing.T, input interface{}, dpmm float64, opts ...converter.Option) map[int]image.Image {
+	pages := make(map[int]image.Image)
+	assert.Nil(t, converter.Image(input, append([]converter.Option{
+		converter.ImageWriter(func(page int, img image.Image) error {
+			pages[page] = img
+			return nil
+		}),
+		converter.BgColor(color.White),
+		converter.DPI(dpmm * 25.4),
+	}, opts...)...))
+	return pages
+}
+
+// patchOFD 替换 OFD 包内文件中的内容，返回新的文档数据
+func patchOFD(t *testing.T, name, file, old, new string) []byte {
+	r, err := zip.OpenReader(name)
+	assert.Nil(t, err)
+	defer r.Close()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		assert.Nil(t, err)
+		data, err := io.ReadAll(rc)
+		assert.Nil(t, err)
+		rc.Close()
+		if f.Name == file {
+			assert.Contains(t, string(data), old)
+			data = bytes.Replace(data, []byte(old), []byte(new), 1)
+		}
+		fw, err := w.Create(f.Name)
+		assert.Nil(t, err)
+		_, err = fw.Write(data)
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+// countNear 统计 (x, y) 毫米附近 r 毫米内满足条件的像素
+func countNear(img image.Image, dpmm, x, y, r float64, f func(c color.RGBA) bool) int {
+	n := 0
+	for py := int((y - r) * dpmm); py <= int((y+r)*dpmm); py++ {
+		for px := int((x - r) * dpmm); px <= int((x+r)*dpmm); px++ {
+			if f(color.RGBAModel.Convert(img.At(px, py)).(color.RGBA)) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestRender_FontMap(t *testing.T) {
+	const dpmm = 10.0
+	dark := func(c color.RGBA) bool { return c.R < 100 && c.G < 100 && c.B < 100 }
+	assert.Nil(t, converter.RegisterFont(gomono.TTF, "OFD Render Mono"))
+	fontMap, err := converter.ParseFontMap(strings.NewReader("宋体 = missing, OFD Render Mono"))
+	assert.Nil(t, err)
+
+	// 没有系统字体时使用注册的内存字体绘制
+	pages := make(map[int]image.Image)
+	assert.Nil(t, converter.Image("testdata/999.ofd",
+		converter.ImageWriter(func(page int, img image.Image) error {
+			pages[page] = img
+			return nil
+		}),
+		converter.BgColor(color.White),
+		converter.DPI(dpmm*25.4),
+		converter.FontDirs(t.TempDir()),
+		converter.FontMap(fontMap),
+	))
+	assert.Greater(t, countNear(pages[1], dpmm, 30, 26, 2.5, dark), 0)
+}
+
+func TestRender_Diagnostics(t *testing.T) {
+	data := patchOFD(t, "testdata/999.ofd", "Doc_0/Pages/Page_0/Content.xml",
+		`ResourceID="6" Boundary="8.5 3.5 20 20"`, `ResourceID="999" Boundary="8.5 3.5 20 20"`)
+	var report converter.Report
+	assert.Nil(t, converter.PDF(data, io.Discard, converter.Diagnostics(&report)))
+	assert.Len(t, report.Pages, 5)
+	assert.True(t, report.HasIssues())
+
+	page := report.Pages[0]
+	assert.Equal(t, 1, page.Page)
+	assert.Contains(t, page.Issues, converter.Issue{Kind: converter.IssueResource, ID: 999, Message: "图像资源 999 不存在"})
+	var fonts []string
+	for _, f := range page.Fonts {
+		fonts = append(fonts, f.FontName)
+	}
+	assert.Contains(t, fonts, "宋体")
+
+	// 渐变按页面记录
+	report = converter.Report{}
+	assert.Nil(t, converter.PDF("testdata/helloworld.ofd", io.Discard, converter.Diagnostics(&report)))
+	assert.Contains(t, report.Pages[0].Features, "AxialShd")
+	assert.NotContains(t, report.Pages[1].Features, "AxialShd")
+}
+
+func TestRender_ParseMode(t *testing.T) {
+	// ano.ofd 的注释索引引用了不存在的页面注释文件
+	err := converter.PDF("testdata/ano.ofd", io.Discard, converter.Strict())
+	assert.ErrorContains(t, err, "Doc_0/Pages/Page_3/Annotation.xml")
+
+	var report converter.Report
+	assert.Nil(t, converter.PDF("testdata/ano.ofd", io.Discard, converter.Diagnostics(&report)))
+	assert.Len(t, report.Warnings, 4)
+	assert.True(t, report.HasIssues())
+
+	// 宽松模式下无法解析的页面按空白页处理
+	data := patchOFD(t, "testdata/999.ofd", "Doc_0/Pages/Page_0/Content.xml", "</ofd:Area>", "</ofd:Ar>")
+	assert.ErrorContains(t, converter.PDF(data, io.Discard, converter.Strict()), "Doc_0/Pages/Page_0/Content.xml")
+	report = converter.Report{}
+	assert.Nil(t, converter.PDF(data, io.Discard, converter.Diagnostics(&report)))
+	assert.Len(t, report.Pages, 5)
+	assert.Len(t, report.Warnings, 1)
+}
+
+func TestRender_Errors(t *testing.T) {
+	// 格式错误的部件返回带路径及行号的 XMLError
+	data := patchOFD(t, "testdata/999.ofd", "Doc_0/Pages/Page_0/Content.xml", "</ofd:Area>", "</ofd:Ar>")
+	err := converter.PDF(data, io.Discard, converter.Strict())
+	var xe *converter.XMLError
+	assert.ErrorAs(t, err, &xe)
+	assert.Equal(t, "Doc_0/Pages/Page_0/Content.xml", xe.Path)
+	assert.Equal(t, 5, xe.Line)
+	var pe *converter.PartError
+	assert.ErrorAs(t, err, &pe)
+	assert.Equal(t, xe.Path, pe.Path)
+
+	// 缺失的部件
+	err = converter.PDF("testdata/ano.ofd", io.Discard, converter.Strict())
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.ErrorAs(t, err, &pe)
+	assert.Equal(t, "Doc_0/Pages/Page_3/Annotation.xml", pe.Path)
+
+	err = converter.Image("testdata/999.ofd", converter.Page(6),
+		converter.ImageWriter(func(int, image.Image) error { return nil }))
+	assert.ErrorIs(t, err, converter.ErrPageOutOfRange)
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("OFD.xml")
+	assert.Nil(t, err)
+	_, err = f.Write([]byte(`<ofd:OFD xmlns:ofd="http://www.ofdspec.org/2016" Version="1.0" DocType="OFD"></ofd:OFD>`))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	assert.ErrorIs(t, converter.PDF(buf.Bytes(), io.Discard), converter.ErrNoDocument)
+}
+
+func TestRender_Pages(t *testing.T) {
+	var pages []int
+	err := converter.Image("testdata/999.ofd", converter.DPI(10), converter.Pages(3, 1),
+		converter.ImageWriter(func(page int, img image.Image) error {
+			pages = append(pages, page)
+			return nil
+		}))
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3, 1}, pages)
+
+	var buf bytes.Buffer
+	err = converter.PDF("testdata/999.ofd", &buf, converter.Pages(2, 9))
+	assert.ErrorIs(t, err, converter.ErrPageOutOfRange)
+}
+
+func TestRender_Info(t *testing.T) {
+	info, err := converter.Info("testdata/999.ofd")
+	assert.Nil(t, err)
+	assert.Equal(t, "050001700111_12235358", info.DocID)
+	assert.Equal(t, "Huhuang Software", info.Author)
+	assert.Equal(t, "2020-08-17", info.CreationDate.Format(time.DateOnly))
+	assert.Contains(t, info.CustomData, converter.CustomData{Name: "发票号码", Value: "12235358"})
+	assert.Len(t, info.Pages, 5)
+	assert.Equal(t, converter.PageInfo{Page: 1, ID: 10, Width: 210, Height: 140}, info.Pages[0])
+	assert.Equal(t, 1, info.Signatures)
+	assert.Equal(t, 1, info.Attachments)
+}
+
+func TestRender_Text(t *testing.T) {
+	pages, err := converter.Text("testdata/999.ofd", converter.Page(1))
+	assert.Nil(t, err)
+	assert.Len(t, pages, 1)
+	lines := strings.Split(pages[0].Text, "\n")
+	assert.Equal(t, "重庆增值税电子普通发票 发票代码：050001700111", lines[0])
+	assert.Contains(t, lines, "合计 ¥10000.00 ¥600.00")
+
+	// 不含模板中的文字
+	pages, err = converter.Text("testdata/999.ofd", converter.Page(1), converter.HideTemplates())
+	assert.Nil(t, err)
+	assert.NotContains(t, pages[0].Text, "发票代码")
+	assert.Contains(t, pages[0].Text, "050001700111")
+
+	// TextCode 中的换行按空格处理
+	pages, err = converter.Text("testdata/helloworld.ofd")
+	assert.Nil(t, err)
+	assert.Equal(t, "你好呀，OFD Reader&Writer！", pages[0].Text)
+}
+
+func TestRender_Verify(t *testing.T) {
+	results, err := converter.Verify("testdata/999.ofd")
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.True(t, results[0].DigestsMatch)
+	assert.Equal(t, []uint64{10}, results[0].Pages)
+	assert.Len(t, results[0].References, 20)
+
+	// 签名后修改页面内容
+	data := patchOFD(t, "testdata/999.ofd", "Doc_0/Pages/Page_0/Content.xml", "050001700111", "050001700112")
+	results, err = converter.Verify(data)
+	assert.Nil(t, err)
+	assert.False(t, results[0].DigestsMatch)
+	for _, ref := range results[0].References {
+		assert.Equal(t, ref.File != "/Doc_0/Pages/Page_0/Content.xml", ref.Valid, ref.File)
+	}
+
+	// 没有引用文件时不视为一致
+	r, err := zip.OpenReader("testdata/999.ofd")
+	assert.Nil(t, err)
+	defer r.Close()
+	sign, err := fs.ReadFile(r, "Doc_0/Signs/Sign_0/Signature.xml")
+	assert.Nil(t, err)
+	data = patchOFD(t, "testdata/999.ofd", "Doc_0/Signs/Sign_0/Signature.xml", string(sign),
+		regexp.MustCompile(`<ofd:Reference .*?</ofd:Reference>`).ReplaceAllString(string(sign), ""))
+	results, err = converter.Verify(data)
+	assert.Nil(t, err)
+	assert.Empty(t, results[0].References)
+	assert.False(t, results[0].DigestsMatch)
+
+	results, err = converter.Verify("testdata/helloworld.ofd")
+	assert.Nil(t, err)
+	assert.Empty(t, results)
+}
+
+func TestRender_Attachments(t *testing.T) {
+	attachments, err := converter.Attachments("testdata/999.ofd")
+	assert.Nil(t, err)
+	assert.Len(t, attachments, 1)
+	a := attachments[0]
+	assert.Equal(t, "original_invoice", a.Name)
+	assert.Equal(t, "xml", a.Format)
+	assert.Equal(t, "Doc_0/Attachs/original_invoice.xml", a.Path)
+	assert.Equal(t, len(a.Data), a.Size)
+	assert.True(t, bytes.HasPrefix(bytes.TrimPrefix(a.Data, []byte("\xef\xbb\xbf")), []byte("<?xml")))
+}